@@ -8,17 +8,22 @@ package cli
 import (
 	"fmt"
 	"os"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 // ProgressBar represents a progress bar
+// It counts files, and bytes as well once SetTotalBytes has been called
 type ProgressBar struct {
 	total    int64
 	current  int64
+	totalBytes   int64
+	currentBytes int64
 	width    int
 	startTime time.Time
 	lastUpdate time.Time
+	mu         sync.Mutex
 }
 
 // NewProgressBar creates a new progress bar
@@ -38,22 +43,40 @@ func (p *ProgressBar) Update(delta int64) {
 	p.Refresh()
 }
 
+// SetTotalBytes switches the bar to byte-level progress
+func (p *ProgressBar) SetTotalBytes(total int64) {
+	atomic.StoreInt64(&p.totalBytes, total)
+}
+
+// AddBytes records transferred bytes; a negative delta takes back bytes of a failed attempt
+func (p *ProgressBar) AddBytes(delta int64) {
+	atomic.AddInt64(&p.currentBytes, delta)
+	p.Refresh()
+}
+
 // Refresh updates the progress bar display
 func (p *ProgressBar) Refresh() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	now := time.Now()
+	current := atomic.LoadInt64(&p.current)
+	total := p.total
 	// Throttle updates to avoid too many screen refreshes (max 10 times per second)
-	if now.Sub(p.lastUpdate) < 100*time.Millisecond && p.current < p.total {
+	if now.Sub(p.lastUpdate) < 100*time.Millisecond && current < total {
 		return
 	}
 	p.lastUpdate = now
 
-	current := atomic.LoadInt64(&p.current)
-	total := p.total
-
 	if total == 0 {
 		return
 	}
 
+	if totalBytes := atomic.LoadInt64(&p.totalBytes); totalBytes > 0 {
+		p.refreshBytes(now, current, total, atomic.LoadInt64(&p.currentBytes), totalBytes)
+		return
+	}
+
 	percentage := float64(current) * 100.0 / float64(total)
 	width := p.width
 	filled := int(float64(width) * percentage / 100.0)
@@ -92,6 +115,46 @@ func (p *ProgressBar) Refresh() {
 		string(bar), percentage, current, total, elapsedStr, remainingStr, speed)
 }
 
+// refreshBytes draws the bar from transferred bytes, with file counts alongside
+func (p *ProgressBar) refreshBytes(now time.Time, current, total, currentBytes, totalBytes int64) {
+	if currentBytes < 0 {
+		currentBytes = 0
+	}
+	percentage := float64(currentBytes) * 100.0 / float64(totalBytes)
+	if percentage > 100 {
+		percentage = 100
+	}
+	filled := int(float64(p.width) * percentage / 100.0)
+
+	elapsed := now.Sub(p.startTime)
+	var remaining time.Duration
+	var speed float64
+	if currentBytes > 0 {
+		speed = float64(currentBytes) / elapsed.Seconds()
+		if speed > 0 && totalBytes > currentBytes {
+			remaining = time.Duration(float64(totalBytes-currentBytes)/speed) * time.Second
+		}
+	}
+
+	bar := make([]byte, p.width)
+	for i := range bar {
+		if i < filled {
+			bar[i] = '='
+		} else {
+			bar[i] = ' '
+		}
+	}
+
+	remainingStr := formatDuration(remaining)
+	if remaining <= 0 {
+		remainingStr = "--:--"
+	}
+
+	fmt.Fprintf(os.Stderr, "\r[%s] %.1f%% %s/%s (%d/%d files) | Elapsed: %s | Remaining: %s | Speed: %s/s\033[K",
+		string(bar), percentage, formatBytes(currentBytes), formatBytes(totalBytes), current, total,
+		formatDuration(elapsed), remainingStr, formatBytes(int64(speed)))
+}
+
 // Finish completes the progress bar and prints final summary
 func (p *ProgressBar) Finish() {
 	current := atomic.LoadInt64(&p.current)
@@ -100,9 +163,27 @@ func (p *ProgressBar) Finish() {
 
 	// Clear progress bar line and print final summary
 	fmt.Fprintf(os.Stderr, "\r\033[K") // Clear line
+	if totalBytes := atomic.LoadInt64(&p.totalBytes); totalBytes > 0 {
+		fmt.Fprintf(os.Stderr, "Completed: %d/%d files (%s) in %s\n", current, total, formatBytes(atomic.LoadInt64(&p.currentBytes)), formatDuration(elapsed))
+		return
+	}
 	fmt.Fprintf(os.Stderr, "Completed: %d/%d files in %s\n", current, total, formatDuration(elapsed))
 }
 
+// formatBytes formats a byte count with a binary unit, e.g. 1.5 MB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 // formatDuration formats a duration as MM:SS or HH:MM:SS
 func formatDuration(d time.Duration) string {
 	totalSeconds := int(d.Seconds())
@@ -31,6 +31,7 @@ var (
 	pullProject    string
 	pullApp        string
 	pullVersion    string
+	pullChannel    string
 	pullPath       string
 	pullConfig     string
 	pullServerURL  string
@@ -45,6 +46,7 @@ func init() {
 	pullCmd.Flags().StringVar(&pullProject, "project", "", "Project name (required)")
 	pullCmd.Flags().StringVar(&pullApp, "app", "", "App name (required)")
 	pullCmd.Flags().StringVar(&pullVersion, "version", "latest", "Version hash (use 'latest' for latest published version)")
+	pullCmd.Flags().StringVar(&pullChannel, "channel", "", "Release channel to pull the version of, e.g. staging (default: the published version)")
 	pullCmd.Flags().StringVar(&pullPath, "path", ".", "Path to local directory")
 	pullCmd.Flags().StringVar(&pullConfig, "config", ".kkartifact.yml", "Config file path")
 	pullCmd.Flags().StringVar(&pullServerURL, "server-url", "", "Server URL (overrides config file)")
@@ -62,6 +64,9 @@ func runPull(cmd *cobra.Command, args []string) error {
 	if pullProject == "" || pullApp == "" {
 		return fmt.Errorf("project and app are required")
 	}
+	if pullChannel != "" && pullVersion != "" && pullVersion != "latest" {
+		return fmt.Errorf("--channel and --version cannot be used together")
+	}
 
 	// Parse ignore patterns from command-line (support comma-separated values)
 	ignorePatterns := make([]string, 0)
@@ -131,16 +136,24 @@ func runPull(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create API client: %w", err)
 	}
 
-	// Handle "latest" version
+	// Handle "latest" version, of the published version or of a channel
 	actualVersion := pullVersion
 	if pullVersion == "" || pullVersion == "latest" {
-		fmt.Printf("Fetching latest published version for %s/%s...\n", pullProject, pullApp)
-		latestResp, err := apiClient.GetLatestVersion(pullProject, pullApp)
+		if pullChannel != "" {
+			fmt.Printf("Fetching version of channel %s for %s/%s...\n", pullChannel, pullProject, pullApp)
+		} else {
+			fmt.Printf("Fetching latest published version for %s/%s...\n", pullProject, pullApp)
+		}
+		latestResp, err := apiClient.GetLatestVersion(pullProject, pullApp, pullChannel)
 		if err != nil {
 			return fmt.Errorf("failed to get latest version: %w", err)
 		}
 		actualVersion = latestResp.Version
-		fmt.Printf("Latest published version: %s\n", actualVersion)
+		if pullChannel != "" {
+			fmt.Printf("Channel %s version: %s\n", pullChannel, actualVersion)
+		} else {
+			fmt.Printf("Latest published version: %s\n", actualVersion)
+		}
 	}
 
 	fmt.Printf("Pulling artifacts from %s/%s:%s to %s\n", pullProject, pullApp, actualVersion, absPath)
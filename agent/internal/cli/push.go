@@ -6,6 +6,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -27,6 +28,9 @@ var pushCmd = &cobra.Command{
 	RunE:         runPush,
 }
 
+// maxFinishAttempts is how often finish is tried, uploading rejected files again in between
+const maxFinishAttempts = 3
+
 var (
 	pushProject    string
 	pushApp        string
@@ -37,6 +41,8 @@ var (
 	pushToken      string
 	pushConcurrency int
 	pushIgnore     []string
+	pushChunkSize  string
+	pushForce      bool
 )
 
 func init() {
@@ -50,6 +56,8 @@ func init() {
 	pushCmd.Flags().StringVar(&pushServerURL, "server-url", "", "Server URL (overrides config file)")
 	pushCmd.Flags().StringVar(&pushToken, "token", "", "Authentication token (overrides config file)")
 	pushCmd.Flags().IntVar(&pushConcurrency, "concurrency", 0, "Number of concurrent uploads (overrides config file, 0 = use config)")
+	pushCmd.Flags().StringVar(&pushChunkSize, "chunk-size", "", "Upload files larger than this in resumable chunks, e.g. 8MB (overrides config file)")
+	pushCmd.Flags().BoolVar(&pushForce, "force", false, "Overwrite the version even if it is pinned or on a channel")
 	pushCmd.Flags().StringArrayVar(&pushIgnore, "ignore", []string{}, "Ignore patterns (can be specified multiple times or comma-separated, merges with config file)")
 	
 	pushCmd.MarkFlagRequired("project")
@@ -83,6 +91,7 @@ func runPush(cmd *cobra.Command, args []string) error {
 		Token:        pushToken,
 		Concurrency: pushConcurrency,
 		Ignore:      ignorePatterns,
+		ChunkSize:   pushChunkSize,
 	}
 	if len(ignorePatterns) == 0 {
 		overrides.Ignore = nil // Don't override if no ignore patterns provided
@@ -115,6 +124,11 @@ func runPush(cmd *cobra.Command, args []string) error {
 		pushApp = cfg.App
 	}
 
+	chunkSize, err := cfg.ChunkSizeBytes()
+	if err != nil {
+		return err
+	}
+
 	// Resolve absolute path
 	absPath, err := filepath.Abs(pushPath)
 	if err != nil {
@@ -142,19 +156,102 @@ func runPush(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create API client: %w", err)
 	}
 
-	// Initialize upload
+	// Initialize upload with the full manifest so the server can tell us what it already has
 	fmt.Println("Initializing upload...")
-	uploadResp, err := apiClient.InitUpload(pushProject, pushApp, pushVersion, len(m.Files))
+	initFiles := make([]client.UploadFileEntry, len(m.Files))
+	for i, file := range m.Files {
+		initFiles[i] = client.UploadFileEntry{
+			Path:   file.Path,
+			SHA256: file.SHA256,
+			Size:   file.Size,
+		}
+	}
+	uploadResp, err := apiClient.InitUpload(pushProject, pushApp, pushVersion, initFiles)
 	if err != nil {
 		return fmt.Errorf("failed to initialize upload: %w", err)
 	}
-	fmt.Printf("Upload ID: %s\n", uploadResp.UploadID)
+	if uploadResp.Resumed {
+		fmt.Printf("Resuming upload: %s\n", uploadResp.UploadID)
+	} else {
+		fmt.Printf("Upload ID: %s\n", uploadResp.UploadID)
+	}
+
+	// Upload only the files the server is missing
+	// Older servers do not report missing files, so everything is uploaded
+	filesToUpload := m.Files
+	if uploadResp.Missing != nil {
+		missingPaths := make(map[string]bool, len(uploadResp.Missing))
+		for _, file := range uploadResp.Missing {
+			missingPaths[file.Path] = true
+		}
+		filesToUpload = make([]manifest.ManifestFile, 0, len(uploadResp.Missing))
+		for _, file := range m.Files {
+			if missingPaths[file.Path] {
+				filesToUpload = append(filesToUpload, file)
+			}
+		}
+		fmt.Printf("Server already has %d of %d files\n", len(m.Files)-len(filesToUpload), len(m.Files))
+	}
 
+	// The session is kept if an upload fails so running the same push again
+	// resumes it; the server discards it once it expires, and the existing
+	// version is untouched
+	if err := uploadFiles(apiClient, uploadResp.UploadID, absPath, filesToUpload, cfg.Concurrency, chunkSize); err != nil {
+		fmt.Fprintf(os.Stderr, "Upload interrupted; run the same push again to resume upload %s\n", uploadResp.UploadID)
+		return err
+	}
+
+	// Finish upload
+	fmt.Println("Finalizing upload...")
+	finishReq := map[string]interface{}{
+		"upload_id": uploadResp.UploadID,
+		"project":   pushProject,
+		"app":       pushApp,
+		"version":   pushVersion,
+		"manifest":  m,
+		"force":     pushForce,
+	}
+
+	// Files the server rejects (e.g. garbage collected during the upload) are
+	// uploaded again and the finish retried; the session stays open meanwhile
+	for attempt := 1; ; attempt++ {
+		err := apiClient.FinishUpload(finishReq)
+		if err == nil {
+			break
+		}
+		var mismatch *client.FinishUploadError
+		if errors.As(err, &mismatch) && attempt < maxFinishAttempts {
+			fmt.Fprintf(os.Stderr, "%v\nUploading %d files again...\n", err, len(mismatch.BadFiles))
+			if err = uploadFiles(apiClient, uploadResp.UploadID, absPath, badManifestFiles(m.Files, mismatch.BadFiles), cfg.Concurrency, chunkSize); err == nil {
+				continue
+			}
+		}
+		if abortErr := apiClient.AbortUpload(uploadResp.UploadID, pushProject, pushApp, pushVersion); abortErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to abort upload: %v\n", abortErr)
+		}
+		return fmt.Errorf("failed to finish upload: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	fmt.Printf("Successfully pushed %s/%s:%s\n", pushProject, pushApp, pushVersion)
+	fmt.Printf("Total time: %v\n", duration.Round(time.Second))
+	return nil
+}
+
+// uploadFiles uploads files of the manifest to an upload session concurrently,
+// showing a progress bar
+func uploadFiles(apiClient *client.Client, uploadID, absPath string, files []manifest.ManifestFile, concurrency int, chunkSize int64) error {
 	// Upload files concurrently
-	fmt.Printf("Uploading %d files with concurrency: %d\n", len(m.Files), cfg.Concurrency)
+	fmt.Printf("Uploading %d files with concurrency: %d\n", len(files), concurrency)
 	
-	// Create progress bar
-	progressBar := NewProgressBar(len(m.Files))
+	// Create progress bar, tracking bytes as they are sent
+	progressBar := NewProgressBar(len(files))
+	var totalBytes int64
+	for _, file := range files {
+		totalBytes += file.Size
+	}
+	progressBar.SetTotalBytes(totalBytes)
+	apiClient.SetProgressFunc(progressBar.AddBytes)
 	
 	type uploadTask struct {
 		index    int
@@ -162,11 +259,11 @@ func runPush(cmd *cobra.Command, args []string) error {
 		localPath string
 	}
 	
-	tasks := make(chan uploadTask, len(m.Files))
-	errors := make(chan error, len(m.Files))
+	tasks := make(chan uploadTask, len(files))
+	errCh := make(chan error, len(files))
 	
 	// Populate tasks
-	for i, file := range m.Files {
+	for i, file := range files {
 		localPath := filepath.Join(absPath, file.Path)
 		tasks <- uploadTask{
 			index:     i,
@@ -178,17 +275,21 @@ func runPush(cmd *cobra.Command, args []string) error {
 	
 	// Start worker goroutines
 	var wg sync.WaitGroup
-	for i := 0; i < cfg.Concurrency; i++ {
+	for i := 0; i < concurrency; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for task := range tasks {
-				// Calculate local file hash to verify it matches manifest
-				// Note: We always upload since server handles overwrite, but we could skip if hash matches
-				// For now, we upload all files as the server already handles overwrite in handleInitUpload
-				
-				if err := apiClient.UploadFile(pushProject, pushApp, pushVersion, task.file.Path, task.localPath); err != nil {
-					errors <- fmt.Errorf("failed to upload file %s: %w", task.file.Path, err)
+				var err error
+				if task.file.Size > chunkSize {
+					// Large files go in chunks so an interruption only resends what is missing
+					entry := client.UploadFileEntry{Path: task.file.Path, SHA256: task.file.SHA256, Size: task.file.Size}
+					err = apiClient.UploadFileChunked(uploadID, pushProject, pushApp, pushVersion, entry, task.localPath, chunkSize)
+				} else {
+					err = apiClient.UploadFile(uploadID, pushProject, pushApp, pushVersion, task.file.Path, task.localPath)
+				}
+				if err != nil {
+					errCh <- fmt.Errorf("failed to upload file %s: %w", task.file.Path, err)
 					return
 				}
 				// Update progress bar
@@ -203,30 +304,28 @@ func runPush(cmd *cobra.Command, args []string) error {
 	// Finish progress bar
 	progressBar.Finish()
 	
-	close(errors)
+	close(errCh)
 	
 	// Check for errors
-	for err := range errors {
+	for err := range errCh {
 		if err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	// Finish upload
-	fmt.Println("Finalizing upload...")
-	finishReq := map[string]interface{}{
-		"project":  pushProject,
-		"app":      pushApp,
-		"version":  pushVersion,
-		"manifest": m,
+// badManifestFiles returns the manifest files the server rejected at finish
+func badManifestFiles(files []manifest.ManifestFile, badFiles []client.BadFile) []manifest.ManifestFile {
+	bad := make(map[string]bool, len(badFiles))
+	for _, file := range badFiles {
+		bad[file.Path] = true
 	}
-
-	if err := apiClient.FinishUpload(finishReq); err != nil {
-		return fmt.Errorf("failed to finish upload: %w", err)
+	var selected []manifest.ManifestFile
+	for _, file := range files {
+		if bad[file.Path] {
+			selected = append(selected, file)
+		}
 	}
-
-	duration := time.Since(startTime)
-	fmt.Printf("Successfully pushed %s/%s:%s\n", pushProject, pushApp, pushVersion)
-	fmt.Printf("Total time: %v\n", duration.Round(time.Second))
-	return nil
+	return selected
 }
@@ -0,0 +1,92 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package cli
+
+import (
+	"fmt"
+
+	"github.com/kk/kkartifact-agent/internal/client"
+	"github.com/kk/kkartifact-agent/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:          "rollback [flags]",
+	Short:        "Roll back a release channel to an earlier version",
+	Long:         "Move a release channel, or the published version, back to the version it pointed at before (or further back with --steps)",
+	SilenceUsage: true, // Don't show usage on errors
+	RunE:         runRollback,
+}
+
+var (
+	rollbackProject   string
+	rollbackApp       string
+	rollbackChannel   string
+	rollbackSteps     int
+	rollbackConfig    string
+	rollbackServerURL string
+	rollbackToken     string
+)
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+
+	rollbackCmd.Flags().StringVar(&rollbackProject, "project", "", "Project name (required)")
+	rollbackCmd.Flags().StringVar(&rollbackApp, "app", "", "App name (required)")
+	rollbackCmd.Flags().StringVar(&rollbackChannel, "channel", "", "Release channel to roll back, e.g. prod (default: the published version)")
+	rollbackCmd.Flags().IntVar(&rollbackSteps, "steps", 1, "Number of versions to go back (1 = the previous version)")
+	rollbackCmd.Flags().StringVar(&rollbackConfig, "config", ".kkartifact.yml", "Config file path")
+	rollbackCmd.Flags().StringVar(&rollbackServerURL, "server-url", "", "Server URL (overrides config file)")
+	rollbackCmd.Flags().StringVar(&rollbackToken, "token", "", "Authentication token (overrides config file)")
+
+	rollbackCmd.MarkFlagRequired("project")
+	rollbackCmd.MarkFlagRequired("app")
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	if rollbackSteps < 1 {
+		return fmt.Errorf("--steps must be at least 1")
+	}
+
+	cfg, err := config.Load(rollbackConfig, &config.Overrides{
+		ServerURL: rollbackServerURL,
+		Token:     rollbackToken,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Validate token is set
+	if cfg.Token == "" {
+		return fmt.Errorf("token is required but not found in config. Please check:\n  - Global config: /etc/kkArtifact/config.yml\n  - Local config: %s\n  - Or use --token flag", rollbackConfig)
+	}
+	if err := config.ValidateTokenFormat(cfg.Token); err != nil {
+		return fmt.Errorf("token validation failed: %w\nToken preview: %s\nConfig file: %s", err, config.MaskToken(cfg.Token), rollbackConfig)
+	}
+
+	apiClient, err := client.New(cfg.ServerURL, cfg.Token)
+	if err != nil {
+		return fmt.Errorf("failed to create API client: %w", err)
+	}
+
+	channel := rollbackChannel
+	if channel == "" {
+		channel = "published"
+	}
+	fmt.Printf("Rolling back channel %s of %s/%s by %d version(s)...\n", channel, rollbackProject, rollbackApp, rollbackSteps)
+
+	result, err := apiClient.Rollback(rollbackProject, rollbackApp, rollbackChannel, rollbackSteps)
+	if err != nil {
+		return err
+	}
+
+	if result.PreviousVersion != "" {
+		fmt.Printf("Channel %s of %s/%s rolled back from %s to %s\n", result.Channel, result.Project, result.App, result.PreviousVersion, result.Version)
+	} else {
+		fmt.Printf("Channel %s of %s/%s restored to %s\n", result.Channel, result.Project, result.App, result.Version)
+	}
+	return nil
+}
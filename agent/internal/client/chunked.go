@@ -0,0 +1,237 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kk/kkartifact-agent/internal/config"
+	"github.com/kk/kkartifact-agent/internal/util"
+)
+
+// chunkRetries is how many times a failed chunk is sent again before giving up
+const chunkRetries = 3
+
+// ChunkedUpload describes a chunked file upload and the chunks the server has received
+type ChunkedUpload struct {
+	ID             string `json:"id"`
+	UploadID       string `json:"upload_id"`
+	Path           string `json:"path"`
+	SHA256         string `json:"sha256"`
+	Size           int64  `json:"size"`
+	ChunkSize      int64  `json:"chunk_size"`
+	ChunkCount     int64  `json:"chunk_count"`
+	Status         string `json:"status"`
+	Deduplicated   bool   `json:"deduplicated"`
+	ReceivedChunks []int  `json:"received_chunks"`
+	ReceivedBytes  int64  `json:"received_bytes"`
+}
+
+// UploadFileChunked uploads a large file in chunks
+// Chunks the server already received (from an interrupted earlier attempt in the
+// same upload session) are skipped, and failed chunks are retried, so only the
+// missing bytes are sent. Each chunk is read straight from the file.
+func (c *Client) UploadFileChunked(uploadID, project, app, version string, file UploadFileEntry, localPath string, chunkSize int64) error {
+	upload, err := c.createChunkedUpload(uploadID, project, app, version, file, chunkSize)
+	if err != nil {
+		return err
+	}
+	if upload.Deduplicated || upload.Status == "completed" {
+		c.reportProgress(file.Size)
+		return nil
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Chunks received in an earlier attempt count as done
+	reported := upload.ReceivedBytes
+	c.reportProgress(reported)
+
+	for attempt := 0; ; attempt++ {
+		received := make(map[int]bool, len(upload.ReceivedChunks))
+		for _, index := range upload.ReceivedChunks {
+			received[index] = true
+		}
+
+		var lastErr error
+		for index := 0; int64(index) < upload.ChunkCount; index++ {
+			if received[index] {
+				continue
+			}
+			sent, err := c.putChunk(project, app, version, upload, f, index)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			reported += sent
+		}
+
+		if lastErr == nil {
+			lastErr = c.completeChunkedUpload(project, app, version, upload.ID)
+			if lastErr == nil {
+				return nil
+			}
+		}
+
+		// Restart the count from what the server reports below
+		c.reportProgress(-reported)
+		reported = 0
+
+		if attempt >= chunkRetries {
+			return lastErr
+		}
+		if util.IsDebugMode() {
+			fmt.Fprintf(os.Stderr, "Retrying chunked upload of %s: %v\n", file.Path, lastErr)
+		}
+		time.Sleep(time.Duration(attempt+1) * time.Second)
+
+		// Ask the server what it has, then send the rest
+		if upload, err = c.getChunkedUpload(project, app, version, upload.ID); err != nil {
+			return err
+		}
+		switch upload.Status {
+		case "completed":
+			c.reportProgress(file.Size)
+			return nil
+		case "aborted":
+			// The assembled file did not match its hash; start the file over
+			if upload, err = c.createChunkedUpload(uploadID, project, app, version, file, chunkSize); err != nil {
+				return err
+			}
+		}
+		reported = upload.ReceivedBytes
+		c.reportProgress(reported)
+	}
+}
+
+// createChunkedUpload starts or resumes the chunked upload of a file
+func (c *Client) createChunkedUpload(uploadID, project, app, version string, file UploadFileEntry, chunkSize int64) (*ChunkedUpload, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"upload_id":  uploadID,
+		"path":       file.Path,
+		"sha256":     file.SHA256,
+		"size":       file.Size,
+		"chunk_size": chunkSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/api/v1/upload/chunked/%s/%s/%s", c.serverURL, project, app, version)
+	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	var upload ChunkedUpload
+	if err := c.doChunkedRequest(httpReq, "start chunked upload", &upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// getChunkedUpload returns the chunks the server has received for an upload
+func (c *Client) getChunkedUpload(project, app, version, id string) (*ChunkedUpload, error) {
+	url := fmt.Sprintf("%s/api/v1/upload/chunked/%s/%s/%s/%s", c.serverURL, project, app, version, id)
+	httpReq, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var upload ChunkedUpload
+	if err := c.doChunkedRequest(httpReq, "get chunked upload", &upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// putChunk sends one chunk, read directly from its offset in the file
+// Returns the number of bytes sent
+func (c *Client) putChunk(project, app, version string, upload *ChunkedUpload, f *os.File, index int) (int64, error) {
+	offset := int64(index) * upload.ChunkSize
+	length := upload.ChunkSize
+	if remaining := upload.Size - offset; remaining < length {
+		length = remaining
+	}
+
+	body := &progressReader{reader: io.NewSectionReader(f, offset, length), client: c}
+	url := fmt.Sprintf("%s/api/v1/upload/chunked/%s/%s/%s/%s/chunks/%d", c.serverURL, project, app, version, upload.ID, index)
+	httpReq, err := http.NewRequest("PUT", url, body)
+	if err != nil {
+		return 0, err
+	}
+	httpReq.ContentLength = length
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+	if length > 0 {
+		httpReq.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, upload.Size))
+	}
+
+	if err := c.doChunkedRequest(httpReq, fmt.Sprintf("upload chunk %d", index), nil); err != nil {
+		body.rollback()
+		return 0, err
+	}
+	return length, nil
+}
+
+// completeChunkedUpload asks the server to assemble and verify the file
+func (c *Client) completeChunkedUpload(project, app, version, id string) error {
+	url := fmt.Sprintf("%s/api/v1/upload/chunked/%s/%s/%s/%s/complete", c.serverURL, project, app, version, id)
+	httpReq, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+	return c.doChunkedRequest(httpReq, "complete chunked upload", nil)
+}
+
+// doChunkedRequest sends an authenticated chunked upload request and decodes the response into out
+func (c *Client) doChunkedRequest(httpReq *http.Request, action string, out interface{}) error {
+	if c.token == "" {
+		return fmt.Errorf("token is empty, cannot %s", action)
+	}
+	// Set Authorization header (token is already cleaned in New(), so we can safely add "Bearer ")
+	httpReq.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+
+		// Optional verbose debug dump (disabled by default)
+		if util.IsDebugMode() {
+			fmt.Fprintf(os.Stderr, "\n=== Chunked Upload Request Details ===\n")
+			fmt.Fprintf(os.Stderr, "URL: %s\n", httpReq.URL)
+			fmt.Fprintf(os.Stderr, "Method: %s\n", httpReq.Method)
+			fmt.Fprintf(os.Stderr, "Response Status: %d %s\n", resp.StatusCode, resp.Status)
+			fmt.Fprintf(os.Stderr, "Response Body: %s\n", string(body))
+			fmt.Fprintf(os.Stderr, "======================================\n\n")
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized {
+			return fmt.Errorf("%s failed with status %d (unauthorized)\nToken preview: %s\nToken length: %d\nPlease verify:\n  - Token is correct in config file (global: /etc/kkArtifact/config.yml or local: .kkartifact.yml)\n  - Token exists and is valid in the server\n  - Token has required permissions (push)\nServer response: %s", action, resp.StatusCode, config.MaskToken(c.token), len(c.token), string(body))
+		}
+		return fmt.Errorf("%s failed with status %d: %s", action, resp.StatusCode, string(body))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
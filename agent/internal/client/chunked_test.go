@@ -0,0 +1,149 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeChunkServer serves the chunked upload API and stores the chunks it receives
+// failChunk is rejected once, to exercise the retry path; -1 disables it
+type fakeChunkServer struct {
+	t         *testing.T
+	mu        sync.Mutex
+	upload    ChunkedUpload
+	chunks    map[int]string
+	failChunk int
+	completed bool
+}
+
+func (s *fakeChunkServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	base := "/api/v1/upload/chunked/proj/app/v1"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == base:
+		s.writeUpload(w)
+	case r.Method == http.MethodGet && r.URL.Path == base+"/"+s.upload.ID:
+		s.writeUpload(w)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, base+"/"+s.upload.ID+"/chunks/"):
+		var index int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, base+"/"+s.upload.ID+"/chunks/"), "%d", &index)
+		data, _ := io.ReadAll(r.Body)
+		if index == s.failChunk {
+			s.failChunk = -1
+			http.Error(w, `{"error":"try again"}`, http.StatusServiceUnavailable)
+			return
+		}
+		if want := fmt.Sprintf("bytes %d-%d/%d", int64(index)*s.upload.ChunkSize, int64(index)*s.upload.ChunkSize+int64(len(data))-1, s.upload.Size); r.Header.Get("Content-Range") != want {
+			s.t.Errorf("Expected Content-Range %q, got %q", want, r.Header.Get("Content-Range"))
+		}
+		s.chunks[index] = string(data)
+		w.Write([]byte(`{}`))
+	case r.Method == http.MethodPost && r.URL.Path == base+"/"+s.upload.ID+"/complete":
+		if int64(len(s.chunks)) != s.upload.ChunkCount {
+			http.Error(w, `{"error":"missing chunks"}`, http.StatusConflict)
+			return
+		}
+		s.completed = true
+		w.Write([]byte(`{}`))
+	default:
+		s.t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		http.NotFound(w, r)
+	}
+}
+
+func (s *fakeChunkServer) writeUpload(w http.ResponseWriter) {
+	upload := s.upload
+	upload.ReceivedChunks = nil
+	upload.ReceivedBytes = 0
+	for index, data := range s.chunks {
+		upload.ReceivedChunks = append(upload.ReceivedChunks, index)
+		upload.ReceivedBytes += int64(len(data))
+	}
+	json.NewEncoder(w).Encode(upload)
+}
+
+func (s *fakeChunkServer) assembled() string {
+	var b strings.Builder
+	for index := 0; int64(index) < s.upload.ChunkCount; index++ {
+		b.WriteString(s.chunks[index])
+	}
+	return b.String()
+}
+
+func TestUploadFileChunked(t *testing.T) {
+	tests := []struct {
+		name      string
+		failChunk int
+	}{
+		{"all chunks accepted", -1},
+		{"failed chunk is retried", 1},
+	}
+
+	content := strings.Repeat("abcdefghij", 25)
+	const chunkSize = 64
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeChunkServer{
+				t: t,
+				upload: ChunkedUpload{
+					ID:         "chunk-1",
+					Size:       int64(len(content)),
+					ChunkSize:  chunkSize,
+					ChunkCount: (int64(len(content)) + chunkSize - 1) / chunkSize,
+					Status:     "active",
+				},
+				chunks:    make(map[int]string),
+				failChunk: tt.failChunk,
+			}
+			c := newTestClient(t, server.handle)
+
+			var progress int64
+			c.SetProgressFunc(func(delta int64) { atomic.AddInt64(&progress, delta) })
+
+			file := UploadFileEntry{Path: "bin/app", SHA256: "aa", Size: int64(len(content))}
+			if err := c.UploadFileChunked("up-1", "proj", "app", "v1", file, writeTempFile(t, content), chunkSize); err != nil {
+				t.Fatalf("UploadFileChunked() error = %v", err)
+			}
+			if !server.completed {
+				t.Error("Expected the upload to be completed")
+			}
+			if got := server.assembled(); got != content {
+				t.Errorf("Assembled content mismatch: got %d bytes, want %d", len(got), len(content))
+			}
+			if progress != int64(len(content)) {
+				t.Errorf("Expected %d bytes of progress, got %d", len(content), progress)
+			}
+		})
+	}
+}
+
+func TestUploadFileChunked_Deduplicated(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"chunk-1","deduplicated":true}`))
+	})
+
+	var progress int64
+	c.SetProgressFunc(func(delta int64) { atomic.AddInt64(&progress, delta) })
+
+	file := UploadFileEntry{Path: "bin/app", SHA256: "aa", Size: 1234}
+	if err := c.UploadFileChunked("up-1", "proj", "app", "v1", file, "/nonexistent", 64); err != nil {
+		t.Fatalf("UploadFileChunked() error = %v", err)
+	}
+	if progress != file.Size {
+		t.Errorf("Expected %d bytes of progress for a deduplicated file, got %d", file.Size, progress)
+	}
+}
@@ -27,6 +27,44 @@ type Client struct {
 	serverURL  string
 	token      string
 	httpClient *http.Client
+	progress   func(delta int64)
+}
+
+// SetProgressFunc sets a callback that receives the number of bytes sent by uploads
+// A failed request reports a negative delta for the bytes it had sent.
+// The callback is called from multiple goroutines.
+func (c *Client) SetProgressFunc(fn func(delta int64)) {
+	c.progress = fn
+}
+
+// reportProgress passes transferred bytes to the progress callback, if any
+func (c *Client) reportProgress(delta int64) {
+	if c.progress != nil && delta != 0 {
+		c.progress(delta)
+	}
+}
+
+// progressReader reports bytes as they are read from an upload body
+type progressReader struct {
+	reader io.Reader
+	client *Client
+	read   int64
+}
+
+// Read implements io.Reader
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		r.client.reportProgress(int64(n))
+	}
+	return n, err
+}
+
+// rollback takes back the bytes reported for a failed request
+func (r *progressReader) rollback() {
+	r.client.reportProgress(-r.read)
+	r.read = 0
 }
 
 // New creates a new API client with optimized HTTP transport for high concurrency
@@ -74,24 +112,42 @@ func New(serverURL, token string) (*Client, error) {
 
 // UploadInitRequest represents upload init request
 type UploadInitRequest struct {
-	Project   string `json:"project"`
-	App       string `json:"app"`
-	Version   string `json:"version"`
-	FileCount int    `json:"file_count"`
+	Project   string            `json:"project"`
+	App       string            `json:"app"`
+	Version   string            `json:"version"`
+	FileCount int               `json:"file_count"`
+	Manifest  []UploadFileEntry `json:"manifest"`
+	Resume    bool              `json:"resume"`
+}
+
+// UploadFileEntry describes a file in the upload manifest
+type UploadFileEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
 }
 
 // UploadInitResponse represents upload init response
+// Missing lists the files the server needs; it is nil when the server does not
+// support deduplication, in which case every file must be uploaded.
+// Resumed is true when an interrupted push of the same files is continued.
 type UploadInitResponse struct {
-	UploadID string `json:"upload_id"`
+	UploadID string            `json:"upload_id"`
+	Missing  []UploadFileEntry `json:"missing"`
+	Resumed  bool              `json:"resumed"`
 }
 
 // InitUpload initializes an upload session
-func (c *Client) InitUpload(project, app, version string, fileCount int) (*UploadInitResponse, error) {
+// The full file list is sent so the server can report which files it already has.
+// An unfinished session for the same files is resumed rather than started over.
+func (c *Client) InitUpload(project, app, version string, files []UploadFileEntry) (*UploadInitResponse, error) {
 	req := UploadInitRequest{
 		Project:   project,
 		App:       app,
 		Version:   version,
-		FileCount: fileCount,
+		FileCount: len(files),
+		Manifest:  files,
+		Resume:    true,
 	}
 
 	body, err := json.Marshal(req)
@@ -152,41 +208,52 @@ func (c *Client) InitUpload(project, app, version string, fileCount int) (*Uploa
 	return &uploadResp, nil
 }
 
-// UploadFile uploads a single file
-func (c *Client) UploadFile(project, app, hash, filePath, localPath string) error {
+// UploadFile uploads a single file into an upload session
+// The multipart body is streamed from the file: only the small form headers are
+// held in memory, so memory use does not depend on the file size.
+func (c *Client) UploadFile(uploadID, project, app, hash, filePath, localPath string) error {
 	file, err := os.Open(localPath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-
-	// Add path field
-	if err := writer.WriteField("path", filePath); err != nil {
+	info, err := file.Stat()
+	if err != nil {
 		return err
 	}
 
-	// Add file
-	part, err := writer.CreateFormFile("file", filepath.Base(localPath))
-	if err != nil {
+	var head bytes.Buffer
+	writer := multipart.NewWriter(&head)
+
+	// Add upload session and path fields
+	if err := writer.WriteField("upload_id", uploadID); err != nil {
+		return err
+	}
+	if err := writer.WriteField("path", filePath); err != nil {
 		return err
 	}
 
-	if _, err := io.Copy(part, file); err != nil {
+	// Add file part header; the content follows from the file itself
+	if _, err := writer.CreateFormFile("file", filepath.Base(localPath)); err != nil {
 		return err
 	}
+	prefix := append([]byte(nil), head.Bytes()...)
 
+	// Closing boundary
+	head.Reset()
 	if err := writer.Close(); err != nil {
 		return err
 	}
+	suffix := head.Bytes()
 
+	body := &progressReader{reader: file, client: c}
 	url := fmt.Sprintf("%s/api/v1/file/%s/%s/%s", c.serverURL, project, app, hash)
-	httpReq, err := http.NewRequest("POST", url, &buf)
+	httpReq, err := http.NewRequest("POST", url, io.MultiReader(bytes.NewReader(prefix), body, bytes.NewReader(suffix)))
 	if err != nil {
 		return err
 	}
+	httpReq.ContentLength = int64(len(prefix)) + info.Size() + int64(len(suffix))
 
 	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
 	// Ensure token is always set before making request
@@ -198,11 +265,13 @@ func (c *Client) UploadFile(project, app, hash, filePath, localPath string) erro
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
+		body.rollback()
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		body.rollback()
 		body, _ := io.ReadAll(resp.Body)
 
 		// Optional verbose debug dump (disabled by default)
@@ -290,12 +359,131 @@ func (c *Client) FinishUpload(req interface{}) error {
 		if resp.StatusCode == http.StatusUnauthorized {
 			return fmt.Errorf("finish upload failed with status %d (unauthorized)\nToken preview: %s\nToken length: %d\nPlease verify:\n  - Token is correct in config file (global: /etc/kkArtifact/config.yml or local: .kkartifact.yml)\n  - Token exists and is valid in the server\n  - Token has required permissions (push)\nServer response: %s", resp.StatusCode, config.MaskToken(c.token), len(c.token), string(body))
 		}
+		if resp.StatusCode == http.StatusUnprocessableEntity {
+			var mismatch FinishUploadError
+			if err := json.Unmarshal(body, &mismatch); err == nil && len(mismatch.BadFiles) > 0 {
+				return &mismatch
+			}
+		}
 		return fmt.Errorf("finish upload failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	return nil
 }
 
+// AbortUpload aborts an upload session so the server discards its staged files
+func (c *Client) AbortUpload(uploadID, project, app, version string) error {
+	body, err := json.Marshal(map[string]string{
+		"upload_id": uploadID,
+		"project":   project,
+		"app":       app,
+		"version":   version,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest("POST", c.serverURL+"/api/v1/upload/abort", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("abort upload failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// RollbackResponse represents the rollback response
+type RollbackResponse struct {
+	Project         string `json:"project"`
+	App             string `json:"app"`
+	Channel         string `json:"channel"`
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previous_version"`
+	Steps           int    `json:"steps"`
+}
+
+// Rollback moves a release channel, or the published version if channel is
+// empty, back by steps versions
+func (c *Client) Rollback(project, app, channel string, steps int) (*RollbackResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"project": project,
+		"app":     app,
+		"channel": channel,
+		"steps":   steps,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest("POST", c.serverURL+"/api/v1/rollback", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("rollback failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var rollbackResp RollbackResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rollbackResp); err != nil {
+		return nil, err
+	}
+	return &rollbackResp, nil
+}
+
+// BadFile is a manifest file the server rejected at finish
+type BadFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// FinishUploadError is returned by FinishUpload when files do not match the manifest
+// The upload session stays open, so the bad files can be uploaded again before
+// finishing once more.
+type FinishUploadError struct {
+	Message  string    `json:"message"`
+	BadFiles []BadFile `json:"bad_files"`
+}
+
+// Error lists the files the server rejected
+func (e *FinishUploadError) Error() string {
+	const maxListed = 20
+	var sb strings.Builder
+	sb.WriteString("finish upload rejected: ")
+	sb.WriteString(e.Message)
+	for i, file := range e.BadFiles {
+		if i == maxListed {
+			sb.WriteString(fmt.Sprintf("\n  ... and %d more", len(e.BadFiles)-maxListed))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("\n  - %s: %s", file.Path, file.Reason))
+	}
+	return sb.String()
+}
+
 // GetManifest retrieves a manifest
 func (c *Client) GetManifest(project, app, version string) (interface{}, error) {
 	// Ensure token is set before making request
@@ -343,17 +531,23 @@ func (c *Client) GetManifest(project, app, version string) (interface{}, error)
 type LatestVersionResponse struct {
 	Project string `json:"project"`
 	App     string `json:"app"`
+	Channel string `json:"channel"`
 	Version string `json:"version"`
 }
 
-// GetLatestVersion retrieves the latest published version for an app
-func (c *Client) GetLatestVersion(project, app string) (*LatestVersionResponse, error) {
+// GetLatestVersion retrieves the latest published version for an app,
+// or the version of a release channel if channel is not empty
+func (c *Client) GetLatestVersion(project, app, channel string) (*LatestVersionResponse, error) {
 	// Ensure token is set before making request
 	if c.token == "" {
 		return nil, fmt.Errorf("token is empty, cannot get latest version. Please check your config file (global: /etc/kkArtifact/config.yml or local: .kkartifact.yml)")
 	}
 
 	url := fmt.Sprintf("%s/api/v1/projects/%s/apps/%s/latest", c.serverURL, project, app)
+	if channel != "" {
+		// Channel names are restricted to URL-safe characters by the server
+		url += "?channel=" + channel
+	}
 
 	httpReq, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -0,0 +1,209 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testToken = "dGVzdC10b2tlbi1mb3ItY2xpZW50LXRlc3Rz"
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c, err := New(server.URL, testToken)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return c
+}
+
+func TestInitUpload_SendsManifest(t *testing.T) {
+	files := []UploadFileEntry{
+		{Path: "bin/app", SHA256: "aa", Size: 10},
+		{Path: "bin/tool", SHA256: "bb", Size: 20},
+	}
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/upload/init" {
+			t.Errorf("Expected path /api/v1/upload/init, got %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer "+testToken {
+			t.Errorf("Expected bearer token, got %q", got)
+		}
+
+		var req UploadInitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if req.Project != "proj" || req.App != "app" || req.Version != "v1" {
+			t.Errorf("Unexpected target %s/%s/%s", req.Project, req.App, req.Version)
+		}
+		if req.FileCount != 2 || len(req.Manifest) != 2 || req.Manifest[1] != files[1] {
+			t.Errorf("Expected manifest %v, got %v (count %d)", files, req.Manifest, req.FileCount)
+		}
+
+		json.NewEncoder(w).Encode(UploadInitResponse{UploadID: "up-1", Missing: files[1:]})
+	})
+
+	resp, err := c.InitUpload("proj", "app", "v1", files)
+	if err != nil {
+		t.Fatalf("InitUpload() error = %v", err)
+	}
+	if resp.UploadID != "up-1" {
+		t.Errorf("Expected upload ID up-1, got %s", resp.UploadID)
+	}
+	if len(resp.Missing) != 1 || resp.Missing[0].Path != "bin/tool" {
+		t.Errorf("Expected only bin/tool to be missing, got %v", resp.Missing)
+	}
+}
+
+func TestInitUpload_WithoutDeduplication(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"upload_id":"up-2"}`))
+	})
+
+	resp, err := c.InitUpload("proj", "app", "v1", []UploadFileEntry{{Path: "a", SHA256: "aa", Size: 1}})
+	if err != nil {
+		t.Fatalf("InitUpload() error = %v", err)
+	}
+	if resp.Missing != nil {
+		t.Errorf("Expected nil Missing from a server without deduplication, got %v", resp.Missing)
+	}
+}
+
+func TestInitUpload_ServerError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"boom"}`, http.StatusInternalServerError)
+	})
+
+	if _, err := c.InitUpload("proj", "app", "v1", nil); err == nil {
+		t.Error("Expected an error for a 500 response, got nil")
+	}
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "artifact.bin")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+	return path
+}
+
+func TestUploadFile_StreamsMultipartBody(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	localPath := writeTempFile(t, content)
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/file/proj/app/v1" {
+			t.Errorf("Expected path /api/v1/file/proj/app/v1, got %s", r.URL.Path)
+		}
+		if r.ContentLength <= int64(len(content)) {
+			t.Errorf("Expected a fixed content length above %d, got %d", len(content), r.ContentLength)
+		}
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("Failed to read body: %v", err)
+		}
+		if int64(len(raw)) != r.ContentLength {
+			t.Errorf("Expected body of %d bytes, got %d", r.ContentLength, len(raw))
+		}
+		r.Body = io.NopCloser(strings.NewReader(string(raw)))
+
+		if got := r.FormValue("upload_id"); got != "up-1" {
+			t.Errorf("Expected upload_id up-1, got %q", got)
+		}
+		if got := r.FormValue("path"); got != "bin/app" {
+			t.Errorf("Expected path bin/app, got %q", got)
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("Failed to read file part: %v", err)
+		}
+		defer file.Close()
+		if header.Filename != "artifact.bin" {
+			t.Errorf("Expected file name artifact.bin, got %s", header.Filename)
+		}
+		got, _ := io.ReadAll(file)
+		if string(got) != content {
+			t.Errorf("File content mismatch: got %d bytes, want %d", len(got), len(content))
+		}
+		w.Write([]byte(`{"message":"ok"}`))
+	})
+
+	var progress int64
+	c.SetProgressFunc(func(delta int64) { atomic.AddInt64(&progress, delta) })
+
+	if err := c.UploadFile("up-1", "proj", "app", "v1", "bin/app", localPath); err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	if progress != int64(len(content)) {
+		t.Errorf("Expected %d bytes of progress, got %d", len(content), progress)
+	}
+}
+
+func TestUploadFile_FailureRollsBackProgress(t *testing.T) {
+	localPath := writeTempFile(t, strings.Repeat("x", 4096))
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, `{"error":"storage unavailable"}`, http.StatusInternalServerError)
+	})
+
+	var progress int64
+	c.SetProgressFunc(func(delta int64) { atomic.AddInt64(&progress, delta) })
+
+	if err := c.UploadFile("up-1", "proj", "app", "v1", "bin/app", localPath); err == nil {
+		t.Fatal("Expected an error for a 500 response, got nil")
+	}
+	if progress != 0 {
+		t.Errorf("Expected progress to be rolled back to 0, got %d", progress)
+	}
+}
+
+func TestFinishUpload_ManifestMismatch(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"error":"manifest_mismatch","message":"1 of 2 files do not match the manifest","bad_files":[{"path":"bin/app","reason":"sha256 mismatch"}]}`))
+	})
+
+	err := c.FinishUpload(map[string]string{"upload_id": "up-1"})
+	mismatch, ok := err.(*FinishUploadError)
+	if !ok {
+		t.Fatalf("Expected *FinishUploadError, got %T: %v", err, err)
+	}
+	if len(mismatch.BadFiles) != 1 || mismatch.BadFiles[0].Path != "bin/app" {
+		t.Errorf("Expected bin/app to be rejected, got %v", mismatch.BadFiles)
+	}
+	if !strings.Contains(mismatch.Error(), "bin/app") {
+		t.Errorf("Expected the error to list bin/app, got %q", mismatch.Error())
+	}
+}
+
+func TestFinishUpload_Conflict(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"version is pinned"}`, http.StatusConflict)
+	})
+
+	err := c.FinishUpload(map[string]string{"upload_id": "up-1"})
+	if err == nil {
+		t.Fatal("Expected an error for a 409 response, got nil")
+	}
+	if _, ok := err.(*FinishUploadError); ok {
+		t.Errorf("Expected a plain error for a 409 response, got %v", err)
+	}
+}
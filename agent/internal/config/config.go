@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -26,6 +27,52 @@ type Config struct {
 	Ignore         []string `yaml:"ignore,omitempty"`
 	RetainVersions *int     `yaml:"retain_versions,omitempty"`
 	Concurrency    int      `yaml:"concurrency"` // Number of concurrent uploads/downloads (default: 50)
+	ChunkSize      string   `yaml:"chunk_size,omitempty"` // Files larger than this are uploaded in chunks, e.g. "8MB" (default: 8MB)
+}
+
+// DefaultChunkSize is used when chunk_size is not configured
+const DefaultChunkSize int64 = 8 << 20
+
+// ChunkSizeBytes returns the configured chunk size in bytes
+func (c *Config) ChunkSizeBytes() (int64, error) {
+	if strings.TrimSpace(c.ChunkSize) == "" {
+		return DefaultChunkSize, nil
+	}
+	size, err := ParseSize(c.ChunkSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chunk_size %q: %w", c.ChunkSize, err)
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("invalid chunk_size %q: must be positive", c.ChunkSize)
+	}
+	return size, nil
+}
+
+// ParseSize parses a byte size such as "4MB", "512KiB", "1G" or "1048576"
+// Units are binary: 1KB = 1024 bytes
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		factor int64
+	}{
+		{"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.factor
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("expected a number with an optional unit (KB, MB, GB)")
+	}
+	return value * multiplier, nil
 }
 
 // GetGlobalConfigPath returns the path to the global configuration file
@@ -223,7 +270,8 @@ type Overrides struct {
 	Project     string
 	App         string
 	Ignore      []string
-	Concurrency int // 0 means not set
+	Concurrency int    // 0 means not set
+	ChunkSize   string // empty means not set
 }
 
 // mergeConfigsWithOverrides merges global config, local config, and command-line overrides
@@ -240,6 +288,7 @@ func mergeConfigsWithOverrides(global, local *Config, overrides *Overrides) *Con
 		result.Ignore = global.Ignore
 		result.RetainVersions = global.RetainVersions
 		result.Concurrency = global.Concurrency
+		result.ChunkSize = global.ChunkSize
 	}
 
 	// Override with local config (if present)
@@ -264,6 +313,9 @@ func mergeConfigsWithOverrides(global, local *Config, overrides *Overrides) *Con
 		if local.Concurrency > 0 {
 			result.Concurrency = local.Concurrency
 		}
+		if local.ChunkSize != "" {
+			result.ChunkSize = local.ChunkSize
+		}
 		// Note: ignore patterns are merged separately below
 	}
 
@@ -284,6 +336,9 @@ func mergeConfigsWithOverrides(global, local *Config, overrides *Overrides) *Con
 		if overrides.Concurrency > 0 {
 			result.Concurrency = overrides.Concurrency
 		}
+		if overrides.ChunkSize != "" {
+			result.ChunkSize = overrides.ChunkSize
+		}
 	}
 
 	// Merge ignore patterns: global → local → command-line
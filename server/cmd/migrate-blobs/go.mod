@@ -0,0 +1,8 @@
+module github.com/kk/kkartifact-server/cmd/migrate-blobs
+
+go 1.24.0
+
+replace github.com/kk/kkartifact-server => ../..
+
+require github.com/kk/kkartifact-server v0.0.0
+
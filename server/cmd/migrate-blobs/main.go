@@ -0,0 +1,113 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/kk/kkartifact-server/internal/config"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/storage"
+)
+
+// migrate-blobs converts version trees stored as {project}/{app}/{version}/{path}
+// into content-addressed blobs referenced from meta.yaml
+func main() {
+	var dryRun bool
+	flag.BoolVar(&dryRun, "dry-run", false, "Only report versions that still use the legacy layout")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Initialize database
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	storageBackend, err := storage.NewStorage(&cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	ctx := context.Background()
+	artifactManager := storage.NewArtifactManager(storageBackend, database.NewBlobRepository(db))
+
+	projectRepo := database.NewProjectRepository(db)
+	appRepo := database.NewAppRepository(db)
+	versionRepo := database.NewVersionRepository(db)
+
+	projects, err := projectRepo.List(10000, 0)
+	if err != nil {
+		log.Fatalf("Failed to list projects: %v", err)
+	}
+
+	migratedCount := 0
+	skippedCount := 0
+	failedCount := 0
+
+	for _, project := range projects {
+		apps, err := appRepo.ListByProject(project.ID, 10000, 0)
+		if err != nil {
+			log.Printf("Warning: failed to list apps for %s: %v", project.Name, err)
+			continue
+		}
+
+		for _, app := range apps {
+			versions, err := versionRepo.ListByApp(app.ID, 10000, 0)
+			if err != nil {
+				log.Printf("Warning: failed to list versions for %s/%s: %v", project.Name, app.Name, err)
+				continue
+			}
+
+			for _, version := range versions {
+				if dryRun {
+					manifest, err := artifactManager.GetManifest(ctx, project.Name, app.Name, version.Hash)
+					if err != nil {
+						log.Printf("Warning: failed to read manifest for %s/%s/%s: %v", project.Name, app.Name, version.Hash, err)
+						failedCount++
+					} else if manifest.IsCAS() {
+						skippedCount++
+					} else {
+						log.Printf("  Would migrate: %s/%s/%s (%d files)", project.Name, app.Name, version.Hash, len(manifest.Files))
+						migratedCount++
+					}
+					continue
+				}
+
+				migrated, err := artifactManager.MigrateVersion(ctx, project.Name, app.Name, version.Hash)
+				if err != nil {
+					log.Printf("Warning: failed to migrate %s/%s/%s: %v", project.Name, app.Name, version.Hash, err)
+					failedCount++
+					continue
+				}
+				if migrated {
+					log.Printf("  Migrated: %s/%s/%s", project.Name, app.Name, version.Hash)
+					migratedCount++
+				} else {
+					skippedCount++
+				}
+			}
+		}
+	}
+
+	log.Printf("========================================")
+	if dryRun {
+		log.Printf("Dry run completed:")
+	} else {
+		log.Printf("Migration completed:")
+	}
+	log.Printf("  Migrated: %d", migratedCount)
+	log.Printf("  Already migrated: %d", skippedCount)
+	log.Printf("  Failed: %d", failedCount)
+	log.Printf("========================================")
+}
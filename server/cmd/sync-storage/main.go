@@ -63,6 +63,11 @@ func main() {
 			return nil
 		}
 
+		// Skip hidden directories such as the .blobs store
+		if strings.HasPrefix(relPath, ".") {
+			return filepath.SkipDir
+		}
+
 		parts := strings.Split(relPath, string(filepath.Separator))
 
 		// Structure: project/app/version
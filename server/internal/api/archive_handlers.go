@@ -0,0 +1,124 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/storage"
+)
+
+// handleGetArchive streams all files of a version as a single archive
+// handleGetArchive godoc
+// @Summary      Download version archive
+// @Description  Download every file of a version as a tar.gz or zip archive, built on the fly. Use "latest" as the version for the latest published version, or with channel for the version of that release channel.
+// @Tags         artifacts
+// @Produce      application/gzip
+// @Produce      application/zip
+// @Param        project  path      string  true   "Project name"
+// @Param        app      path      string  true   "App name"
+// @Param        version  path      string  true   "Version identifier or latest"
+// @Param        channel  query     string  false  "Release channel the latest version is taken from (default: published)"
+// @Param        format   query     string  false  "Archive format: tar.gz (default) or zip"
+// @Param        include  query     []string  false  "Glob patterns of files to include (repeatable or comma-separated)"
+// @Param        exclude  query     []string  false  "Glob patterns of files to exclude (repeatable or comma-separated)"
+// @Success      200      {file}    binary
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /archive/{project}/{app}/{version} [get]
+func (h *Handler) handleGetArchive(c *gin.Context) {
+	project := c.Param("project")
+	app := c.Param("app")
+	version := c.Param("version")
+
+	format := c.DefaultQuery("format", storage.ArchiveTarGz)
+	var contentType string
+	switch format {
+	case storage.ArchiveTarGz, "tgz":
+		format = storage.ArchiveTarGz
+		contentType = "application/gzip"
+	case storage.ArchiveZip:
+		contentType = "application/zip"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be tar.gz or zip"})
+		return
+	}
+
+	filter := storage.ArchiveFilter{
+		Include: splitQueryList(c.QueryArray("include")),
+		Exclude: splitQueryList(c.QueryArray("exclude")),
+	}
+	if err := filter.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Resolve the latest published version, so `curl ... | tar xz` always gets the current release
+	if version == "latest" {
+		channel := c.Query("channel")
+		projectObj, err := h.projectRepo.GetByName(project)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+			return
+		}
+		appObj, err := h.appRepo.GetByName(projectObj.ID, app)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "app not found"})
+			return
+		}
+		latestVersion, err := h.channelVersion(appObj.ID, channel)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if latestVersion == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": channelNotSetError(channel)})
+			return
+		}
+		version = latestVersion
+	}
+
+	manifest, err := h.artifactManager.GetManifest(c.Request.Context(), project, app, version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "manifest not found"})
+		return
+	}
+
+	h.recordPull(c, project, app, version, manifest, map[string]interface{}{
+		"archive": format,
+	})
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s.%s\"", app, version, format))
+	c.Header("X-Artifact-Version", version)
+	c.Status(http.StatusOK)
+
+	// The archive is written as it is read from storage; once the first bytes are
+	// sent the status can no longer change, so a failure can only cut the stream
+	if err := h.artifactManager.WriteArchive(c.Request.Context(), c.Writer, project, app, version, manifest, format, filter); err != nil {
+		log.Printf("Failed to stream archive of %s/%s/%s: %v", project, app, version, err)
+		c.Abort()
+	}
+}
+
+// splitQueryList flattens repeated and comma-separated query values
+func splitQueryList(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
@@ -8,11 +8,25 @@ package api
 import (
 	"net"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/auth"
 )
 
+// getActorFromRequest names who made a request: user:<username> for a Web UI
+// session or token:<id> for an API token
+func getActorFromRequest(c *gin.Context) string {
+	if sessionInfo := auth.GetSessionInfo(c); sessionInfo != nil {
+		return "user:" + sessionInfo.Username
+	}
+	if tokenInfo := auth.GetTokenInfo(c); tokenInfo != nil {
+		return "token:" + strconv.Itoa(tokenInfo.TokenID)
+	}
+	return "unknown"
+}
+
 // getAgentIDFromRequest extracts agent identifier from the request
 // Format: hostname-ip
 func getAgentIDFromRequest(c *gin.Context) string {
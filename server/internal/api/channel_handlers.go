@@ -0,0 +1,388 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
+)
+
+// DefaultChannel is the channel of the published version
+// Pulling latest without a channel uses it, and setting it publishes a version.
+const DefaultChannel = "published"
+
+// SetChannelRequest represents a request to point a channel at a version
+type SetChannelRequest struct {
+	Version string `json:"version" binding:"required"`
+}
+
+// ChannelResponse represents a release channel in API response
+type ChannelResponse struct {
+	Channel   string  `json:"channel"`
+	Version   string  `json:"version"`
+	Default   bool    `json:"default"`              // the published version
+	UpdatedBy *string `json:"updated_by,omitempty"` // user or token that last moved the channel
+	UpdatedAt *string `json:"updated_at,omitempty"` // RFC3339 format
+}
+
+// handleListChannels godoc
+// @Summary      List channels
+// @Description  List the release channels of an app (e.g. dev, staging, prod) with the version each points at. The published version is listed as the default channel "published".
+// @Tags         channels
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Success      200      {array}   ChannelResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/channels [get]
+func (h *Handler) handleListChannels(c *gin.Context) {
+	app, ok := h.findApp(c)
+	if !ok {
+		return
+	}
+
+	channels, err := database.NewChannelRepository(h.db).ListByApp(app.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responses := make([]ChannelResponse, 0, len(channels)+1)
+	if published, err := h.versionRepo.GetLatestPublished(app.ID); err == nil {
+		responses = append(responses, ChannelResponse{Channel: DefaultChannel, Version: published.Hash, Default: true})
+	}
+	for _, channel := range channels {
+		responses = append(responses, toChannelResponse(channel))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// handleGetChannel godoc
+// @Summary      Get channel
+// @Description  Get the version a release channel of an app points at
+// @Tags         channels
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        channel  path      string  true  "Channel name"
+// @Success      200      {object}  ChannelResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/channels/{channel} [get]
+func (h *Handler) handleGetChannel(c *gin.Context) {
+	app, ok := h.findApp(c)
+	if !ok {
+		return
+	}
+
+	name := c.Param("channel")
+	if name == DefaultChannel {
+		published, err := h.versionRepo.GetLatestPublished(app.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no published version found"})
+			return
+		}
+		c.JSON(http.StatusOK, ChannelResponse{Channel: DefaultChannel, Version: published.Hash, Default: true})
+		return
+	}
+
+	channel, err := database.NewChannelRepository(h.db).Get(app.ID, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if channel == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toChannelResponse(channel))
+}
+
+// handleSetChannel godoc
+// @Summary      Set channel
+// @Description  Point a release channel of an app at a version, creating the channel if needed. Setting the default channel "published" publishes the version. Versions on a channel are never pruned by retention. Channels with a promotion policy can only be moved by POST /promote.
+// @Tags         channels
+// @Accept       json
+// @Produce      json
+// @Param        project  path      string             true  "Project name"
+// @Param        app      path      string             true  "App name"
+// @Param        channel  path      string             true  "Channel name"
+// @Param        request  body      SetChannelRequest  true  "Version"
+// @Success      200      {object}  ChannelResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/channels/{channel} [put]
+func (h *Handler) handleSetChannel(c *gin.Context) {
+	var req SetChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Channel names take the same form as label keys
+	name := c.Param("channel")
+	if err := validateLabelKey(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("channel name %v", err)})
+		return
+	}
+
+	app, ok := h.findApp(c)
+	if !ok {
+		return
+	}
+	projectName, appName := c.Param("project"), c.Param("app")
+	if !h.requireNoPromotionPolicy(c, app.ID, name) {
+		return
+	}
+
+	if _, err := h.versionRepo.GetByHash(app.ID, req.Version); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+		return
+	}
+	if _, err := h.artifactManager.GetManifest(c.Request.Context(), projectName, appName, req.Version); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "version not found in storage"})
+		return
+	}
+
+	if name == DefaultChannel {
+		if err := h.publishVersion(c, projectName, appName, app.ID, req.Version); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, ChannelResponse{Channel: DefaultChannel, Version: req.Version, Default: true})
+		return
+	}
+
+	response, previous, err := h.setChannelVersion(c, app.ID, name, req.Version, database.ChannelActionSet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	metadata := map[string]interface{}{
+		"channel": name,
+	}
+	if previous != "" {
+		metadata["previous_version"] = previous
+	}
+	h.publishEventWithContext(c, events.EventTypeChannelUpdated, projectName, appName, req.Version, "", metadata)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// handleDeleteChannel godoc
+// @Summary      Delete channel
+// @Description  Delete a release channel of an app; the version it points at is kept. Deleting the default channel "published" unpublishes the published version.
+// @Tags         channels
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        channel  path      string  true  "Channel name"
+// @Success      200      {object}  map[string]string
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/channels/{channel} [delete]
+func (h *Handler) handleDeleteChannel(c *gin.Context) {
+	app, ok := h.findApp(c)
+	if !ok {
+		return
+	}
+	projectName, appName := c.Param("project"), c.Param("app")
+
+	name := c.Param("channel")
+	previous, err := h.clearChannel(c, app.ID, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if previous == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": channelNotSetError(name)})
+		return
+	}
+
+	if name == DefaultChannel {
+		h.publishEventWithContext(c, events.EventTypeVersionUnpublished, projectName, appName, previous, "", map[string]interface{}{
+			"target_version": previous,
+		})
+	} else {
+		h.publishEventWithContext(c, events.EventTypeChannelDeleted, projectName, appName, previous, "", map[string]interface{}{
+			"channel": name,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// setChannelVersion points a channel at a version, publishing the version for
+// the default channel, and records the change in the channel history
+// It returns the channel and the version it pointed at before, if any.
+func (h *Handler) setChannelVersion(c *gin.Context, appID int, channel, version, action string) (ChannelResponse, string, error) {
+	var previous string
+	var response ChannelResponse
+	if channel == DefaultChannel {
+		if published, err := h.versionRepo.GetLatestPublished(appID); err == nil {
+			previous = published.Hash
+		}
+		// Unpublish all other versions for this app (only one version can be published at a time)
+		if err := h.versionRepo.UnpublishAllVersions(appID); err != nil {
+			return response, "", err
+		}
+		if err := h.versionRepo.SetPublished(appID, version, true); err != nil {
+			return response, "", err
+		}
+		response = ChannelResponse{Channel: DefaultChannel, Version: version, Default: true}
+	} else {
+		channelRepo := database.NewChannelRepository(h.db)
+		current, err := channelRepo.Get(appID, channel)
+		if err != nil {
+			return response, "", err
+		}
+		if current != nil {
+			previous = current.Version
+		}
+		updated, err := channelRepo.Set(appID, channel, version, getActorFromRequest(c))
+		if err != nil {
+			return response, "", err
+		}
+		response = toChannelResponse(updated)
+	}
+
+	h.recordChannelHistory(c, appID, channel, version, previous, action)
+	return response, previous, nil
+}
+
+// clearChannel deletes a channel, unpublishing the published version for the
+// default channel, and records the change in the channel history
+// It returns the version the channel pointed at, or "" if it was not set.
+func (h *Handler) clearChannel(c *gin.Context, appID int, channel string) (string, error) {
+	var previous string
+	if channel == DefaultChannel {
+		published, err := h.versionRepo.GetLatestPublished(appID)
+		if err != nil {
+			return "", nil
+		}
+		if err := h.versionRepo.UnpublishAllVersions(appID); err != nil {
+			return "", err
+		}
+		previous = published.Hash
+	} else {
+		channelRepo := database.NewChannelRepository(h.db)
+		current, err := channelRepo.Get(appID, channel)
+		if err != nil || current == nil {
+			return "", err
+		}
+		if _, err := channelRepo.Delete(appID, channel); err != nil {
+			return "", err
+		}
+		previous = current.Version
+	}
+
+	h.recordChannelHistory(c, appID, channel, "", previous, database.ChannelActionDelete)
+	return previous, nil
+}
+
+// clearVersionChannels removes the channels pointing at a version whose content
+// was replaced, recording each as a deletion
+// The version has already been replaced, so a failure is only logged.
+func (h *Handler) clearVersionChannels(c *gin.Context, appID int, version string) {
+	channelRepo := database.NewChannelRepository(h.db)
+	channels, err := channelRepo.ChannelsByVersion(appID)
+	if err == nil && len(channels[version]) > 0 {
+		err = channelRepo.DeleteByVersion(appID, version)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to clear channels of version %s of app %d: %v", version, appID, err)
+		return
+	}
+	for _, channel := range channels[version] {
+		h.recordChannelHistory(c, appID, channel, "", version, database.ChannelActionDelete)
+	}
+}
+
+// recordChannelHistory records a change of a channel or of the published version
+// The change has already been made, so a failure to record it is only logged.
+func (h *Handler) recordChannelHistory(c *gin.Context, appID int, channel, version, previous, action string) {
+	entry := &database.ChannelHistory{
+		AppID:           appID,
+		Channel:         channel,
+		Version:         toNullString(version),
+		PreviousVersion: toNullString(previous),
+		Action:          action,
+		ChangedBy:       toNullString(getActorFromRequest(c)),
+	}
+	if err := database.NewChannelRepository(h.db).AddHistory(entry); err != nil {
+		log.Printf("Warning: failed to record history of channel %s of app %d: %v", channel, appID, err)
+	}
+}
+
+// channelVersion returns the version a channel of an app points at
+// An empty channel is the default channel. Returns "" if the channel is not set.
+func (h *Handler) channelVersion(appID int, channel string) (string, error) {
+	if channel == "" || channel == DefaultChannel {
+		published, err := h.versionRepo.GetLatestPublished(appID)
+		if err != nil {
+			// Nothing published reads the same as a database error, as before channels
+			return "", nil
+		}
+		return published.Hash, nil
+	}
+
+	ch, err := database.NewChannelRepository(h.db).Get(appID, channel)
+	if err != nil || ch == nil {
+		return "", err
+	}
+	return ch.Version, nil
+}
+
+// channelNotSetError is the error of a pull from a channel that is not set
+func channelNotSetError(channel string) string {
+	if channel == "" || channel == DefaultChannel {
+		return "no published version found"
+	}
+	return fmt.Sprintf("channel %s is not set", channel)
+}
+
+// toNullString converts an optional string, empty for NULL
+func toNullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func toChannelResponse(channel *database.Channel) ChannelResponse {
+	response := ChannelResponse{
+		Channel: channel.Name,
+		Version: channel.Version,
+	}
+	if channel.UpdatedBy.Valid {
+		response.UpdatedBy = &channel.UpdatedBy.String
+	}
+	updatedAt := channel.UpdatedAt.Format(time.RFC3339)
+	response.UpdatedAt = &updatedAt
+	return response
+}
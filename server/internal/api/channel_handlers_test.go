@@ -0,0 +1,137 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func getLatest(t *testing.T, h *Handler, query string) (int, map[string]string) {
+	t.Helper()
+	w := callHandler(h.handleGetLatestVersion, http.MethodGet, "/api/v1/projects/shop/apps/api/latest"+query, "",
+		"project", "shop", "app", "api")
+	var body map[string]string
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body
+}
+
+func setChannel(h *Handler, channel, version string) int {
+	w := callHandler(h.handleSetChannel, http.MethodPut, "/api/v1/projects/shop/apps/api/channels/"+channel, `{"version":"`+version+`"}`,
+		"project", "shop", "app", "api", "channel", channel)
+	return w.Code
+}
+
+func TestChannels_SetGetAndLatest(t *testing.T) {
+	h := newIntegrationHandler(t)
+	createTestVersion(t, h, "shop", "api", "v1")
+	createTestVersion(t, h, "shop", "api", "v2")
+
+	if status := setChannel(h, "staging", "v2"); status != http.StatusOK {
+		t.Fatalf("Expected status 200 setting staging, got %d", status)
+	}
+
+	w := callHandler(h.handleGetChannel, http.MethodGet, "/api/v1/projects/shop/apps/api/channels/staging", "",
+		"project", "shop", "app", "api", "channel", "staging")
+	var channel ChannelResponse
+	json.Unmarshal(w.Body.Bytes(), &channel)
+	if w.Code != http.StatusOK || channel.Version != "v2" || channel.Default {
+		t.Errorf("Expected staging at v2, got %d %+v", w.Code, channel)
+	}
+
+	status, body := getLatest(t, h, "?channel=staging")
+	if status != http.StatusOK || body["version"] != "v2" || body["channel"] != "staging" {
+		t.Errorf("Expected latest on staging to be v2, got %d %v", status, body)
+	}
+
+	// Nothing is published yet
+	status, body = getLatest(t, h, "")
+	if status != http.StatusNotFound || body["error"] != "no published version found" {
+		t.Errorf("Expected 404 without a published version, got %d %v", status, body)
+	}
+
+	// Setting the default channel publishes the version
+	if status := setChannel(h, DefaultChannel, "v1"); status != http.StatusOK {
+		t.Fatalf("Expected status 200 setting the default channel, got %d", status)
+	}
+	status, body = getLatest(t, h, "")
+	if status != http.StatusOK || body["version"] != "v1" || body["channel"] != DefaultChannel {
+		t.Errorf("Expected latest to be the published v1, got %d %v", status, body)
+	}
+
+	// Moving a channel does not touch the others
+	if status := setChannel(h, "staging", "v1"); status != http.StatusOK {
+		t.Fatalf("Expected status 200 moving staging, got %d", status)
+	}
+	if _, body = getLatest(t, h, "?channel=staging"); body["version"] != "v1" {
+		t.Errorf("Expected staging to move to v1, got %v", body)
+	}
+
+	w = callHandler(h.handleListChannels, http.MethodGet, "/api/v1/projects/shop/apps/api/channels", "",
+		"project", "shop", "app", "api")
+	var channels []ChannelResponse
+	json.Unmarshal(w.Body.Bytes(), &channels)
+	if len(channels) != 2 || !channels[0].Default || channels[1].Channel != "staging" {
+		t.Errorf("Expected the default channel and staging, got %+v", channels)
+	}
+}
+
+func TestChannels_Errors(t *testing.T) {
+	h := newIntegrationHandler(t)
+	createTestVersion(t, h, "shop", "api", "v1")
+
+	tests := []struct {
+		name       string
+		channel    string
+		version    string
+		wantStatus int
+	}{
+		{"unknown version", "prod", "v9", http.StatusNotFound},
+		{"invalid channel name", "bad name", "v1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := setChannel(h, tt.channel, tt.version); status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, status)
+			}
+		})
+	}
+
+	status, body := getLatest(t, h, "?channel=prod")
+	if status != http.StatusNotFound || !strings.Contains(body["error"], "channel prod is not set") {
+		t.Errorf("Expected 404 for an unset channel, got %d %v", status, body)
+	}
+
+	w := callHandler(h.handleGetChannel, http.MethodGet, "/api/v1/projects/shop/apps/api/channels/prod", "",
+		"project", "shop", "app", "api", "channel", "prod")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 getting an unset channel, got %d", w.Code)
+	}
+}
+
+func TestChannels_Delete(t *testing.T) {
+	h := newIntegrationHandler(t)
+	app, _ := createTestVersion(t, h, "shop", "api", "v1")
+	if status := setChannel(h, "dev", "v1"); status != http.StatusOK {
+		t.Fatalf("Expected status 200 setting dev, got %d", status)
+	}
+
+	w := callHandler(h.handleDeleteChannel, http.MethodDelete, "/api/v1/projects/shop/apps/api/channels/dev", "",
+		"project", "shop", "app", "api", "channel", "dev")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 deleting dev, got %d", w.Code)
+	}
+	if status, _ := getLatest(t, h, "?channel=dev"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted channel, got %d", status)
+	}
+	if _, err := h.versionRepo.GetByHash(app.ID, "v1"); err != nil {
+		t.Errorf("Expected the version to be kept: %v", err)
+	}
+}
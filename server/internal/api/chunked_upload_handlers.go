@@ -0,0 +1,441 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/storage"
+)
+
+// CreateChunkedUploadRequest represents the request to start (or resume) a chunked file upload
+// ChunkSize is a hint; the server may raise it to stay within the chunk limits
+type CreateChunkedUploadRequest struct {
+	UploadID  string `json:"upload_id" binding:"required"`
+	Path      string `json:"path" binding:"required"`
+	SHA256    string `json:"sha256" binding:"required"`
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunk_size,omitempty"`
+}
+
+// ByteRange is an inclusive range of bytes
+type ByteRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// ChunkedUploadResponse describes a chunked upload and the chunks received so far
+// Chunk i covers bytes [i*chunk_size, min((i+1)*chunk_size, size)-1]
+type ChunkedUploadResponse struct {
+	ID             string      `json:"id"`
+	UploadID       string      `json:"upload_id"`
+	Path           string      `json:"path"`
+	SHA256         string      `json:"sha256"`
+	Size           int64       `json:"size"`
+	ChunkSize      int64       `json:"chunk_size"`
+	ChunkCount     int64       `json:"chunk_count"`
+	Status         string      `json:"status"`
+	Deduplicated   bool        `json:"deduplicated"`
+	ReceivedChunks []int       `json:"received_chunks"`
+	ReceivedBytes  int64       `json:"received_bytes"`
+	Ranges         []ByteRange `json:"ranges"`
+}
+
+// handleCreateChunkedUpload godoc
+// @Summary      Start chunked upload
+// @Description  Start sending a large file of an upload session in numbered chunks. Calling it again for the same file returns the existing upload with the chunks already received, so an interrupted upload can resume.
+// @Description  If the server already has the content, deduplicated is true and no chunks need to be sent.
+// @Tags         artifacts
+// @Accept       json
+// @Produce      json
+// @Param        project  path      string                      true  "Project name"
+// @Param        app      path      string                      true  "App name"
+// @Param        version  path      string                      true  "Version"
+// @Param        request  body      CreateChunkedUploadRequest  true  "Chunked upload request"
+// @Success      200      {object}  ChunkedUploadResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /upload/chunked/{project}/{app}/{version} [post]
+func (h *Handler) handleCreateChunkedUpload(c *gin.Context) {
+	var req CreateChunkedUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, ok := h.loadUploadSession(c, req.UploadID, c.Param("project"), c.Param("app"), c.Param("version"))
+	if !ok {
+		return
+	}
+
+	if err := storage.ValidatePath(req.Path); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !storage.IsBlobHash(req.SHA256) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid sha256: %s", req.SHA256)})
+		return
+	}
+	if req.Size < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must not be negative"})
+		return
+	}
+
+	// Files announced at init must match what was announced
+	if expected, ok := expectedUploadFiles(session); ok {
+		entry, found := expected[req.Path]
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("file %s is not part of upload session %s", req.Path, session.ID)})
+			return
+		}
+		if entry.SHA256 != req.SHA256 || entry.Size != req.Size {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("file %s does not match upload manifest", req.Path)})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	exists, err := h.artifactManager.HasBlob(ctx, req.SHA256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if exists {
+		c.JSON(http.StatusOK, ChunkedUploadResponse{
+			UploadID:       session.ID,
+			Path:           req.Path,
+			SHA256:         req.SHA256,
+			Size:           req.Size,
+			Status:         database.UploadSessionCompleted,
+			Deduplicated:   true,
+			ReceivedChunks: []int{},
+			Ranges:         []ByteRange{},
+		})
+		return
+	}
+
+	// Resume the upload of the same content within this session
+	uploadRepo := database.NewChunkedUploadRepository(h.db)
+	if upload, err := uploadRepo.GetBySession(session.ID, req.SHA256); err == nil {
+		if upload.Status != database.UploadSessionAborted {
+			h.respondChunkedUpload(c, upload)
+			return
+		}
+		// A failed attempt is started over
+		if err := uploadRepo.Delete(upload.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	chunkSize := chooseChunkSize(req.Size, req.ChunkSize)
+	storageUploadID, err := h.artifactManager.StartChunkedBlob(ctx, session.ID, req.SHA256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, err := newUploadSessionID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	upload, err := uploadRepo.Create(id, session.ID, req.Path, req.SHA256, req.Size, chunkSize, storageUploadID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.respondChunkedUpload(c, upload)
+}
+
+// handleGetChunkedUpload godoc
+// @Summary      Get chunked upload
+// @Description  Get a chunked upload with the chunks and byte ranges received so far
+// @Tags         artifacts
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        version  path      string  true  "Version"
+// @Param        id       path      string  true  "Chunked upload ID"
+// @Success      200      {object}  ChunkedUploadResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /upload/chunked/{project}/{app}/{version}/{id} [get]
+func (h *Handler) handleGetChunkedUpload(c *gin.Context) {
+	upload, _, ok := h.loadChunkedUpload(c)
+	if !ok {
+		return
+	}
+	h.respondChunkedUpload(c, upload)
+}
+
+// handlePutChunk godoc
+// @Summary      Upload chunk
+// @Description  Upload one chunk as the raw request body. Chunks are numbered from 0 and may be sent in any order or in parallel; sending a chunk again replaces it.
+// @Description  An optional Content-Range header (bytes start-end/size) is checked against the chunk's offset.
+// @Tags         artifacts
+// @Accept       application/octet-stream
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        version  path      string  true  "Version"
+// @Param        id       path      string  true  "Chunked upload ID"
+// @Param        index    path      int     true  "Chunk index"
+// @Success      200      {object}  map[string]interface{}
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /upload/chunked/{project}/{app}/{version}/{id}/chunks/{index} [put]
+func (h *Handler) handlePutChunk(c *gin.Context) {
+	upload, session, ok := h.loadChunkedUpload(c)
+	if !ok {
+		return
+	}
+	if upload.Status != database.UploadSessionActive {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("chunked upload is %s", upload.Status)})
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || int64(index) >= storage.ChunkCount(upload.Size, upload.ChunkSize) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid chunk index: %s", c.Param("index"))})
+		return
+	}
+
+	offset := int64(index) * upload.ChunkSize
+	length := storage.ChunkLength(int64(index), upload.Size, upload.ChunkSize)
+	if header := c.GetHeader("Content-Range"); header != "" {
+		var start, end, total int64
+		if _, err := fmt.Sscanf(header, "bytes %d-%d/%d", &start, &end, &total); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid Content-Range: %s", header)})
+			return
+		}
+		if start != offset || end-start+1 != length || total != upload.Size {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("chunk %d must cover bytes %d-%d/%d", index, offset, offset+length-1, upload.Size)})
+			return
+		}
+	}
+	if c.Request.ContentLength >= 0 && c.Request.ContentLength != length {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("chunk %d must be %d bytes, got %d", index, length, c.Request.ContentLength)})
+		return
+	}
+
+	// Read one byte more than allowed so an oversized body is detected by the size check
+	body := io.LimitReader(c.Request.Body, length+1)
+	etag, err := h.artifactManager.PutChunk(c.Request.Context(), session.ID, upload.SHA256, upload.StorageUploadID, index, body, length)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	uploadRepo := database.NewChunkedUploadRepository(h.db)
+	if err := uploadRepo.PutPart(upload.ID, index+1, offset, length, etag); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "received",
+		"index":  index,
+		"offset": offset,
+		"size":   length,
+	})
+}
+
+// handleCompleteChunkedUpload godoc
+// @Summary      Complete chunked upload
+// @Description  Assemble the received chunks into the staged file and verify its SHA256. All chunks must have been received; otherwise the missing chunk indexes are returned.
+// @Description  A file that does not match its hash is discarded and must be uploaded again.
+// @Tags         artifacts
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        version  path      string  true  "Version"
+// @Param        id       path      string  true  "Chunked upload ID"
+// @Success      200      {object}  map[string]interface{}
+// @Failure      401      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
+// @Failure      422      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /upload/chunked/{project}/{app}/{version}/{id}/complete [post]
+func (h *Handler) handleCompleteChunkedUpload(c *gin.Context) {
+	upload, session, ok := h.loadChunkedUpload(c)
+	if !ok {
+		return
+	}
+
+	// Completing twice is harmless, e.g. when the first response was lost
+	if upload.Status == database.UploadSessionCompleted {
+		c.JSON(http.StatusOK, gin.H{"status": "uploaded", "hash": upload.SHA256, "size": upload.Size})
+		return
+	}
+	if upload.Status != database.UploadSessionActive {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("chunked upload is %s", upload.Status)})
+		return
+	}
+
+	uploadRepo := database.NewChunkedUploadRepository(h.db)
+	received, err := uploadRepo.ListParts(upload.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	byNumber := make(map[int]*database.ChunkedUploadPart, len(received))
+	for _, part := range received {
+		byNumber[part.PartNumber] = part
+	}
+	chunkCount := storage.ChunkCount(upload.Size, upload.ChunkSize)
+	parts := make([]storage.Part, 0, chunkCount)
+	missing := make([]int, 0)
+	for i := 0; int64(i) < chunkCount; i++ {
+		part, found := byNumber[i+1]
+		if !found {
+			missing = append(missing, i)
+			continue
+		}
+		parts = append(parts, storage.Part{Number: part.PartNumber, ETag: part.ETag})
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":          "incomplete_upload",
+			"message":        fmt.Sprintf("%d of %d chunks are missing", len(missing), chunkCount),
+			"missing_chunks": missing,
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = h.artifactManager.CompleteChunkedBlob(ctx, session.ID, upload.SHA256, upload.StorageUploadID, parts)
+	if err != nil {
+		var mismatch *storage.ChunkMismatchError
+		if errors.As(err, &mismatch) {
+			if _, err := uploadRepo.SetStatus(upload.ID, database.UploadSessionAborted); err != nil {
+				log.Printf("Warning: failed to abort chunked upload %s: %v", upload.ID, err)
+			}
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "hash_mismatch", "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionRepo := database.NewUploadSessionRepository(h.db)
+	if err := sessionRepo.AddFile(session.ID, upload.SHA256, upload.Size); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := uploadRepo.SetStatus(upload.ID, database.UploadSessionCompleted); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "uploaded",
+		"hash":   upload.SHA256,
+		"size":   upload.Size,
+	})
+}
+
+// loadChunkedUpload loads the chunked upload named by the :id path param and
+// the upload session it belongs to, checking ownership like loadUploadSession.
+// On failure the error response has been written and false is returned.
+func (h *Handler) loadChunkedUpload(c *gin.Context) (*database.ChunkedUpload, *database.UploadSession, bool) {
+	uploadRepo := database.NewChunkedUploadRepository(h.db)
+	upload, err := uploadRepo.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "chunked upload not found"})
+		return nil, nil, false
+	}
+
+	session, ok := h.loadUploadSession(c, upload.SessionID, c.Param("project"), c.Param("app"), c.Param("version"))
+	if !ok {
+		return nil, nil, false
+	}
+	return upload, session, true
+}
+
+// respondChunkedUpload writes a chunked upload with its received chunks
+func (h *Handler) respondChunkedUpload(c *gin.Context, upload *database.ChunkedUpload) {
+	uploadRepo := database.NewChunkedUploadRepository(h.db)
+	parts, err := uploadRepo.ListParts(upload.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := ChunkedUploadResponse{
+		ID:             upload.ID,
+		UploadID:       upload.SessionID,
+		Path:           upload.Path,
+		SHA256:         upload.SHA256,
+		Size:           upload.Size,
+		ChunkSize:      upload.ChunkSize,
+		ChunkCount:     storage.ChunkCount(upload.Size, upload.ChunkSize),
+		Status:         upload.Status,
+		ReceivedChunks: make([]int, 0, len(parts)),
+		Ranges:         make([]ByteRange, 0),
+	}
+
+	// Parts are ordered by number, so adjacent chunks merge into one range
+	for _, part := range parts {
+		resp.ReceivedChunks = append(resp.ReceivedChunks, part.PartNumber-1)
+		resp.ReceivedBytes += part.Size
+		if part.Size == 0 {
+			continue
+		}
+		end := part.Offset + part.Size - 1
+		if n := len(resp.Ranges); n > 0 && resp.Ranges[n-1].End+1 == part.Offset {
+			resp.Ranges[n-1].End = end
+		} else {
+			resp.Ranges = append(resp.Ranges, ByteRange{Start: part.Offset, End: end})
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// chooseChunkSize picks the chunk size for a file
+// The requested size is clamped to the allowed range and raised if the file
+// would otherwise need more than storage.MaxChunks chunks.
+func chooseChunkSize(size, requested int64) int64 {
+	chunkSize := requested
+	if chunkSize <= 0 {
+		chunkSize = storage.DefaultChunkSize
+	}
+	if chunkSize < storage.MinChunkSize {
+		chunkSize = storage.MinChunkSize
+	}
+	if chunkSize > storage.MaxChunkSize {
+		chunkSize = storage.MaxChunkSize
+	}
+	for storage.ChunkCount(size, chunkSize) > storage.MaxChunks && chunkSize < storage.MaxChunkSize {
+		chunkSize *= 2
+	}
+	if chunkSize > storage.MaxChunkSize {
+		chunkSize = storage.MaxChunkSize
+	}
+	return chunkSize
+}
@@ -6,17 +6,22 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
+	"github.com/kk/kkartifact-server/internal/scheduler"
+	"github.com/kk/kkartifact-server/internal/storage"
 )
 
 // handleGetConfig gets global configuration
 // handleGetConfig godoc
 // @Summary      Get config
-// @Description  Get the global configuration (e.g., version retention limit)
+// @Description  Get the global configuration (e.g., global retention rules, webhook delivery attempts, task schedules). version_retention_limit is the global keep-last rule; projects and apps override the retention rules with retention policies.
 // @Tags         config
 // @Accept       json
 // @Produce      json
@@ -45,17 +50,50 @@ func (h *Handler) handleGetConfig(c *gin.Context) {
 	}
 	
 	auditDays, _ := strconv.Atoi(auditRetentionDays)
+
+	// Get the other global retention rules
+	retentionPolicy, err := storage.LoadGlobalRetentionPolicy(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get webhook delivery attempts
+	webhookMaxAttempts := events.DefaultWebhookMaxAttempts
+	if value, err := configRepo.Get("webhook_max_attempts"); err == nil {
+		if attempts, err := strconv.Atoi(value); err == nil {
+			webhookMaxAttempts = attempts
+		}
+	}
+	
+	// Get task schedules, and whether scheduled runs are dry runs
+	taskSchedules := make(map[string]string)
+	taskDryRun := make(map[string]bool)
+	if h.scheduler != nil {
+		for _, task := range h.scheduler.Tasks() {
+			taskSchedules[task.Name] = task.Schedule
+			if task.CanDryRun {
+				taskDryRun[task.Name] = task.DryRun
+			}
+		}
+	}
 	
 	c.JSON(http.StatusOK, gin.H{
 		"version_retention_limit": limit,
+		"retention_keep_days": retentionPolicy.KeepDays,
+		"retention_keep_per_branch": retentionPolicy.KeepPerBranch,
+		"retention_branch_label": retentionPolicy.BranchLabel,
 		"audit_log_retention_days": auditDays,
+		"webhook_max_attempts": webhookMaxAttempts,
+		"task_schedules": taskSchedules,
+		"task_dry_run": taskDryRun,
 	})
 }
 
 // handleUpdateConfig updates global configuration
 // handleUpdateConfig godoc
 // @Summary      Update config
-// @Description  Update the global configuration
+// @Description  Update the global configuration. retention_keep_days and retention_keep_per_branch are global retention rules (0 turns a rule off); retention_branch_label is the version label holding the git branch. task_schedules maps task names to cron expressions (minute hour day-of-month month day-of-week) or "off". task_dry_run makes the scheduled runs of a task (e.g. version-cleanup) dry runs, which only record what they would do.
 // @Tags         config
 // @Accept       json
 // @Produce      json
@@ -69,7 +107,13 @@ func (h *Handler) handleGetConfig(c *gin.Context) {
 func (h *Handler) handleUpdateConfig(c *gin.Context) {
 	var req struct {
 		VersionRetentionLimit *int `json:"version_retention_limit"`
+		RetentionKeepDays      *int    `json:"retention_keep_days"`
+		RetentionKeepPerBranch *int    `json:"retention_keep_per_branch"`
+		RetentionBranchLabel   *string `json:"retention_branch_label"`
 		AuditLogRetentionDays *int `json:"audit_log_retention_days"`
+		WebhookMaxAttempts    *int `json:"webhook_max_attempts"`
+		TaskSchedules         map[string]string `json:"task_schedules"` // Task name to cron expression or "off"
+		TaskDryRun            map[string]bool   `json:"task_dry_run"`   // Task name to whether scheduled runs are dry runs
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -77,7 +121,30 @@ func (h *Handler) handleUpdateConfig(c *gin.Context) {
 		return
 	}
 
+	// Validate every schedule before anything is changed
+	for name, schedule := range req.TaskSchedules {
+		if h.scheduler == nil || !h.scheduler.HasTask(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown task: %s", name)})
+			return
+		}
+		if err := scheduler.ValidateSchedule(schedule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	for name := range req.TaskDryRun {
+		if h.scheduler == nil || !h.scheduler.HasTask(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown task: %s", name)})
+			return
+		}
+		if !h.scheduler.CanDryRun(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("task %s does not support dry runs", name)})
+			return
+		}
+	}
+
 	configRepo := database.NewConfigRepository(h.db)
+	changes := make(map[string]interface{})
 
 	if req.VersionRetentionLimit != nil {
 		if *req.VersionRetentionLimit < 1 {
@@ -88,6 +155,44 @@ func (h *Handler) handleUpdateConfig(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		changes["version_retention_limit"] = *req.VersionRetentionLimit
+	}
+
+	if req.RetentionKeepDays != nil {
+		if *req.RetentionKeepDays < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "retention_keep_days must not be negative"})
+			return
+		}
+		if err := configRepo.Set("retention_keep_days", strconv.Itoa(*req.RetentionKeepDays)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		changes["retention_keep_days"] = *req.RetentionKeepDays
+	}
+
+	if req.RetentionKeepPerBranch != nil {
+		if *req.RetentionKeepPerBranch < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "retention_keep_per_branch must not be negative"})
+			return
+		}
+		if err := configRepo.Set("retention_keep_per_branch", strconv.Itoa(*req.RetentionKeepPerBranch)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		changes["retention_keep_per_branch"] = *req.RetentionKeepPerBranch
+	}
+
+	if req.RetentionBranchLabel != nil {
+		label := strings.TrimSpace(*req.RetentionBranchLabel)
+		if err := validateLabelKey(label); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "retention_branch_label " + err.Error()})
+			return
+		}
+		if err := configRepo.Set("retention_branch_label", label); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		changes["retention_branch_label"] = label
 	}
 
 	if req.AuditLogRetentionDays != nil {
@@ -99,6 +204,45 @@ func (h *Handler) handleUpdateConfig(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		changes["audit_log_retention_days"] = *req.AuditLogRetentionDays
+	}
+
+	if req.WebhookMaxAttempts != nil {
+		if *req.WebhookMaxAttempts < 1 || *req.WebhookMaxAttempts > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "webhook_max_attempts must be between 1 and 50"})
+			return
+		}
+		if err := configRepo.Set("webhook_max_attempts", strconv.Itoa(*req.WebhookMaxAttempts)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		changes["webhook_max_attempts"] = *req.WebhookMaxAttempts
+	}
+
+	if len(req.TaskSchedules) > 0 {
+		for name, schedule := range req.TaskSchedules {
+			if err := configRepo.Set(scheduler.ScheduleKey(name), strings.TrimSpace(schedule)); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		changes["task_schedules"] = req.TaskSchedules
+	}
+
+	if len(req.TaskDryRun) > 0 {
+		for name, dryRun := range req.TaskDryRun {
+			if err := configRepo.Set(scheduler.DryRunKey(name), strconv.FormatBool(dryRun)); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		changes["task_dry_run"] = req.TaskDryRun
+	}
+
+	if len(changes) > 0 {
+		h.publishEventWithContext(c, events.EventTypeConfigUpdated, "", "", "", "", map[string]interface{}{
+			"changes": changes,
+		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{"status": "updated"})
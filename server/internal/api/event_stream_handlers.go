@@ -0,0 +1,299 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/auth"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
+)
+
+const (
+	// eventStreamBuffer is how many events may wait for a slow client; a client
+	// that falls further behind is disconnected and resumes with Last-Event-ID
+	eventStreamBuffer = 256
+
+	// eventStreamHeartbeat keeps idle connections open through proxies
+	eventStreamHeartbeat = 15 * time.Second
+
+	// eventStreamReplayPage is how many audit log entries are read at a time on resume
+	eventStreamReplayPage = 500
+)
+
+// adminEventTypes are only streamed to admins; they are not about artifacts
+var adminEventTypes = map[events.EventType]bool{
+	events.EventTypeTokenCreated:  true,
+	events.EventTypeTokenRevoked:  true,
+	events.EventTypeConfigUpdated: true,
+	events.EventTypeWebhookFailed: true,
+}
+
+// eventStreamFilter selects the events sent to a stream client
+type eventStreamFilter struct {
+	project string
+	app     string
+	types   map[events.EventType]bool // empty means every type
+	admin   bool
+}
+
+// matches reports whether an event passes the filter
+func (f *eventStreamFilter) matches(event *events.Event) bool {
+	if adminEventTypes[event.Type] && !f.admin {
+		return false
+	}
+	if len(f.types) > 0 && !f.types[event.Type] {
+		return false
+	}
+	if f.project != "" && event.Project != f.project {
+		return false
+	}
+	if f.app != "" && event.App != f.app {
+		return false
+	}
+	return true
+}
+
+// handleEventStream godoc
+// @Summary      Stream events
+// @Description  Server-Sent Events feed of published events. Each message has the audit log ID as its id, the event type as its event and the event JSON as its data. Send Last-Event-ID (or last_event_id) to resume: missed events are replayed from the audit log first. Tokens scoped to a project or app only receive events of that project or app; token, config and webhook events are only sent to admins.
+// @Tags         events
+// @Produce      text/event-stream
+// @Param        project        query     string  false  "Only events of this project"
+// @Param        app            query     string  false  "Only events of this app"
+// @Param        type           query     string  false  "Only these event types (comma-separated)"
+// @Param        last_event_id  query     int     false  "Resume after this event ID (same as the Last-Event-ID header)"
+// @Success      200            {string}  string  "text/event-stream"
+// @Failure      400            {object}  ErrorResponse
+// @Failure      401            {object}  ErrorResponse
+// @Failure      403            {object}  ErrorResponse
+// @Failure      503            {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /events/stream [get]
+func (h *Handler) handleEventStream(c *gin.Context) {
+	if h.publisher == nil || h.publisher.Bus() == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "event stream is not available"})
+		return
+	}
+
+	filter, ok := h.eventStreamFilter(c)
+	if !ok {
+		return
+	}
+
+	lastEventID := 0
+	if value := c.GetHeader("Last-Event-ID"); value != "" {
+		lastEventID, _ = strconv.Atoi(value)
+	} else if value := c.Query("last_event_id"); value != "" {
+		lastEventID, _ = strconv.Atoi(value)
+	}
+
+	// Subscribe before replaying so nothing published in between is missed
+	live := make(chan *events.Event, eventStreamBuffer)
+	lagging := make(chan struct{})
+	var lagOnce sync.Once
+	unsubscribe := h.publisher.Bus().SubscribeAll(func(event *events.Event) error {
+		select {
+		case live <- event:
+		default:
+			lagOnce.Do(func() { close(lagging) })
+		}
+		return nil
+	})
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx buffering
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	c.Writer.Flush()
+
+	if lastEventID > 0 {
+		var err error
+		if lastEventID, err = h.replayEvents(c, filter, lastEventID); err != nil {
+			fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", jsonString(err.Error()))
+			c.Writer.Flush()
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-lagging:
+			// The client resumes from the last event it got when it reconnects
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		case event := <-live:
+			if event.ID != 0 && event.ID <= lastEventID {
+				continue // Already replayed
+			}
+			if !filter.matches(event) {
+				continue
+			}
+			if err := writeEvent(c, event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// eventStreamFilter builds the filter of a stream request and checks the caller may use it
+// It writes the error response and returns false if the request is refused.
+func (h *Handler) eventStreamFilter(c *gin.Context) (*eventStreamFilter, bool) {
+	filter := &eventStreamFilter{
+		project: c.Query("project"),
+		app:     c.Query("app"),
+		types:   make(map[events.EventType]bool),
+	}
+	for _, name := range splitQueryList(c.QueryArray("type")) {
+		eventType, err := events.ParseEventType(name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		if eventType != events.EventTypeAll {
+			filter.types[eventType] = true
+		}
+	}
+
+	if sessionInfo := auth.GetSessionInfo(c); sessionInfo != nil {
+		filter.admin = sessionInfo.IsAdmin
+		return filter, true
+	}
+
+	tokenInfo := auth.GetTokenInfo(c)
+	if tokenInfo == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return nil, false
+	}
+	if !auth.HasPermission(tokenInfo.Permissions, auth.PermissionPull) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "token lacks required permission: pull"})
+		return nil, false
+	}
+	if !tokenInfo.IsScoped() {
+		filter.admin = auth.HasPermission(tokenInfo.Permissions, auth.PermissionAdmin)
+		return filter, true
+	}
+
+	// Scoped tokens only see their own project/app
+	scopeProject, scopeApp := h.scopeNames(tokenInfo.ProjectID, tokenInfo.AppID)
+	if filter.project == "" {
+		filter.project = scopeProject
+	}
+	if filter.app == "" {
+		filter.app = scopeApp
+	}
+	if err := h.authenticator.CheckScope(tokenInfo, filter.project, filter.app); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": err.Error()})
+		return nil, false
+	}
+	return filter, true
+}
+
+// replayEvents sends the events recorded after lastEventID and returns the last ID sent
+func (h *Handler) replayEvents(c *gin.Context, filter *eventStreamFilter, lastEventID int) (int, error) {
+	operations := make([]string, 0, len(events.EventTypes))
+	for _, eventType := range events.EventTypes {
+		if len(filter.types) == 0 || filter.types[eventType] {
+			operations = append(operations, string(eventType))
+		}
+	}
+
+	auditRepo := database.NewAuditRepository(h.db)
+	for {
+		logs, err := auditRepo.ListAfter(lastEventID, operations, eventStreamReplayPage)
+		if err != nil {
+			return lastEventID, err
+		}
+		for _, log := range logs {
+			lastEventID = log.ID
+			event := h.auditLogEvent(log)
+			if !filter.matches(event) {
+				continue
+			}
+			if err := writeEvent(c, event); err != nil {
+				return lastEventID, err
+			}
+		}
+		if len(logs) < eventStreamReplayPage {
+			return lastEventID, nil
+		}
+	}
+}
+
+// auditLogEvent rebuilds a published event from its audit log entry
+// Deleted projects and apps are named by the event metadata.
+func (h *Handler) auditLogEvent(log *database.AuditLog) *events.Event {
+	var projectID, appID *int
+	if log.ProjectID.Valid {
+		id := int(log.ProjectID.Int64)
+		projectID = &id
+	}
+	if log.AppID.Valid {
+		id := int(log.AppID.Int64)
+		appID = &id
+	}
+	project, app := h.scopeNames(projectID, appID)
+
+	event := &events.Event{
+		ID:        log.ID,
+		Type:      events.EventType(log.Operation),
+		Project:   project,
+		App:       app,
+		Version:   log.VersionHash.String,
+		AgentID:   log.AgentID.String,
+		Timestamp: log.CreatedAt,
+	}
+	if log.Metadata.Valid {
+		_ = json.Unmarshal([]byte(log.Metadata.String), &event.Metadata)
+	}
+	if name, ok := event.Metadata["project_name"].(string); ok && event.Project == "" {
+		event.Project = name
+	}
+	if name, ok := event.Metadata["app_name"].(string); ok && event.App == "" {
+		event.App = name
+	}
+	return event
+}
+
+// writeEvent writes one event to the stream
+func writeEvent(c *gin.Context, event *events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	// An event that could not be recorded has no ID and cannot be resumed from
+	if event.ID != 0 {
+		fmt.Fprintf(c.Writer, "id: %d\n", event.ID)
+	}
+	if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// jsonString encodes a string as a JSON string
+func jsonString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
+)
+
+func TestEventStreamFilter_Matches(t *testing.T) {
+	pushed := &events.Event{Type: events.EventTypeVersionPushed, Project: "shop", App: "api", Version: "v1"}
+	otherApp := &events.Event{Type: events.EventTypeVersionPushed, Project: "shop", App: "web", Version: "v1"}
+	otherProject := &events.Event{Type: events.EventTypeVersionPushed, Project: "blog", App: "api", Version: "v1"}
+	deleted := &events.Event{Type: events.EventTypeVersionDeleted, Project: "shop", App: "api", Version: "v1"}
+	tokenCreated := &events.Event{Type: events.EventTypeTokenCreated}
+	configUpdated := &events.Event{Type: events.EventTypeConfigUpdated}
+	webhookFailed := &events.Event{Type: events.EventTypeWebhookFailed, Project: "shop"}
+
+	tests := []struct {
+		name     string
+		filter   eventStreamFilter
+		event    *events.Event
+		expected bool
+	}{
+		{"no filter", eventStreamFilter{}, pushed, true},
+		{"admin-only type hidden from non-admins", eventStreamFilter{}, tokenCreated, false},
+		{"config events hidden from non-admins", eventStreamFilter{}, configUpdated, false},
+		{"webhook failures hidden from non-admins", eventStreamFilter{project: "shop"}, webhookFailed, false},
+		{"admin-only type sent to admins", eventStreamFilter{admin: true}, tokenCreated, true},
+		{"admin-only type asked for by a non-admin", eventStreamFilter{types: map[events.EventType]bool{events.EventTypeTokenCreated: true}}, tokenCreated, false},
+		{"type selected", eventStreamFilter{types: map[events.EventType]bool{events.EventTypeVersionPushed: true}}, pushed, true},
+		{"type not selected", eventStreamFilter{types: map[events.EventType]bool{events.EventTypeVersionPushed: true}}, deleted, false},
+		{"empty types match every type", eventStreamFilter{types: map[events.EventType]bool{}}, deleted, true},
+		{"project matches", eventStreamFilter{project: "shop"}, otherApp, true},
+		{"project differs", eventStreamFilter{project: "shop"}, otherProject, false},
+		{"app matches", eventStreamFilter{project: "shop", app: "api"}, pushed, true},
+		{"app differs", eventStreamFilter{project: "shop", app: "api"}, otherApp, false},
+		{"app filter alone", eventStreamFilter{app: "api"}, otherProject, true},
+		{"events without a project are filtered out by project", eventStreamFilter{project: "shop", admin: true}, configUpdated, false},
+		{"admins are still filtered by project", eventStreamFilter{project: "shop", admin: true}, otherProject, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(tt.event); got != tt.expected {
+				t.Errorf("matches() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuditLogEvent_DeletedProject(t *testing.T) {
+	h := &Handler{}
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// The project and app rows are gone, so their IDs were cleared
+	log := &database.AuditLog{
+		ID:          42,
+		Operation:   string(events.EventTypeVersionDeleted),
+		VersionHash: sql.NullString{String: "v1", Valid: true},
+		AgentID:     sql.NullString{String: "user:alice", Valid: true},
+		Metadata:    sql.NullString{String: `{"project_name": "shop", "app_name": "api", "version": "v1"}`, Valid: true},
+		CreatedAt:   createdAt,
+	}
+
+	event := h.auditLogEvent(log)
+	if event.ID != 42 || event.Type != events.EventTypeVersionDeleted {
+		t.Errorf("Expected event 42 of type %s, got %d of type %s", events.EventTypeVersionDeleted, event.ID, event.Type)
+	}
+	if event.Project != "shop" || event.App != "api" {
+		t.Errorf("Expected shop/api from the metadata, got %q/%q", event.Project, event.App)
+	}
+	if event.Version != "v1" || event.AgentID != "user:alice" || !event.Timestamp.Equal(createdAt) {
+		t.Errorf("Expected version, agent and time from the log, got %+v", event)
+	}
+	if !(&eventStreamFilter{project: "shop", app: "api"}).matches(event) {
+		t.Error("Expected the replayed event to match a filter on its deleted project and app")
+	}
+}
+
+func TestAuditLogEvent_WithoutMetadata(t *testing.T) {
+	log := &database.AuditLog{
+		ID:        7,
+		Operation: string(events.EventTypeConfigUpdated),
+		Metadata:  sql.NullString{String: "not json", Valid: true},
+	}
+
+	event := (&Handler{}).auditLogEvent(log)
+	if event.Project != "" || event.App != "" || event.Metadata != nil {
+		t.Errorf("Expected an event without project, app or metadata, got %+v", event)
+	}
+}
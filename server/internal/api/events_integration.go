@@ -6,11 +6,9 @@
 package api
 
 import (
-	"log"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/kk/kkartifact-server/internal/database"
 	"github.com/kk/kkartifact-server/internal/events"
 )
 
@@ -20,17 +18,27 @@ func (h *Handler) publishEvent(eventType events.EventType, project, app, version
 }
 
 // publishEventWithContext publishes an event with context for extracting client information
+// The publisher sends it to the event bus, records it in the audit log and
+// queues it for subscribed webhooks.
 func (h *Handler) publishEventWithContext(c *gin.Context, eventType events.EventType, project, app, version, agentID string, metadata map[string]interface{}) {
+	h.publishScopedEventWithContext(c, nil, nil, eventType, project, app, version, agentID, metadata)
+}
+
+// publishScopedEventWithContext publishes an event with the IDs of its project and app
+// Events about a project or app that is deleted by the time they are published
+// carry the IDs looked up before the delete, so webhooks scoped to the project
+// still match.
+func (h *Handler) publishScopedEventWithContext(c *gin.Context, projectID, appID *int, eventType events.EventType, project, app, version, agentID string, metadata map[string]interface{}) {
+	if h.publisher == nil {
+		return
+	}
+
 	// Extract agent ID from context if not provided
 	if agentID == "" && c != nil {
 		agentID = getAgentIDFromRequest(c)
 	}
 
-	if metadata == nil {
-		metadata = make(map[string]interface{})
-	}
-
-	event := &events.Event{
+	h.publisher.Publish(&events.Event{
 		Type:      eventType,
 		Project:   project,
 		App:       app,
@@ -38,51 +46,20 @@ func (h *Handler) publishEventWithContext(c *gin.Context, eventType events.Event
 		AgentID:   agentID,
 		Metadata:  metadata,
 		Timestamp: time.Now(),
-	}
-
-	// Publish event to event bus if available
-	if h.eventBus != nil {
-		if err := h.eventBus.Publish(event); err != nil {
-			// Log error but continue with audit log and webhook triggering
-			log.Printf("Failed to publish event to event bus: %v", err)
-		}
-	}
-
-	// Get project and app IDs
-	projectObj, err := h.projectRepo.CreateOrGet(project)
-	if err != nil {
-		return
-	}
-	
-	var projectID *int
-	var appID *int
-	if projectObj != nil {
-		projectID = &projectObj.ID
-		
-		if app != "" {
-			appObj, err := h.appRepo.CreateOrGet(projectObj.ID, app)
-			if err == nil && appObj != nil {
-				appID = &appObj.ID
-			}
-		}
-	}
-
-	// Record audit log
-	auditRepo := database.NewAuditRepository(h.db)
-	_ = auditRepo.Create(string(eventType), projectID, appID, version, agentID, metadata)
+		ProjectID: projectID,
+		AppID:     appID,
+	})
+}
 
-	// Get webhooks and trigger them
-	webhookRepo := database.NewWebhookRepository(h.db)
-	webhooks, err := webhookRepo.FindByEventType(string(eventType), projectID, appID)
-	if err != nil {
-		return
+// scopeNames looks up the project and app names of a project/app scope
+// Empty names are returned for a nil or unknown ID.
+func (h *Handler) scopeNames(projectID, appID *int) (string, string) {
+	var projectName, appName string
+	if projectID != nil {
+		_ = h.db.QueryRow(`SELECT name FROM projects WHERE id = $1`, *projectID).Scan(&projectName)
 	}
-
-	// Trigger webhooks asynchronously
-	for _, webhook := range webhooks {
-		go h.triggerWebhook(webhook, event)
+	if appID != nil {
+		_ = h.db.QueryRow(`SELECT name FROM apps WHERE id = $1`, *appID).Scan(&appName)
 	}
+	return projectName, appName
 }
-
-// triggerWebhook is in webhook_trigger.go
-
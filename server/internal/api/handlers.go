@@ -7,14 +7,14 @@ package api
 
 import (
 	"fmt"
-	"io"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kk/kkartifact-server/internal/auth"
 	"github.com/kk/kkartifact-server/internal/database"
 	"github.com/kk/kkartifact-server/internal/events"
+	"github.com/kk/kkartifact-server/internal/scheduler"
 	"github.com/kk/kkartifact-server/internal/services"
 	"github.com/kk/kkartifact-server/internal/storage"
 )
@@ -29,28 +29,37 @@ type Handler struct {
 	appRepo         *database.AppRepository
 	versionRepo     *database.VersionRepository
 	inventoryService *services.InventoryService
-	eventBus        events.EventBus
+	publisher       *events.Publisher
+	webhookDispatcher *events.WebhookDispatcher
+	scheduler       *scheduler.Scheduler
 }
 
 // NewHandler creates a new API handler
-func NewHandler(db *database.DB, storageBackend storage.Storage, authenticator *auth.TokenAuthenticator) *Handler {
+// The artifact manager is shared with the scheduler's tasks so they use the
+// same manifest cache. The publisher may be nil, in which case no events are published, and the
+// scheduler may be nil, in which case the task endpoints are unavailable.
+func NewHandler(db *database.DB, storageBackend storage.Storage, artifactManager *storage.ArtifactManager, authenticator *auth.TokenAuthenticator, publisher *events.Publisher, sched *scheduler.Scheduler) *Handler {
 	projectRepo := database.NewProjectRepository(db)
 	appRepo := database.NewAppRepository(db)
 	versionRepo := database.NewVersionRepository(db)
-	
-	// Initialize event bus (can be nil if not needed)
-	eventBus := events.NewMemoryEventBus()
+
+	var webhookDispatcher *events.WebhookDispatcher
+	if publisher != nil {
+		webhookDispatcher = publisher.Dispatcher()
+	}
 	
 	return &Handler{
 		db:              db,
 		storage:         storageBackend,
-		artifactManager: storage.NewArtifactManager(storageBackend),
+		artifactManager: artifactManager,
 		authenticator:   authenticator,
 		projectRepo:     projectRepo,
 		appRepo:         appRepo,
 		versionRepo:     versionRepo,
 		inventoryService: services.NewInventoryService(projectRepo, appRepo, versionRepo),
-		eventBus:        eventBus,
+		publisher:       publisher,
+		webhookDispatcher: webhookDispatcher,
+		scheduler:       sched,
 	}
 }
 
@@ -65,10 +74,6 @@ func (h *Handler) RegisterRoutes(router *gin.Engine) {
 	// Login endpoint (public)
 	api.POST("/login", h.handleLogin)
 	
-	// Token creation endpoint (public for initial setup, consider protecting in production)
-	// Register BEFORE protected routes to avoid conflicts
-	api.POST("/tokens", h.handleCreateToken)
-	
 	// Public read-only endpoints for inventory (no authentication required)
 	public := api.Group("/public")
 	{
@@ -87,58 +92,125 @@ func (h *Handler) RegisterRoutes(router *gin.Engine) {
 	}
 	
 	// Protected routes
+	// Every route declares the permission it needs; scoped tokens are additionally
+	// checked against the :project/:app path params or the JSON body
 	protected := api.Group("")
 	protected.Use(h.authenticator.AuthMiddleware())
+
+	requirePull := h.authenticator.RequirePermission(auth.PermissionPull)
+	requirePush := h.authenticator.RequirePermission(auth.PermissionPush)
+	requirePublish := h.authenticator.RequirePermission(auth.PermissionPublish)
+	requirePromote := h.authenticator.RequirePermission(auth.PermissionPromote)
+	requireAdmin := h.authenticator.RequirePermission(auth.PermissionAdmin)
 	{
 		// List endpoints
-		protected.GET("/projects", h.handleListProjects)
-		protected.GET("/projects/:project/apps", h.handleListApps)
-		protected.GET("/projects/:project/apps/:app/versions", h.handleListVersions)
-		protected.GET("/projects/:project/apps/:app/latest", h.handleGetLatestVersion)
+		protected.GET("/projects", requirePull, h.handleListProjects)
+		protected.GET("/projects/:project/apps", requirePull, h.handleListApps)
+		protected.GET("/projects/:project/apps/:app/versions", requirePull, h.handleListVersions)
+		protected.GET("/projects/:project/apps/:app/latest", requirePull, h.handleGetLatestVersion)
 		
 		// Delete endpoints
-		protected.DELETE("/projects/:project", h.handleDeleteProject)
-		protected.DELETE("/projects/:project/apps/:app", h.handleDeleteApp)
-		protected.DELETE("/projects/:project/apps/:app/versions/:version", h.handleDeleteVersion)
-		protected.GET("/manifest/:project/:app/:hash", h.handleGetManifest)
-		protected.GET("/file/:project/:app/:hash", h.handleGetFile)
+		protected.DELETE("/projects/:project", requireAdmin, h.handleDeleteProject)
+		protected.DELETE("/projects/:project/apps/:app", requireAdmin, h.handleDeleteApp)
+		protected.DELETE("/projects/:project/apps/:app/versions/:version", requireAdmin, h.handleDeleteVersion)
+
+		// Version labels and pins
+		protected.PUT("/projects/:project/apps/:app/versions/:version/labels", requirePush, h.handleSetVersionLabels)
+		protected.DELETE("/projects/:project/apps/:app/versions/:version/labels/:key", requirePush, h.handleDeleteVersionLabel)
+		protected.PUT("/projects/:project/apps/:app/versions/:version/pin", requirePublish, h.handlePinVersion)
+		protected.DELETE("/projects/:project/apps/:app/versions/:version/pin", requirePublish, h.handleUnpinVersion)
+
+		// Release channels (the published version is the default channel)
+		protected.GET("/projects/:project/apps/:app/channels", requirePull, h.handleListChannels)
+		protected.GET("/projects/:project/apps/:app/channels/:channel", requirePull, h.handleGetChannel)
+		protected.PUT("/projects/:project/apps/:app/channels/:channel", requirePublish, h.handleSetChannel)
+		protected.DELETE("/projects/:project/apps/:app/channels/:channel", requirePublish, h.handleDeleteChannel)
+		protected.GET("/projects/:project/apps/:app/channels/:channel/history", requirePull, h.handleListChannelHistory)
+
+		// Promotions between channels and the policies that gate them
+		protected.GET("/projects/:project/apps/:app/promotions", requirePull, h.handleListPromotions)
+		protected.GET("/projects/:project/apps/:app/promotions/:id", requirePull, h.handleGetPromotion)
+		protected.POST("/projects/:project/apps/:app/promotions/:id/approve", requirePromote, h.handleApprovePromotion)
+		protected.POST("/projects/:project/apps/:app/promotions/:id/reject", requirePromote, h.handleRejectPromotion)
+		protected.GET("/projects/:project/apps/:app/promotion-policies", requirePull, h.handleListPromotionPolicies)
+		protected.GET("/projects/:project/apps/:app/promotion-policies/:channel", requirePull, h.handleGetPromotionPolicy)
+		protected.PUT("/projects/:project/apps/:app/promotion-policies/:channel", requireAdmin, h.handleSetPromotionPolicy)
+		protected.DELETE("/projects/:project/apps/:app/promotion-policies/:channel", requireAdmin, h.handleDeletePromotionPolicy)
+
+		protected.GET("/manifest/:project/:app/:hash", requirePull, h.handleGetManifest)
+		protected.GET("/file/:project/:app/:hash", requirePull, h.handleGetFile)
+		protected.HEAD("/file/:project/:app/:hash", requirePull, h.handleGetFile)
+		protected.GET("/archive/:project/:app/:version", requirePull, h.handleGetArchive)
 		
 		// Upload endpoints
-		protected.POST("/upload/init", h.handleInitUpload)
-		protected.POST("/file/:project/:app/:hash", h.handleUploadFile)
-		protected.POST("/upload/finish", h.handleFinishUpload)
+		protected.POST("/upload/init", requirePush, h.handleInitUpload)
+		protected.POST("/file/:project/:app/:hash", requirePush, h.handleUploadFile)
+		protected.POST("/upload/finish", requirePush, h.handleFinishUpload)
+		protected.POST("/upload/abort", requirePush, h.handleAbortUpload)
+		protected.POST("/upload/chunked/:project/:app/:version", requirePush, h.handleCreateChunkedUpload)
+		protected.GET("/upload/chunked/:project/:app/:version/:id", requirePush, h.handleGetChunkedUpload)
+		protected.PUT("/upload/chunked/:project/:app/:version/:id/chunks/:index", requirePush, h.handlePutChunk)
+		protected.POST("/upload/chunked/:project/:app/:version/:id/complete", requirePush, h.handleCompleteChunkedUpload)
 		
 		// Webhook endpoints
-		protected.POST("/webhooks", h.handleCreateWebhook)
-		protected.GET("/webhooks", h.handleListWebhooks)
-		protected.GET("/webhooks/:id", h.handleGetWebhook)
-		protected.PUT("/webhooks/:id", h.handleUpdateWebhook)
-		protected.DELETE("/webhooks/:id", h.handleDeleteWebhook)
+		protected.POST("/webhooks", requireAdmin, h.handleCreateWebhook)
+		protected.GET("/webhooks", requireAdmin, h.handleListWebhooks)
+		protected.GET("/webhooks/:id", requireAdmin, h.handleGetWebhook)
+		protected.PUT("/webhooks/:id", requireAdmin, h.handleUpdateWebhook)
+		protected.DELETE("/webhooks/:id", requireAdmin, h.handleDeleteWebhook)
+		protected.GET("/webhooks/:id/deliveries", requireAdmin, h.handleListWebhookDeliveries)
+		protected.GET("/webhooks/:id/deliveries/:delivery_id", requireAdmin, h.handleGetWebhookDelivery)
+		protected.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", requireAdmin, h.handleRedeliverWebhook)
+		protected.POST("/webhooks/:id/test", requireAdmin, h.handleTestWebhook)
 		
 		// Config endpoints
-		protected.GET("/config", h.handleGetConfig)
-		protected.PUT("/config", h.handleUpdateConfig)
+		protected.GET("/config", requireAdmin, h.handleGetConfig)
+		protected.PUT("/config", requireAdmin, h.handleUpdateConfig)
+
+		// Retention policy endpoints (project and app overrides of the global rules)
+		protected.GET("/retention-policies", requireAdmin, h.handleListRetentionPolicies)
+		protected.GET("/projects/:project/retention-policy", requireAdmin, h.handleGetRetentionPolicy)
+		protected.PUT("/projects/:project/retention-policy", requireAdmin, h.handleSetRetentionPolicy)
+		protected.DELETE("/projects/:project/retention-policy", requireAdmin, h.handleDeleteRetentionPolicy)
+		protected.GET("/projects/:project/apps/:app/retention-policy", requireAdmin, h.handleGetRetentionPolicy)
+		protected.PUT("/projects/:project/apps/:app/retention-policy", requireAdmin, h.handleSetRetentionPolicy)
+		protected.DELETE("/projects/:project/apps/:app/retention-policy", requireAdmin, h.handleDeleteRetentionPolicy)
 		
 		// Publish/Unpublish endpoints
-		protected.POST("/publish", h.handlePublish)
-		protected.POST("/unpublish", h.handleUnpublish)
+		protected.POST("/publish", requirePublish, h.handlePublish)
+		protected.POST("/unpublish", requirePublish, h.handleUnpublish)
+		protected.POST("/rollback", requirePublish, h.handleRollback)
+		protected.POST("/promote", requirePromote, h.handlePromote)
 		
 		// Audit logs endpoint
-		protected.GET("/audit-logs", h.handleListAuditLogs)
+		protected.GET("/audit-logs", requireAdmin, h.handleListAuditLogs)
+
+		// Live event stream (checks permissions and token scope itself)
+		protected.GET("/events/stream", h.handleEventStream)
 		
-		// Token management endpoints (list and delete require auth)
-		protected.GET("/tokens", h.handleListTokens)
-		protected.DELETE("/tokens/:id", h.handleDeleteToken)
+		// Token management endpoints
+		protected.POST("/tokens", requireAdmin, h.handleCreateToken)
+		protected.GET("/tokens", requireAdmin, h.handleListTokens)
+		protected.DELETE("/tokens/:id", requireAdmin, h.handleDeleteToken)
 		
 		// Storage sync endpoint (admin only - rebuilds database from storage)
-		protected.POST("/sync-storage", h.handleSyncStorage)
+		protected.POST("/sync-storage", requireAdmin, h.handleSyncStorage)
 		
-		// Admin inventory endpoints (require authentication)
-		admin := protected.Group("/admin")
+		// Admin inventory endpoints
+		admin := protected.Group("/admin", requireAdmin)
 		{
 			admin.GET("/inventory", h.handleGetInventory)
 			admin.GET("/inventory/:project", h.handleGetProjectInventory)
 			admin.GET("/inventory/summary", h.handleGetInventorySummary)
+
+			// Scheduled tasks
+			admin.GET("/tasks", h.handleListTasks)
+			admin.POST("/tasks/:name/run", h.handleRunTask)
+			admin.GET("/tasks/:name/runs", h.handleListTaskRuns)
+			admin.GET("/tasks/:name/runs/:id", h.handleGetTaskRun)
+
+			// Retention preview (what the next cleanup would delete)
+			admin.GET("/retention/preview", h.handleRetentionPreview)
 		}
 	}
 }
@@ -161,7 +233,7 @@ func (h *Handler) handleHealth(c *gin.Context) {
 
 // handleGetFile godoc
 // @Summary      Download file
-// @Description  Download a file from artifact storage. Supports HTTP Range requests for resumable downloads.
+// @Description  Download a file from artifact storage. Supports HTTP Range requests (including suffix and multiple ranges) for resumable downloads, with the file SHA256 as a strong ETag for If-None-Match and If-Range.
 // @Tags         artifacts
 // @Accept       json
 // @Produce      application/octet-stream
@@ -170,15 +242,21 @@ func (h *Handler) handleHealth(c *gin.Context) {
 // @Param        hash     path      string  true  "Version hash"
 // @Param        path     query     string  true  "File path within the artifact"
 // @Header       206      {string}  Content-Range  "Content-Range header for partial content"
+// @Param        Range          header  string  false  "Byte ranges, e.g. bytes=0-1023, bytes=-512 or bytes=0-99,200-299"
+// @Param        If-None-Match  header  string  false  "ETag from an earlier response"
+// @Param        If-Range       header  string  false  "Only return the range if the ETag still matches"
 // @Header       200,206  {string}  Accept-Ranges  "bytes"
+// @Header       200,206  {string}  ETag           "Quoted SHA256 of the file"
 // @Success      200      {file}    binary
 // @Success      206      {file}    binary  "Partial content"
+// @Success      304      "Not modified"
 // @Failure      401      {object}  ErrorResponse
 // @Failure      404      {object}  ErrorResponse
 // @Failure      416      {object}  ErrorResponse  "Range not satisfiable"
 // @Failure      500      {object}  ErrorResponse
 // @Security     Bearer
 // @Router       /file/{project}/{app}/{hash} [get]
+// @Router       /file/{project}/{app}/{hash} [head]
 func (h *Handler) handleGetFile(c *gin.Context) {
 	project := c.Param("project")
 	app := c.Param("app")
@@ -190,65 +268,34 @@ func (h *Handler) handleGetFile(c *gin.Context) {
 		return
 	}
 	
-	fullPath := project + "/" + app + "/" + hash + "/" + filePath
-	
-	// Handle HEAD request for file existence check
-	if c.Request.Method == "HEAD" {
-		exists, err := h.storage.Exists(c.Request.Context(), fullPath)
-		if err != nil || !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
-			return
-		}
-		c.Status(http.StatusOK)
+	fullPath, manifestFile, err := h.artifactManager.ResolveFile(c.Request.Context(), project, app, hash, filePath)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 	
-	reader, err := h.storage.Get(c.Request.Context(), fullPath)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	info, err := h.storage.Stat(c.Request.Context(), fullPath)
+	if err != nil || info.IsDir {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
 		return
 	}
-	defer reader.Close()
 	
-	// Support HTTP Range requests for resume download
-	rangeHeader := c.GetHeader("Range")
-	if rangeHeader != "" {
-		// Parse range header (e.g., "bytes=0-1023" or "bytes=1024-")
-		var start, end int64 = 0, -1
-		if len(rangeHeader) > 6 && rangeHeader[:6] == "bytes=" {
-			rangeStr := rangeHeader[6:]
-			parts := strings.Split(rangeStr, "-")
-			if len(parts) == 2 {
-				fmt.Sscanf(parts[0], "%d", &start)
-				if parts[1] != "" {
-					fmt.Sscanf(parts[1], "%d", &end)
-				}
-			}
-		}
-		
-		// Try to seek to start position if reader supports it
-		if seeker, ok := reader.(io.Seeker); ok {
-			if _, err := seeker.Seek(start, io.SeekStart); err == nil {
-				// Set Content-Range header for partial content
-				c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/*", start, end))
-				c.Header("Accept-Ranges", "bytes")
-				if end > 0 {
-					length := end - start + 1
-					c.DataFromReader(http.StatusPartialContent, length, "application/octet-stream", reader, nil)
-				} else {
-					c.DataFromReader(http.StatusPartialContent, -1, "application/octet-stream", reader, nil)
-				}
-				return
-			}
-		}
+	// The manifest SHA256 identifies the content exactly, so it is a strong ETag
+	// and lets clients resume with If-Range or revalidate with If-None-Match
+	if manifestFile != nil && manifestFile.SHA256 != "" {
+		c.Header("ETag", fmt.Sprintf("\"%s\"", manifestFile.SHA256))
 	}
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Cache-Control", "private, no-cache")
 	
 	// Note: Audit log for pull operation is recorded in handleGetManifest
 	// to ensure one record per version, not per file
 
-	// Full file download
-	c.Header("Accept-Ranges", "bytes")
-	c.DataFromReader(http.StatusOK, -1, "application/octet-stream", reader, nil)
+	// ServeContent handles HEAD, single, suffix and multi-part ranges, and the
+	// conditional headers; only the requested ranges are read from storage
+	reader := storage.NewRangeReader(c.Request.Context(), h.storage, fullPath, info.Size)
+	defer reader.Close()
+	http.ServeContent(c.Writer, c.Request, "", time.Unix(info.ModTime, 0), reader)
 }
 
 // authMiddleware is moved to auth.AuthMiddleware() which supports both JWT and API tokens
@@ -45,3 +45,8 @@ func getIntParam(c *gin.Context, key string, defaultValue int) int {
 	return intValue
 }
 
+// getBoolQuery gets a boolean query parameter such as ?dry_run=true
+func getBoolQuery(c *gin.Context, key string) bool {
+	value, err := strconv.ParseBool(c.Query(key))
+	return err == nil && value
+}
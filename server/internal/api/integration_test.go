@@ -0,0 +1,90 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package api
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/auth"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/database/dbtest"
+	"github.com/kk/kkartifact-server/internal/storage"
+)
+
+// newIntegrationHandler returns a handler on a fresh test database and local storage
+// Events are not published and the scheduler is not available.
+func newIntegrationHandler(t *testing.T) *Handler {
+	t.Helper()
+	localStorage, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	db := dbtest.Open(t)
+	artifactManager := storage.NewArtifactManager(localStorage, database.NewBlobRepository(db))
+	return NewHandler(db, localStorage, artifactManager, nil, nil, nil)
+}
+
+// callHandler runs a handler function with the given path params, e.g. "project", "shop"
+// The handler is called directly, so route middleware such as authentication does not run.
+func callHandler(handler gin.HandlerFunc, method, target, body string, params ...string) *httptest.ResponseRecorder {
+	c, recorder := newRequestContext(method, target, body, params...)
+	handler(c)
+	return recorder
+}
+
+// callHandlerAs runs a handler function like callHandler, signed in as a user
+func callHandlerAs(user *auth.SessionInfo, handler gin.HandlerFunc, method, target, body string, params ...string) *httptest.ResponseRecorder {
+	c, recorder := newRequestContext(method, target, body, params...)
+	c.Set("session_info", user)
+	handler(c)
+	return recorder
+}
+
+func newRequestContext(method, target, body string, params ...string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	c.Request = httptest.NewRequest(method, target, reader)
+	c.Request.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(params); i += 2 {
+		c.Params = append(c.Params, gin.Param{Key: params[i], Value: params[i+1]})
+	}
+	return c, recorder
+}
+
+// createTestVersion creates a version of project/app with an empty manifest and returns it
+func createTestVersion(t *testing.T, h *Handler, projectName, appName, hash string) (*database.App, *database.Version) {
+	t.Helper()
+	project, err := h.projectRepo.CreateOrGet(projectName)
+	if err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	app, err := h.appRepo.CreateOrGet(project.ID, appName)
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	version, err := h.versionRepo.Create(app.ID, hash)
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+	manifest := &storage.Manifest{Project: projectName, App: appName, Version: hash}
+	if err := h.artifactManager.CommitManifest(context.Background(), projectName, appName, hash, manifest); err != nil {
+		t.Fatalf("Failed to commit manifest: %v", err)
+	}
+	return app, version
+}
@@ -9,17 +9,17 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/kk/kkartifact-server/internal/database"
 )
 
-// handleGetLatestVersion returns the latest published version for an app
+// handleGetLatestVersion returns the latest published version for an app, or the version of a channel
 // handleGetLatestVersion godoc
 // @Summary      Get latest published version
-// @Description  Get the latest published version hash for a project/app
+// @Description  Get the latest published version hash for a project/app, or with channel the version that release channel points at
 // @Tags         artifacts
 // @Produce      json
-// @Param        project  path  string  true  "Project name"
-// @Param        app      path  string  true  "App name"
+// @Param        project  path   string  true   "Project name"
+// @Param        app      path   string  true   "App name"
+// @Param        channel  query  string  false  "Release channel (default: published)"
 // @Success      200      {object}  map[string]string
 // @Failure      404      {object}  ErrorResponse
 // @Failure      500      {object}  ErrorResponse
@@ -28,6 +28,7 @@ import (
 func (h *Handler) handleGetLatestVersion(c *gin.Context) {
 	projectName := c.Param("project")
 	appName := c.Param("app")
+	channel := c.Query("channel")
 
 	// Get project and app
 	project, err := h.projectRepo.GetByName(projectName)
@@ -42,18 +43,25 @@ func (h *Handler) handleGetLatestVersion(c *gin.Context) {
 		return
 	}
 
-	// Get latest published version
-	versionRepo := database.NewVersionRepository(h.db)
-	latestVersion, err := versionRepo.GetLatestPublished(app.ID)
+	// Get latest published version, or the version of the channel
+	version, err := h.channelVersion(app.ID, channel)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "no published version found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if version == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": channelNotSetError(channel)})
+		return
+	}
+	if channel == "" {
+		channel = DefaultChannel
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"project": projectName,
 		"app":     appName,
-		"version": latestVersion.Hash,
+		"channel": channel,
+		"version": version,
 	})
 }
 
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/storage"
 )
 
 // ManifestResponse represents a manifest in API response
@@ -58,38 +59,7 @@ func (h *Handler) handleGetManifest(c *gin.Context) {
 
 	// Record audit log for pull operation (one record per version)
 	// This is called when agent starts pulling a version, before downloading files
-	auditRepo := database.NewAuditRepository(h.db)
-	projectObj, err := h.projectRepo.CreateOrGet(project)
-	if err == nil {
-		var projectID *int
-		var appID *int
-		if projectObj != nil {
-			projectID = &projectObj.ID
-			appObj, err := h.appRepo.CreateOrGet(projectObj.ID, app)
-			if err == nil && appObj != nil {
-				appID = &appObj.ID
-			}
-		}
-		agentID := getAgentIDFromRequest(c)
-		metadata := map[string]interface{}{
-			"file_count": len(manifest.Files),
-		}
-		var totalSize int64
-		for _, file := range manifest.Files {
-			totalSize += file.Size
-		}
-		metadata["total_size"] = totalSize
-		if manifest.GitCommit != "" {
-			metadata["git_commit"] = manifest.GitCommit
-		}
-		if manifest.BuildTime != "" {
-			metadata["build_time"] = manifest.BuildTime
-		}
-		if manifest.Builder != "" {
-			metadata["builder"] = manifest.Builder
-		}
-		_ = auditRepo.Create("pull", projectID, appID, hash, agentID, metadata)
-	}
+	h.recordPull(c, project, app, hash, manifest, nil)
 
 	// Convert to response format
 	files := make([]ManifestFileResponse, len(manifest.Files))
@@ -114,3 +84,44 @@ func (h *Handler) handleGetManifest(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// recordPull records a pull audit log for a version with its manifest summary
+// extra is merged into the metadata and may be nil
+func (h *Handler) recordPull(c *gin.Context, project, app, version string, manifest *storage.Manifest, extra map[string]interface{}) {
+	auditRepo := database.NewAuditRepository(h.db)
+	projectObj, err := h.projectRepo.CreateOrGet(project)
+	if err != nil {
+		return
+	}
+
+	var projectID *int
+	var appID *int
+	if projectObj != nil {
+		projectID = &projectObj.ID
+		appObj, err := h.appRepo.CreateOrGet(projectObj.ID, app)
+		if err == nil && appObj != nil {
+			appID = &appObj.ID
+		}
+	}
+	agentID := getAgentIDFromRequest(c)
+	metadata := map[string]interface{}{
+		"file_count": len(manifest.Files),
+	}
+	var totalSize int64
+	for _, file := range manifest.Files {
+		totalSize += file.Size
+	}
+	metadata["total_size"] = totalSize
+	if manifest.GitCommit != "" {
+		metadata["git_commit"] = manifest.GitCommit
+	}
+	if manifest.BuildTime != "" {
+		metadata["build_time"] = manifest.BuildTime
+	}
+	if manifest.Builder != "" {
+		metadata["builder"] = manifest.Builder
+	}
+	for key, value := range extra {
+		metadata[key] = value
+	}
+	_ = auditRepo.Create("pull", projectID, appID, version, agentID, metadata)
+}
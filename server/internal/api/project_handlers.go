@@ -8,10 +8,12 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
 )
 
 // ProjectResponse represents a project in API response
@@ -31,11 +33,14 @@ type AppResponse struct {
 
 // VersionResponse represents a version in API response
 type VersionResponse struct {
-	ID          int    `json:"id"`
-	AppID       int    `json:"app_id"`
-	Version     string `json:"version"`      // Version identifier (same as hash in database)
-	IsPublished bool   `json:"is_published"` // Whether this version is published
-	CreatedAt   string `json:"created_at"`   // RFC3339 format
+	ID          int               `json:"id"`
+	AppID       int               `json:"app_id"`
+	Version     string            `json:"version"`            // Version identifier (same as hash in database)
+	IsPublished bool              `json:"is_published"`       // Whether this version is published
+	Pinned      bool              `json:"pinned"`             // Pinned versions are never pruned
+	Labels      map[string]string `json:"labels,omitempty"`   // e.g. {"branch": "main"}
+	Channels    []string          `json:"channels,omitempty"` // release channels pointing at this version
+	CreatedAt   string            `json:"created_at"`         // RFC3339 format
 }
 
 // handleListProjects godoc
@@ -107,15 +112,17 @@ func (h *Handler) handleListApps(c *gin.Context) {
 
 // handleListVersions godoc
 // @Summary      List versions
-// @Description  Get a list of all versions for a specific app
+// @Description  Get a list of all versions for a specific app, with their labels. label filters by key=value, or by key for any value; several filters must all match.
 // @Tags         projects
 // @Accept       json
 // @Produce      json
 // @Param        project  path      string  true   "Project name"
 // @Param        app      path      string  true   "App name"
+// @Param        label    query     string  false  "Only versions with these labels (key=value or key, comma-separated)"
 // @Param        limit    query     int     false  "Limit number of results (default: 50)"
 // @Param        offset   query     int     false  "Offset for pagination (default: 0)"
 // @Success      200      {array}   VersionResponse
+// @Failure      400      {object}  ErrorResponse
 // @Failure      401      {object}  ErrorResponse
 // @Failure      500      {object}  ErrorResponse
 // @Security     Bearer
@@ -126,6 +133,12 @@ func (h *Handler) handleListVersions(c *gin.Context) {
 	limit := getIntQuery(c, "limit", 50)
 	offset := getIntQuery(c, "offset", 0)
 
+	selectors, err := parseLabelSelectors(c.QueryArray("label"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	project, err := h.projectRepo.CreateOrGet(projectName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -138,7 +151,23 @@ func (h *Handler) handleListVersions(c *gin.Context) {
 		return
 	}
 
-	versions, err := h.versionRepo.ListByApp(app.ID, limit, offset)
+	var versions []*database.Version
+	if len(selectors) > 0 {
+		versions, err = h.versionRepo.ListByLabels(app.ID, selectors, limit, offset)
+	} else {
+		versions, err = h.versionRepo.ListByApp(app.ID, limit, offset)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	labels, err := h.versionRepo.ListLabelsByApp(app.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	channels, err := database.NewChannelRepository(h.db).ChannelsByVersion(app.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -152,6 +181,9 @@ func (h *Handler) handleListVersions(c *gin.Context) {
 			AppID:       v.AppID,
 			Version:     v.Hash, // Map hash field from database to version in API
 			IsPublished: v.IsPublished,
+			Pinned:      v.Pinned,
+			Labels:      labels[v.ID],
+			Channels:    channels[v.Hash],
 			CreatedAt:   v.CreatedAt.Format(time.RFC3339),
 		}
 	}
@@ -212,10 +244,8 @@ func (h *Handler) handleDeleteProject(c *gin.Context) {
 		_ = err
 	}
 
-	// Create audit log
-	auditRepo := database.NewAuditRepository(h.db)
-	projectID := project.ID
-	_ = auditRepo.Create("project_delete", &projectID, nil, "", "", map[string]interface{}{
+	h.publishScopedEventWithContext(c, &project.ID, nil, events.EventTypeProjectDeleted, projectName, "", "", "", map[string]interface{}{
+		"project_id":   project.ID,
 		"project_name": projectName,
 	})
 
@@ -264,13 +294,10 @@ func (h *Handler) handleDeleteApp(c *gin.Context) {
 		_ = err
 	}
 
-	// Create audit log
-	auditRepo := database.NewAuditRepository(h.db)
-	projectID := project.ID
-	appID := app.ID
-	_ = auditRepo.Create("app_delete", &projectID, &appID, "", "", map[string]interface{}{
+	h.publishScopedEventWithContext(c, &project.ID, &app.ID, events.EventTypeAppDeleted, projectName, appName, "", "", map[string]interface{}{
+		"app_id":       app.ID,
 		"project_name": projectName,
-		"app_name":      appName,
+		"app_name":     appName,
 	})
 
 	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
@@ -278,15 +305,17 @@ func (h *Handler) handleDeleteApp(c *gin.Context) {
 
 // handleDeleteVersion godoc
 // @Summary      Delete version
-// @Description  Delete a version
+// @Description  Delete a version. Pinned versions and versions on a release channel are only deleted with force=true, which also deletes those channels.
 // @Tags         projects
 // @Accept       json
 // @Produce      json
 // @Param        project  path      string  true   "Project name"
 // @Param        app      path      string  true   "App name"
 // @Param        version  path      string  true   "Version hash"
+// @Param        force    query     bool    false  "Delete the version even if it is pinned or on a channel"
 // @Success      200      {object}  map[string]string
 // @Failure      401      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
 // @Failure      500      {object}  ErrorResponse
 // @Security     Bearer
 // @Router       /projects/{project}/apps/{app}/versions/{version} [delete]
@@ -308,11 +337,42 @@ func (h *Handler) handleDeleteVersion(c *gin.Context) {
 		return
 	}
 
+	// Pinned versions and versions on a channel are kept unless the caller insists
+	force := getBoolQuery(c, "force")
+	version, err := h.versionRepo.GetByHash(app.ID, versionHash)
+	if err == nil && version.Pinned && !force {
+		c.JSON(http.StatusConflict, gin.H{"error": "version is pinned; unpin it or pass force=true to delete it"})
+		return
+	}
+	wasPublished := err == nil && version.IsPublished
+	channelRepo := database.NewChannelRepository(h.db)
+	channels, err := channelRepo.ChannelsByVersion(app.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if onChannels := channels[versionHash]; len(onChannels) > 0 {
+		if !force {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("version is on channel %s; move the channel or pass force=true to delete it", strings.Join(onChannels, ", "))})
+			return
+		}
+		if err := channelRepo.DeleteByVersion(app.ID, versionHash); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, channel := range onChannels {
+			h.recordChannelHistory(c, app.ID, channel, "", versionHash, database.ChannelActionDelete)
+		}
+	}
+
 	// Delete version from database
 	if err := h.versionRepo.Delete(app.ID, versionHash); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to delete version: %v", err)})
 		return
 	}
+	if wasPublished {
+		h.recordChannelHistory(c, app.ID, DefaultChannel, "", versionHash, database.ChannelActionDelete)
+	}
 
 	// Delete version from storage
 	if err := h.artifactManager.DeleteVersion(c.Request.Context(), projectName, appName, versionHash); err != nil {
@@ -320,14 +380,10 @@ func (h *Handler) handleDeleteVersion(c *gin.Context) {
 		_ = err
 	}
 
-	// Create audit log
-	auditRepo := database.NewAuditRepository(h.db)
-	projectID := project.ID
-	appID := app.ID
-	_ = auditRepo.Create("version_delete", &projectID, &appID, versionHash, "", map[string]interface{}{
+	h.publishEventWithContext(c, events.EventTypeVersionDeleted, projectName, appName, versionHash, "", map[string]interface{}{
 		"project_name": projectName,
-		"app_name":      appName,
-		"version":       versionHash,
+		"app_name":     appName,
+		"version":      versionHash,
 	})
 
 	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
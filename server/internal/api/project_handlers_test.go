@@ -0,0 +1,92 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kk/kkartifact-server/internal/database"
+)
+
+func TestHandleDeleteVersion_Pinned(t *testing.T) {
+	tests := []struct {
+		name        string
+		pinned      bool
+		query       string
+		wantStatus  int
+		wantDeleted bool
+	}{
+		{"unpinned", false, "", http.StatusOK, true},
+		{"pinned", true, "", http.StatusConflict, false},
+		{"pinned with force=false", true, "?force=false", http.StatusConflict, false},
+		{"pinned with force", true, "?force=true", http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newIntegrationHandler(t)
+			app, _ := createTestVersion(t, h, "shop", "api", "v1")
+			if err := h.versionRepo.SetPinned(app.ID, "v1", tt.pinned); err != nil {
+				t.Fatalf("Failed to pin version: %v", err)
+			}
+
+			w := callHandler(h.handleDeleteVersion, http.MethodDelete, "/api/v1/projects/shop/apps/api/versions/v1"+tt.query, "",
+				"project", "shop", "app", "api", "version", "v1")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			version, err := h.versionRepo.GetByHash(app.ID, "v1")
+			if deleted := err != nil || version == nil; deleted != tt.wantDeleted {
+				t.Errorf("Expected deleted=%v, got version %v, err %v", tt.wantDeleted, version, err)
+			}
+		})
+	}
+}
+
+func TestHandleDeleteVersion_OnChannel(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantDeleted bool
+	}{
+		{"without force", "", http.StatusConflict, false},
+		{"with force", "?force=true", http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newIntegrationHandler(t)
+			app, _ := createTestVersion(t, h, "shop", "api", "v1")
+			channelRepo := database.NewChannelRepository(h.db)
+			if _, err := channelRepo.Set(app.ID, "prod", "v1", "user:alice"); err != nil {
+				t.Fatalf("Failed to set channel: %v", err)
+			}
+
+			w := callHandler(h.handleDeleteVersion, http.MethodDelete, "/api/v1/projects/shop/apps/api/versions/v1"+tt.query, "",
+				"project", "shop", "app", "api", "version", "v1")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			_, err := h.versionRepo.GetByHash(app.ID, "v1")
+			if deleted := err != nil; deleted != tt.wantDeleted {
+				t.Errorf("Expected deleted=%v, got err %v", tt.wantDeleted, err)
+			}
+			channel, err := channelRepo.Get(app.ID, "prod")
+			if err != nil {
+				t.Fatalf("Failed to get channel: %v", err)
+			}
+			if (channel == nil) != tt.wantDeleted {
+				t.Errorf("Expected the channel to be cleared only with the version, got %+v", channel)
+			}
+		})
+	}
+}
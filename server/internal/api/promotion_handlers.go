@@ -0,0 +1,868 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/auth"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
+)
+
+// policyRejector is the rejected_by of a promotion that did not meet the channel's policy
+const policyRejector = "policy"
+
+// PromoteRequest represents a request to promote a version to a channel
+type PromoteRequest struct {
+	Project string `json:"project" binding:"required"`
+	App     string `json:"app" binding:"required"`
+	Channel string `json:"channel" binding:"required"`
+	Version string `json:"version"` // default: the version on the policy's from_channel
+}
+
+// RejectPromotionRequest represents a request to reject a pending promotion
+type RejectPromotionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// PromotionResponse represents a promotion request in API response
+type PromotionResponse struct {
+	ID                int      `json:"id"`
+	Channel           string   `json:"channel"`
+	Version           string   `json:"version"`
+	FromChannel       *string  `json:"from_channel,omitempty"`
+	Status            string   `json:"status"` // pending, approved or rejected
+	RequiredApprovals int      `json:"required_approvals"`
+	Approvers         []string `json:"approvers"`
+	RequestedBy       string   `json:"requested_by"`
+	DecidedBy         *string  `json:"decided_by,omitempty"`
+	Reason            *string  `json:"reason,omitempty"`
+	PreviousVersion   string   `json:"previous_version,omitempty"` // set when this request moved the channel
+	CreatedAt         string   `json:"created_at"`                 // RFC3339 format
+	DecidedAt         *string  `json:"decided_at,omitempty"`       // RFC3339 format
+}
+
+// PromotionListResponse represents the paginated promotion list API response
+type PromotionListResponse struct {
+	Data  []PromotionResponse `json:"data"`
+	Total int                 `json:"total"`
+}
+
+// PromotionPolicyRequest represents the rules a version must meet to be promoted to a channel
+type PromotionPolicyRequest struct {
+	FromChannel       string `json:"from_channel"`       // the version must be on this channel, e.g. staging
+	MinHours          int    `json:"min_hours"`          // hours on from_channel, or since the push without one
+	RequiredLabel     string `json:"required_label"`     // "key" or "key=value"
+	RequiredApprovals int    `json:"required_approvals"` // distinct approvers besides the requester
+}
+
+// PromotionPolicyResponse represents the promotion policy of a channel in API response
+type PromotionPolicyResponse struct {
+	Channel           string  `json:"channel"`
+	FromChannel       *string `json:"from_channel,omitempty"`
+	MinHours          int     `json:"min_hours"`
+	RequiredLabel     *string `json:"required_label,omitempty"`
+	RequiredApprovals int     `json:"required_approvals"`
+	UpdatedAt         string  `json:"updated_at"` // RFC3339 format
+}
+
+// handlePromote godoc
+// @Summary      Promote version
+// @Description  Promote a version to a release channel under the channel's promotion policy. Without a version, the version on the policy's from_channel is promoted. A version that does not meet the policy is rejected with the unmet rules. If the policy requires approvals, a pending promotion is created (202) and the channel moves once enough users approve it; otherwise the channel moves at once. Channels without a policy are promoted to at once.
+// @Tags         promotions
+// @Accept       json
+// @Produce      json
+// @Param        request  body      PromoteRequest  true  "Promote request"
+// @Success      200      {object}  PromotionResponse
+// @Success      202      {object}  PromotionResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /promote [post]
+func (h *Handler) handlePromote(c *gin.Context) {
+	var req PromoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateLabelKey(req.Channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("channel name %v", err)})
+		return
+	}
+
+	project, err := h.projectRepo.GetByName(req.Project)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+	app, err := h.appRepo.GetByName(project.ID, req.App)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "app not found"})
+		return
+	}
+
+	promotionRepo := database.NewPromotionRepository(h.db)
+	policy, err := promotionRepo.GetPolicy(app.ID, req.Channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Version == "" {
+		if policy == nil || !policy.FromChannel.Valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("version is required: channel %s has no from_channel to promote from", req.Channel)})
+			return
+		}
+		req.Version, err = h.channelVersion(app.ID, policy.FromChannel.String)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Version == "" {
+			c.JSON(http.StatusConflict, gin.H{"error": channelNotSetError(policy.FromChannel.String)})
+			return
+		}
+	}
+
+	version, err := h.versionRepo.GetByHash(app.ID, req.Version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+		return
+	}
+	if _, err := h.artifactManager.GetManifest(c.Request.Context(), req.Project, req.App, req.Version); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "version not found in storage"})
+		return
+	}
+
+	current, err := h.channelVersion(app.ID, req.Channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if current == req.Version {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("version %s is already on channel %s", req.Version, req.Channel)})
+		return
+	}
+
+	actor := getActorFromRequest(c)
+	promotion := &database.Promotion{
+		AppID:       app.ID,
+		Channel:     req.Channel,
+		Version:     req.Version,
+		Status:      database.PromotionApproved,
+		RequestedBy: actor,
+	}
+
+	if policy != nil {
+		promotion.FromChannel = policy.FromChannel
+		promotion.RequiredApprovals = policy.RequiredApprovals
+
+		unmet, err := h.unmetPromotionRules(app.ID, policy, version)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(unmet) > 0 {
+			metadata := promotionMetadata(promotion)
+			metadata["requested_by"] = actor
+			metadata["rejected_by"] = policyRejector
+			metadata["unmet"] = unmet
+			h.publishEventWithContext(c, events.EventTypePromotionRejected, req.Project, req.App, req.Version, "", metadata)
+
+			c.JSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("version %s does not meet the promotion policy of channel %s", req.Version, req.Channel),
+				"unmet": unmet,
+			})
+			return
+		}
+	}
+
+	if promotion.RequiredApprovals > 0 {
+		pending, err := promotionRepo.GetPending(app.ID, req.Channel, req.Version)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if pending != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("promotion %d of version %s to channel %s is already pending", pending.ID, req.Version, req.Channel)})
+			return
+		}
+
+		promotion.Status = database.PromotionPending
+		created, err := promotionRepo.Create(promotion)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		metadata := promotionMetadata(created)
+		metadata["requested_by"] = actor
+		h.publishEventWithContext(c, events.EventTypePromotionRequested, req.Project, req.App, req.Version, "", metadata)
+
+		c.JSON(http.StatusAccepted, toPromotionResponse(created))
+		return
+	}
+
+	// No approvals needed: move the channel, then record the promotion
+	previous, err := h.completePromotion(c, req.Project, req.App, promotion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	promotion.DecidedBy = toNullString(actor)
+	created, err := promotionRepo.Create(promotion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := toPromotionResponse(created)
+	response.PreviousVersion = previous
+	c.JSON(http.StatusOK, response)
+}
+
+// handleListPromotions godoc
+// @Summary      List promotions
+// @Description  Get the promotion requests of an app, newest first. Returns paginated results with total count.
+// @Tags         promotions
+// @Produce      json
+// @Param        project  path      string  true   "Project name"
+// @Param        app      path      string  true   "App name"
+// @Param        status   query     string  false  "Filter by status (pending, approved or rejected)"
+// @Param        limit    query     int     false  "Limit number of results (default: 50)"
+// @Param        offset   query     int     false  "Offset for pagination (default: 0)"
+// @Success      200      {object}  PromotionListResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/promotions [get]
+func (h *Handler) handleListPromotions(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", database.PromotionPending, database.PromotionApproved, database.PromotionRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid status %q: must be pending, approved or rejected", status)})
+		return
+	}
+
+	app, ok := h.findApp(c)
+	if !ok {
+		return
+	}
+
+	limit := getIntQuery(c, "limit", 50)
+	offset := getIntQuery(c, "offset", 0)
+
+	promotions, total, err := database.NewPromotionRepository(h.db).List(app.ID, status, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responses := make([]PromotionResponse, len(promotions))
+	for i, promotion := range promotions {
+		responses[i] = toPromotionResponse(promotion)
+	}
+
+	c.JSON(http.StatusOK, PromotionListResponse{
+		Data:  responses,
+		Total: total,
+	})
+}
+
+// handleGetPromotion godoc
+// @Summary      Get promotion
+// @Description  Get a promotion request of an app with its approvers
+// @Tags         promotions
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        id       path      int     true  "Promotion ID"
+// @Success      200      {object}  PromotionResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/promotions/{id} [get]
+func (h *Handler) handleGetPromotion(c *gin.Context) {
+	_, promotion, ok := h.findPromotion(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toPromotionResponse(promotion))
+}
+
+// handleApprovePromotion godoc
+// @Summary      Approve promotion
+// @Description  Approve a pending promotion request. Only Web UI users can approve; the requester cannot approve their own request, and each user approves once. The policy is checked again on the final approval and the request is rejected if the version no longer meets it. The channel moves to the version once the request has the approvals its policy requires.
+// @Tags         promotions
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        id       path      int     true  "Promotion ID"
+// @Success      200      {object}  PromotionResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/promotions/{id}/approve [post]
+func (h *Handler) handleApprovePromotion(c *gin.Context) {
+	app, promotion, ok := h.findPromotion(c)
+	if !ok {
+		return
+	}
+	if promotion.Status != database.PromotionPending {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("promotion %d is already %s", promotion.ID, promotion.Status)})
+		return
+	}
+
+	// API tokens have no owning user, so only Web UI users can approve and
+	// each approval counts one distinct user
+	if auth.GetSessionInfo(c) == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "promotions can only be approved by users, not API tokens"})
+		return
+	}
+	actor := getActorFromRequest(c)
+	if actor == promotion.RequestedBy {
+		c.JSON(http.StatusForbidden, gin.H{"error": "a promotion cannot be approved by its requester"})
+		return
+	}
+
+	promotionRepo := database.NewPromotionRepository(h.db)
+	added, approvals, err := promotionRepo.AddApproval(promotion.ID, actor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !added {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("promotion %d is already approved by %s", promotion.ID, actor)})
+		return
+	}
+
+	projectName, appName := c.Param("project"), c.Param("app")
+	metadata := promotionMetadata(promotion)
+	metadata["approved_by"] = actor
+	metadata["approvals"] = fmt.Sprintf("%d/%d", approvals, promotion.RequiredApprovals)
+	h.publishEventWithContext(c, events.EventTypePromotionApproved, projectName, appName, promotion.Version, "", metadata)
+
+	var previous string
+	if approvals >= promotion.RequiredApprovals {
+		// The version may have been deleted while the promotion was pending
+		version, err := h.versionRepo.GetByHash(app.ID, promotion.Version)
+		if err != nil {
+			h.rejectPromotion(c, promotion, policyRejector, fmt.Sprintf("version %s no longer exists", promotion.Version))
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("version %s no longer exists; promotion %d is rejected", promotion.Version, promotion.ID)})
+			return
+		}
+
+		// The version may no longer meet the policy, e.g. it left the from_channel
+		policy, err := promotionRepo.GetPolicy(app.ID, promotion.Channel)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if policy != nil {
+			unmet, err := h.unmetPromotionRules(app.ID, policy, version)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if len(unmet) > 0 {
+				h.rejectPromotion(c, promotion, policyRejector, strings.Join(unmet, "; "))
+				c.JSON(http.StatusConflict, gin.H{
+					"error": fmt.Sprintf("version %s no longer meets the promotion policy of channel %s; promotion %d is rejected", promotion.Version, promotion.Channel, promotion.ID),
+					"unmet": unmet,
+				})
+				return
+			}
+		}
+
+		decided, err := promotionRepo.Decide(promotion.ID, database.PromotionApproved, actor, "")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !decided {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("promotion %d was decided concurrently", promotion.ID)})
+			return
+		}
+		if previous, err = h.completePromotion(c, projectName, appName, promotion); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	updated, err := promotionRepo.Get(app.ID, promotion.ID)
+	if err != nil || updated == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to reload promotion %d: %v", promotion.ID, err)})
+		return
+	}
+	response := toPromotionResponse(updated)
+	response.PreviousVersion = previous
+	c.JSON(http.StatusOK, response)
+}
+
+// handleRejectPromotion godoc
+// @Summary      Reject promotion
+// @Description  Reject a pending promotion request; the channel is left as it is. The requester may reject their own request to withdraw it.
+// @Tags         promotions
+// @Accept       json
+// @Produce      json
+// @Param        project  path      string                  true   "Project name"
+// @Param        app      path      string                  true   "App name"
+// @Param        id       path      int                     true   "Promotion ID"
+// @Param        request  body      RejectPromotionRequest  false  "Reason"
+// @Success      200      {object}  PromotionResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/promotions/{id}/reject [post]
+func (h *Handler) handleRejectPromotion(c *gin.Context) {
+	var req RejectPromotionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	app, promotion, ok := h.findPromotion(c)
+	if !ok {
+		return
+	}
+	if promotion.Status != database.PromotionPending {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("promotion %d is already %s", promotion.ID, promotion.Status)})
+		return
+	}
+
+	decided, err := h.rejectPromotion(c, promotion, getActorFromRequest(c), strings.TrimSpace(req.Reason))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !decided {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("promotion %d was decided concurrently", promotion.ID)})
+		return
+	}
+
+	updated, err := database.NewPromotionRepository(h.db).Get(app.ID, promotion.ID)
+	if err != nil || updated == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to reload promotion %d: %v", promotion.ID, err)})
+		return
+	}
+	c.JSON(http.StatusOK, toPromotionResponse(updated))
+}
+
+// handleListPromotionPolicies godoc
+// @Summary      List promotion policies
+// @Description  List the promotion policies of the channels of an app
+// @Tags         promotions
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Success      200      {array}   PromotionPolicyResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/promotion-policies [get]
+func (h *Handler) handleListPromotionPolicies(c *gin.Context) {
+	app, ok := h.findApp(c)
+	if !ok {
+		return
+	}
+
+	policies, err := database.NewPromotionRepository(h.db).ListPolicies(app.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responses := make([]PromotionPolicyResponse, len(policies))
+	for i, policy := range policies {
+		responses[i] = toPromotionPolicyResponse(policy)
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// handleGetPromotionPolicy godoc
+// @Summary      Get promotion policy
+// @Description  Get the promotion policy of a channel of an app
+// @Tags         promotions
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        channel  path      string  true  "Channel name"
+// @Success      200      {object}  PromotionPolicyResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/promotion-policies/{channel} [get]
+func (h *Handler) handleGetPromotionPolicy(c *gin.Context) {
+	app, ok := h.findApp(c)
+	if !ok {
+		return
+	}
+
+	policy, err := database.NewPromotionRepository(h.db).GetPolicy(app.ID, c.Param("channel"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if policy == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "promotion policy not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toPromotionPolicyResponse(policy))
+}
+
+// handleSetPromotionPolicy godoc
+// @Summary      Set promotion policy
+// @Description  Set the rules a version must meet to be promoted to a channel of an app: be on from_channel (for at least min_hours), carry required_label, and be approved by required_approvals users besides the requester. Once a channel has a policy, it can only be moved by POST /promote (or a rollback).
+// @Tags         promotions
+// @Accept       json
+// @Produce      json
+// @Param        project  path      string                  true  "Project name"
+// @Param        app      path      string                  true  "App name"
+// @Param        channel  path      string                  true  "Channel name"
+// @Param        request  body      PromotionPolicyRequest  true  "Promotion policy"
+// @Success      200      {object}  PromotionPolicyResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/promotion-policies/{channel} [put]
+func (h *Handler) handleSetPromotionPolicy(c *gin.Context) {
+	var req PromotionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	channel := c.Param("channel")
+	if err := validateLabelKey(channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("channel name %v", err)})
+		return
+	}
+	policy := &database.PromotionPolicy{
+		Channel:           channel,
+		MinHours:          req.MinHours,
+		RequiredApprovals: req.RequiredApprovals,
+	}
+	if req.MinHours < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_hours must not be negative"})
+		return
+	}
+	if req.RequiredApprovals < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "required_approvals must not be negative"})
+		return
+	}
+	if from := strings.TrimSpace(req.FromChannel); from != "" {
+		if err := validateLabelKey(from); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("from_channel %v", err)})
+			return
+		}
+		if from == channel {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from_channel must differ from the channel"})
+			return
+		}
+		policy.FromChannel = toNullString(from)
+	}
+	if label := strings.TrimSpace(req.RequiredLabel); label != "" {
+		key, value, _ := strings.Cut(label, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if err := validateLabelKey(key); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("required_label key %v", err)})
+			return
+		}
+		if value != "" {
+			if err := validateLabelValue(value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("required_label value %v", err)})
+				return
+			}
+		}
+		policy.RequiredLabelKey = toNullString(key)
+		policy.RequiredLabelValue = toNullString(value)
+	}
+
+	app, ok := h.findApp(c)
+	if !ok {
+		return
+	}
+	policy.AppID = app.ID
+
+	saved, err := database.NewPromotionRepository(h.db).SetPolicy(policy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response := toPromotionPolicyResponse(saved)
+
+	h.publishEventWithContext(c, events.EventTypeConfigUpdated, c.Param("project"), c.Param("app"), "", "", map[string]interface{}{
+		"changes": map[string]interface{}{"promotion_policy." + channel: response},
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// handleDeletePromotionPolicy godoc
+// @Summary      Delete promotion policy
+// @Description  Remove the promotion policy of a channel of an app, so it can be set directly again. Pending promotions to the channel stay pending.
+// @Tags         promotions
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        channel  path      string  true  "Channel name"
+// @Success      200      {object}  map[string]string
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/promotion-policies/{channel} [delete]
+func (h *Handler) handleDeletePromotionPolicy(c *gin.Context) {
+	app, ok := h.findApp(c)
+	if !ok {
+		return
+	}
+
+	channel := c.Param("channel")
+	deleted, err := database.NewPromotionRepository(h.db).DeletePolicy(app.ID, channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "promotion policy not found"})
+		return
+	}
+
+	h.publishEventWithContext(c, events.EventTypeConfigUpdated, c.Param("project"), c.Param("app"), "", "", map[string]interface{}{
+		"changes": map[string]interface{}{"promotion_policy." + channel: nil},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// unmetPromotionRules lists the rules of a promotion policy a version does not meet
+// Approvals are not checked here; they are collected after the request.
+func (h *Handler) unmetPromotionRules(appID int, policy *database.PromotionPolicy, version *database.Version) ([]string, error) {
+	var unmet []string
+	minAge := time.Duration(policy.MinHours) * time.Hour
+
+	if policy.FromChannel.Valid {
+		from := policy.FromChannel.String
+		onChannel, err := h.channelVersion(appID, from)
+		if err != nil {
+			return nil, err
+		}
+		if onChannel != version.Hash {
+			unmet = append(unmet, fmt.Sprintf("version is not on channel %s", from))
+		} else if minAge > 0 {
+			since, ok, err := database.NewChannelRepository(h.db).OnChannelSince(appID, from, version.Hash)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				since = time.Now()
+			}
+			if age := time.Since(since); age < minAge {
+				unmet = append(unmet, fmt.Sprintf("version has been on channel %s for %s; needs %dh", from, age.Truncate(time.Minute), policy.MinHours))
+			}
+		}
+	} else if minAge > 0 {
+		if age := time.Since(version.CreatedAt); age < minAge {
+			unmet = append(unmet, fmt.Sprintf("version was pushed %s ago; needs %dh", age.Truncate(time.Minute), policy.MinHours))
+		}
+	}
+
+	if policy.RequiredLabelKey.Valid {
+		labels, err := h.versionRepo.GetLabels(version.ID)
+		if err != nil {
+			return nil, err
+		}
+		key := policy.RequiredLabelKey.String
+		value, ok := labels[key]
+		switch {
+		case !ok:
+			unmet = append(unmet, fmt.Sprintf("version has no label %s", key))
+		case policy.RequiredLabelValue.Valid && value != policy.RequiredLabelValue.String:
+			unmet = append(unmet, fmt.Sprintf("version label %s is %s; needs %s", key, value, policy.RequiredLabelValue.String))
+		}
+	}
+
+	return unmet, nil
+}
+
+// completePromotion moves the channel of an approved promotion to its version
+// It returns the version the channel pointed at before, if any.
+func (h *Handler) completePromotion(c *gin.Context, projectName, appName string, promotion *database.Promotion) (string, error) {
+	_, previous, err := h.setChannelVersion(c, promotion.AppID, promotion.Channel, promotion.Version, database.ChannelActionPromote)
+	if err != nil {
+		return "", err
+	}
+
+	metadata := promotionMetadata(promotion)
+	metadata["requested_by"] = promotion.RequestedBy
+	if previous != "" {
+		metadata["previous_version"] = previous
+	}
+	h.publishEventWithContext(c, events.EventTypeVersionPromoted, projectName, appName, promotion.Version, "", metadata)
+	if promotion.Channel == DefaultChannel {
+		h.publishEventWithContext(c, events.EventTypeVersionPublished, projectName, appName, promotion.Version, "", map[string]interface{}{
+			"target_version": promotion.Version,
+		})
+	}
+	return previous, nil
+}
+
+// rejectPromotion rejects a pending promotion and publishes the decision
+// Returns false if the promotion was no longer pending.
+func (h *Handler) rejectPromotion(c *gin.Context, promotion *database.Promotion, rejectedBy, reason string) (bool, error) {
+	decided, err := database.NewPromotionRepository(h.db).Decide(promotion.ID, database.PromotionRejected, rejectedBy, reason)
+	if err != nil || !decided {
+		return false, err
+	}
+
+	metadata := promotionMetadata(promotion)
+	metadata["rejected_by"] = rejectedBy
+	if reason != "" {
+		metadata["reason"] = reason
+	}
+	h.publishEventWithContext(c, events.EventTypePromotionRejected, c.Param("project"), c.Param("app"), promotion.Version, "", metadata)
+	return true, nil
+}
+
+// findPromotion looks up the promotion named by the :project, :app and :id path params
+// It writes the error response and returns false if it does not exist.
+func (h *Handler) findPromotion(c *gin.Context) (*database.App, *database.Promotion, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid promotion ID"})
+		return nil, nil, false
+	}
+	app, ok := h.findApp(c)
+	if !ok {
+		return nil, nil, false
+	}
+	promotion, err := database.NewPromotionRepository(h.db).Get(app.ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	if promotion == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "promotion not found"})
+		return nil, nil, false
+	}
+	return app, promotion, true
+}
+
+// requireNoPromotionPolicy rejects moving a channel directly when it has a promotion policy
+// It writes the error response and returns false if the channel has one.
+func (h *Handler) requireNoPromotionPolicy(c *gin.Context, appID int, channel string) bool {
+	policy, err := database.NewPromotionRepository(h.db).GetPolicy(appID, channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if policy != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("channel %s has a promotion policy; promote to it with POST /api/v1/promote", channel)})
+		return false
+	}
+	return true
+}
+
+// promotionMetadata is the event metadata shared by the events of a promotion
+func promotionMetadata(promotion *database.Promotion) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"channel": promotion.Channel,
+	}
+	if promotion.ID != 0 {
+		metadata["promotion_id"] = promotion.ID
+	}
+	if promotion.FromChannel.Valid {
+		metadata["from_channel"] = promotion.FromChannel.String
+	}
+	return metadata
+}
+
+func toPromotionResponse(promotion *database.Promotion) PromotionResponse {
+	response := PromotionResponse{
+		ID:                promotion.ID,
+		Channel:           promotion.Channel,
+		Version:           promotion.Version,
+		Status:            promotion.Status,
+		RequiredApprovals: promotion.RequiredApprovals,
+		Approvers:         promotion.Approvers,
+		RequestedBy:       promotion.RequestedBy,
+		CreatedAt:         promotion.CreatedAt.Format(time.RFC3339),
+	}
+	if response.Approvers == nil {
+		response.Approvers = []string{}
+	}
+	if promotion.FromChannel.Valid {
+		response.FromChannel = &promotion.FromChannel.String
+	}
+	if promotion.DecidedBy.Valid {
+		response.DecidedBy = &promotion.DecidedBy.String
+	}
+	if promotion.Reason.Valid {
+		response.Reason = &promotion.Reason.String
+	}
+	if promotion.DecidedAt.Valid {
+		decidedAt := promotion.DecidedAt.Time.Format(time.RFC3339)
+		response.DecidedAt = &decidedAt
+	}
+	return response
+}
+
+func toPromotionPolicyResponse(policy *database.PromotionPolicy) PromotionPolicyResponse {
+	response := PromotionPolicyResponse{
+		Channel:           policy.Channel,
+		MinHours:          policy.MinHours,
+		RequiredApprovals: policy.RequiredApprovals,
+		UpdatedAt:         policy.UpdatedAt.Format(time.RFC3339),
+	}
+	if policy.FromChannel.Valid {
+		response.FromChannel = &policy.FromChannel.String
+	}
+	if policy.RequiredLabelKey.Valid {
+		label := policy.RequiredLabelKey.String
+		if policy.RequiredLabelValue.Valid {
+			label += "=" + policy.RequiredLabelValue.String
+		}
+		response.RequiredLabel = &label
+	}
+	return response
+}
@@ -0,0 +1,430 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kk/kkartifact-server/internal/auth"
+	"github.com/kk/kkartifact-server/internal/database"
+)
+
+var (
+	alice = &auth.SessionInfo{UserID: 1, Username: "alice"}
+	bob   = &auth.SessionInfo{UserID: 2, Username: "bob"}
+	carol = &auth.SessionInfo{UserID: 3, Username: "carol"}
+)
+
+func setPromotionPolicy(t *testing.T, h *Handler, channel, body string) {
+	t.Helper()
+	w := callHandler(h.handleSetPromotionPolicy, http.MethodPut, "/api/v1/projects/shop/apps/api/promotion-policies/"+channel, body,
+		"project", "shop", "app", "api", "channel", channel)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 setting the policy of %s, got %d: %s", channel, w.Code, w.Body.String())
+	}
+}
+
+func promoteAs(user *auth.SessionInfo, h *Handler, channel, version string) (int, PromotionResponse, map[string]interface{}) {
+	body := fmt.Sprintf(`{"project":"shop","app":"api","channel":%q,"version":%q}`, channel, version)
+	w := callHandlerAs(user, h.handlePromote, http.MethodPost, "/api/v1/promote", body)
+	var response PromotionResponse
+	var raw map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	json.Unmarshal(w.Body.Bytes(), &raw)
+	return w.Code, response, raw
+}
+
+func approveAs(user *auth.SessionInfo, h *Handler, id int) (int, PromotionResponse) {
+	w := callHandlerAs(user, h.handleApprovePromotion, http.MethodPost, fmt.Sprintf("/api/v1/projects/shop/apps/api/promotions/%d/approve", id), "",
+		"project", "shop", "app", "api", "id", strconv.Itoa(id))
+	var response PromotionResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func rejectAs(user *auth.SessionInfo, h *Handler, id int, body string) (int, PromotionResponse) {
+	w := callHandlerAs(user, h.handleRejectPromotion, http.MethodPost, fmt.Sprintf("/api/v1/projects/shop/apps/api/promotions/%d/reject", id), body,
+		"project", "shop", "app", "api", "id", strconv.Itoa(id))
+	var response PromotionResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func channelAt(t *testing.T, h *Handler, appID int, channel string) string {
+	t.Helper()
+	version, err := h.channelVersion(appID, channel)
+	if err != nil {
+		t.Fatalf("Failed to get channel %s: %v", channel, err)
+	}
+	return version
+}
+
+func TestUnmetPromotionRules(t *testing.T) {
+	h := newIntegrationHandler(t)
+	app, fresh := createTestVersion(t, h, "shop", "api", "fresh")
+	_, old := createTestVersion(t, h, "shop", "api", "old")
+	_, labeled := createTestVersion(t, h, "shop", "api", "labeled")
+	_, staged := createTestVersion(t, h, "shop", "api", "staged")
+
+	// Far enough in the past to be clear of any time zone offset
+	if _, err := h.db.Exec(`UPDATE versions SET created_at = created_at - INTERVAL '48 hours' WHERE id = $1`, old.ID); err != nil {
+		t.Fatalf("Failed to backdate version: %v", err)
+	}
+	old, _ = h.versionRepo.GetByHash(app.ID, "old")
+	if err := h.versionRepo.SetLabels(labeled.ID, map[string]string{"qa": "passed"}); err != nil {
+		t.Fatalf("Failed to label version: %v", err)
+	}
+	if status := setChannel(h, "staging", "staged"); status != http.StatusOK {
+		t.Fatalf("Expected status 200 setting staging, got %d", status)
+	}
+
+	policy := func(from string, minHours int, labelKey, labelValue string) *database.PromotionPolicy {
+		return &database.PromotionPolicy{
+			AppID:              app.ID,
+			Channel:            "prod",
+			FromChannel:        toNullString(from),
+			MinHours:           minHours,
+			RequiredLabelKey:   toNullString(labelKey),
+			RequiredLabelValue: toNullString(labelValue),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		policy  *database.PromotionPolicy
+		version *database.Version
+		unmet   []string // substrings of the unmet rules, in order
+	}{
+		{"no rules", policy("", 0, "", ""), fresh, nil},
+		{"min_hours since the push not met", policy("", 24, "", ""), fresh, []string{"version was pushed"}},
+		{"min_hours since the push met", policy("", 24, "", ""), old, nil},
+		{"from_channel not met", policy("staging", 0, "", ""), fresh, []string{"version is not on channel staging"}},
+		{"from_channel met", policy("staging", 0, "", ""), staged, nil},
+		{"min_hours on from_channel not met", policy("staging", 24, "", ""), staged, []string{"version has been on channel staging for"}},
+		{"min_hours not checked off from_channel", policy("staging", 24, "", ""), old, []string{"version is not on channel staging"}},
+		{"required_label missing", policy("", 0, "qa", ""), fresh, []string{"version has no label qa"}},
+		{"required_label any value", policy("", 0, "qa", ""), labeled, nil},
+		{"required_label value met", policy("", 0, "qa", "passed"), labeled, nil},
+		{"required_label value not met", policy("", 0, "qa", "signed-off"), labeled, []string{"version label qa is passed; needs signed-off"}},
+		{"every rule unmet", policy("staging", 24, "qa", ""), fresh, []string{"version is not on channel staging", "version has no label qa"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unmet, err := h.unmetPromotionRules(app.ID, tt.policy, tt.version)
+			if err != nil {
+				t.Fatalf("unmetPromotionRules() error = %v", err)
+			}
+			if len(unmet) != len(tt.unmet) {
+				t.Fatalf("Expected %d unmet rules, got %v", len(tt.unmet), unmet)
+			}
+			for i, rule := range tt.unmet {
+				if !strings.Contains(unmet[i], rule) {
+					t.Errorf("Expected unmet rule %d to contain %q, got %q", i, rule, unmet[i])
+				}
+			}
+		})
+	}
+
+	// Time on from_channel counts from when the version went onto it
+	if _, err := h.db.Exec(`UPDATE channel_history SET created_at = created_at - INTERVAL '48 hours' WHERE app_id = $1 AND channel = 'staging'`, app.ID); err != nil {
+		t.Fatalf("Failed to backdate channel history: %v", err)
+	}
+	if unmet, err := h.unmetPromotionRules(app.ID, policy("staging", 24, "", ""), staged); err != nil || len(unmet) != 0 {
+		t.Errorf("Expected min_hours on staging to be met, got %v (err %v)", unmet, err)
+	}
+}
+
+func TestHandlePromote_WithoutPolicy(t *testing.T) {
+	h := newIntegrationHandler(t)
+	app, _ := createTestVersion(t, h, "shop", "api", "v1")
+
+	status, response, _ := promoteAs(alice, h, "staging", "v1")
+	if status != http.StatusOK || response.Status != database.PromotionApproved || response.RequestedBy != "user:alice" {
+		t.Fatalf("Expected an approved promotion, got %d %+v", status, response)
+	}
+	if version := channelAt(t, h, app.ID, "staging"); version != "v1" {
+		t.Errorf("Expected staging at v1, got %q", version)
+	}
+
+	if status, _, _ := promoteAs(alice, h, "staging", "v1"); status != http.StatusConflict {
+		t.Errorf("Expected 409 promoting the version on the channel, got %d", status)
+	}
+	if status, _, _ := promoteAs(alice, h, "staging", "missing"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing version, got %d", status)
+	}
+	// Without a policy there is no from_channel to take the version from
+	if status, _, _ := promoteAs(alice, h, "prod", ""); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a version, got %d", status)
+	}
+}
+
+func TestHandlePromote_Policy(t *testing.T) {
+	h := newIntegrationHandler(t)
+	app, _ := createTestVersion(t, h, "shop", "api", "v1")
+	createTestVersion(t, h, "shop", "api", "v2")
+	setPromotionPolicy(t, h, "prod", `{"from_channel":"staging"}`)
+
+	// Nothing on staging to promote
+	if status, _, _ := promoteAs(alice, h, "prod", ""); status != http.StatusConflict {
+		t.Errorf("Expected 409 with staging unset, got %d", status)
+	}
+
+	setChannel(h, "staging", "v1")
+	status, _, raw := promoteAs(alice, h, "prod", "v2")
+	if status != http.StatusConflict || raw["unmet"] == nil {
+		t.Fatalf("Expected 409 with the unmet rules, got %d %v", status, raw)
+	}
+	if _, total, err := database.NewPromotionRepository(h.db).List(app.ID, "", 10, 0); err != nil || total != 0 {
+		t.Errorf("Expected no promotion to be recorded, got %d (err %v)", total, err)
+	}
+
+	// The version on from_channel is promoted by default
+	status, response, _ := promoteAs(alice, h, "prod", "")
+	if status != http.StatusOK || response.Version != "v1" {
+		t.Fatalf("Expected v1 to be promoted, got %d %+v", status, response)
+	}
+	if version := channelAt(t, h, app.ID, "prod"); version != "v1" {
+		t.Errorf("Expected prod at v1, got %q", version)
+	}
+
+	// A channel with a policy cannot be set directly
+	if status := setChannel(h, "prod", "v2"); status != http.StatusConflict {
+		t.Errorf("Expected 409 setting prod directly, got %d", status)
+	}
+}
+
+func TestHandleApprovePromotion(t *testing.T) {
+	h := newIntegrationHandler(t)
+	app, _ := createTestVersion(t, h, "shop", "api", "v1")
+	setChannel(h, "staging", "v1")
+	setPromotionPolicy(t, h, "prod", `{"from_channel":"staging","required_approvals":2}`)
+
+	status, requested, _ := promoteAs(alice, h, "prod", "v1")
+	if status != http.StatusAccepted || requested.Status != database.PromotionPending || requested.RequiredApprovals != 2 {
+		t.Fatalf("Expected a pending promotion, got %d %+v", status, requested)
+	}
+	if status, _, _ := promoteAs(bob, h, "prod", "v1"); status != http.StatusConflict {
+		t.Errorf("Expected 409 for a second request of the same promotion, got %d", status)
+	}
+
+	// The requester cannot approve their own request
+	if status, _ := approveAs(alice, h, requested.ID); status != http.StatusForbidden {
+		t.Errorf("Expected 403 for the requester, got %d", status)
+	}
+
+	// API tokens cannot approve
+	c, w := newRequestContext(http.MethodPost, "/api/v1/projects/shop/apps/api/promotions/1/approve", "",
+		"project", "shop", "app", "api", "id", strconv.Itoa(requested.ID))
+	c.Set("token_info", &auth.TokenInfo{TokenID: 7})
+	h.handleApprovePromotion(c)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an API token, got %d", w.Code)
+	}
+
+	status, approved := approveAs(bob, h, requested.ID)
+	if status != http.StatusOK || approved.Status != database.PromotionPending || len(approved.Approvers) != 1 {
+		t.Fatalf("Expected one approval, got %d %+v", status, approved)
+	}
+	// Each approver counts once
+	if status, _ := approveAs(bob, h, requested.ID); status != http.StatusConflict {
+		t.Errorf("Expected 409 for a repeated approval, got %d", status)
+	}
+	if version := channelAt(t, h, app.ID, "prod"); version != "" {
+		t.Errorf("Expected prod to be unset before enough approvals, got %q", version)
+	}
+
+	status, approved = approveAs(carol, h, requested.ID)
+	if status != http.StatusOK || approved.Status != database.PromotionApproved {
+		t.Fatalf("Expected the promotion to be approved, got %d %+v", status, approved)
+	}
+	if approved.DecidedBy == nil || *approved.DecidedBy != "user:carol" {
+		t.Errorf("Expected the promotion to be decided by user:carol, got %v", approved.DecidedBy)
+	}
+	if strings.Join(approved.Approvers, ",") != "user:bob,user:carol" {
+		t.Errorf("Expected approvers user:bob,user:carol, got %v", approved.Approvers)
+	}
+	if version := channelAt(t, h, app.ID, "prod"); version != "v1" {
+		t.Errorf("Expected prod at v1, got %q", version)
+	}
+
+	if status, _ := approveAs(bob, h, requested.ID); status != http.StatusConflict {
+		t.Errorf("Expected 409 approving a decided promotion, got %d", status)
+	}
+}
+
+func TestHandleApprovePromotion_PolicyRecheckedOnFinalApproval(t *testing.T) {
+	h := newIntegrationHandler(t)
+	app, _ := createTestVersion(t, h, "shop", "api", "v1")
+	createTestVersion(t, h, "shop", "api", "v2")
+	setChannel(h, "staging", "v1")
+	setPromotionPolicy(t, h, "prod", `{"from_channel":"staging","required_approvals":1}`)
+
+	status, requested, _ := promoteAs(alice, h, "prod", "v1")
+	if status != http.StatusAccepted {
+		t.Fatalf("Expected a pending promotion, got %d", status)
+	}
+
+	// v1 leaves staging while the promotion is pending
+	setChannel(h, "staging", "v2")
+
+	if status, _ := approveAs(bob, h, requested.ID); status != http.StatusConflict {
+		t.Fatalf("Expected 409 for a version that no longer meets the policy, got %d", status)
+	}
+	promotion, err := database.NewPromotionRepository(h.db).Get(app.ID, requested.ID)
+	if err != nil || promotion == nil {
+		t.Fatalf("Failed to get promotion: %v", err)
+	}
+	if promotion.Status != database.PromotionRejected || promotion.DecidedBy.String != policyRejector {
+		t.Errorf("Expected the promotion to be rejected by the policy, got %s by %s", promotion.Status, promotion.DecidedBy.String)
+	}
+	if !strings.Contains(promotion.Reason.String, "not on channel staging") {
+		t.Errorf("Expected the unmet rule as the reason, got %q", promotion.Reason.String)
+	}
+	if version := channelAt(t, h, app.ID, "prod"); version != "" {
+		t.Errorf("Expected prod to stay unset, got %q", version)
+	}
+}
+
+func TestHandleRejectPromotion(t *testing.T) {
+	h := newIntegrationHandler(t)
+	app, _ := createTestVersion(t, h, "shop", "api", "v1")
+	setChannel(h, "staging", "v1")
+	setPromotionPolicy(t, h, "prod", `{"from_channel":"staging","required_approvals":1}`)
+
+	_, requested, _ := promoteAs(alice, h, "prod", "v1")
+
+	// The requester may withdraw their own request
+	status, rejected := rejectAs(alice, h, requested.ID, `{"reason":" not today "}`)
+	if status != http.StatusOK || rejected.Status != database.PromotionRejected {
+		t.Fatalf("Expected the promotion to be rejected, got %d %+v", status, rejected)
+	}
+	if rejected.Reason == nil || *rejected.Reason != "not today" {
+		t.Errorf("Expected reason %q, got %v", "not today", rejected.Reason)
+	}
+	if rejected.DecidedBy == nil || *rejected.DecidedBy != "user:alice" {
+		t.Errorf("Expected the promotion to be decided by user:alice, got %v", rejected.DecidedBy)
+	}
+
+	if status, _ := rejectAs(bob, h, requested.ID, ""); status != http.StatusConflict {
+		t.Errorf("Expected 409 rejecting a decided promotion, got %d", status)
+	}
+	if status, _ := approveAs(bob, h, requested.ID); status != http.StatusConflict {
+		t.Errorf("Expected 409 approving a rejected promotion, got %d", status)
+	}
+	if status, _ := rejectAs(bob, h, 9999, ""); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing promotion, got %d", status)
+	}
+	if version := channelAt(t, h, app.ID, "prod"); version != "" {
+		t.Errorf("Expected prod to stay unset, got %q", version)
+	}
+
+	// A new request can be made once the old one is decided
+	if status, _, _ := promoteAs(alice, h, "prod", "v1"); status != http.StatusAccepted {
+		t.Errorf("Expected a new pending promotion, got %d", status)
+	}
+}
+
+func TestHandleApprovePromotion_Concurrent(t *testing.T) {
+	h := newIntegrationHandler(t)
+	app, _ := createTestVersion(t, h, "shop", "api", "v1")
+	setChannel(h, "staging", "v1")
+	setPromotionPolicy(t, h, "prod", `{"from_channel":"staging","required_approvals":1}`)
+
+	_, requested, _ := promoteAs(alice, h, "prod", "v1")
+
+	// Every approval is enough on its own, but the promotion is decided once
+	const approvers = 8
+	statuses := make([]int, approvers)
+	var wg sync.WaitGroup
+	for i := 0; i < approvers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := &auth.SessionInfo{UserID: 10 + i, Username: fmt.Sprintf("approver%d", i)}
+			statuses[i], _ = approveAs(user, h, requested.ID)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for i, status := range statuses {
+		switch status {
+		case http.StatusOK:
+			succeeded++
+		case http.StatusConflict:
+		default:
+			t.Errorf("Approver %d: expected 200 or 409, got %d", i, status)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected exactly one approval to decide the promotion, got %d", succeeded)
+	}
+
+	var promoted int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM channel_history WHERE app_id = $1 AND channel = 'prod' AND action = $2`,
+		app.ID, database.ChannelActionPromote).Scan(&promoted); err != nil {
+		t.Fatalf("Failed to count channel history: %v", err)
+	}
+	if promoted != 1 {
+		t.Errorf("Expected prod to be promoted once, got %d", promoted)
+	}
+}
+
+func TestPromotionRepository_DecideOnce(t *testing.T) {
+	h := newIntegrationHandler(t)
+	app, _ := createTestVersion(t, h, "shop", "api", "v1")
+	promotionRepo := database.NewPromotionRepository(h.db)
+	promotion, err := promotionRepo.Create(&database.Promotion{
+		AppID:             app.ID,
+		Channel:           "prod",
+		Version:           "v1",
+		Status:            database.PromotionPending,
+		RequiredApprovals: 1,
+		RequestedBy:       "user:alice",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create promotion: %v", err)
+	}
+
+	const deciders = 8
+	decided := make([]bool, deciders)
+	var wg sync.WaitGroup
+	for i := 0; i < deciders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status := database.PromotionApproved
+			if i%2 == 1 {
+				status = database.PromotionRejected
+			}
+			ok, err := promotionRepo.Decide(promotion.ID, status, fmt.Sprintf("user:%d", i), "")
+			if err != nil {
+				t.Errorf("Decide() error = %v", err)
+			}
+			decided[i] = ok
+		}(i)
+	}
+	wg.Wait()
+
+	count := 0
+	for _, ok := range decided {
+		if ok {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected one Decide to win, got %d", count)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
 )
 
 // PublishRequest represents a publish request
@@ -21,7 +23,7 @@ type PublishRequest struct {
 // handlePublish publishes a version (marks it as published)
 // handlePublish godoc
 // @Summary      Publish version
-// @Description  Mark a version as published so it can be retrieved via pull latest
+// @Description  Mark a version as published so it can be retrieved via pull latest. If the published channel has a promotion policy, use POST /promote instead.
 // @Tags         artifacts
 // @Accept       json
 // @Produce      json
@@ -30,6 +32,7 @@ type PublishRequest struct {
 // @Failure      400      {object}  ErrorResponse
 // @Failure      401      {object}  ErrorResponse
 // @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
 // @Failure      500      {object}  ErrorResponse
 // @Security     Bearer
 // @Router       /publish [post]
@@ -52,6 +55,9 @@ func (h *Handler) handlePublish(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "app not found"})
 		return
 	}
+	if !h.requireNoPromotionPolicy(c, app.ID, DefaultChannel) {
+		return
+	}
 
 	// Verify version exists in database
 	versions, err := h.versionRepo.ListByApp(app.ID, 10000, 0)
@@ -80,32 +86,11 @@ func (h *Handler) handlePublish(c *gin.Context) {
 		return
 	}
 
-	// Unpublish all other versions for this app (only one version can be published at a time)
-	if err := h.versionRepo.UnpublishAllVersions(app.ID); err != nil {
+	if err := h.publishVersion(c, req.Project, req.App, app.ID, req.Version); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Mark version as published
-	if err := h.versionRepo.SetPublished(app.ID, req.Version, true); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Publish publish event with context to extract agent ID
-	metadata := make(map[string]interface{})
-	metadata["target_version"] = req.Version
-
-	h.publishEventWithContext(
-		c,
-		"publish",
-		req.Project,
-		req.App,
-		req.Version,
-		"",
-		metadata,
-	)
-
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "published",
 		"project": req.Project,
@@ -156,10 +141,11 @@ func (h *Handler) handleUnpublish(c *gin.Context) {
 		return
 	}
 
-	var versionExists bool
+	var versionExists, wasPublished bool
 	for _, v := range versions {
 		if v.Hash == req.Version {
 			versionExists = true
+			wasPublished = v.IsPublished
 			break
 		}
 	}
@@ -174,6 +160,9 @@ func (h *Handler) handleUnpublish(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if wasPublished {
+		h.recordChannelHistory(c, app.ID, DefaultChannel, "", req.Version, database.ChannelActionDelete)
+	}
 
 	// Publish unpublish event with context to extract agent ID
 	metadata := make(map[string]interface{})
@@ -181,7 +170,7 @@ func (h *Handler) handleUnpublish(c *gin.Context) {
 
 	h.publishEventWithContext(
 		c,
-		"unpublish",
+		events.EventTypeVersionUnpublished,
 		req.Project,
 		req.App,
 		req.Version,
@@ -196,3 +185,26 @@ func (h *Handler) handleUnpublish(c *gin.Context) {
 		"version": req.Version,
 	})
 }
+
+// publishVersion makes a version the published version of an app, i.e. points
+// the default channel at it
+func (h *Handler) publishVersion(c *gin.Context, projectName, appName string, appID int, hash string) error {
+	if _, _, err := h.setChannelVersion(c, appID, DefaultChannel, hash, database.ChannelActionSet); err != nil {
+		return err
+	}
+
+	// Publish publish event with context to extract agent ID
+	metadata := make(map[string]interface{})
+	metadata["target_version"] = hash
+
+	h.publishEventWithContext(
+		c,
+		events.EventTypeVersionPublished,
+		projectName,
+		appName,
+		hash,
+		"",
+		metadata,
+	)
+	return nil
+}
@@ -0,0 +1,400 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
+	"github.com/kk/kkartifact-server/internal/storage"
+)
+
+// RetentionRules are the retention rules a project or app overrides
+// A null rule is inherited from the project policy or the global config; 0
+// turns a rule off.
+type RetentionRules struct {
+	KeepLast      *int    `json:"keep_last"`       // keep the N newest versions
+	KeepDays      *int    `json:"keep_days"`       // keep versions newer than D days
+	KeepPerBranch *int    `json:"keep_per_branch"` // keep the N newest versions of each branch
+	BranchLabel   *string `json:"branch_label"`    // version label holding the branch
+}
+
+// RetentionPolicyResponse represents a project or app retention policy in API response
+type RetentionPolicyResponse struct {
+	Project   string                   `json:"project"`
+	App       string                   `json:"app,omitempty"`
+	Rules     *RetentionRules          `json:"rules"`                // null if nothing is overridden
+	Effective *storage.RetentionPolicy `json:"effective"`            // the rules cleanup applies
+	UpdatedAt *string                  `json:"updated_at,omitempty"` // RFC3339
+}
+
+// RetentionPreviewResponse represents the retention preview API response
+type RetentionPreviewResponse struct {
+	Apps     []*storage.RetentionPreview `json:"apps"`     // apps with versions to prune
+	Versions int                         `json:"versions"` // versions to prune in total
+	Bytes    int64                       `json:"bytes"`    // bytes freed in total
+}
+
+// handleRetentionPreview godoc
+// @Summary      Preview retention
+// @Description  List the versions the next version-cleanup run would delete under each app's effective retention policy, with the rules that select each version and the bytes deleting it frees. Nothing is deleted.
+// @Tags         retention
+// @Produce      json
+// @Param        project  query     string  false  "Only apps of this project"
+// @Param        app      query     string  false  "Only this app (requires project)"
+// @Success      200      {object}  RetentionPreviewResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /admin/retention/preview [get]
+func (h *Handler) handleRetentionPreview(c *gin.Context) {
+	projectName := c.Query("project")
+	appName := c.Query("app")
+	if appName != "" && projectName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app requires project"})
+		return
+	}
+
+	var projects []*database.Project
+	if projectName != "" {
+		project, err := h.projectRepo.GetByName(projectName)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+			return
+		}
+		projects = []*database.Project{project}
+	} else {
+		var err error
+		if projects, err = h.projectRepo.List(1000, 0); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	cleanupManager := storage.NewCleanupManager(h.artifactManager, h.db)
+	response := RetentionPreviewResponse{Apps: []*storage.RetentionPreview{}}
+	for _, project := range projects {
+		var apps []*database.App
+		if appName != "" {
+			app, err := h.appRepo.GetByName(project.ID, appName)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "app not found"})
+				return
+			}
+			apps = []*database.App{app}
+		} else {
+			var err error
+			if apps, err = h.appRepo.ListByProject(project.ID, 1000, 0); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		for _, app := range apps {
+			policy, err := storage.LoadRetentionPolicy(h.db, project.ID, &app.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			preview, err := cleanupManager.PreviewRetention(project.Name, app.Name, app.ID, policy)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if len(preview.Versions) == 0 {
+				continue
+			}
+			response.Apps = append(response.Apps, preview)
+			response.Versions += len(preview.Versions)
+			response.Bytes += preview.Bytes
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// handleListRetentionPolicies godoc
+// @Summary      List retention policies
+// @Description  Get every project and app retention policy with the rules it overrides and its effective policy. Global rules are part of the config.
+// @Tags         retention
+// @Produce      json
+// @Success      200  {array}   RetentionPolicyResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /retention-policies [get]
+func (h *Handler) handleListRetentionPolicies(c *gin.Context) {
+	policies, err := database.NewRetentionPolicyRepository(h.db).List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responses := make([]RetentionPolicyResponse, 0, len(policies))
+	for _, policy := range policies {
+		var appID *int
+		if policy.AppID.Valid {
+			id := int(policy.AppID.Int64)
+			appID = &id
+		}
+		projectName, appName := h.scopeNames(&policy.ProjectID, appID)
+
+		response, err := h.retentionPolicyResponse(projectName, appName, policy.ProjectID, appID, policy)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		responses = append(responses, *response)
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// handleGetRetentionPolicy godoc
+// @Summary      Get retention policy
+// @Description  Get the retention rules a project or app overrides and its effective policy, with where each rule comes from (global, project or app).
+// @Tags         retention
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  false "App name (app routes only)"
+// @Success      200      {object}  RetentionPolicyResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/retention-policy [get]
+// @Router       /projects/{project}/apps/{app}/retention-policy [get]
+func (h *Handler) handleGetRetentionPolicy(c *gin.Context) {
+	projectID, appID, ok := h.retentionPolicyTarget(c)
+	if !ok {
+		return
+	}
+
+	policyRepo := database.NewRetentionPolicyRepository(h.db)
+	var policy *database.RetentionPolicy
+	var err error
+	if appID != nil {
+		policy, err = policyRepo.GetForApp(*appID)
+	} else {
+		policy, err = policyRepo.GetForProject(projectID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.retentionPolicyResponse(c.Param("project"), c.Param("app"), projectID, appID, policy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// handleSetRetentionPolicy godoc
+// @Summary      Set retention policy
+// @Description  Replace the retention rules a project or app overrides. Null or missing rules are inherited; 0 turns a rule off. Published and pinned versions are never pruned.
+// @Tags         retention
+// @Accept       json
+// @Produce      json
+// @Param        project  path      string          true  "Project name"
+// @Param        app      path      string          false "App name (app routes only)"
+// @Param        request  body      RetentionRules  true  "Retention rules"
+// @Success      200      {object}  RetentionPolicyResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/retention-policy [put]
+// @Router       /projects/{project}/apps/{app}/retention-policy [put]
+func (h *Handler) handleSetRetentionPolicy(c *gin.Context) {
+	var req RetentionRules
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateRetentionRules(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	projectID, appID, ok := h.retentionPolicyTarget(c)
+	if !ok {
+		return
+	}
+
+	policy := &database.RetentionPolicy{
+		ProjectID:     projectID,
+		KeepLast:      toNullInt(req.KeepLast),
+		KeepDays:      toNullInt(req.KeepDays),
+		KeepPerBranch: toNullInt(req.KeepPerBranch),
+	}
+	if appID != nil {
+		policy.AppID = sql.NullInt64{Int64: int64(*appID), Valid: true}
+	}
+	if req.BranchLabel != nil {
+		policy.BranchLabel = sql.NullString{String: *req.BranchLabel, Valid: true}
+	}
+
+	saved, err := database.NewRetentionPolicyRepository(h.db).Set(policy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.retentionPolicyResponse(c.Param("project"), c.Param("app"), projectID, appID, saved)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.publishEventWithContext(c, events.EventTypeConfigUpdated, c.Param("project"), c.Param("app"), "", "", map[string]interface{}{
+		"changes": map[string]interface{}{"retention_policy": response.Rules},
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// handleDeleteRetentionPolicy godoc
+// @Summary      Delete retention policy
+// @Description  Remove the retention rules a project or app overrides, so it inherits them again. Deleting a project policy keeps its app policies.
+// @Tags         retention
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  false "App name (app routes only)"
+// @Success      200      {object}  map[string]string
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/retention-policy [delete]
+// @Router       /projects/{project}/apps/{app}/retention-policy [delete]
+func (h *Handler) handleDeleteRetentionPolicy(c *gin.Context) {
+	projectID, appID, ok := h.retentionPolicyTarget(c)
+	if !ok {
+		return
+	}
+
+	policyRepo := database.NewRetentionPolicyRepository(h.db)
+	var deleted bool
+	var err error
+	if appID != nil {
+		deleted, err = policyRepo.DeleteForApp(*appID)
+	} else {
+		deleted, err = policyRepo.DeleteForProject(projectID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "retention policy not found"})
+		return
+	}
+
+	h.publishEventWithContext(c, events.EventTypeConfigUpdated, c.Param("project"), c.Param("app"), "", "", map[string]interface{}{
+		"changes": map[string]interface{}{"retention_policy": nil},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// retentionPolicyTarget looks up the project, and the app on app routes, of a policy request
+// It writes the error response and returns false if either does not exist.
+func (h *Handler) retentionPolicyTarget(c *gin.Context) (int, *int, bool) {
+	project, err := h.projectRepo.GetByName(c.Param("project"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return 0, nil, false
+	}
+	if c.Param("app") == "" {
+		return project.ID, nil, true
+	}
+
+	app, err := h.appRepo.GetByName(project.ID, c.Param("app"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "app not found"})
+		return 0, nil, false
+	}
+	return project.ID, &app.ID, true
+}
+
+// retentionPolicyResponse builds the response for a policy, which is nil if nothing is overridden
+func (h *Handler) retentionPolicyResponse(projectName, appName string, projectID int, appID *int, policy *database.RetentionPolicy) (*RetentionPolicyResponse, error) {
+	effective, err := storage.LoadRetentionPolicy(h.db, projectID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &RetentionPolicyResponse{
+		Project:   projectName,
+		App:       appName,
+		Effective: effective,
+	}
+	if policy != nil {
+		response.Rules = &RetentionRules{
+			KeepLast:      fromNullInt(policy.KeepLast),
+			KeepDays:      fromNullInt(policy.KeepDays),
+			KeepPerBranch: fromNullInt(policy.KeepPerBranch),
+		}
+		if policy.BranchLabel.Valid {
+			response.Rules.BranchLabel = &policy.BranchLabel.String
+		}
+		updatedAt := policy.UpdatedAt.Format(time.RFC3339)
+		response.UpdatedAt = &updatedAt
+	}
+	return response, nil
+}
+
+// validateRetentionRules checks the rules of a policy request and trims the branch label
+func validateRetentionRules(rules *RetentionRules) error {
+	for name, value := range map[string]*int{
+		"keep_last":       rules.KeepLast,
+		"keep_days":       rules.KeepDays,
+		"keep_per_branch": rules.KeepPerBranch,
+	} {
+		if value != nil && *value < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	if rules.BranchLabel != nil {
+		label := strings.TrimSpace(*rules.BranchLabel)
+		if err := validateLabelKey(label); err != nil {
+			return fmt.Errorf("branch_label %w", err)
+		}
+		rules.BranchLabel = &label
+	}
+	return nil
+}
+
+func toNullInt(value *int) sql.NullInt64 {
+	if value == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*value), Valid: true}
+}
+
+func fromNullInt(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	n := int(value.Int64)
+	return &n
+}
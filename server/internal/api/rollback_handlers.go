@@ -0,0 +1,245 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
+)
+
+// rollbackHistoryLimit is how many history entries a rollback looks back through
+const rollbackHistoryLimit = 1000
+
+// RollbackRequest represents a request to move a channel back to an earlier version
+type RollbackRequest struct {
+	Project string `json:"project" binding:"required"`
+	App     string `json:"app" binding:"required"`
+	Channel string `json:"channel"` // default: published
+	Steps   int    `json:"steps"`   // how many versions to go back (default: 1, the previous version)
+}
+
+// RollbackResponse represents the result of a rollback
+type RollbackResponse struct {
+	Project         string `json:"project"`
+	App             string `json:"app"`
+	Channel         string `json:"channel"`
+	Version         string `json:"version"`                    // the version the channel points at now
+	PreviousVersion string `json:"previous_version,omitempty"` // the version it was rolled back from
+	Steps           int    `json:"steps"`
+}
+
+// ChannelHistoryResponse represents a change of a channel in API response
+type ChannelHistoryResponse struct {
+	ID              int64   `json:"id"`
+	Channel         string  `json:"channel"`
+	Version         *string `json:"version,omitempty"` // empty when the channel was deleted or unpublished
+	PreviousVersion *string `json:"previous_version,omitempty"`
+	Action          string  `json:"action"` // set, delete or rollback
+	ChangedBy       *string `json:"changed_by,omitempty"`
+	CreatedAt       string  `json:"created_at"` // RFC3339 format
+}
+
+// ChannelHistoryListResponse represents the paginated channel history API response
+type ChannelHistoryListResponse struct {
+	Data  []ChannelHistoryResponse `json:"data"`
+	Total int                      `json:"total"`
+}
+
+// handleRollback godoc
+// @Summary      Roll back channel
+// @Description  Move a release channel, or the published version (channel "published", the default), back to the version it pointed at before. steps goes back further, e.g. steps=2 for the version before the previous one. The versions are taken from the channel history; repeats in a row count once.
+// @Tags         channels
+// @Accept       json
+// @Produce      json
+// @Param        request  body      RollbackRequest  true  "Rollback request"
+// @Success      200      {object}  RollbackResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /rollback [post]
+func (h *Handler) handleRollback(c *gin.Context) {
+	var req RollbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Channel == "" {
+		req.Channel = DefaultChannel
+	}
+	if req.Steps == 0 {
+		req.Steps = 1
+	}
+	if req.Steps < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "steps must be positive"})
+		return
+	}
+
+	project, err := h.projectRepo.GetByName(req.Project)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+	app, err := h.appRepo.GetByName(project.ID, req.App)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "app not found"})
+		return
+	}
+
+	current, err := h.channelVersion(app.ID, req.Channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	history, _, err := database.NewChannelRepository(h.db).ListHistory(app.ID, req.Channel, rollbackHistoryLimit, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	earlier := earlierChannelVersions(history, current)
+	if len(earlier) < req.Steps {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("channel %s has %d earlier versions; cannot go back %d", req.Channel, len(earlier), req.Steps)})
+		return
+	}
+	target := earlier[req.Steps-1]
+
+	// The version may have been deleted since the channel pointed at it
+	if _, err := h.versionRepo.GetByHash(app.ID, target); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("version %s no longer exists", target)})
+		return
+	}
+	if _, err := h.artifactManager.GetManifest(c.Request.Context(), req.Project, req.App, target); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("version %s no longer exists in storage", target)})
+		return
+	}
+
+	_, previous, err := h.setChannelVersion(c, app.ID, req.Channel, target, database.ChannelActionRollback)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	metadata := map[string]interface{}{
+		"channel": req.Channel,
+		"steps":   req.Steps,
+	}
+	if previous != "" {
+		metadata["previous_version"] = previous
+	}
+	h.publishEventWithContext(c, events.EventTypeChannelRolledBack, req.Project, req.App, target, "", metadata)
+
+	c.JSON(http.StatusOK, RollbackResponse{
+		Project:         req.Project,
+		App:             req.App,
+		Channel:         req.Channel,
+		Version:         target,
+		PreviousVersion: previous,
+		Steps:           req.Steps,
+	})
+}
+
+// handleListChannelHistory godoc
+// @Summary      List channel history
+// @Description  Get every change of a release channel, or of the published version (channel "published"), newest first. Returns paginated results with total count.
+// @Tags         channels
+// @Produce      json
+// @Param        project  path      string  true   "Project name"
+// @Param        app      path      string  true   "App name"
+// @Param        channel  path      string  true   "Channel name"
+// @Param        limit    query     int     false  "Limit number of results (default: 50)"
+// @Param        offset   query     int     false  "Offset for pagination (default: 0)"
+// @Success      200      {object}  ChannelHistoryListResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/channels/{channel}/history [get]
+func (h *Handler) handleListChannelHistory(c *gin.Context) {
+	app, ok := h.findApp(c)
+	if !ok {
+		return
+	}
+
+	limit := getIntQuery(c, "limit", 50)
+	offset := getIntQuery(c, "offset", 0)
+
+	history, total, err := database.NewChannelRepository(h.db).ListHistory(app.ID, c.Param("channel"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responses := make([]ChannelHistoryResponse, len(history))
+	for i, entry := range history {
+		responses[i] = ChannelHistoryResponse{
+			ID:        entry.ID,
+			Channel:   entry.Channel,
+			Action:    entry.Action,
+			CreatedAt: entry.CreatedAt.Format(time.RFC3339),
+		}
+		if entry.Version.Valid {
+			responses[i].Version = &entry.Version.String
+		}
+		if entry.PreviousVersion.Valid {
+			responses[i].PreviousVersion = &entry.PreviousVersion.String
+		}
+		if entry.ChangedBy.Valid {
+			responses[i].ChangedBy = &entry.ChangedBy.String
+		}
+	}
+
+	c.JSON(http.StatusOK, ChannelHistoryListResponse{
+		Data:  responses,
+		Total: total,
+	})
+}
+
+// earlierChannelVersions lists the versions a channel pointed at before current, newest first
+// Repeats of a version in a row count once, and deletions are skipped. A rollback
+// undoes the changes after the version it restored, so the walk resumes below the
+// entry that first set that version; repeated rollbacks keep going back.
+func earlierChannelVersions(history []*database.ChannelHistory, current string) []string {
+	var versions []string
+	last := current
+	for i := 0; i < len(history); i++ {
+		entry := history[i]
+		if !entry.Version.Valid {
+			continue
+		}
+		if entry.Version.String != last {
+			versions = append(versions, entry.Version.String)
+			last = entry.Version.String
+		}
+		if entry.Action == database.ChannelActionRollback {
+			i = restoredChannelEntry(history, i)
+		}
+	}
+	return versions
+}
+
+// restoredChannelEntry returns the index of the entry a rollback at index i restored:
+// the next older change to the same version that was not itself a rollback
+// If it is not in the history, the index of the last entry is returned.
+func restoredChannelEntry(history []*database.ChannelHistory, i int) int {
+	version := history[i].Version.String
+	for j := i + 1; j < len(history); j++ {
+		entry := history[j]
+		if entry.Action != database.ChannelActionRollback && entry.Version.Valid && entry.Version.String == version {
+			return j
+		}
+	}
+	return len(history) - 1
+}
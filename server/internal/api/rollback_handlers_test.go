@@ -0,0 +1,108 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kk/kkartifact-server/internal/database"
+)
+
+// testChannelHistory builds a channel history, newest first, from entries
+// written oldest first as "action:version" (an empty version is a deletion)
+func testChannelHistory(entries ...string) []*database.ChannelHistory {
+	history := make([]*database.ChannelHistory, len(entries))
+	for i, entry := range entries {
+		action, version, _ := strings.Cut(entry, ":")
+		history[len(entries)-1-i] = &database.ChannelHistory{
+			Action:  action,
+			Version: sql.NullString{String: version, Valid: version != ""},
+		}
+	}
+	return history
+}
+
+func TestEarlierChannelVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		history  []*database.ChannelHistory
+		current  string
+		expected []string
+	}{
+		{
+			name:     "empty history",
+			history:  nil,
+			current:  "",
+			expected: nil,
+		},
+		{
+			name:     "sets",
+			history:  testChannelHistory("set:a", "set:b", "set:c"),
+			current:  "c",
+			expected: []string{"b", "a"},
+		},
+		{
+			name:     "repeats count once",
+			history:  testChannelHistory("set:a", "set:b", "set:b", "promote:c"),
+			current:  "c",
+			expected: []string{"b", "a"},
+		},
+		{
+			name:     "deletions are skipped",
+			history:  testChannelHistory("set:a", "delete:", "set:b"),
+			current:  "b",
+			expected: []string{"a"},
+		},
+		{
+			name:     "rollback resumes below the restored version",
+			history:  testChannelHistory("set:a", "set:b", "set:c", "rollback:b"),
+			current:  "b",
+			expected: []string{"a"},
+		},
+		{
+			name:     "repeated rollbacks keep going back",
+			history:  testChannelHistory("set:a", "set:b", "set:c", "set:d", "rollback:c", "rollback:b"),
+			current:  "b",
+			expected: []string{"a"},
+		},
+		{
+			name:     "change after a rollback returns to the restored version",
+			history:  testChannelHistory("set:a", "set:b", "rollback:a", "set:c"),
+			current:  "c",
+			expected: []string{"a"},
+		},
+		{
+			name:     "multi-step rollback",
+			history:  testChannelHistory("set:a", "set:b", "set:c", "set:d", "rollback:b"),
+			current:  "b",
+			expected: []string{"a"},
+		},
+		{
+			name:     "rollback to a version older than the history",
+			history:  testChannelHistory("set:b", "set:c", "rollback:a"),
+			current:  "a",
+			expected: nil,
+		},
+		{
+			name:     "deleted channel",
+			history:  testChannelHistory("set:a", "set:b", "delete:"),
+			current:  "",
+			expected: []string{"b", "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := earlierChannelVersions(tt.history, tt.current)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("earlierChannelVersions() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -87,6 +87,11 @@ func (h *Handler) handleSyncStorage(c *gin.Context) {
 			return nil
 		}
 
+		// Skip hidden directories such as the .blobs store
+		if strings.HasPrefix(relPath, ".") {
+			return filepath.SkipDir
+		}
+
 		parts := strings.Split(relPath, string(filepath.Separator))
 
 		// Structure: project/app/version
@@ -158,6 +163,11 @@ func (h *Handler) handleSyncStorage(c *gin.Context) {
 			return nil
 		}
 
+		// Skip hidden directories such as the .blobs store
+		if strings.HasPrefix(relPath, ".") {
+			return filepath.SkipDir
+		}
+
 		parts := strings.Split(relPath, string(filepath.Separator))
 
 		// Structure: project/app/version
@@ -0,0 +1,296 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/scheduler"
+)
+
+// TasksResponse represents the scheduled tasks API response
+type TasksResponse struct {
+	Replica  string                   `json:"replica"`   // the replica that served the request
+	IsLeader bool                     `json:"is_leader"` // whether that replica schedules tasks
+	Leader   *SchedulerLeaderResponse `json:"leader,omitempty"`
+	Tasks    []TaskResponse           `json:"tasks"`
+}
+
+// SchedulerLeaderResponse represents the replica holding the scheduler leadership
+type SchedulerLeaderResponse struct {
+	Holder     string `json:"holder"`
+	AcquiredAt string `json:"acquired_at"`
+	RenewedAt  string `json:"renewed_at"`
+	Stale      bool   `json:"stale"` // not renewed recently; another replica takes over
+}
+
+// TaskResponse represents a scheduled task in API response
+type TaskResponse struct {
+	Name            string           `json:"name"`
+	Schedule        string           `json:"schedule"` // cron expression or "off"
+	DefaultSchedule string           `json:"default_schedule"`
+	NextRunAt       *string          `json:"next_run_at,omitempty"` // RFC3339
+	CanDryRun       bool             `json:"can_dry_run"`
+	DryRun          bool             `json:"dry_run"` // scheduled runs are dry runs
+	Running         bool             `json:"running"`
+	LockHolder      *string          `json:"lock_holder,omitempty"` // replica running the task
+	LastRun         *TaskRunResponse `json:"last_run,omitempty"`
+}
+
+// TaskRunResponse represents a task run in API response
+type TaskRunResponse struct {
+	ID          int64           `json:"id"`
+	TaskName    string          `json:"task_name"`
+	TriggeredBy string          `json:"triggered_by"`     // schedule, catch-up, or the user/token that started it
+	Runner      *string         `json:"runner,omitempty"` // replica that ran it
+	Status      string          `json:"status"`           // running, succeeded or failed
+	DryRun      bool            `json:"dry_run"`          // only recorded what the task would do
+	ScheduledAt *string         `json:"scheduled_at,omitempty"`
+	StartedAt   string          `json:"started_at"`
+	FinishedAt  *string         `json:"finished_at,omitempty"`
+	Error       *string         `json:"error,omitempty"`
+	Counts      map[string]int  `json:"counts,omitempty"`
+	Details     json.RawMessage `json:"details,omitempty" swaggertype:"object"` // only returned for a single run
+}
+
+// TaskRunsListResponse represents the paginated task runs API response
+type TaskRunsListResponse struct {
+	Data  []TaskRunResponse `json:"data"`
+	Total int               `json:"total"`
+}
+
+// handleListTasks godoc
+// @Summary      List scheduled tasks
+// @Description  Get the scheduled tasks with their cron schedule, next run time and last run, and the replica holding the scheduler leadership. A running task shows the replica holding its lock. Schedules are changed through PUT /config (task_schedules).
+// @Tags         tasks
+// @Produce      json
+// @Success      200  {object}  TasksResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /admin/tasks [get]
+func (h *Handler) handleListTasks(c *gin.Context) {
+	if h.scheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduler is not available"})
+		return
+	}
+
+	runRepo := database.NewTaskRunRepository(h.db)
+	response := TasksResponse{
+		Replica:  h.scheduler.Replica(),
+		IsLeader: h.scheduler.IsLeader(),
+	}
+
+	leader, err := runRepo.GetLeader(scheduler.LeaderStaleAfter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if leader != nil {
+		response.Leader = &SchedulerLeaderResponse{
+			Holder:     leader.Holder,
+			AcquiredAt: leader.AcquiredAt.Format(time.RFC3339),
+			RenewedAt:  leader.RenewedAt.Format(time.RFC3339),
+			Stale:      leader.Stale,
+		}
+	}
+
+	tasks := h.scheduler.Tasks()
+	responses := make([]TaskResponse, len(tasks))
+	for i, task := range tasks {
+		responses[i] = TaskResponse{
+			Name:            task.Name,
+			Schedule:        task.Schedule,
+			DefaultSchedule: task.DefaultSchedule,
+			CanDryRun:       task.CanDryRun,
+			DryRun:          task.DryRun,
+		}
+		if task.NextRun != nil {
+			nextRunAt := task.NextRun.Format(time.RFC3339)
+			responses[i].NextRunAt = &nextRunAt
+		}
+
+		lastRun, err := runRepo.Latest(task.Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if lastRun != nil {
+			run := toTaskRunResponse(lastRun)
+			responses[i].LastRun = &run
+			if lastRun.Status == database.TaskRunRunning {
+				responses[i].Running = true
+				responses[i].LockHolder = run.Runner
+			}
+		}
+	}
+	response.Tasks = responses
+
+	c.JSON(http.StatusOK, response)
+}
+
+// handleRunTask godoc
+// @Summary      Run a task
+// @Description  Start a run of a scheduled task now, on the replica that serves the request. The task runs in the background; the returned run can be followed in the task's run history. Returns 409 if the task is running on any replica. A dry run (for tasks with can_dry_run) only records what the task would do in the run's details.
+// @Tags         tasks
+// @Produce      json
+// @Param        name     path      string  true   "Task name"
+// @Param        dry_run  query     bool    false  "Only record what the task would do"
+// @Success      202      {object}  TaskRunResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Failure      503      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /admin/tasks/{name}/run [post]
+func (h *Handler) handleRunTask(c *gin.Context) {
+	if h.scheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduler is not available"})
+		return
+	}
+
+	run, err := h.scheduler.RunNow(c.Param("name"), getActorFromRequest(c), getBoolQuery(c, "dry_run"))
+	if errors.Is(err, scheduler.ErrUnknownTask) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+	if errors.Is(err, scheduler.ErrDryRunUnsupported) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, scheduler.ErrTaskRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, toTaskRunResponse(run))
+}
+
+// handleListTaskRuns godoc
+// @Summary      List task runs
+// @Description  Get the run history of a scheduled task, newest first. Returns paginated results with total count.
+// @Tags         tasks
+// @Produce      json
+// @Param        name    path      string  true   "Task name"
+// @Param        limit   query     int     false  "Limit number of results (default: 50)"
+// @Param        offset  query     int     false  "Offset for pagination (default: 0)"
+// @Success      200     {object}  TaskRunsListResponse
+// @Failure      401     {object}  ErrorResponse
+// @Failure      403     {object}  ErrorResponse
+// @Failure      404     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /admin/tasks/{name}/runs [get]
+func (h *Handler) handleListTaskRuns(c *gin.Context) {
+	name := c.Param("name")
+	if h.scheduler == nil || !h.scheduler.HasTask(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+
+	limit := getIntQuery(c, "limit", 50)
+	offset := getIntQuery(c, "offset", 0)
+
+	runs, total, err := database.NewTaskRunRepository(h.db).ListByTask(name, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responses := make([]TaskRunResponse, len(runs))
+	for i, run := range runs {
+		responses[i] = toTaskRunResponse(run)
+	}
+
+	c.JSON(http.StatusOK, TaskRunsListResponse{
+		Data:  responses,
+		Total: total,
+	})
+}
+
+// handleGetTaskRun godoc
+// @Summary      Get task run
+// @Description  Get a run of a scheduled task with its details, e.g. the versions a version-cleanup dry run would delete.
+// @Tags         tasks
+// @Produce      json
+// @Param        name  path      string  true  "Task name"
+// @Param        id    path      int     true  "Run ID"
+// @Success      200   {object}  TaskRunResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /admin/tasks/{name}/runs/{id} [get]
+func (h *Handler) handleGetTaskRun(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run ID"})
+		return
+	}
+
+	run, err := database.NewTaskRunRepository(h.db).Get(c.Param("name"), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task run not found"})
+		return
+	}
+
+	response := toTaskRunResponse(run)
+	if run.Details.Valid {
+		response.Details = json.RawMessage(run.Details.String)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func toTaskRunResponse(run *database.TaskRun) TaskRunResponse {
+	response := TaskRunResponse{
+		ID:          run.ID,
+		TaskName:    run.TaskName,
+		TriggeredBy: run.TriggeredBy,
+		Status:      run.Status,
+		DryRun:      run.DryRun,
+		StartedAt:   run.StartedAt.Format(time.RFC3339),
+	}
+	if run.Runner.Valid {
+		response.Runner = &run.Runner.String
+	}
+	if run.ScheduledAt.Valid {
+		// Stored in UTC
+		scheduledAt := run.ScheduledAt.Time.Format("2006-01-02T15:04:05Z")
+		response.ScheduledAt = &scheduledAt
+	}
+	if run.FinishedAt.Valid {
+		finishedAt := run.FinishedAt.Time.Format(time.RFC3339)
+		response.FinishedAt = &finishedAt
+	}
+	if run.Error.Valid {
+		response.Error = &run.Error.String
+	}
+	if run.Counts.Valid {
+		_ = json.Unmarshal([]byte(run.Counts.String), &response.Counts)
+	}
+	return response
+}
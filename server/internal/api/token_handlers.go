@@ -13,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/kk/kkartifact-server/internal/auth"
 	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
 )
 
 // CreateTokenRequest represents a token creation request
@@ -45,7 +46,10 @@ type TokenResponse struct {
 // @Param        request  body      CreateTokenRequest  true  "Token creation request"
 // @Success      201      {object}  TokenResponse
 // @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
 // @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
 // @Router       /tokens [post]
 func (h *Handler) handleCreateToken(c *gin.Context) {
 	var req CreateTokenRequest
@@ -133,11 +137,10 @@ func (h *Handler) handleCreateToken(c *gin.Context) {
 		CreatedAt:   createdToken.CreatedAt.Format(time.RFC3339),
 	}
 
-	// Record audit log for token creation
-	auditRepo := database.NewAuditRepository(h.db)
-	agentID := getAgentIDFromRequest(c)
+	// Publish token creation (recorded in the audit log)
 	metadata := map[string]interface{}{
-		"token_name": name,
+		"token_id":    createdToken.ID,
+		"token_name":  name,
 		"permissions": permissions,
 	}
 	if projectID != nil {
@@ -149,7 +152,8 @@ func (h *Handler) handleCreateToken(c *gin.Context) {
 	if expiresAtStr != nil {
 		metadata["expires_at"] = *expiresAtStr
 	}
-	_ = auditRepo.Create("token_create", projectID, appID, "", agentID, metadata)
+	projectName, appName := h.scopeNames(projectID, appID)
+	h.publishEventWithContext(c, events.EventTypeTokenCreated, projectName, appName, "", "", metadata)
 
 	// Invalidate token cache when a new token is created
 	h.authenticator.InvalidateTokenCache()
@@ -257,9 +261,7 @@ func (h *Handler) handleDeleteToken(c *gin.Context) {
 	// Invalidate token cache when a token is deleted
 	h.authenticator.InvalidateTokenCache()
 
-	// Record audit log for token deletion
-	auditRepo := database.NewAuditRepository(h.db)
-	agentID := getAgentIDFromRequest(c)
+	// Publish token revocation (recorded in the audit log)
 	var projectID, appID *int
 	if tokenToDelete.ProjectID.Valid {
 		pid := int(tokenToDelete.ProjectID.Int64)
@@ -275,7 +277,8 @@ func (h *Handler) handleDeleteToken(c *gin.Context) {
 	if tokenToDelete.Name.Valid {
 		metadata["token_name"] = tokenToDelete.Name.String
 	}
-	_ = auditRepo.Create("token_delete", projectID, appID, "", agentID, metadata)
+	projectName, appName := h.scopeNames(projectID, appID)
+	h.publishEventWithContext(c, events.EventTypeTokenRevoked, projectName, appName, "", "", metadata)
 
 	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
 }
@@ -7,42 +7,75 @@ package api
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
 	"github.com/kk/kkartifact-server/internal/storage"
 )
 
 // UploadInitRequest represents the upload initialization request
 type UploadInitRequest struct {
-	Project   string   `json:"project" binding:"required"`
-	App       string   `json:"app" binding:"required"`
-	Version   string   `json:"version" binding:"required"`
-	FileCount int      `json:"file_count"`
-	Files     []string `json:"files,omitempty"`
+	Project   string            `json:"project" binding:"required"`
+	App       string            `json:"app" binding:"required"`
+	Version   string            `json:"version" binding:"required"`
+	FileCount int               `json:"file_count"`
+	Files     []string          `json:"files,omitempty"`
+	Manifest  []UploadFileEntry `json:"manifest,omitempty"`
+	Resume    bool              `json:"resume,omitempty"`
+}
+
+// UploadFileEntry describes a file the client intends to upload
+type UploadFileEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
 }
 
 // UploadInitResponse represents the upload initialization response
+// Missing is null when the request carried no manifest; otherwise it lists one
+// file per blob the server does not have yet and is empty when nothing needs uploading
+// Resumed is true when an unfinished session of the same caller and manifest was reused
 type UploadInitResponse struct {
-	UploadID string `json:"upload_id"`
+	UploadID  string            `json:"upload_id"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Missing   []UploadFileEntry `json:"missing"`
+	Resumed   bool              `json:"resumed"`
 }
 
 // FinishUploadRequest represents the finish upload request
+// UploadID may be omitted by older agents; the newest active session of the version is used
+// Force allows overwriting a pinned version or a version on a channel
 type FinishUploadRequest struct {
+	UploadID string            `json:"upload_id"`
 	Project  string            `json:"project" binding:"required"`
 	App      string            `json:"app" binding:"required"`
 	Version  string            `json:"version" binding:"required"`
 	Manifest *storage.Manifest `json:"manifest" binding:"required"`
+	Force    bool              `json:"force,omitempty"`
+}
+
+// FinishUploadErrorResponse is returned when the uploaded files do not match the manifest
+type FinishUploadErrorResponse struct {
+	Error    string                 `json:"error" example:"manifest_mismatch"`
+	Message  string                 `json:"message"`
+	BadFiles []storage.FileMismatch `json:"bad_files"`
 }
 
 // handleInitUpload godoc
 // @Summary      Initialize upload
 // @Description  Initialize a new artifact upload session. Returns upload ID for subsequent file uploads.
+// @Description  Files are staged under the session; an existing version is only replaced when the upload is finished.
+// @Description  If the request includes the manifest (path, sha256, size), the response lists the files whose content the server does not have yet; only those need to be uploaded.
+// @Description  With resume set, the caller's unfinished session for the same version and manifest is reused and files it already received are not listed as missing.
 // @Tags         artifacts
 // @Accept       json
 // @Produce      json
@@ -67,45 +100,110 @@ func (h *Handler) handleInitUpload(c *gin.Context) {
 		return
 	}
 
-	app, err := h.appRepo.CreateOrGet(project.ID, req.App)
+	if _, err := h.appRepo.CreateOrGet(project.ID, req.App); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var missing []UploadFileEntry
+	if req.Manifest != nil {
+		missing, err = h.missingFiles(c, req.Manifest)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.Resume && req.Manifest != nil {
+		if session := h.resumableUploadSession(c, req); session != nil {
+			sessionRepo := database.NewUploadSessionRepository(h.db)
+			staged, err := sessionRepo.ListFiles(session.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			stillMissing := make([]UploadFileEntry, 0, len(missing))
+			for _, file := range missing {
+				if _, ok := staged[file.SHA256]; !ok {
+					stillMissing = append(stillMissing, file)
+				}
+			}
+			c.JSON(http.StatusOK, UploadInitResponse{
+				UploadID:  session.ID,
+				ExpiresAt: session.ExpiresAt,
+				Missing:   stillMissing,
+				Resumed:   true,
+			})
+			return
+		}
+	}
+
+	// Existing versions are left untouched here; they are replaced on finish
+	uploadID, err := newUploadSessionID()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Check if version already exists - if it does, delete it to allow overwrite
-	versionPath := filepath.Join(req.Project, req.App, req.Version, "meta.yaml")
-	exists, err := h.storage.Exists(c.Request.Context(), versionPath)
+	var expectedFiles interface{}
+	if req.Manifest != nil {
+		expectedFiles = req.Manifest
+	}
+	tokenID, username := uploadOwner(c)
+	sessionRepo := database.NewUploadSessionRepository(h.db)
+	session, err := sessionRepo.Create(uploadID, req.Project, req.App, req.Version, tokenID, username, expectedFiles, time.Now().Add(uploadSessionTTL))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	if exists {
-		// Delete existing version from storage to allow overwrite
-		err := h.artifactManager.DeleteVersion(c.Request.Context(), req.Project, req.App, req.Version)
+
+	c.JSON(http.StatusOK, UploadInitResponse{
+		UploadID:  session.ID,
+		ExpiresAt: session.ExpiresAt,
+		Missing:   missing,
+	})
+}
+
+// missingFiles returns the manifest entries whose blobs are not in storage yet
+// Files sharing the same content are reported once
+func (h *Handler) missingFiles(c *gin.Context, files []UploadFileEntry) ([]UploadFileEntry, error) {
+	missing := make([]UploadFileEntry, 0)
+	checked := make(map[string]bool, len(files))
+
+	for _, file := range files {
+		if err := storage.ValidatePath(file.Path); err != nil {
+			return nil, fmt.Errorf("invalid path %s: %w", file.Path, err)
+		}
+		if !storage.IsBlobHash(file.SHA256) {
+			return nil, fmt.Errorf("invalid sha256 for %s: %s", file.Path, file.SHA256)
+		}
+		if checked[file.SHA256] {
+			continue
+		}
+		checked[file.SHA256] = true
+
+		exists, err := h.artifactManager.HasBlob(c.Request.Context(), file.SHA256)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to delete existing version: %v", err)})
-			return
+			return nil, fmt.Errorf("failed to check blob for %s: %w", file.Path, err)
+		}
+		if !exists {
+			missing = append(missing, file)
 		}
-		// Also delete from database (ignore error if version doesn't exist in DB)
-		_ = h.versionRepo.Delete(app.ID, req.Version)
 	}
 
-	// For now, return a simple upload ID (in production, use UUID)
-	uploadID := fmt.Sprintf("%s-%s-%s", req.Project, req.App, req.Version)
-	
-	c.JSON(http.StatusOK, UploadInitResponse{
-		UploadID: uploadID,
-	})
+	return missing, nil
 }
 
 // handleUploadFile handles file upload
 // The hash parameter in the URL is the version (not the file's SHA256)
-// Files are stored under {project}/{app}/{version}/{filePath}
+// Files are staged under the upload session (upload_id form field) and moved to
+// the content-addressed blob store when the upload finishes. Content the server
+// already has is not stored again.
 func (h *Handler) handleUploadFile(c *gin.Context) {
-	project := c.Param("project")
-	app := c.Param("app")
-	version := c.Param("hash") // This is actually the version, not file hash
+	session, ok := h.loadUploadSession(c, c.PostForm("upload_id"), c.Param("project"), c.Param("app"), c.Param("hash"))
+	if !ok {
+		return
+	}
 
 	// Get file from multipart form
 	file, _, err := c.Request.FormFile("file")
@@ -128,12 +226,9 @@ func (h *Handler) handleUploadFile(c *gin.Context) {
 		return
 	}
 
-	// Calculate file size and SHA256 for response
-	// Note: We don't validate SHA256 here because the hash param is the version, not file hash
-	// File integrity is verified via manifest validation in finish upload
-	hashReader := io.TeeReader(file, io.Discard)
+	// Calculate file size and SHA256, which is the blob key
 	hash := sha256.New()
-	fileSize, err := io.Copy(hash, hashReader)
+	fileSize, err := io.Copy(hash, file)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -141,27 +236,55 @@ func (h *Handler) handleUploadFile(c *gin.Context) {
 
 	calculatedHash := fmt.Sprintf("%x", hash.Sum(nil))
 
-	// Reset file reader
-	file.Seek(0, io.SeekStart)
+	// Files announced at init must match what was announced
+	if expected, ok := expectedUploadFiles(session); ok {
+		entry, found := expected[filePath]
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("file %s is not part of upload session %s", filePath, session.ID)})
+			return
+		}
+		if entry.SHA256 != calculatedHash {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("file %s does not match upload manifest: expected sha256 %s, got %s", filePath, entry.SHA256, calculatedHash)})
+			return
+		}
+	}
 
-	// Store file under {project}/{app}/{version}/{filePath}
-	fullPath := filepath.Join(project, app, version, filePath)
-	if err := h.storage.Put(c.Request.Context(), fullPath, file, fileSize); err != nil {
+	// Skip the write if the content is already in the blob store
+	exists, err := h.artifactManager.HasBlob(c.Request.Context(), calculatedHash)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if !exists {
+		// Reset file reader
+		file.Seek(0, io.SeekStart)
+
+		if err := h.artifactManager.StageBlob(c.Request.Context(), session.ID, calculatedHash, file, fileSize); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		sessionRepo := database.NewUploadSessionRepository(h.db)
+		if err := sessionRepo.AddFile(session.ID, calculatedHash, fileSize); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status": "uploaded",
-		"hash":   calculatedHash, // Return calculated file hash for reference
-		"size":   fileSize,
+		"status":       "uploaded",
+		"hash":         calculatedHash, // Return calculated file hash for reference
+		"size":         fileSize,
+		"deduplicated": exists,
 	})
 }
 
 // handleFinishUpload finishes an upload session and creates the version
 // handleFinishUpload godoc
 // @Summary      Finish upload
-// @Description  Complete the artifact upload and create version record
+// @Description  Complete the artifact upload and create version record. Staged files are moved into the blob store and an existing version with the same name is replaced.
+// @Description  Each manifest file must exist with the declared size and SHA256; otherwise nothing is committed and the bad files are listed.
+// @Description  The session is claimed while the version is committed; a concurrent finish or abort of it gets 409.
+// @Description  Overwriting a pinned version or a version on a channel gets 409 unless force is set; the new record is unpinned, has no labels and is on no channel.
 // @Tags         artifacts
 // @Accept       json
 // @Produce      json
@@ -169,22 +292,47 @@ func (h *Handler) handleUploadFile(c *gin.Context) {
 // @Success      200      {object}  map[string]string
 // @Failure      400      {object}  ErrorResponse
 // @Failure      401      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
+// @Failure      422      {object}  FinishUploadErrorResponse
 // @Failure      500      {object}  ErrorResponse
 // @Security     Bearer
 // @Router       /upload/finish [post]
 func (h *Handler) handleFinishUpload(c *gin.Context) {
-	var req struct {
-		Project   string                  `json:"project" binding:"required"`
-		App       string                  `json:"app" binding:"required"`
-		Version   string                  `json:"version" binding:"required"`
-		Manifest  *storage.Manifest       `json:"manifest" binding:"required"`
-	}
-
+	var req FinishUploadRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	session, ok := h.loadUploadSession(c, req.UploadID, req.Project, req.App, req.Version)
+	if !ok {
+		return
+	}
+	sessionRepo := database.NewUploadSessionRepository(h.db)
+	ctx := c.Request.Context()
+
+	// Claim the session so a concurrent finish, abort or expiry cannot discard
+	// its staged files while they are being committed
+	claimed, err := sessionRepo.Claim(session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !claimed {
+		c.JSON(http.StatusConflict, gin.H{"error": "upload session is no longer active"})
+		return
+	}
+	// Until the version is committed, a failed finish hands the session back
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if _, err := sessionRepo.Unclaim(session.ID); err != nil {
+			log.Printf("Warning: failed to reopen upload session %s: %v", session.ID, err)
+		}
+	}()
+
 	// Get or create project and app
 	project, err := h.projectRepo.CreateOrGet(req.Project)
 	if err != nil {
@@ -198,23 +346,115 @@ func (h *Handler) handleFinishUpload(c *gin.Context) {
 		return
 	}
 
-	// Store manifest
-	manifestBytes, err := storage.SerializeManifest(req.Manifest)
+	// An overwritten version gets a fresh record and leaves its channels, so
+	// pinned versions and versions on a channel are kept unless the caller insists
+	if !req.Force {
+		if old, err := h.versionRepo.GetByHash(app.ID, req.Version); err == nil && old.Pinned {
+			c.JSON(http.StatusConflict, gin.H{"error": "version is pinned; unpin it or pass force=true to overwrite it"})
+			return
+		}
+		channels, err := database.NewChannelRepository(h.db).ChannelsByVersion(app.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if onChannels := channels[req.Version]; len(onChannels) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("version is on channel %s; move the channel or pass force=true to overwrite it", strings.Join(onChannels, ", "))})
+			return
+		}
+	}
+
+	staged, err := sessionRepo.ListFiles(session.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	manifestPath := filepath.Join(req.Project, req.App, req.Version, "meta.yaml")
-	manifestReader := strings.NewReader(string(manifestBytes))
-	if err := h.storage.Put(c.Request.Context(), manifestPath, manifestReader, int64(len(manifestBytes))); err != nil {
+	// Every manifest file must exist with the declared size and hash
+	// The session stays active so the client can re-upload the bad files
+	badFiles, err := h.artifactManager.VerifyUpload(ctx, session.ID, req.Manifest, staged)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if len(badFiles) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, FinishUploadErrorResponse{
+			Error:    "manifest_mismatch",
+			Message:  fmt.Sprintf("%d of %d files do not match the manifest", len(badFiles), len(req.Manifest.Files)),
+			BadFiles: badFiles,
+		})
+		return
+	}
 
+	// Move staged files into the blob store; everything else is already there
+	for _, file := range req.Manifest.Files {
+		size, ok := staged[file.SHA256]
+		if !ok {
+			continue
+		}
+		if err := h.artifactManager.PromoteStagedBlob(ctx, session.ID, file.SHA256, size); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		delete(staged, file.SHA256)
+	}
+
+	// Replace the existing version only now that all new data is in place
+	versionExists, err := h.storage.Exists(ctx, filepath.Join(req.Project, req.App, req.Version, "meta.yaml"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Store manifest pointing at the uploaded blobs
+	if err := h.artifactManager.CommitManifest(ctx, req.Project, req.App, req.Version, req.Manifest); err != nil {
+		// Blobs found present may have been garbage collected since; they must be uploaded again
+		var missingErr *storage.MissingBlobsError
+		if errors.As(err, &missingErr) {
+			badFiles := missingErr.Mismatches(req.Manifest)
+			c.JSON(http.StatusUnprocessableEntity, FinishUploadErrorResponse{
+				Error:    "manifest_mismatch",
+				Message:  fmt.Sprintf("%d of %d files were removed from storage during the upload", len(badFiles), len(req.Manifest.Files)),
+				BadFiles: badFiles,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	committed = true
+
+	// Staged files the manifest did not use are no longer needed
+	leftover := make([]string, 0, len(staged))
+	for hash := range staged {
+		leftover = append(leftover, hash)
+	}
+	if err := h.artifactManager.DiscardStaging(ctx, session.ID, leftover); err != nil {
+		log.Printf("Warning: failed to clean up staging for upload session %s: %v", session.ID, err)
+	}
+	if err := h.artifactManager.AbortChunkedUploads(ctx, database.NewChunkedUploadRepository(h.db), session.ID); err != nil {
+		log.Printf("Warning: failed to abort chunked uploads of upload session %s: %v", session.ID, err)
+	}
+	if _, err := sessionRepo.Complete(session.ID); err != nil {
+		log.Printf("Warning: failed to complete upload session %s: %v", session.ID, err)
+	}
+
+	// An overwritten version gets a fresh record (unpublished, unpinned, no labels, new creation time)
 	// Create version record in database
 	// Uses ON CONFLICT DO NOTHING for idempotency - handles race conditions gracefully
-	_, err = h.versionRepo.Create(app.ID, req.Version)
+	if versionExists {
+		// The overwritten version is no longer published or on any channel
+		old, getErr := h.versionRepo.GetByHash(app.ID, req.Version)
+		_, err = h.versionRepo.Replace(app.ID, req.Version)
+		if err == nil && getErr == nil && old.IsPublished {
+			h.recordChannelHistory(c, app.ID, DefaultChannel, "", req.Version, database.ChannelActionDelete)
+		}
+		if err == nil {
+			h.clearVersionChannels(c, app.ID, req.Version)
+		}
+	} else {
+		_, err = h.versionRepo.Create(app.ID, req.Version)
+	}
 	if err != nil {
 		// Log error but don't fail the request - version exists in storage which is what matters most
 		// The Create method now handles conflicts gracefully using ON CONFLICT DO NOTHING
@@ -241,7 +481,7 @@ func (h *Handler) handleFinishUpload(c *gin.Context) {
 
 	h.publishEventWithContext(
 		c,
-		"push",
+		events.EventTypeVersionPushed,
 		req.Project,
 		req.App,
 		req.Version,
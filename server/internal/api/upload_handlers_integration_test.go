@@ -0,0 +1,121 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kk/kkartifact-server/internal/auth"
+	"github.com/kk/kkartifact-server/internal/database"
+)
+
+// finishEmptyUpload opens an upload session of shop/api@v1 as alice and finishes it
+// with an empty manifest
+func finishEmptyUpload(t *testing.T, h *Handler, force bool) *httptest.ResponseRecorder {
+	t.Helper()
+	sessionRepo := database.NewUploadSessionRepository(h.db)
+	if _, err := sessionRepo.Create("up-1", "shop", "api", "v1", nil, "alice", nil, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to create upload session: %v", err)
+	}
+
+	body := `{"upload_id":"up-1","project":"shop","app":"api","version":"v1","manifest":{"project":"shop","app":"api","version":"v1","files":[]}`
+	if force {
+		body += `,"force":true`
+	}
+	body += `}`
+	alice := &auth.SessionInfo{UserID: 1, Username: "alice"}
+	return callHandlerAs(alice, h.handleFinishUpload, http.MethodPost, "/api/v1/upload/finish", body)
+}
+
+func TestHandleFinishUpload_Pinned(t *testing.T) {
+	tests := []struct {
+		name       string
+		force      bool
+		wantStatus int
+	}{
+		{"without force", false, http.StatusConflict},
+		{"with force", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newIntegrationHandler(t)
+			app, _ := createTestVersion(t, h, "shop", "api", "v1")
+			if err := h.versionRepo.SetPinned(app.ID, "v1", true); err != nil {
+				t.Fatalf("Failed to pin version: %v", err)
+			}
+			sessionRepo := database.NewUploadSessionRepository(h.db)
+
+			w := finishEmptyUpload(t, h, tt.force)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			version, err := h.versionRepo.GetByHash(app.ID, "v1")
+			if err != nil {
+				t.Fatalf("Failed to get version: %v", err)
+			}
+			if version.Pinned != !tt.force {
+				t.Errorf("Expected pinned=%v after finish, got %v", !tt.force, version.Pinned)
+			}
+
+			// A refused finish hands the session back so the push can be retried
+			session, err := sessionRepo.GetByID("up-1")
+			if err != nil {
+				t.Fatalf("Failed to get upload session: %v", err)
+			}
+			wantSession := database.UploadSessionActive
+			if tt.force {
+				wantSession = database.UploadSessionCompleted
+			}
+			if session.Status != wantSession {
+				t.Errorf("Expected session %s, got %s", wantSession, session.Status)
+			}
+		})
+	}
+}
+
+func TestHandleFinishUpload_OnChannel(t *testing.T) {
+	tests := []struct {
+		name        string
+		force       bool
+		wantStatus  int
+		wantChannel bool
+	}{
+		{"without force", false, http.StatusConflict, true},
+		{"with force", true, http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newIntegrationHandler(t)
+			app, _ := createTestVersion(t, h, "shop", "api", "v1")
+			channelRepo := database.NewChannelRepository(h.db)
+			if _, err := channelRepo.Set(app.ID, "prod", "v1", "user:alice"); err != nil {
+				t.Fatalf("Failed to set channel: %v", err)
+			}
+
+			w := finishEmptyUpload(t, h, tt.force)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			// An overwritten version leaves its channels
+			channel, err := channelRepo.Get(app.ID, "prod")
+			if err != nil {
+				t.Fatalf("Failed to get channel: %v", err)
+			}
+			if (channel != nil) != tt.wantChannel {
+				t.Errorf("Expected channel set=%v, got %+v", tt.wantChannel, channel)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/storage"
+)
+
+func sha256Hex(content string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+}
+
+func newTestContext() *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	return c
+}
+
+func newBlobTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	localStorage, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	return &Handler{
+		storage:         localStorage,
+		artifactManager: storage.NewArtifactManager(localStorage, nil),
+	}
+}
+
+func TestMissingFiles(t *testing.T) {
+	h := newBlobTestHandler(t)
+	c := newTestContext()
+
+	stored := "already stored"
+	storedHash := sha256Hex(stored)
+	if _, err := h.artifactManager.PutBlob(c.Request.Context(), storedHash, strings.NewReader(stored), int64(len(stored))); err != nil {
+		t.Fatalf("Failed to put blob: %v", err)
+	}
+	newHash := sha256Hex("new content")
+
+	files := []UploadFileEntry{
+		{Path: "bin/app", SHA256: storedHash, Size: int64(len(stored))},
+		{Path: "bin/tool", SHA256: newHash, Size: 11},
+		{Path: "bin/tool-copy", SHA256: newHash, Size: 11},
+	}
+
+	missing, err := h.missingFiles(c, files)
+	if err != nil {
+		t.Fatalf("missingFiles() error = %v", err)
+	}
+	if len(missing) != 1 {
+		t.Fatalf("Expected 1 missing file, got %d: %v", len(missing), missing)
+	}
+	if missing[0].Path != "bin/tool" || missing[0].SHA256 != newHash {
+		t.Errorf("Expected bin/tool to be missing, got %+v", missing[0])
+	}
+}
+
+func TestMissingFiles_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		file UploadFileEntry
+	}{
+		{"path traversal", UploadFileEntry{Path: "../etc/passwd", SHA256: sha256Hex("x")}},
+		{"invalid hash", UploadFileEntry{Path: "bin/app", SHA256: "not-a-hash"}},
+	}
+
+	h := newBlobTestHandler(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := h.missingFiles(newTestContext(), []UploadFileEntry{tt.file}); err == nil {
+				t.Error("Expected an error, got nil")
+			}
+		})
+	}
+}
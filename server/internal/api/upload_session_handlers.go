@@ -0,0 +1,209 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/auth"
+	"github.com/kk/kkartifact-server/internal/database"
+)
+
+// uploadSessionTTL is how long an upload session stays open before it is garbage-collected
+const uploadSessionTTL = 24 * time.Hour
+
+// AbortUploadRequest represents the abort upload request
+type AbortUploadRequest struct {
+	UploadID string `json:"upload_id" binding:"required"`
+	Project  string `json:"project" binding:"required"`
+	App      string `json:"app" binding:"required"`
+	Version  string `json:"version" binding:"required"`
+}
+
+// handleAbortUpload godoc
+// @Summary      Abort upload
+// @Description  Abort an upload session and discard its staged files. The existing version, if any, is left untouched.
+// @Tags         artifacts
+// @Accept       json
+// @Produce      json
+// @Param        request  body      AbortUploadRequest  true  "Upload abort request"
+// @Success      200      {object}  map[string]string
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /upload/abort [post]
+func (h *Handler) handleAbortUpload(c *gin.Context) {
+	var req AbortUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, ok := h.loadUploadSession(c, req.UploadID, req.Project, req.App, req.Version)
+	if !ok {
+		return
+	}
+
+	sessionRepo := database.NewUploadSessionRepository(h.db)
+	aborted, err := sessionRepo.SetStatus(session.ID, database.UploadSessionAborted)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !aborted {
+		c.JSON(http.StatusConflict, gin.H{"error": "upload session is no longer active"})
+		return
+	}
+
+	staged, err := sessionRepo.ListFiles(session.ID)
+	if err == nil {
+		hashes := make([]string, 0, len(staged))
+		for hash := range staged {
+			hashes = append(hashes, hash)
+		}
+		err = h.artifactManager.DiscardStaging(c.Request.Context(), session.ID, hashes)
+	}
+	if err != nil {
+		// The scheduler only collects active sessions, so log for manual cleanup
+		log.Printf("Warning: failed to discard staged files of upload session %s: %v", session.ID, err)
+	}
+	if err := h.artifactManager.AbortChunkedUploads(c.Request.Context(), database.NewChunkedUploadRepository(h.db), session.ID); err != nil {
+		log.Printf("Warning: failed to abort chunked uploads of upload session %s: %v", session.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "aborted",
+		"upload_id": session.ID,
+	})
+}
+
+// loadUploadSession loads the active upload session a request belongs to
+// and checks that it matches the target version and the caller owns it.
+// An empty uploadID selects the newest active session of the version (older agents).
+// On failure the error response has been written and false is returned.
+func (h *Handler) loadUploadSession(c *gin.Context, uploadID, project, app, version string) (*database.UploadSession, bool) {
+	sessionRepo := database.NewUploadSessionRepository(h.db)
+
+	var session *database.UploadSession
+	var err error
+	if uploadID != "" {
+		session, err = sessionRepo.GetByID(uploadID)
+	} else {
+		session, err = sessionRepo.GetActiveByVersion(project, app, version)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload session not found, call /upload/init first"})
+		return nil, false
+	}
+
+	if session.Project != project || session.App != app || session.Version != version {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("upload session %s belongs to %s/%s/%s", session.ID, session.Project, session.App, session.Version)})
+		return nil, false
+	}
+
+	if !ownsUploadSession(c, session) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "forbidden",
+			"message": "upload session belongs to another client",
+		})
+		return nil, false
+	}
+
+	if session.Status != database.UploadSessionActive {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("upload session is %s", session.Status)})
+		return nil, false
+	}
+	if time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "upload session has expired"})
+		return nil, false
+	}
+
+	return session, true
+}
+
+// uploadOwner returns the token or user that owns an upload session created by this request
+func uploadOwner(c *gin.Context) (*int, string) {
+	if tokenInfo := auth.GetTokenInfo(c); tokenInfo != nil {
+		tokenID := tokenInfo.TokenID
+		return &tokenID, ""
+	}
+	if sessionInfo := auth.GetSessionInfo(c); sessionInfo != nil {
+		return nil, sessionInfo.Username
+	}
+	return nil, ""
+}
+
+// ownsUploadSession checks that the caller created the session
+// Admin users may act on any session
+func ownsUploadSession(c *gin.Context, session *database.UploadSession) bool {
+	if sessionInfo := auth.GetSessionInfo(c); sessionInfo != nil {
+		if sessionInfo.IsAdmin {
+			return true
+		}
+		return session.Username.Valid && session.Username.String == sessionInfo.Username
+	}
+	if tokenInfo := auth.GetTokenInfo(c); tokenInfo != nil {
+		return session.TokenID.Valid && int(session.TokenID.Int64) == tokenInfo.TokenID
+	}
+	return false
+}
+
+// expectedUploadFiles returns the files announced at init, keyed by path
+// Returns false if the session was created without a manifest
+func expectedUploadFiles(session *database.UploadSession) (map[string]UploadFileEntry, bool) {
+	if !session.ExpectedFiles.Valid {
+		return nil, false
+	}
+	var files []UploadFileEntry
+	if err := json.Unmarshal([]byte(session.ExpectedFiles.String), &files); err != nil {
+		return nil, false
+	}
+	expected := make(map[string]UploadFileEntry, len(files))
+	for _, file := range files {
+		expected[file.Path] = file
+	}
+	return expected, true
+}
+
+// newUploadSessionID generates a random upload session ID
+func newUploadSessionID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate upload ID: %w", err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// resumableUploadSession returns the caller's active session for the requested
+// version if it was started with the same manifest, or nil
+func (h *Handler) resumableUploadSession(c *gin.Context, req UploadInitRequest) *database.UploadSession {
+	sessionRepo := database.NewUploadSessionRepository(h.db)
+	session, err := sessionRepo.GetActiveByVersion(req.Project, req.App, req.Version)
+	if err != nil || !ownsUploadSession(c, session) {
+		return nil
+	}
+
+	expected, ok := expectedUploadFiles(session)
+	if !ok || len(expected) != len(req.Manifest) {
+		return nil
+	}
+	for _, file := range req.Manifest {
+		if expected[file.Path] != file {
+			return nil
+		}
+	}
+	return session
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
+)
+
+// labelKeyPattern is the form of a label key, e.g. branch or ci.pipeline
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// SetVersionLabelsRequest represents a request to set version labels
+type SetVersionLabelsRequest struct {
+	Labels map[string]string `json:"labels" binding:"required"` // e.g. {"branch": "main"}
+}
+
+// VersionLabelsResponse represents the labels of a version in API response
+type VersionLabelsResponse struct {
+	Version string            `json:"version"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// handleSetVersionLabels godoc
+// @Summary      Set version labels
+// @Description  Set labels of a version (e.g. branch=main, env=prod-candidate). Given keys are added or replaced; other labels are kept. The branch label is used by the keep-per-branch retention rule.
+// @Tags         projects
+// @Accept       json
+// @Produce      json
+// @Param        project  path      string                   true  "Project name"
+// @Param        app      path      string                   true  "App name"
+// @Param        version  path      string                   true  "Version hash"
+// @Param        request  body      SetVersionLabelsRequest  true  "Labels"
+// @Success      200      {object}  VersionLabelsResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/versions/{version}/labels [put]
+func (h *Handler) handleSetVersionLabels(c *gin.Context) {
+	var req SetVersionLabelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	labels := make(map[string]string, len(req.Labels))
+	for key, value := range req.Labels {
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if err := validateLabelKey(key); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("label key %q %v", key, err)})
+			return
+		}
+		if err := validateLabelValue(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("label %s value %v", key, err)})
+			return
+		}
+		labels[key] = value
+	}
+
+	version, ok := h.findVersion(c)
+	if !ok {
+		return
+	}
+
+	if err := h.versionRepo.SetLabels(version.ID, labels); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	current, err := h.versionRepo.GetLabels(version.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.publishEventWithContext(c, events.EventTypeVersionLabeled, c.Param("project"), c.Param("app"), version.Hash, "", map[string]interface{}{
+		"labels": current,
+	})
+
+	c.JSON(http.StatusOK, VersionLabelsResponse{Version: version.Hash, Labels: current})
+}
+
+// handleDeleteVersionLabel godoc
+// @Summary      Clear version label
+// @Description  Remove a label from a version
+// @Tags         projects
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        version  path      string  true  "Version hash"
+// @Param        key      path      string  true  "Label key"
+// @Success      200      {object}  VersionLabelsResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/versions/{version}/labels/{key} [delete]
+func (h *Handler) handleDeleteVersionLabel(c *gin.Context) {
+	version, ok := h.findVersion(c)
+	if !ok {
+		return
+	}
+
+	key := c.Param("key")
+	deleted, err := h.versionRepo.DeleteLabel(version.ID, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "label not found"})
+		return
+	}
+	current, err := h.versionRepo.GetLabels(version.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.publishEventWithContext(c, events.EventTypeVersionLabeled, c.Param("project"), c.Param("app"), version.Hash, "", map[string]interface{}{
+		"labels":  current,
+		"removed": key,
+	})
+
+	c.JSON(http.StatusOK, VersionLabelsResponse{Version: version.Hash, Labels: current})
+}
+
+// handlePinVersion godoc
+// @Summary      Pin version
+// @Description  Pin a version so it is kept forever: retention never prunes it and deleting it requires force=true
+// @Tags         projects
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        version  path      string  true  "Version hash"
+// @Success      200      {object}  map[string]interface{}
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/versions/{version}/pin [put]
+func (h *Handler) handlePinVersion(c *gin.Context) {
+	h.setVersionPinned(c, true)
+}
+
+// handleUnpinVersion godoc
+// @Summary      Unpin version
+// @Description  Unpin a version so retention and deletion treat it like any other version
+// @Tags         projects
+// @Produce      json
+// @Param        project  path      string  true  "Project name"
+// @Param        app      path      string  true  "App name"
+// @Param        version  path      string  true  "Version hash"
+// @Success      200      {object}  map[string]interface{}
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /projects/{project}/apps/{app}/versions/{version}/pin [delete]
+func (h *Handler) handleUnpinVersion(c *gin.Context) {
+	h.setVersionPinned(c, false)
+}
+
+// setVersionPinned pins or unpins the version of the request
+func (h *Handler) setVersionPinned(c *gin.Context, pinned bool) {
+	version, ok := h.findVersion(c)
+	if !ok {
+		return
+	}
+
+	if version.Pinned != pinned {
+		if err := h.versionRepo.SetPinned(version.AppID, version.Hash, pinned); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		eventType := events.EventTypeVersionPinned
+		if !pinned {
+			eventType = events.EventTypeVersionUnpinned
+		}
+		h.publishEventWithContext(c, eventType, c.Param("project"), c.Param("app"), version.Hash, "", nil)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"version": version.Hash, "pinned": pinned})
+}
+
+// findApp looks up the app named by the :project and :app path params
+// It writes the error response and returns false if it does not exist.
+func (h *Handler) findApp(c *gin.Context) (*database.App, bool) {
+	project, err := h.projectRepo.GetByName(c.Param("project"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return nil, false
+	}
+	app, err := h.appRepo.GetByName(project.ID, c.Param("app"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "app not found"})
+		return nil, false
+	}
+	return app, true
+}
+
+// findVersion looks up the version named by the :project, :app and :version path params
+// It writes the error response and returns false if it does not exist.
+func (h *Handler) findVersion(c *gin.Context) (*database.Version, bool) {
+	app, ok := h.findApp(c)
+	if !ok {
+		return nil, false
+	}
+	version, err := h.versionRepo.GetByHash(app.ID, c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+		return nil, false
+	}
+	return version, true
+}
+
+// parseLabelSelectors parses label filters of the form key=value, or key for any value
+func parseLabelSelectors(values []string) ([]database.LabelSelector, error) {
+	var selectors []database.LabelSelector
+	for _, value := range splitQueryList(values) {
+		key, labelValue, _ := strings.Cut(value, "=")
+		key = strings.TrimSpace(key)
+		if err := validateLabelKey(key); err != nil {
+			return nil, fmt.Errorf("invalid label filter %q: key %v", value, err)
+		}
+		selectors = append(selectors, database.LabelSelector{Key: key, Value: strings.TrimSpace(labelValue)})
+	}
+	return selectors, nil
+}
+
+// validateLabelKey checks a label key
+func validateLabelKey(key string) error {
+	if key == "" {
+		return errors.New("must not be empty")
+	}
+	if len(key) > 100 {
+		return errors.New("must be at most 100 characters")
+	}
+	if !labelKeyPattern.MatchString(key) {
+		return errors.New("may only contain letters, digits, '.', '_' and '-'")
+	}
+	return nil
+}
+
+// validateLabelValue checks a label value
+// Commas are not allowed, as label filters are comma-separated.
+func validateLabelValue(value string) error {
+	if value == "" {
+		return errors.New("must not be empty")
+	}
+	if len(value) > 255 {
+		return errors.New("must be at most 255 characters")
+	}
+	if strings.Contains(value, ",") {
+		return errors.New("must not contain commas")
+	}
+	return nil
+}
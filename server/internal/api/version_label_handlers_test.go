@@ -0,0 +1,70 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kk/kkartifact-server/internal/database"
+)
+
+func TestParseLabelSelectors(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    []database.LabelSelector
+		wantErr bool
+	}{
+		{"none", nil, nil, false},
+		{"key and value", []string{"env=prod"}, []database.LabelSelector{{Key: "env", Value: "prod"}}, false},
+		{"key only", []string{"release"}, []database.LabelSelector{{Key: "release"}}, false},
+		{"comma separated", []string{"env=prod, tier=gold"}, []database.LabelSelector{{Key: "env", Value: "prod"}, {Key: "tier", Value: "gold"}}, false},
+		{"repeated", []string{"env=prod", "release"}, []database.LabelSelector{{Key: "env", Value: "prod"}, {Key: "release"}}, false},
+		{"value with equals", []string{"build=a=b"}, []database.LabelSelector{{Key: "build", Value: "a=b"}}, false},
+		{"empty key", []string{"=prod"}, nil, true},
+		{"invalid key", []string{"bad key=x"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLabelSelectors(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLabelSelectors(%v) error = %v, wantErr %v", tt.values, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLabelSelectors(%v) = %v, want %v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateLabel(t *testing.T) {
+	tests := []struct {
+		key, value string
+		wantErr    bool
+	}{
+		{"env", "prod", false},
+		{"build.id_2-x", "a b/c", false},
+		{"", "prod", true},
+		{"env/prod", "x", true},
+		{strings.Repeat("k", 101), "x", true},
+		{"env", "", true},
+		{"env", "a,b", true},
+		{"env", strings.Repeat("v", 256), true},
+	}
+
+	for _, tt := range tests {
+		err := validateLabelKey(tt.key)
+		if err == nil {
+			err = validateLabelValue(tt.value)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validate label %q=%q error = %v, wantErr %v", tt.key, tt.value, err, tt.wantErr)
+		}
+	}
+}
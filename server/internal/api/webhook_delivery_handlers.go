@@ -0,0 +1,445 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
+)
+
+// WebhookDeliveryResponse represents a webhook delivery in API response
+type WebhookDeliveryResponse struct {
+	ID             int64           `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	EventType      string          `json:"event_type"`
+	Status         string          `json:"status"` // pending, delivering, succeeded or failed
+	Attempts       int             `json:"attempts"`
+	MaxAttempts    int             `json:"max_attempts"`
+	NextAttemptAt  *string         `json:"next_attempt_at,omitempty"` // RFC3339, while pending
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	RedeliveryOf   *int64          `json:"redelivery_of,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      string          `json:"created_at"`
+	CompletedAt    *string         `json:"completed_at,omitempty"`
+}
+
+// WebhookDeliveriesListResponse represents the paginated webhook deliveries API response
+type WebhookDeliveriesListResponse struct {
+	Data  []WebhookDeliveryResponse `json:"data"`
+	Total int                       `json:"total"`
+}
+
+// WebhookDeliveryAttemptResponse represents one delivery attempt in API response
+type WebhookDeliveryAttemptResponse struct {
+	Attempt         int               `json:"attempt"`
+	RequestURL      string            `json:"request_url"`
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	StatusCode      *int              `json:"status_code,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	ResponseBody    string            `json:"response_body,omitempty"`
+	DurationMs      int               `json:"duration_ms"`
+	Error           *string           `json:"error,omitempty"`
+	CreatedAt       string            `json:"created_at"`
+}
+
+// WebhookDeliveryDetailResponse represents a webhook delivery with its attempts
+type WebhookDeliveryDetailResponse struct {
+	WebhookDeliveryResponse
+	AttemptLog []WebhookDeliveryAttemptResponse `json:"attempt_log"`
+}
+
+// handleListWebhookDeliveries godoc
+// @Summary      List webhook deliveries
+// @Description  Get the deliveries of a webhook, newest first, with optional status filter. Returns paginated results with total count.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id      path      int     true   "Webhook ID"
+// @Param        status  query     string  false  "Filter by status (pending, delivering, succeeded, failed)"
+// @Param        limit   query     int     false  "Limit number of results (default: 50)"
+// @Param        offset  query     int     false  "Offset for pagination (default: 0)"
+// @Success      200     {object}  WebhookDeliveriesListResponse
+// @Failure      400     {object}  ErrorResponse
+// @Failure      401     {object}  ErrorResponse
+// @Failure      404     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /webhooks/{id}/deliveries [get]
+func (h *Handler) handleListWebhookDeliveries(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	limit := getIntQuery(c, "limit", 50)
+	offset := getIntQuery(c, "offset", 0)
+
+	deliveryRepo := database.NewWebhookDeliveryRepository(h.db)
+	deliveries, total, err := deliveryRepo.ListByWebhook(webhook.ID, c.Query("status"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responses := make([]WebhookDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		responses[i] = toWebhookDeliveryResponse(delivery)
+	}
+
+	c.JSON(http.StatusOK, WebhookDeliveriesListResponse{
+		Data:  responses,
+		Total: total,
+	})
+}
+
+// handleGetWebhookDelivery godoc
+// @Summary      Get webhook delivery
+// @Description  Get a webhook delivery with the request and response of every attempt
+// @Tags         webhooks
+// @Produce      json
+// @Param        id           path      int  true  "Webhook ID"
+// @Param        delivery_id  path      int  true  "Delivery ID"
+// @Success      200          {object}  WebhookDeliveryDetailResponse
+// @Failure      400          {object}  ErrorResponse
+// @Failure      401          {object}  ErrorResponse
+// @Failure      404          {object}  ErrorResponse
+// @Failure      500          {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /webhooks/{id}/deliveries/{delivery_id} [get]
+func (h *Handler) handleGetWebhookDelivery(c *gin.Context) {
+	delivery, ok := h.loadWebhookDelivery(c)
+	if !ok {
+		return
+	}
+
+	attempts, err := database.NewWebhookDeliveryRepository(h.db).ListAttempts(delivery.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := WebhookDeliveryDetailResponse{
+		WebhookDeliveryResponse: toWebhookDeliveryResponse(delivery),
+		AttemptLog:              make([]WebhookDeliveryAttemptResponse, len(attempts)),
+	}
+	for i, attempt := range attempts {
+		item := WebhookDeliveryAttemptResponse{
+			Attempt:      attempt.Attempt,
+			RequestURL:   attempt.RequestURL,
+			RequestBody:  attempt.RequestBody.String,
+			ResponseBody: attempt.ResponseBody.String,
+			DurationMs:   attempt.DurationMs,
+			CreatedAt:    attempt.CreatedAt.Format(time.RFC3339),
+		}
+		if attempt.RequestHeaders.Valid {
+			_ = json.Unmarshal([]byte(attempt.RequestHeaders.String), &item.RequestHeaders)
+		}
+		if attempt.ResponseHeaders.Valid {
+			_ = json.Unmarshal([]byte(attempt.ResponseHeaders.String), &item.ResponseHeaders)
+		}
+		if attempt.StatusCode.Valid {
+			statusCode := int(attempt.StatusCode.Int64)
+			item.StatusCode = &statusCode
+		}
+		if attempt.Error.Valid {
+			item.Error = &attempt.Error.String
+		}
+		response.AttemptLog[i] = item
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// handleRedeliverWebhook godoc
+// @Summary      Redeliver webhook
+// @Description  Queue a new delivery of the event of an earlier delivery, with a fresh set of attempts
+// @Tags         webhooks
+// @Produce      json
+// @Param        id           path      int  true  "Webhook ID"
+// @Param        delivery_id  path      int  true  "Delivery ID"
+// @Success      202          {object}  WebhookDeliveryResponse
+// @Failure      400          {object}  ErrorResponse
+// @Failure      401          {object}  ErrorResponse
+// @Failure      404          {object}  ErrorResponse
+// @Failure      500          {object}  ErrorResponse
+// @Failure      503          {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *Handler) handleRedeliverWebhook(c *gin.Context) {
+	delivery, ok := h.loadWebhookDelivery(c)
+	if !ok {
+		return
+	}
+	if h.webhookDispatcher == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "webhook delivery is not available"})
+		return
+	}
+
+	redelivery, err := h.webhookDispatcher.Redeliver(delivery)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, toWebhookDeliveryResponse(redelivery))
+}
+
+// webhookTestBodySnippet limits how much of the receiver's response a test returns
+const webhookTestBodySnippet = 4 * 1024
+
+// TestWebhookRequest selects the synthetic event sent by a webhook test
+// Every field is optional: the event type defaults to the first type the
+// webhook subscribes to, project and app to the webhook's scope.
+type TestWebhookRequest struct {
+	EventType string `json:"event_type"`
+	Project   string `json:"project"`
+	App       string `json:"app"`
+	Version   string `json:"version"`
+}
+
+// WebhookTestRequestInfo describes the rendered test request
+type WebhookTestRequestInfo struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// TestWebhookResponse represents the result of a webhook test
+type TestWebhookResponse struct {
+	DryRun          bool                   `json:"dry_run"`
+	Request         WebhookTestRequestInfo `json:"request"`
+	Success         bool                   `json:"success"`
+	StatusCode      *int                   `json:"status_code,omitempty"`
+	ResponseHeaders map[string]string      `json:"response_headers,omitempty"`
+	ResponseBody    string                 `json:"response_body,omitempty"` // first 4 KiB
+	LatencyMs       int64                  `json:"latency_ms"`
+	Error           string                 `json:"error,omitempty"`
+}
+
+// handleTestWebhook godoc
+// @Summary      Test webhook
+// @Description  Send a synthetic event through the webhook's format, headers and signature and report the receiver's response. With dry_run=true the rendered request is returned without sending it. Test requests are not recorded as deliveries.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                 true   "Webhook ID"
+// @Param        dry_run  query     bool                false  "Only render the request"
+// @Param        request  body      TestWebhookRequest  false  "Synthetic event"
+// @Success      200      {object}  TestWebhookResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     Bearer
+// @Router       /webhooks/{id}/test [post]
+func (h *Handler) handleTestWebhook(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	h.testWebhook(c, webhook)
+}
+
+// testWebhook renders a synthetic event for a loaded webhook and sends it unless dry_run is set
+func (h *Handler) testWebhook(c *gin.Context, webhook *database.Webhook) {
+	// The body is optional
+	var req TestWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.EventType != "" {
+		eventType, err := events.ParseEventType(req.EventType)
+		if err != nil || eventType == events.EventTypeAll {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown event type %q", req.EventType)})
+			return
+		}
+		req.EventType = string(eventType)
+	}
+
+	event := h.newTestEvent(webhook, req)
+	request, err := events.NewWebhookRequest(webhook, "test", event)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := TestWebhookResponse{
+		DryRun: getBoolQuery(c, "dry_run"),
+		Request: WebhookTestRequestInfo{
+			URL:     request.URL,
+			Headers: request.Headers,
+			Body:    string(request.Body),
+		},
+	}
+	if response.DryRun {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	result, err := events.NewWebhookSender().Deliver(c.Request.Context(), request)
+	response.Success = err == nil
+	if err != nil {
+		response.Error = err.Error()
+	}
+	if result != nil {
+		response.Request.Headers = result.RequestHeaders
+		response.LatencyMs = result.Duration.Milliseconds()
+		if result.StatusCode != 0 {
+			response.StatusCode = &result.StatusCode
+			response.ResponseHeaders = result.ResponseHeaders
+			response.ResponseBody = result.ResponseBody
+			if len(response.ResponseBody) > webhookTestBodySnippet {
+				response.ResponseBody = strings.ToValidUTF8(response.ResponseBody[:webhookTestBodySnippet], "")
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// newTestEvent builds the synthetic event of a webhook test
+func (h *Handler) newTestEvent(webhook *database.Webhook, req TestWebhookRequest) *events.Event {
+	eventType := events.EventTypeVersionPushed
+	if req.EventType != "" {
+		eventType = events.EventType(req.EventType)
+	} else if len(webhook.EventTypes) > 0 && webhook.EventTypes[0] != string(events.EventTypeAll) {
+		eventType = events.EventType(webhook.EventTypes[0])
+	}
+
+	// Default to the webhook's scope so project/app filters on the receiver match
+	scope := h.toWebhookResponse(webhook)
+	project, app := req.Project, req.App
+	if project == "" {
+		project = "example-project"
+		if scope.ProjectName != nil {
+			project = *scope.ProjectName
+		}
+	}
+	if app == "" {
+		app = "example-app"
+		if scope.AppName != nil {
+			app = *scope.AppName
+		}
+	}
+	version := req.Version
+	if version == "" {
+		version = "v0.0.0-test"
+	}
+
+	metadata := map[string]interface{}{"test": true}
+	switch eventType {
+	case events.EventTypeVersionPushed:
+		metadata["file_count"] = 42
+		metadata["total_size"] = 12345678
+		metadata["git_commit"] = "0123456789abcdef0123456789abcdef01234567"
+		metadata["build_time"] = time.Now().UTC().Format(time.RFC3339)
+		metadata["builder"] = "webhook-test"
+	case events.EventTypeVersionPublished, events.EventTypeVersionUnpublished:
+		metadata["target_version"] = version
+	}
+
+	return &events.Event{
+		Type:      eventType,
+		Project:   project,
+		App:       app,
+		Version:   version,
+		AgentID:   "webhook-test",
+		Metadata:  metadata,
+		Timestamp: time.Now(),
+	}
+}
+
+// loadWebhook loads the webhook named by the :id path parameter
+// It writes the error response and returns false if the webhook cannot be loaded.
+func (h *Handler) loadWebhook(c *gin.Context) (*database.Webhook, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook ID"})
+		return nil, false
+	}
+
+	webhook, err := database.NewWebhookRepository(h.db).GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if webhook == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return nil, false
+	}
+	return webhook, true
+}
+
+// loadWebhookDelivery loads the delivery named by the :delivery_id path parameter
+// The delivery must belong to the webhook named by :id.
+func (h *Handler) loadWebhookDelivery(c *gin.Context) (*database.WebhookDelivery, bool) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return nil, false
+	}
+
+	deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery ID"})
+		return nil, false
+	}
+
+	delivery, err := database.NewWebhookDeliveryRepository(h.db).GetByID(deliveryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if delivery == nil || delivery.WebhookID != webhook.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+		return nil, false
+	}
+	return delivery, true
+}
+
+// toWebhookDeliveryResponse converts a delivery to its API response
+func toWebhookDeliveryResponse(delivery *database.WebhookDelivery) WebhookDeliveryResponse {
+	response := WebhookDeliveryResponse{
+		ID:          delivery.ID,
+		WebhookID:   delivery.WebhookID,
+		EventType:   delivery.EventType,
+		Status:      delivery.Status,
+		Attempts:    delivery.Attempts,
+		MaxAttempts: delivery.MaxAttempts,
+		Payload:     json.RawMessage(delivery.Payload),
+		CreatedAt:   delivery.CreatedAt.Format(time.RFC3339),
+	}
+	if delivery.Status == database.WebhookDeliveryPending {
+		nextAttemptAt := delivery.NextAttemptAt.Format(time.RFC3339)
+		response.NextAttemptAt = &nextAttemptAt
+	}
+	if delivery.LastStatusCode.Valid {
+		statusCode := int(delivery.LastStatusCode.Int64)
+		response.LastStatusCode = &statusCode
+	}
+	if delivery.LastError.Valid {
+		response.LastError = &delivery.LastError.String
+	}
+	if delivery.RedeliveryOf.Valid {
+		response.RedeliveryOf = &delivery.RedeliveryOf.Int64
+	}
+	if delivery.CompletedAt.Valid {
+		completedAt := delivery.CompletedAt.Time.Format(time.RFC3339)
+		response.CompletedAt = &completedAt
+	}
+	return response
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/pkg/webhook"
+)
+
+// runWebhookTest calls testWebhook with the given query and body and decodes the response
+func runWebhookTest(t *testing.T, hook *database.Webhook, query, body string) (int, TestWebhookResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/1/test?"+query, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	(&Handler{}).testWebhook(c, hook)
+
+	var response TestWebhookResponse
+	if recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+	return recorder.Code, response
+}
+
+func TestTestWebhook_DryRun(t *testing.T) {
+	var hits int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer receiver.Close()
+
+	hook := &database.Webhook{
+		ID:         1,
+		URL:        receiver.URL,
+		EventTypes: []string{"version.pushed"},
+		Secret:     sql.NullString{String: "s3cret", Valid: true},
+	}
+
+	status, response := runWebhookTest(t, hook, "dry_run=true", "")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if !response.DryRun || response.Success {
+		t.Errorf("Expected an unsent dry run, got dry_run=%v success=%v", response.DryRun, response.Success)
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Errorf("Expected no request to the receiver on a dry run, got %d", hits)
+	}
+	if response.Request.URL != receiver.URL {
+		t.Errorf("Expected URL %s, got %s", receiver.URL, response.Request.URL)
+	}
+	headers := response.Request.Headers
+	if headers[webhook.HeaderEvent] != "version.pushed" {
+		t.Errorf("Expected event header version.pushed, got %q", headers[webhook.HeaderEvent])
+	}
+	if err := webhook.Verify("s3cret", headers[webhook.HeaderSignature], headers[webhook.HeaderTimestamp], []byte(response.Request.Body), time.Minute); err != nil {
+		t.Errorf("Expected a valid signature on the rendered body: %v", err)
+	}
+	if !strings.Contains(response.Request.Body, `"project":"example-project"`) {
+		t.Errorf("Expected the default example project in the body, got %s", response.Request.Body)
+	}
+}
+
+func TestTestWebhook_Send(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		wantSuccess bool
+	}{
+		{"receiver accepts", http.StatusAccepted, true},
+		{"receiver fails", http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotEvent, gotProject string
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotEvent = r.Header.Get(webhook.HeaderEvent)
+				var payload map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&payload)
+				gotProject, _ = payload["project"].(string)
+				w.Header().Set("X-Receiver", "test")
+				w.WriteHeader(tt.status)
+				w.Write([]byte("received"))
+			}))
+			defer receiver.Close()
+
+			hook := &database.Webhook{ID: 1, URL: receiver.URL, EventTypes: []string{"version.pushed"}}
+			status, response := runWebhookTest(t, hook, "", `{"event_type":"app.deleted","project":"shop"}`)
+			if status != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", status)
+			}
+			if response.Success != tt.wantSuccess {
+				t.Errorf("Expected success=%v, got %v (error %q)", tt.wantSuccess, response.Success, response.Error)
+			}
+			if response.StatusCode == nil || *response.StatusCode != tt.status {
+				t.Errorf("Expected receiver status %d, got %v", tt.status, response.StatusCode)
+			}
+			if response.ResponseBody != "received" || response.ResponseHeaders["X-Receiver"] != "test" {
+				t.Errorf("Expected the receiver's response, got body %q headers %v", response.ResponseBody, response.ResponseHeaders)
+			}
+			if gotEvent != "app.deleted" || gotProject != "shop" {
+				t.Errorf("Expected an app.deleted event for shop, got %q for %q", gotEvent, gotProject)
+			}
+		})
+	}
+}
+
+func TestTestWebhook_BadRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		hook *database.Webhook
+		body string
+	}{
+		{"invalid body", &database.Webhook{URL: "http://127.0.0.1:1"}, `{"event_type":`},
+		{"broken template", &database.Webhook{URL: "http://127.0.0.1:1", Format: "template", Template: sql.NullString{String: "{{.Nope}}", Valid: true}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := runWebhookTest(t, tt.hook, "", tt.body); status != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", status)
+			}
+		})
+	}
+}
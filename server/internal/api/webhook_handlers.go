@@ -6,12 +6,15 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
 )
 
 // CreateWebhookRequest represents a webhook creation request
@@ -23,6 +26,9 @@ type CreateWebhookRequest struct {
 	Enabled    bool              `json:"enabled"`
 	ProjectID  *int              `json:"project_id,omitempty"`
 	AppID      *int              `json:"app_id,omitempty"`
+	Secret     string            `json:"secret,omitempty"` // Optional secret for signing requests (X-KKArtifact-Signature)
+	Format     string            `json:"format,omitempty"` // raw (default), slack, dingtalk, feishu, wecom, msteams or template
+	Template   string            `json:"template,omitempty"` // Go text/template for the body when format is template
 }
 
 // handleCreateWebhook godoc
@@ -44,68 +50,35 @@ func (h *Handler) handleCreateWebhook(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := events.ValidateWebhookFormat(req.Format, req.Template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	eventTypes, err := parseEventTypes(req.EventTypes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	webhookRepo := database.NewWebhookRepository(h.db)
 	webhook, err := webhookRepo.Create(
 		req.Name,
-		req.EventTypes,
+		eventTypes,
 		req.URL,
 		req.Headers,
 		req.Enabled,
 		req.ProjectID,
 		req.AppID,
+		req.Secret,
+		req.Format,
+		req.Template,
 	)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Convert to response format with project and app names
-	var projectID, appID *int
-	var projectName, appName *string
-
-	if webhook.ProjectID.Valid {
-		pid := int(webhook.ProjectID.Int64)
-		projectID = &pid
-		// Get project name
-		var name string
-		query := `SELECT name FROM projects WHERE id = $1`
-		if err := h.db.QueryRow(query, pid).Scan(&name); err == nil {
-			projectName = &name
-		}
-	}
-
-	if webhook.AppID.Valid {
-		aid := int(webhook.AppID.Int64)
-		appID = &aid
-		// Get app name
-		var name string
-		query := `SELECT name FROM apps WHERE id = $1`
-		if err := h.db.QueryRow(query, aid).Scan(&name); err == nil {
-			appName = &name
-		}
-	}
-
-	var headers *string
-	if webhook.Headers.Valid {
-		headers = &webhook.Headers.String
-	}
-
-	response := WebhookResponse{
-		ID:          webhook.ID,
-		Name:        webhook.Name,
-		EventTypes:  webhook.EventTypes,
-		URL:         webhook.URL,
-		Headers:     headers,
-		Enabled:     webhook.Enabled,
-		ProjectID:   projectID,
-		AppID:       appID,
-		ProjectName: projectName,
-		AppName:     appName,
-		CreatedAt:   webhook.CreatedAt.Format(time.RFC3339),
-	}
-
-	c.JSON(http.StatusCreated, response)
+	c.JSON(http.StatusCreated, h.toWebhookResponse(webhook))
 }
 
 // WebhookResponse represents a webhook in API response
@@ -120,6 +93,9 @@ type WebhookResponse struct {
 	AppID      *int    `json:"app_id,omitempty"`
 	ProjectName *string `json:"project_name,omitempty"`
 	AppName     *string `json:"app_name,omitempty"`
+	HasSecret   bool    `json:"has_secret"` // The secret itself is never returned
+	Format      string  `json:"format"`
+	Template    *string `json:"template,omitempty"`
 	CreatedAt  string  `json:"created_at"`
 }
 
@@ -145,49 +121,7 @@ func (h *Handler) handleListWebhooks(c *gin.Context) {
 	// Convert to response format with project and app names
 	responses := make([]WebhookResponse, len(webhooks))
 	for i, webhook := range webhooks {
-		var projectID, appID *int
-		var projectName, appName *string
-
-		if webhook.ProjectID.Valid {
-			pid := int(webhook.ProjectID.Int64)
-			projectID = &pid
-			// Get project name
-			var name string
-			query := `SELECT name FROM projects WHERE id = $1`
-			if err := h.db.QueryRow(query, pid).Scan(&name); err == nil {
-				projectName = &name
-			}
-		}
-
-		if webhook.AppID.Valid {
-			aid := int(webhook.AppID.Int64)
-			appID = &aid
-			// Get app name
-			var name string
-			query := `SELECT name FROM apps WHERE id = $1`
-			if err := h.db.QueryRow(query, aid).Scan(&name); err == nil {
-				appName = &name
-			}
-		}
-
-		var headers *string
-		if webhook.Headers.Valid {
-			headers = &webhook.Headers.String
-		}
-
-		responses[i] = WebhookResponse{
-			ID:          webhook.ID,
-			Name:        webhook.Name,
-			EventTypes:  webhook.EventTypes,
-			URL:         webhook.URL,
-			Headers:     headers,
-			Enabled:     webhook.Enabled,
-			ProjectID:   projectID,
-			AppID:       appID,
-			ProjectName: projectName,
-			AppName:     appName,
-			CreatedAt:   webhook.CreatedAt.Format(time.RFC3339),
-		}
+		responses[i] = h.toWebhookResponse(webhook)
 	}
 
 	c.JSON(http.StatusOK, responses)
@@ -213,52 +147,7 @@ func (h *Handler) handleGetWebhook(c *gin.Context) {
 		return
 	}
 
-	// Convert to response format with project and app names
-	var projectID, appID *int
-	var projectName, appName *string
-
-	if webhook.ProjectID.Valid {
-		pid := int(webhook.ProjectID.Int64)
-		projectID = &pid
-		// Get project name
-		var name string
-		query := `SELECT name FROM projects WHERE id = $1`
-		if err := h.db.QueryRow(query, pid).Scan(&name); err == nil {
-			projectName = &name
-		}
-	}
-
-	if webhook.AppID.Valid {
-		aid := int(webhook.AppID.Int64)
-		appID = &aid
-		// Get app name
-		var name string
-		query := `SELECT name FROM apps WHERE id = $1`
-		if err := h.db.QueryRow(query, aid).Scan(&name); err == nil {
-			appName = &name
-		}
-	}
-
-	var headers *string
-	if webhook.Headers.Valid {
-		headers = &webhook.Headers.String
-	}
-
-	response := WebhookResponse{
-		ID:          webhook.ID,
-		Name:        webhook.Name,
-		EventTypes:  webhook.EventTypes,
-		URL:         webhook.URL,
-		Headers:     headers,
-		Enabled:     webhook.Enabled,
-		ProjectID:   projectID,
-		AppID:       appID,
-		ProjectName: projectName,
-		AppName:     appName,
-		CreatedAt:   webhook.CreatedAt.Format(time.RFC3339),
-	}
-
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, h.toWebhookResponse(webhook))
 }
 
 // handleUpdateWebhook godoc
@@ -292,6 +181,9 @@ func (h *Handler) handleUpdateWebhook(c *gin.Context) {
 		Enabled    *bool             `json:"enabled"`
 		ProjectID  *int              `json:"project_id,omitempty"`
 		AppID      *int              `json:"app_id,omitempty"`
+		Secret     *string           `json:"secret"` // Omit to keep, empty string to remove
+		Format     *string           `json:"format"`
+		Template   *string           `json:"template"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -315,9 +207,13 @@ func (h *Handler) handleUpdateWebhook(c *gin.Context) {
 	if name == "" {
 		name = webhook.Name
 	}
-	eventTypes := req.EventTypes
-	if len(eventTypes) == 0 {
-		eventTypes = webhook.EventTypes
+	eventTypes := webhook.EventTypes
+	if len(req.EventTypes) > 0 {
+		eventTypes, err = parseEventTypes(req.EventTypes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
 	url := req.URL
 	if url == "" {
@@ -348,7 +244,25 @@ func (h *Handler) handleUpdateWebhook(c *gin.Context) {
 		}
 	}
 
-	if err := webhookRepo.Update(id, name, eventTypes, url, req.Headers, enabled, projectID, appID); err != nil {
+	secret := webhook.Secret.String
+	if req.Secret != nil {
+		secret = *req.Secret
+	}
+
+	format := webhook.Format
+	if req.Format != nil {
+		format = *req.Format
+	}
+	template := webhook.Template.String
+	if req.Template != nil {
+		template = *req.Template
+	}
+	if err := events.ValidateWebhookFormat(format, template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := webhookRepo.Update(id, name, eventTypes, url, req.Headers, enabled, projectID, appID, secret, format, template); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -359,7 +273,12 @@ func (h *Handler) handleUpdateWebhook(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, updated)
+	if updated == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toWebhookResponse(updated))
 }
 
 // handleDeleteWebhook godoc
@@ -391,3 +310,79 @@ func (h *Handler) handleDeleteWebhook(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
 }
 
+
+// toWebhookResponse converts a webhook to its API response, with project and app names
+func (h *Handler) toWebhookResponse(webhook *database.Webhook) WebhookResponse {
+	var projectID, appID *int
+	var projectName, appName *string
+
+	if webhook.ProjectID.Valid {
+		pid := int(webhook.ProjectID.Int64)
+		projectID = &pid
+		// Get project name
+		var name string
+		query := `SELECT name FROM projects WHERE id = $1`
+		if err := h.db.QueryRow(query, pid).Scan(&name); err == nil {
+			projectName = &name
+		}
+	}
+
+	if webhook.AppID.Valid {
+		aid := int(webhook.AppID.Int64)
+		appID = &aid
+		// Get app name
+		var name string
+		query := `SELECT name FROM apps WHERE id = $1`
+		if err := h.db.QueryRow(query, aid).Scan(&name); err == nil {
+			appName = &name
+		}
+	}
+
+	var headers *string
+	if webhook.Headers.Valid {
+		headers = &webhook.Headers.String
+	}
+	var template *string
+	if webhook.Template.Valid {
+		template = &webhook.Template.String
+	}
+
+	return WebhookResponse{
+		ID:          webhook.ID,
+		Name:        webhook.Name,
+		EventTypes:  webhook.EventTypes,
+		URL:         webhook.URL,
+		Headers:     headers,
+		Enabled:     webhook.Enabled,
+		ProjectID:   projectID,
+		AppID:       appID,
+		ProjectName: projectName,
+		AppName:     appName,
+		HasSecret:   webhook.Secret.Valid && webhook.Secret.String != "",
+		Format:      webhook.Format,
+		Template:    template,
+		CreatedAt:   webhook.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// parseEventTypes checks that webhook event types are in the event catalog
+// Legacy names such as push are stored as their catalog names; "*" subscribes
+// to every event.
+func parseEventTypes(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("at least one event type is required")
+	}
+	eventTypes := make([]string, 0, len(names))
+	seen := make(map[events.EventType]bool, len(names))
+	for _, name := range names {
+		eventType, err := events.ParseEventType(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			eventTypes = append(eventTypes, string(eventType))
+		}
+	}
+	return eventTypes, nil
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kk/kkartifact-server/internal/database"
+)
+
+// GetSessionInfo returns the session info stored by AuthMiddleware, if any
+func GetSessionInfo(c *gin.Context) *SessionInfo {
+	if value, ok := c.Get("session_info"); ok {
+		if sessionInfo, ok := value.(*SessionInfo); ok {
+			return sessionInfo
+		}
+	}
+	return nil
+}
+
+// GetTokenInfo returns the API token info stored by AuthMiddleware, if any
+func GetTokenInfo(c *gin.Context) *TokenInfo {
+	if value, ok := c.Get("token_info"); ok {
+		if tokenInfo, ok := value.(*TokenInfo); ok {
+			return tokenInfo
+		}
+	}
+	return nil
+}
+
+// IsScoped reports whether the token is limited to a project or an app
+func (t *TokenInfo) IsScoped() bool {
+	return t.ProjectID != nil || t.AppID != nil
+}
+
+// RequirePermission returns a Gin middleware that authorizes the request
+// It must run after AuthMiddleware. The caller must hold the required permission,
+// and scoped tokens may only touch the project/app named by the :project/:app path
+// params or by the "project"/"app" fields of a JSON body. Requests that name no
+// project are treated as global and are refused for scoped tokens.
+func (ta *TokenAuthenticator) RequirePermission(required Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sessionInfo := GetSessionInfo(c); sessionInfo != nil {
+			// Web UI sessions: admins can do everything, other users can only read
+			if !sessionInfo.IsAdmin && required != PermissionPull {
+				forbidden(c, fmt.Sprintf("user %s lacks required permission: %s", sessionInfo.Username, required))
+				return
+			}
+			c.Next()
+			return
+		}
+
+		tokenInfo := GetTokenInfo(c)
+		if tokenInfo == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			c.Abort()
+			return
+		}
+
+		if !HasPermission(tokenInfo.Permissions, required) {
+			forbidden(c, fmt.Sprintf("token lacks required permission: %s", required))
+			return
+		}
+
+		if tokenInfo.IsScoped() {
+			projectName, appName := requestTarget(c)
+			if err := ta.checkScope(tokenInfo, projectName, appName); err != nil {
+				forbidden(c, err.Error())
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// CheckScope verifies that a token may access the given project/app
+// It is exported for handlers that resolve their target after routing (e.g. by ID).
+func (ta *TokenAuthenticator) CheckScope(tokenInfo *TokenInfo, projectName, appName string) error {
+	if tokenInfo == nil || !tokenInfo.IsScoped() {
+		return nil
+	}
+	return ta.checkScope(tokenInfo, projectName, appName)
+}
+
+// checkScope compares the token's project/app scope with the request target
+func (ta *TokenAuthenticator) checkScope(tokenInfo *TokenInfo, projectName, appName string) error {
+	if projectName == "" {
+		return fmt.Errorf("token is scoped to a project and cannot access global resources")
+	}
+
+	projectRepo := database.NewProjectRepository(ta.db)
+	project, err := projectRepo.GetByName(projectName)
+	if err != nil {
+		return fmt.Errorf("token is not scoped to project %s", projectName)
+	}
+	if tokenInfo.ProjectID != nil && *tokenInfo.ProjectID != project.ID {
+		return fmt.Errorf("token is not scoped to project %s", projectName)
+	}
+
+	if tokenInfo.AppID == nil {
+		return nil
+	}
+	if appName == "" {
+		return fmt.Errorf("token is scoped to a single app and cannot access project-wide resources")
+	}
+
+	appRepo := database.NewAppRepository(ta.db)
+	app, err := appRepo.GetByName(project.ID, appName)
+	if err != nil || app.ID != *tokenInfo.AppID {
+		return fmt.Errorf("token is not scoped to app %s/%s", projectName, appName)
+	}
+	return nil
+}
+
+// requestTarget extracts the project/app a request operates on
+// Path params take precedence; otherwise the JSON body is inspected and restored
+// so the handler can still bind it.
+func requestTarget(c *gin.Context) (string, string) {
+	projectName := c.Param("project")
+	appName := c.Param("app")
+	if projectName != "" {
+		return projectName, appName
+	}
+
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return "", ""
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return "", ""
+	}
+
+	var target struct {
+		Project string `json:"project"`
+		App     string `json:"app"`
+	}
+	if err := json.Unmarshal(data, &target); err != nil {
+		return "", ""
+	}
+	return target.Project, target.App
+}
+
+// forbidden aborts the request with a 403 response and the denial reason
+func forbidden(c *gin.Context, reason string) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":   "forbidden",
+		"message": reason,
+	})
+	c.Abort()
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newAuthorizeTestRouter(ta *TokenAuthenticator, session *SessionInfo, token *TokenInfo, required Permission) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if session != nil {
+			c.Set("session_info", session)
+		}
+		if token != nil {
+			c.Set("token_info", token)
+		}
+	})
+	router.POST("/target", ta.RequirePermission(required), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	return router
+}
+
+func TestRequirePermission(t *testing.T) {
+	projectID := 1
+	ta := &TokenAuthenticator{}
+
+	tests := []struct {
+		name     string
+		session  *SessionInfo
+		token    *TokenInfo
+		required Permission
+		want     int
+	}{
+		{"admin session", &SessionInfo{Username: "admin", IsAdmin: true}, nil, PermissionAdmin, http.StatusOK},
+		{"non-admin session can pull", &SessionInfo{Username: "viewer"}, nil, PermissionPull, http.StatusOK},
+		{"non-admin session cannot push", &SessionInfo{Username: "viewer"}, nil, PermissionPush, http.StatusForbidden},
+		{"global token with permission", nil, &TokenInfo{Permissions: []string{"push"}}, PermissionPush, http.StatusOK},
+		{"global token missing permission", nil, &TokenInfo{Permissions: []string{"pull"}}, PermissionAdmin, http.StatusForbidden},
+		{"scoped token on global resource", nil, &TokenInfo{ProjectID: &projectID, Permissions: []string{"admin"}}, PermissionAdmin, http.StatusForbidden},
+		{"no credentials", nil, nil, PermissionPull, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newAuthorizeTestRouter(ta, tt.session, tt.token, tt.required)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/target", strings.NewReader(`{"name":"x"}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body: %s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestTargetRestoresBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := `{"project":"p1","app":"a1","version":"v1"}`
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/upload/init", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	project, app := requestTarget(c)
+	if project != "p1" || app != "a1" {
+		t.Errorf("requestTarget() = %q, %q, want p1, a1", project, app)
+	}
+
+	restored, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		t.Fatalf("Failed to read restored body: %v", err)
+	}
+	if string(restored) != body {
+		t.Errorf("Restored body = %s, want %s", restored, body)
+	}
+}
@@ -29,6 +29,7 @@ type Permission string
 const (
 	PermissionPush    Permission = "push"
 	PermissionPull    Permission = "pull"
+	PermissionPublish Permission = "publish"
 	PermissionPromote Permission = "promote"
 	PermissionAdmin   Permission = "admin"
 )
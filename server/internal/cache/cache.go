@@ -44,6 +44,11 @@ func NewRedisCache(host, port, password string, db int) (*RedisCache, error) {
 	return &RedisCache{client: client}, nil
 }
 
+// Client returns the underlying Redis client, so other components can share the connection
+func (c *RedisCache) Client() *redis.Client {
+	return c.client
+}
+
 // Get retrieves a value from cache
 func (c *RedisCache) Get(ctx context.Context, key string) (string, error) {
 	val, err := c.client.Get(ctx, key).Result()
@@ -17,6 +17,8 @@ type Config struct {
 	Redis    RedisConfig
 	Storage  StorageConfig
 	Log      LogConfig
+	Webhook  WebhookConfig
+	Events   EventsConfig
 }
 
 // ServerConfig holds server configuration
@@ -58,6 +60,19 @@ type StorageConfig struct {
 	BasePath   string
 }
 
+// WebhookConfig holds webhook delivery configuration
+type WebhookConfig struct {
+	Workers int
+}
+
+// EventsConfig holds event bus configuration
+type EventsConfig struct {
+	Backend       string // memory or redis
+	Stream        string
+	ConsumerGroup string
+	StreamMaxLen  int
+}
+
 // LogConfig holds logging configuration
 type LogConfig struct {
 	Level  string
@@ -102,6 +117,15 @@ func Load() (*Config, error) {
 			Level:  getEnv("LOG_LEVEL", "info"),
 			Format: getEnv("LOG_FORMAT", "json"),
 		},
+		Webhook: WebhookConfig{
+			Workers: getEnvAsInt("WEBHOOK_WORKERS", 4),
+		},
+		Events: EventsConfig{
+			Backend:       getEnv("EVENT_BUS", "memory"),
+			Stream:        getEnv("EVENT_STREAM", "kkartifact:events"),
+			ConsumerGroup: getEnv("EVENT_CONSUMER_GROUP", "kkartifact"),
+			StreamMaxLen:  getEnvAsInt("EVENT_STREAM_MAXLEN", 100000),
+		},
 	}, nil
 }
 
@@ -0,0 +1,90 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// AdvisoryLock is a Postgres advisory lock held on a dedicated connection
+// It is released by Release, or by Postgres when the connection is lost (e.g.
+// the server holding it crashed), so another server can take it over.
+type AdvisoryLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// TryAdvisoryLock takes the advisory lock with the given name if no other
+// session holds it. Returns nil if it is held elsewhere.
+func (db *DB) TryAdvisoryLock(ctx context.Context, name string) (*AdvisoryLock, error) {
+	key := AdvisoryKey(name)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for advisory lock: %w", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to take advisory lock %s: %w", name, err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+	return &AdvisoryLock{conn: conn, key: key}, nil
+}
+
+// Check returns an error if the lock's connection was lost, and with it the lock
+func (l *AdvisoryLock) Check(ctx context.Context) error {
+	return l.conn.PingContext(ctx)
+}
+
+// Release releases the lock and its connection
+func (l *AdvisoryLock) Release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// The connection goes back to the pool, so the lock must be released explicitly
+	if _, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		// Discard the connection instead; Postgres releases the lock with it
+		l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+	l.conn.Close()
+}
+
+// AdvisoryKey maps a lock name to a 64-bit advisory lock key, e.g. to find the
+// lock in pg_locks
+// 64 bits keep unrelated names from sharing a lock, as 32-bit hashtext keys would.
+func AdvisoryKey(name string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	return int64(hash.Sum64())
+}
+
+// lockInTx takes the advisory lock with the given name for the rest of a transaction
+// Waiting for it holds no connection besides the transaction's own.
+func lockInTx(tx *sql.Tx, name string) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, AdvisoryKey(name)); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", name, err)
+	}
+	return nil
+}
+
+// lockSharedInTx takes the advisory lock with the given name in shared mode for
+// the rest of a transaction
+// Shared holders only wait for, and block, holders of the exclusive lock.
+func lockSharedInTx(tx *sql.Tx, name string) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock_shared($1)`, AdvisoryKey(name)); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kk/kkartifact-server/internal/database/dbtest"
+)
+
+func TestTryAdvisoryLock(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	lock, err := db.TryAdvisoryLock(ctx, "test:lock")
+	if err != nil || lock == nil {
+		t.Fatalf("Expected to take the lock, got %v (err %v)", lock, err)
+	}
+	if err := lock.Check(ctx); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+
+	// Held by another session
+	other, err := db.TryAdvisoryLock(ctx, "test:lock")
+	if err != nil || other != nil {
+		t.Fatalf("Expected the lock to be held, got %v (err %v)", other, err)
+	}
+
+	// Locks of other names are independent
+	unrelated, err := db.TryAdvisoryLock(ctx, "test:other")
+	if err != nil || unrelated == nil {
+		t.Fatalf("Expected to take an unrelated lock, got %v (err %v)", unrelated, err)
+	}
+	unrelated.Release()
+
+	lock.Release()
+	again, err := db.TryAdvisoryLock(ctx, "test:lock")
+	if err != nil || again == nil {
+		t.Fatalf("Expected to take the released lock, got %v (err %v)", again, err)
+	}
+	again.Release()
+}
+
+func TestAdvisoryLock_LostConnection(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	lock, err := db.TryAdvisoryLock(ctx, "test:lock")
+	if err != nil || lock == nil {
+		t.Fatalf("Expected to take the lock, got %v (err %v)", lock, err)
+	}
+	defer lock.Release()
+
+	dbtest.TerminateLockHolder(t, db, "test:lock")
+
+	if err := lock.Check(ctx); err == nil {
+		t.Error("Expected Check to fail once the connection is lost")
+	}
+	// Postgres released the lock with the connection
+	other, err := db.TryAdvisoryLock(ctx, "test:lock")
+	if err != nil || other == nil {
+		t.Fatalf("Expected to take over the lock, got %v (err %v)", other, err)
+	}
+	other.Release()
+}
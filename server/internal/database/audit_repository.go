@@ -9,6 +9,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+
+	"github.com/lib/pq"
 )
 
 // AuditRepository handles audit log database operations
@@ -23,19 +25,27 @@ func NewAuditRepository(db *DB) *AuditRepository {
 
 // Create creates a new audit log entry
 func (r *AuditRepository) Create(operation string, projectID, appID *int, versionHash, agentID string, metadata map[string]interface{}) error {
+	_, err := r.Record(operation, projectID, appID, versionHash, agentID, metadata)
+	return err
+}
+
+// Record creates a new audit log entry and returns its ID
+func (r *AuditRepository) Record(operation string, projectID, appID *int, versionHash, agentID string, metadata map[string]interface{}) (int, error) {
 	var metadataJSON sql.NullString
 	if metadata != nil {
 		metadataBytes, err := json.Marshal(metadata)
 		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
+			return 0, fmt.Errorf("failed to marshal metadata: %w", err)
 		}
 		metadataJSON = sql.NullString{String: string(metadataBytes), Valid: true}
 	}
 
 	query := `INSERT INTO audit_logs (operation, project_id, app_id, version_hash, agent_id, metadata)
-	          VALUES ($1, $2, $3, $4, $5, $6)`
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          RETURNING id`
 	
-	_, err := r.db.Exec(
+	var id int
+	err := r.db.QueryRow(
 		query,
 		operation,
 		toNullInt64(projectID),
@@ -43,8 +53,43 @@ func (r *AuditRepository) Create(operation string, projectID, appID *int, versio
 		toNullString(versionHash),
 		toNullString(agentID),
 		metadataJSON,
-	)
-	return err
+	).Scan(&id)
+	return id, err
+}
+
+// ListAfter lists the entries with an ID greater than afterID, oldest first
+// Only the given operations are listed.
+func (r *AuditRepository) ListAfter(afterID int, operations []string, limit int) ([]*AuditLog, error) {
+	query := `SELECT id, operation, project_id, app_id, version_hash, agent_id, metadata, created_at
+	          FROM audit_logs
+	          WHERE id > $1 AND operation = ANY($2)
+	          ORDER BY id
+	          LIMIT $3`
+
+	rows, err := r.db.Query(query, afterID, pq.Array(operations), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*AuditLog
+	for rows.Next() {
+		var log AuditLog
+		if err := rows.Scan(
+			&log.ID,
+			&log.Operation,
+			&log.ProjectID,
+			&log.AppID,
+			&log.VersionHash,
+			&log.AgentID,
+			&log.Metadata,
+			&log.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		logs = append(logs, &log)
+	}
+	return logs, rows.Err()
 }
 
 // List lists audit logs with optional filters
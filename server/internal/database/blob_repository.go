@@ -0,0 +1,397 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// BlobRepository handles blob reference counting
+type BlobRepository struct {
+	db *DB
+}
+
+// NewBlobRepository creates a new blob repository
+func NewBlobRepository(db *DB) *BlobRepository {
+	return &BlobRepository{db: db}
+}
+
+// SetRefs makes a version reference exactly the given blobs (sha256 -> size)
+// The transaction holds the version's lock, so commits and deletes of the same
+// version are serialized across server instances. commit runs inside it once
+// the references are in place (e.g. to write the manifest); if it fails, the
+// references are rolled back. The buckets of the referenced blobs stay locked
+// until then, so the blobs cannot be garbage collected mid-commit; commit gets
+// the blobs nothing referenced before, whose stored data a concurrent
+// collection may have removed. References the version held to other blobs are dropped, and those
+// blobs are returned if no version references them any more. Setting the same
+// references twice is a no-op, so commits can be retried safely.
+func (r *BlobRepository) SetRefs(project, app, version string, blobs map[string]int64, commit func(revived []string) error) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockInTx(tx, versionLockName(project, app, version)); err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(blobs))
+	for hash := range blobs {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	sizes := make([]int64, len(hashes))
+	for i, hash := range hashes {
+		sizes[i] = blobs[hash]
+	}
+
+	// Keep garbage collection away from the blobs; commits share the bucket locks
+	if err := lockBlobBuckets(tx, hashes); err != nil {
+		return nil, err
+	}
+
+	// Rows are created and locked in hash order so concurrent commits cannot deadlock
+	if _, err := tx.Exec(
+		`INSERT INTO blobs (sha256, size)
+		 SELECT * FROM unnest($1::text[], $2::bigint[]) ORDER BY 1
+		 ON CONFLICT (sha256) DO NOTHING`,
+		pq.Array(hashes), pq.Array(sizes),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create blobs: %w", err)
+	}
+	// The blobs the version referenced so far are locked with the new ones, as
+	// their counts are lowered below
+	if _, err := tx.Exec(
+		`SELECT sha256 FROM blobs
+		 WHERE sha256 = ANY($1)
+		    OR sha256 IN (SELECT sha256 FROM blob_refs WHERE project = $2 AND app = $3 AND version = $4)
+		 ORDER BY sha256 FOR UPDATE`,
+		pq.Array(hashes), project, app, version,
+	); err != nil {
+		return nil, fmt.Errorf("failed to lock blobs: %w", err)
+	}
+
+	rows, err := tx.Query(
+		`DELETE FROM blob_refs
+		 WHERE project = $1 AND app = $2 AND version = $3 AND NOT (sha256 = ANY($4))
+		 RETURNING sha256`,
+		project, app, version, pq.Array(hashes),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to release blob references: %w", err)
+	}
+	unreferenced, err := decrementRefs(tx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Count the references that are new; a count going from 0 to 1 revives the blob
+	rows, err = tx.Query(
+		`WITH added AS (
+		     INSERT INTO blob_refs (project, app, version, sha256)
+		     SELECT $1, $2, $3, hash FROM unnest($4::text[]) AS hash
+		     ON CONFLICT DO NOTHING
+		     RETURNING sha256
+		 )
+		 UPDATE blobs SET ref_count = blobs.ref_count + 1
+		 FROM added WHERE blobs.sha256 = added.sha256
+		 RETURNING blobs.sha256, blobs.ref_count`,
+		project, app, version, pq.Array(hashes),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add blob references: %w", err)
+	}
+	var revived []string
+	for rows.Next() {
+		var hash string
+		var refCount int
+		if err := rows.Scan(&hash, &refCount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if refCount == 1 {
+			revived = append(revived, hash)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to add blob references: %w", err)
+	}
+	sort.Strings(revived)
+
+	if commit != nil {
+		if err := commit(revived); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit blob references: %w", err)
+	}
+	return unreferenced, nil
+}
+
+// ReleaseRefs drops the references held by matching versions and returns the
+// blobs that are no longer referenced by any version
+// An empty app matches every app in the project, an empty version every version
+// in the app. For a single version the transaction holds the version's lock and
+// remove, if given, runs in it before the references are dropped.
+func (r *BlobRepository) ReleaseRefs(project, app, version string, remove func() error) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if version != "" {
+		if err := lockInTx(tx, versionLockName(project, app, version)); err != nil {
+			return nil, err
+		}
+	}
+	if remove != nil {
+		if err := remove(); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := tx.Query(
+		`DELETE FROM blob_refs
+		 WHERE project = $1 AND ($2 = '' OR app = $2) AND ($3 = '' OR version = $3)
+		 RETURNING sha256`,
+		project, app, version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to release blob references: %w", err)
+	}
+	unreferenced, err := decrementRefs(tx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit blob release: %w", err)
+	}
+	return unreferenced, nil
+}
+
+// decrementRefs lowers the reference counts of the blobs returned by a DELETE ... RETURNING sha256
+// and returns the blobs whose count reached zero
+func decrementRefs(tx *sql.Tx, rows *sql.Rows) ([]string, error) {
+	released := make(map[string]int)
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		released[hash]++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(released) == 0 {
+		return nil, nil
+	}
+
+	hashes := make([]string, 0, len(released))
+	for hash := range released {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	counts := make([]int64, len(hashes))
+	for i, hash := range hashes {
+		counts[i] = int64(released[hash])
+	}
+
+	// Lock rows in a fixed order so concurrent releases cannot deadlock
+	if _, err := tx.Exec(`SELECT sha256 FROM blobs WHERE sha256 = ANY($1) ORDER BY sha256 FOR UPDATE`, pq.Array(hashes)); err != nil {
+		return nil, fmt.Errorf("failed to lock blobs: %w", err)
+	}
+	updated, err := tx.Query(
+		`UPDATE blobs SET ref_count = GREATEST(blobs.ref_count - released.count, 0)
+		 FROM unnest($1::text[], $2::bigint[]) AS released(sha256, count)
+		 WHERE blobs.sha256 = released.sha256
+		 RETURNING blobs.sha256, blobs.ref_count`,
+		pq.Array(hashes), pq.Array(counts),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrement blob reference counts: %w", err)
+	}
+	defer updated.Close()
+
+	var unreferenced []string
+	for updated.Next() {
+		var hash string
+		var refCount int
+		if err := updated.Scan(&hash, &refCount); err != nil {
+			return nil, err
+		}
+		if refCount == 0 {
+			unreferenced = append(unreferenced, hash)
+		}
+	}
+	if err := updated.Err(); err != nil {
+		return nil, fmt.Errorf("failed to decrement blob reference counts: %w", err)
+	}
+	sort.Strings(unreferenced)
+	return unreferenced, nil
+}
+
+// DeleteUnreferenced removes a blob that no version references
+// deleteObject removes the stored data while the blob's bucket is locked, so a
+// commit cannot reference the blob between its record and its data going away.
+// The record is kept if deleteObject fails, so the blob is retried by cleanup.
+// Returns false if the blob is referenced again or was already removed.
+func (r *BlobRepository) DeleteUnreferenced(hash string, deleteObject func() error) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockInTx(tx, blobBucketLockName(blobBucket(hash))); err != nil {
+		return false, err
+	}
+	result, err := tx.Exec(`DELETE FROM blobs WHERE sha256 = $1 AND ref_count = 0`, hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete blob %s: %w", hash, err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if deleted == 0 {
+		return false, nil
+	}
+
+	if err := deleteObject(); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit blob deletion %s: %w", hash, err)
+	}
+	return true, nil
+}
+
+// Exists checks whether a blob is tracked
+func (r *BlobRepository) Exists(hash string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM blobs WHERE sha256 = $1)`, hash).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check blob: %w", err)
+	}
+	return exists, nil
+}
+
+// ListUnreferenced lists blobs whose reference count dropped to zero
+// These are normally removed right away; leftovers come from interrupted deletes
+func (r *BlobRepository) ListUnreferenced(limit int) ([]string, error) {
+	rows, err := r.db.Query(`SELECT sha256 FROM blobs WHERE ref_count = 0 LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unreferenced blobs: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// BlobRef is a blob referenced by a version, with the blob's size
+type BlobRef struct {
+	Version string
+	SHA256  string
+	Size    int64
+}
+
+// ListExclusiveRefs lists the blobs of the given versions of an app that no
+// other version references, i.e. the blobs deleting all of them would free
+// A blob shared by several of the versions is listed once for each.
+func (r *BlobRepository) ListExclusiveRefs(project, app string, versions []string) ([]BlobRef, error) {
+	query := `SELECT r.version, r.sha256, b.size FROM blob_refs r
+	          JOIN blobs b ON b.sha256 = r.sha256
+	          WHERE r.project = $1 AND r.app = $2 AND r.version = ANY($3)
+	            AND NOT EXISTS (
+	                SELECT 1 FROM blob_refs o
+	                WHERE o.sha256 = r.sha256
+	                  AND NOT (o.project = $1 AND o.app = $2 AND o.version = ANY($3))
+	            )`
+	rows, err := r.db.Query(query, project, app, pq.Array(versions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exclusive blob references: %w", err)
+	}
+	defer rows.Close()
+
+	var refs []BlobRef
+	for rows.Next() {
+		var ref BlobRef
+		if err := rows.Scan(&ref.Version, &ref.SHA256, &ref.Size); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// blobBuckets is the number of advisory locks guarding blobs against garbage collection
+// A commit takes the locks of the buckets its blobs fall into, so however many
+// blobs it references, it holds at most this many locks.
+const blobBuckets = 16
+
+// blobBucket returns the bucket of a blob: the value of its first hex digit
+func blobBucket(hash string) int {
+	if hash == "" {
+		return 0
+	}
+	bucket, err := strconv.ParseInt(hash[:1], 16, 0)
+	if err != nil {
+		return 0
+	}
+	return int(bucket) % blobBuckets
+}
+
+// lockBlobBuckets takes the shared locks of the buckets of the given blobs in a fixed order
+// Shared locks let commits run side by side while keeping garbage collection,
+// which takes a bucket's lock exclusively, out.
+func lockBlobBuckets(tx *sql.Tx, hashes []string) error {
+	var buckets [blobBuckets]bool
+	for _, hash := range hashes {
+		buckets[blobBucket(hash)] = true
+	}
+	for bucket, used := range buckets {
+		if !used {
+			continue
+		}
+		if err := lockSharedInTx(tx, blobBucketLockName(bucket)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blobBucketLockName names the advisory lock of a blob bucket
+func blobBucketLockName(bucket int) string {
+	return fmt.Sprintf("blobs:%d", bucket)
+}
+
+// versionLockName names the advisory lock serializing commits and deletes of a version
+func versionLockName(project, app, version string) string {
+	return "version:" + project + "/" + app + "/" + version
+}
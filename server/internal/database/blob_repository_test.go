@@ -0,0 +1,239 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package database_test
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/database/dbtest"
+)
+
+func testHash(i int) string {
+	return fmt.Sprintf("%064x", i)
+}
+
+func testBlobs(indexes ...int) map[string]int64 {
+	blobs := make(map[string]int64, len(indexes))
+	for _, i := range indexes {
+		blobs[testHash(i)] = int64(i)
+	}
+	return blobs
+}
+
+func refCount(t *testing.T, db *database.DB, hash string) int {
+	t.Helper()
+	var count int
+	if err := db.QueryRow(`SELECT ref_count FROM blobs WHERE sha256 = $1`, hash).Scan(&count); err != nil {
+		t.Fatalf("Failed to get reference count of %s: %v", hash, err)
+	}
+	return count
+}
+
+// setRefs calls SetRefs and returns the revived and unreferenced blobs
+func setRefs(t *testing.T, repo *database.BlobRepository, version string, blobs map[string]int64) ([]string, []string) {
+	t.Helper()
+	var revived []string
+	unreferenced, err := repo.SetRefs("shop", "api", version, blobs, func(r []string) error {
+		revived = r
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SetRefs(%s) error = %v", version, err)
+	}
+	return revived, unreferenced
+}
+
+func TestBlobRepository_SetRefs(t *testing.T) {
+	db := dbtest.Open(t)
+	repo := database.NewBlobRepository(db)
+
+	revived, unreferenced := setRefs(t, repo, "v1", testBlobs(1, 2))
+	if !reflect.DeepEqual(revived, []string{testHash(1), testHash(2)}) || unreferenced != nil {
+		t.Errorf("First commit: revived %v, unreferenced %v", revived, unreferenced)
+	}
+	revived, _ = setRefs(t, repo, "v2", testBlobs(2, 3))
+	if !reflect.DeepEqual(revived, []string{testHash(3)}) {
+		t.Errorf("Expected only the new blob to be revived, got %v", revived)
+	}
+	if got := refCount(t, db, testHash(2)); got != 2 {
+		t.Errorf("Expected the shared blob to have 2 references, got %d", got)
+	}
+
+	// Setting the same references again changes nothing
+	revived, unreferenced = setRefs(t, repo, "v2", testBlobs(2, 3))
+	if revived != nil || unreferenced != nil || refCount(t, db, testHash(2)) != 2 {
+		t.Errorf("Repeated commit: revived %v, unreferenced %v", revived, unreferenced)
+	}
+
+	// Replacing v1 drops its references to blobs it no longer uses
+	_, unreferenced = setRefs(t, repo, "v1", testBlobs(3))
+	if !reflect.DeepEqual(unreferenced, []string{testHash(1)}) {
+		t.Errorf("Expected blob 1 to become unreferenced, got %v", unreferenced)
+	}
+	for i, want := range map[int]int{1: 0, 2: 1, 3: 2} {
+		if got := refCount(t, db, testHash(i)); got != want {
+			t.Errorf("Expected blob %d to have %d references, got %d", i, want, got)
+		}
+	}
+
+	// A failed commit rolls the references back
+	if _, err := repo.SetRefs("shop", "api", "v3", testBlobs(4), func([]string) error { return fmt.Errorf("boom") }); err == nil {
+		t.Error("Expected the commit error to be returned")
+	}
+	var exists bool
+	db.QueryRow(`SELECT EXISTS(SELECT 1 FROM blobs WHERE sha256 = $1)`, testHash(4)).Scan(&exists)
+	if exists {
+		t.Error("Expected the blob of a failed commit to be rolled back")
+	}
+}
+
+func TestBlobRepository_SetRefsManyBlobs(t *testing.T) {
+	db := dbtest.Open(t)
+	repo := database.NewBlobRepository(db)
+
+	// Far more blobs than Postgres has lock slots for a single transaction
+	indexes := make([]int, 20000)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	revived, _ := setRefs(t, repo, "v1", testBlobs(indexes...))
+	if len(revived) != len(indexes) {
+		t.Errorf("Expected %d revived blobs, got %d", len(indexes), len(revived))
+	}
+	_, unreferenced := setRefs(t, repo, "v1", testBlobs(indexes[:10]...))
+	if len(unreferenced) != len(indexes)-10 {
+		t.Errorf("Expected %d unreferenced blobs, got %d", len(indexes)-10, len(unreferenced))
+	}
+}
+
+func TestBlobRepository_ConcurrentSetRefs(t *testing.T) {
+	db := dbtest.Open(t)
+	repo := database.NewBlobRepository(db)
+
+	const versions, rounds, shared = 8, 20, 40
+	var wg sync.WaitGroup
+	errs := make(chan error, versions*rounds)
+	for v := 0; v < versions; v++ {
+		wg.Add(1)
+		go func(v int) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(int64(v)))
+			for round := 0; round < rounds; round++ {
+				// Overlapping, shuffled sets make commits and releases contend on the same rows
+				blobs := make(map[string]int64)
+				for _, i := range random.Perm(shared)[:shared/2] {
+					blobs[testHash(i)] = int64(i)
+				}
+				if _, err := repo.SetRefs("shop", "api", fmt.Sprintf("v%d", v), blobs, nil); err != nil {
+					errs <- err
+				}
+			}
+		}(v)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("SetRefs() error = %v", err)
+	}
+
+	var mismatched int
+	if err := db.QueryRow(
+		`SELECT COUNT(*) FROM blobs b
+		 WHERE b.ref_count <> (SELECT COUNT(*) FROM blob_refs r WHERE r.sha256 = b.sha256)`,
+	).Scan(&mismatched); err != nil {
+		t.Fatalf("Failed to check reference counts: %v", err)
+	}
+	if mismatched != 0 {
+		t.Errorf("Expected reference counts to match the references, %d blobs differ", mismatched)
+	}
+}
+
+func TestBlobRepository_DeleteUnreferenced(t *testing.T) {
+	db := dbtest.Open(t)
+	repo := database.NewBlobRepository(db)
+
+	setRefs(t, repo, "v1", testBlobs(1))
+	deleted, err := repo.DeleteUnreferenced(testHash(1), func() error { return nil })
+	if err != nil || deleted {
+		t.Fatalf("Expected a referenced blob to be kept, got %v, %v", deleted, err)
+	}
+
+	if _, err := repo.ReleaseRefs("shop", "api", "v1", nil); err != nil {
+		t.Fatalf("ReleaseRefs() error = %v", err)
+	}
+	if _, err := repo.DeleteUnreferenced(testHash(1), func() error { return fmt.Errorf("storage down") }); err == nil {
+		t.Error("Expected the storage error to be returned")
+	}
+	if exists, _ := repo.Exists(testHash(1)); !exists {
+		t.Error("Expected the record to be kept when the stored data could not be removed")
+	}
+	deleted, err = repo.DeleteUnreferenced(testHash(1), func() error { return nil })
+	if err != nil || !deleted {
+		t.Fatalf("Expected an unreferenced blob to be deleted, got %v, %v", deleted, err)
+	}
+
+	// Referencing it again revives it, so the commit re-checks its data
+	revived, _ := setRefs(t, repo, "v2", testBlobs(1))
+	if !reflect.DeepEqual(revived, []string{testHash(1)}) {
+		t.Errorf("Expected the deleted blob to be revived, got %v", revived)
+	}
+}
+
+func TestBlobRepository_DeleteWaitsForCommit(t *testing.T) {
+	db := dbtest.Open(t)
+	repo := database.NewBlobRepository(db)
+
+	// Leave an unreferenced blob behind, as an interrupted delete would
+	setRefs(t, repo, "v1", testBlobs(1))
+	if _, err := repo.ReleaseRefs("shop", "api", "v1", nil); err != nil {
+		t.Fatalf("ReleaseRefs() error = %v", err)
+	}
+
+	committing := make(chan struct{})
+	result := make(chan bool, 1)
+	var deletedObject atomic.Bool
+	_, err := repo.SetRefs("shop", "api", "v2", testBlobs(1), func(revived []string) error {
+		close(committing)
+		go func() {
+			deleted, err := repo.DeleteUnreferenced(testHash(1), func() error {
+				deletedObject.Store(true)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("DeleteUnreferenced() error = %v", err)
+			}
+			result <- deleted
+		}()
+		// Collection must wait for the commit instead of removing the blob under it
+		select {
+		case deleted := <-result:
+			t.Error("Expected DeleteUnreferenced to wait for the commit")
+			result <- deleted
+		case <-time.After(200 * time.Millisecond):
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SetRefs() error = %v", err)
+	}
+	<-committing
+
+	if <-result || deletedObject.Load() {
+		t.Error("Expected the blob referenced by the commit to be kept")
+	}
+	if got := refCount(t, db, testHash(1)); got != 1 {
+		t.Errorf("Expected 1 reference, got %d", got)
+	}
+}
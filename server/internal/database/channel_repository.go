@@ -0,0 +1,179 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const channelColumns = `id, app_id, name, version, updated_by, created_at, updated_at`
+
+// ChannelRepository handles release channel database operations
+type ChannelRepository struct {
+	db *DB
+}
+
+// NewChannelRepository creates a new channel repository
+func NewChannelRepository(db *DB) *ChannelRepository {
+	return &ChannelRepository{db: db}
+}
+
+// Get returns a channel of an app, or nil if it does not exist
+func (r *ChannelRepository) Get(appID int, name string) (*Channel, error) {
+	query := `SELECT ` + channelColumns + ` FROM channels WHERE app_id = $1 AND name = $2`
+	channel, err := scanChannel(r.db.QueryRow(query, appID, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel: %w", err)
+	}
+	return channel, nil
+}
+
+// ListByApp lists the channels of an app by name
+func (r *ChannelRepository) ListByApp(appID int) ([]*Channel, error) {
+	query := `SELECT ` + channelColumns + ` FROM channels WHERE app_id = $1 ORDER BY name`
+	rows, err := r.db.Query(query, appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []*Channel
+	for rows.Next() {
+		channel, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+// Set points a channel at a version, creating the channel if needed
+func (r *ChannelRepository) Set(appID int, name, version, updatedBy string) (*Channel, error) {
+	query := `INSERT INTO channels (app_id, name, version, updated_by)
+	          VALUES ($1, $2, $3, $4)
+	          ON CONFLICT (app_id, name) DO UPDATE SET
+	              version = EXCLUDED.version,
+	              updated_by = EXCLUDED.updated_by,
+	              updated_at = CURRENT_TIMESTAMP
+	          RETURNING ` + channelColumns
+	channel, err := scanChannel(r.db.QueryRow(query, appID, name, version, sql.NullString{String: updatedBy, Valid: updatedBy != ""}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set channel: %w", err)
+	}
+	return channel, nil
+}
+
+// Delete removes a channel
+// Returns false if the app had no such channel.
+func (r *ChannelRepository) Delete(appID int, name string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM channels WHERE app_id = $1 AND name = $2`, appID, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete channel: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// DeleteByVersion removes the channels pointing at a version, e.g. when it is deleted
+func (r *ChannelRepository) DeleteByVersion(appID int, version string) error {
+	if _, err := r.db.Exec(`DELETE FROM channels WHERE app_id = $1 AND version = $2`, appID, version); err != nil {
+		return fmt.Errorf("failed to delete channels of version: %w", err)
+	}
+	return nil
+}
+
+// ChannelsByVersion returns the channel names of the versions of an app, keyed by version hash
+func (r *ChannelRepository) ChannelsByVersion(appID int) (map[string][]string, error) {
+	channels, err := r.ListByApp(appID)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[string][]string)
+	for _, channel := range channels {
+		byVersion[channel.Version] = append(byVersion[channel.Version], channel.Name)
+	}
+	return byVersion, nil
+}
+
+// AddHistory records a change of a channel or of the published version
+func (r *ChannelRepository) AddHistory(entry *ChannelHistory) error {
+	query := `INSERT INTO channel_history (app_id, channel, version, previous_version, action, changed_by)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          RETURNING id, created_at`
+	err := r.db.QueryRow(query, entry.AppID, entry.Channel, entry.Version, entry.PreviousVersion, entry.Action, entry.ChangedBy).
+		Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record channel history: %w", err)
+	}
+	return nil
+}
+
+// ListHistory lists the changes of a channel of an app, newest first
+func (r *ChannelRepository) ListHistory(appID int, channel string, limit, offset int) ([]*ChannelHistory, int, error) {
+	var total int
+	if err := r.db.QueryRow(
+		`SELECT COUNT(*) FROM channel_history WHERE app_id = $1 AND channel = $2`, appID, channel,
+	).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count channel history: %w", err)
+	}
+
+	query := `SELECT id, app_id, channel, version, previous_version, action, changed_by, created_at
+	          FROM channel_history WHERE app_id = $1 AND channel = $2
+	          ORDER BY id DESC
+	          LIMIT $3 OFFSET $4`
+	rows, err := r.db.Query(query, appID, channel, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list channel history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []*ChannelHistory
+	for rows.Next() {
+		var entry ChannelHistory
+		if err := rows.Scan(&entry.ID, &entry.AppID, &entry.Channel, &entry.Version, &entry.PreviousVersion,
+			&entry.Action, &entry.ChangedBy, &entry.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		history = append(history, &entry)
+	}
+	return history, total, rows.Err()
+}
+
+// OnChannelSince returns when a channel last started pointing at a version
+// without pointing at any other version since. Returns false if the history
+// has no such entry.
+func (r *ChannelRepository) OnChannelSince(appID int, channel, version string) (time.Time, bool, error) {
+	query := `SELECT MIN(created_at) FROM channel_history
+	          WHERE app_id = $1 AND channel = $2 AND version = $3
+	            AND id > COALESCE((
+	                SELECT MAX(id) FROM channel_history
+	                WHERE app_id = $1 AND channel = $2 AND version IS DISTINCT FROM $3
+	            ), 0)`
+	var since sql.NullTime
+	if err := r.db.QueryRow(query, appID, channel, version).Scan(&since); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get channel history: %w", err)
+	}
+	return since.Time, since.Valid, nil
+}
+
+// scanChannel scans a channel row selected with channelColumns
+func scanChannel(row interface{ Scan(...interface{}) error }) (*Channel, error) {
+	var channel Channel
+	err := row.Scan(
+		&channel.ID, &channel.AppID, &channel.Name, &channel.Version,
+		&channel.UpdatedBy, &channel.CreatedAt, &channel.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package database
+
+import (
+	"fmt"
+)
+
+const chunkedUploadColumns = `id, session_id, path, sha256, size, chunk_size, storage_upload_id, status, created_at, completed_at`
+
+// ChunkedUploadRepository handles chunked upload database operations
+// Chunked uploads use the upload session statuses (active, completed, aborted)
+type ChunkedUploadRepository struct {
+	db *DB
+}
+
+// NewChunkedUploadRepository creates a new chunked upload repository
+func NewChunkedUploadRepository(db *DB) *ChunkedUploadRepository {
+	return &ChunkedUploadRepository{db: db}
+}
+
+// Create creates a new active chunked upload
+func (r *ChunkedUploadRepository) Create(id, sessionID, path, sha256 string, size, chunkSize int64, storageUploadID string) (*ChunkedUpload, error) {
+	query := `INSERT INTO chunked_uploads (id, session_id, path, sha256, size, chunk_size, storage_upload_id, status)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	          RETURNING ` + chunkedUploadColumns
+
+	upload, err := scanChunkedUpload(r.db.QueryRow(query, id, sessionID, path, sha256, size, chunkSize, storageUploadID, UploadSessionActive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunked upload: %w", err)
+	}
+	return upload, nil
+}
+
+// GetByID retrieves a chunked upload by ID
+func (r *ChunkedUploadRepository) GetByID(id string) (*ChunkedUpload, error) {
+	query := `SELECT ` + chunkedUploadColumns + ` FROM chunked_uploads WHERE id = $1`
+	upload, err := scanChunkedUpload(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunked upload: %w", err)
+	}
+	return upload, nil
+}
+
+// GetBySession retrieves the chunked upload of a blob within an upload session
+func (r *ChunkedUploadRepository) GetBySession(sessionID, sha256 string) (*ChunkedUpload, error) {
+	query := `SELECT ` + chunkedUploadColumns + ` FROM chunked_uploads WHERE session_id = $1 AND sha256 = $2`
+	upload, err := scanChunkedUpload(r.db.QueryRow(query, sessionID, sha256))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunked upload: %w", err)
+	}
+	return upload, nil
+}
+
+// ListActiveBySession lists the unfinished chunked uploads of an upload session
+func (r *ChunkedUploadRepository) ListActiveBySession(sessionID string) ([]*ChunkedUpload, error) {
+	query := `SELECT ` + chunkedUploadColumns + ` FROM chunked_uploads WHERE session_id = $1 AND status = $2`
+	rows, err := r.db.Query(query, sessionID, UploadSessionActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunked uploads: %w", err)
+	}
+	defer rows.Close()
+
+	var uploads []*ChunkedUpload
+	for rows.Next() {
+		upload, err := scanChunkedUpload(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, rows.Err()
+}
+
+// SetStatus moves an active chunked upload to a final status
+// Returns false if the upload was no longer active
+func (r *ChunkedUploadRepository) SetStatus(id, status string) (bool, error) {
+	result, err := r.db.Exec(
+		`UPDATE chunked_uploads SET status = $1, completed_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = $3`,
+		status, id, UploadSessionActive,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update chunked upload: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated > 0, nil
+}
+
+// PutPart records a received chunk, replacing an earlier copy of the same chunk
+func (r *ChunkedUploadRepository) PutPart(uploadID string, number int, offset, size int64, etag string) error {
+	_, err := r.db.Exec(
+		`INSERT INTO chunked_upload_parts (upload_id, part_number, byte_offset, size, etag) VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (upload_id, part_number) DO UPDATE
+		 SET byte_offset = EXCLUDED.byte_offset, size = EXCLUDED.size, etag = EXCLUDED.etag, created_at = CURRENT_TIMESTAMP`,
+		uploadID, number, offset, size, etag,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record chunk %d: %w", number, err)
+	}
+	return nil
+}
+
+// ListParts lists the chunks received for an upload, ordered by part number
+func (r *ChunkedUploadRepository) ListParts(uploadID string) ([]*ChunkedUploadPart, error) {
+	rows, err := r.db.Query(
+		`SELECT upload_id, part_number, byte_offset, size, etag, created_at FROM chunked_upload_parts
+		 WHERE upload_id = $1 ORDER BY part_number ASC`,
+		uploadID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var parts []*ChunkedUploadPart
+	for rows.Next() {
+		var part ChunkedUploadPart
+		if err := rows.Scan(&part.UploadID, &part.PartNumber, &part.Offset, &part.Size, &part.ETag, &part.CreatedAt); err != nil {
+			return nil, err
+		}
+		parts = append(parts, &part)
+	}
+	return parts, rows.Err()
+}
+
+// scanChunkedUpload scans a chunked upload from a row
+func scanChunkedUpload(row interface{ Scan(...interface{}) error }) (*ChunkedUpload, error) {
+	var upload ChunkedUpload
+	err := row.Scan(
+		&upload.ID,
+		&upload.SessionID,
+		&upload.Path,
+		&upload.SHA256,
+		&upload.Size,
+		&upload.ChunkSize,
+		&upload.StorageUploadID,
+		&upload.Status,
+		&upload.CreatedAt,
+		&upload.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// Delete deletes a chunked upload and its recorded chunks
+func (r *ChunkedUploadRepository) Delete(id string) error {
+	if _, err := r.db.Exec(`DELETE FROM chunked_uploads WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete chunked upload: %w", err)
+	}
+	return nil
+}
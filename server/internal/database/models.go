@@ -31,9 +31,17 @@ type Version struct {
 	AppID       int       `db:"app_id"`
 	Hash        string    `db:"hash"`
 	IsPublished bool      `db:"is_published"`
+	Pinned      bool      `db:"pinned"`
 	CreatedAt   time.Time `db:"created_at"`
 }
 
+// LabelSelector matches versions by label
+// An empty Value matches any value of Key.
+type LabelSelector struct {
+	Key   string
+	Value string
+}
+
 // Token represents a token in the database
 type Token struct {
 	ID         int            `db:"id"`
@@ -56,6 +64,9 @@ type Webhook struct {
 	Enabled    bool           `db:"enabled"`
 	ProjectID  sql.NullInt64  `db:"project_id"`
 	AppID      sql.NullInt64  `db:"app_id"`
+	Secret     sql.NullString `db:"secret"`
+	Format     string         `db:"format"`
+	Template   sql.NullString `db:"template"`
 	CreatedAt  time.Time      `db:"created_at"`
 }
 
@@ -79,3 +90,185 @@ type Config struct {
 	UpdatedAt time.Time `db:"updated_at"`
 }
 
+
+// UploadSession represents an upload in progress
+type UploadSession struct {
+	ID            string         `db:"id"`
+	Project       string         `db:"project"`
+	App           string         `db:"app"`
+	Version       string         `db:"version"`
+	TokenID       sql.NullInt64  `db:"token_id"`
+	Username      sql.NullString `db:"username"`
+	ExpectedFiles sql.NullString `db:"expected_files"`
+	Status        string         `db:"status"`
+	CreatedAt     time.Time      `db:"created_at"`
+	ExpiresAt     time.Time      `db:"expires_at"`
+	CompletedAt   sql.NullTime   `db:"completed_at"`
+}
+
+// ChunkedUpload represents a large file of an upload session sent in chunks
+type ChunkedUpload struct {
+	ID              string       `db:"id"`
+	SessionID       string       `db:"session_id"`
+	Path            string       `db:"path"`
+	SHA256          string       `db:"sha256"`
+	Size            int64        `db:"size"`
+	ChunkSize       int64        `db:"chunk_size"`
+	StorageUploadID string       `db:"storage_upload_id"`
+	Status          string       `db:"status"`
+	CreatedAt       time.Time    `db:"created_at"`
+	CompletedAt     sql.NullTime `db:"completed_at"`
+}
+
+// ChunkedUploadPart represents a chunk received for a chunked upload
+type ChunkedUploadPart struct {
+	UploadID   string    `db:"upload_id"`
+	PartNumber int       `db:"part_number"`
+	Offset     int64     `db:"byte_offset"`
+	Size       int64     `db:"size"`
+	ETag       string    `db:"etag"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// WebhookDelivery represents an event queued for delivery to a webhook
+type WebhookDelivery struct {
+	ID             int64          `db:"id"`
+	WebhookID      int            `db:"webhook_id"`
+	EventType      string         `db:"event_type"`
+	Payload        string         `db:"payload"`
+	Status         string         `db:"status"`
+	Attempts       int            `db:"attempts"`
+	MaxAttempts    int            `db:"max_attempts"`
+	NextAttemptAt  time.Time      `db:"next_attempt_at"`
+	LockedUntil    sql.NullTime   `db:"locked_until"`
+	LastStatusCode sql.NullInt64  `db:"last_status_code"`
+	LastError      sql.NullString `db:"last_error"`
+	RedeliveryOf   sql.NullInt64  `db:"redelivery_of"`
+	CreatedAt      time.Time      `db:"created_at"`
+	CompletedAt    sql.NullTime   `db:"completed_at"`
+}
+
+// WebhookDeliveryAttempt represents one attempt to deliver a webhook
+type WebhookDeliveryAttempt struct {
+	ID              int64          `db:"id"`
+	DeliveryID      int64          `db:"delivery_id"`
+	Attempt         int            `db:"attempt"`
+	RequestURL      string         `db:"request_url"`
+	RequestHeaders  sql.NullString `db:"request_headers"`
+	RequestBody     sql.NullString `db:"request_body"`
+	StatusCode      sql.NullInt64  `db:"status_code"`
+	ResponseHeaders sql.NullString `db:"response_headers"`
+	ResponseBody    sql.NullString `db:"response_body"`
+	DurationMs      int            `db:"duration_ms"`
+	Error           sql.NullString `db:"error"`
+	CreatedAt       time.Time      `db:"created_at"`
+}
+
+// TaskRun represents one run of a scheduled task
+type TaskRun struct {
+	ID          int64          `db:"id"`
+	TaskName    string         `db:"task_name"`
+	TriggeredBy string         `db:"triggered_by"`
+	Runner      sql.NullString `db:"runner"`
+	Status      string         `db:"status"`
+	DryRun      bool           `db:"dry_run"`
+	ScheduledAt sql.NullTime   `db:"scheduled_at"`
+	StartedAt   time.Time      `db:"started_at"`
+	FinishedAt  sql.NullTime   `db:"finished_at"`
+	Error       sql.NullString `db:"error"`
+	Counts      sql.NullString `db:"counts"`
+	Details     sql.NullString `db:"details"` // only read by TaskRunRepository.Get
+}
+
+// SchedulerLeader represents the replica currently scheduling tasks
+type SchedulerLeader struct {
+	Holder     string    `db:"holder"`
+	AcquiredAt time.Time `db:"acquired_at"`
+	RenewedAt  time.Time `db:"renewed_at"`
+	Stale      bool      // not renewed for a while; the holder is probably gone
+}
+
+// RetentionPolicy represents the retention rules a project or app overrides
+// A project policy has no app ID. NULL rules are inherited and 0 turns a rule off.
+type RetentionPolicy struct {
+	ID            int            `db:"id"`
+	ProjectID     int            `db:"project_id"`
+	AppID         sql.NullInt64  `db:"app_id"`
+	KeepLast      sql.NullInt64  `db:"keep_last"`
+	KeepDays      sql.NullInt64  `db:"keep_days"`
+	KeepPerBranch sql.NullInt64  `db:"keep_per_branch"`
+	BranchLabel   sql.NullString `db:"branch_label"`
+	CreatedAt     time.Time      `db:"created_at"`
+	UpdatedAt     time.Time      `db:"updated_at"`
+}
+
+// Channel represents a named release channel of an app (e.g. staging)
+// pointing at one version
+type Channel struct {
+	ID        int            `db:"id"`
+	AppID     int            `db:"app_id"`
+	Name      string         `db:"name"`
+	Version   string         `db:"version"`
+	UpdatedBy sql.NullString `db:"updated_by"`
+	CreatedAt time.Time      `db:"created_at"`
+	UpdatedAt time.Time      `db:"updated_at"`
+}
+
+// Channel history actions
+const (
+	ChannelActionSet      = "set"
+	ChannelActionDelete   = "delete"
+	ChannelActionRollback = "rollback"
+	ChannelActionPromote  = "promote"
+)
+
+// ChannelHistory represents one change of a channel or of the published version
+// Version is NULL when the channel was deleted or the version unpublished.
+type ChannelHistory struct {
+	ID              int64          `db:"id"`
+	AppID           int            `db:"app_id"`
+	Channel         string         `db:"channel"`
+	Version         sql.NullString `db:"version"`
+	PreviousVersion sql.NullString `db:"previous_version"`
+	Action          string         `db:"action"`
+	ChangedBy       sql.NullString `db:"changed_by"`
+	CreatedAt       time.Time      `db:"created_at"`
+}
+
+// PromotionPolicy represents the rules a version must meet to be promoted to a channel of an app
+type PromotionPolicy struct {
+	ID                 int            `db:"id"`
+	AppID              int            `db:"app_id"`
+	Channel            string         `db:"channel"`
+	FromChannel        sql.NullString `db:"from_channel"`
+	MinHours           int            `db:"min_hours"`
+	RequiredLabelKey   sql.NullString `db:"required_label_key"`
+	RequiredLabelValue sql.NullString `db:"required_label_value"`
+	RequiredApprovals  int            `db:"required_approvals"`
+	CreatedAt          time.Time      `db:"created_at"`
+	UpdatedAt          time.Time      `db:"updated_at"`
+}
+
+// Promotion statuses
+const (
+	PromotionPending  = "pending"
+	PromotionApproved = "approved"
+	PromotionRejected = "rejected"
+)
+
+// Promotion represents a request to promote a version to a channel
+type Promotion struct {
+	ID                int            `db:"id"`
+	AppID             int            `db:"app_id"`
+	Channel           string         `db:"channel"`
+	Version           string         `db:"version"`
+	FromChannel       sql.NullString `db:"from_channel"`
+	Status            string         `db:"status"`
+	RequiredApprovals int            `db:"required_approvals"`
+	RequestedBy       string         `db:"requested_by"`
+	DecidedBy         sql.NullString `db:"decided_by"`
+	Reason            sql.NullString `db:"reason"`
+	CreatedAt         time.Time      `db:"created_at"`
+	DecidedAt         sql.NullTime   `db:"decided_at"`
+	Approvers         []string       // users and tokens that approved, oldest first
+}
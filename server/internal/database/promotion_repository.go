@@ -0,0 +1,276 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const promotionPolicyColumns = `id, app_id, channel, from_channel, min_hours, required_label_key, required_label_value, required_approvals, created_at, updated_at`
+
+const promotionColumns = `id, app_id, channel, version, from_channel, status, required_approvals, requested_by, decided_by, reason, created_at, decided_at`
+
+// PromotionRepository handles promotion policy and promotion request database operations
+type PromotionRepository struct {
+	db *DB
+}
+
+// NewPromotionRepository creates a new promotion repository
+func NewPromotionRepository(db *DB) *PromotionRepository {
+	return &PromotionRepository{db: db}
+}
+
+// GetPolicy returns the promotion policy of a channel of an app, or nil if it has none
+func (r *PromotionRepository) GetPolicy(appID int, channel string) (*PromotionPolicy, error) {
+	query := `SELECT ` + promotionPolicyColumns + ` FROM promotion_policies WHERE app_id = $1 AND channel = $2`
+	policy, err := scanPromotionPolicy(r.db.QueryRow(query, appID, channel))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotion policy: %w", err)
+	}
+	return policy, nil
+}
+
+// ListPolicies lists the promotion policies of an app by channel
+func (r *PromotionRepository) ListPolicies(appID int) ([]*PromotionPolicy, error) {
+	query := `SELECT ` + promotionPolicyColumns + ` FROM promotion_policies WHERE app_id = $1 ORDER BY channel`
+	rows, err := r.db.Query(query, appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list promotion policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []*PromotionPolicy
+	for rows.Next() {
+		policy, err := scanPromotionPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// SetPolicy creates or replaces the promotion policy of a channel of an app
+func (r *PromotionRepository) SetPolicy(policy *PromotionPolicy) (*PromotionPolicy, error) {
+	query := `INSERT INTO promotion_policies (app_id, channel, from_channel, min_hours, required_label_key, required_label_value, required_approvals)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          ON CONFLICT (app_id, channel) DO UPDATE SET
+	              from_channel = EXCLUDED.from_channel,
+	              min_hours = EXCLUDED.min_hours,
+	              required_label_key = EXCLUDED.required_label_key,
+	              required_label_value = EXCLUDED.required_label_value,
+	              required_approvals = EXCLUDED.required_approvals,
+	              updated_at = CURRENT_TIMESTAMP
+	          RETURNING ` + promotionPolicyColumns
+	saved, err := scanPromotionPolicy(r.db.QueryRow(query,
+		policy.AppID, policy.Channel, policy.FromChannel, policy.MinHours,
+		policy.RequiredLabelKey, policy.RequiredLabelValue, policy.RequiredApprovals,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set promotion policy: %w", err)
+	}
+	return saved, nil
+}
+
+// DeletePolicy removes the promotion policy of a channel of an app
+// Returns false if the channel had no policy.
+func (r *PromotionRepository) DeletePolicy(appID int, channel string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM promotion_policies WHERE app_id = $1 AND channel = $2`, appID, channel)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete promotion policy: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Create records a promotion request
+// A request created already approved (no approvals needed) is decided at once.
+func (r *PromotionRepository) Create(promotion *Promotion) (*Promotion, error) {
+	query := `INSERT INTO promotions (app_id, channel, version, from_channel, status, required_approvals, requested_by, decided_by, decided_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CASE WHEN $5 = 'pending' THEN NULL ELSE CURRENT_TIMESTAMP END)
+	          RETURNING ` + promotionColumns
+	created, err := scanPromotion(r.db.QueryRow(query,
+		promotion.AppID, promotion.Channel, promotion.Version, promotion.FromChannel, promotion.Status,
+		promotion.RequiredApprovals, promotion.RequestedBy, promotion.DecidedBy,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create promotion: %w", err)
+	}
+	return created, nil
+}
+
+// Get returns a promotion request of an app with its approvers, or nil if it does not exist
+func (r *PromotionRepository) Get(appID, id int) (*Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions WHERE app_id = $1 AND id = $2`
+	promotion, err := scanPromotion(r.db.QueryRow(query, appID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotion: %w", err)
+	}
+	if promotion.Approvers, err = r.approvers(promotion.ID); err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+// GetPending returns the pending request to promote a version to a channel, or nil
+func (r *PromotionRepository) GetPending(appID int, channel, version string) (*Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions
+	          WHERE app_id = $1 AND channel = $2 AND version = $3 AND status = 'pending'`
+	promotion, err := scanPromotion(r.db.QueryRow(query, appID, channel, version))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending promotion: %w", err)
+	}
+	return promotion, nil
+}
+
+// List lists the promotion requests of an app, newest first
+// An empty status lists requests of any status.
+func (r *PromotionRepository) List(appID int, status string, limit, offset int) ([]*Promotion, int, error) {
+	where := `WHERE app_id = $1 AND ($2 = '' OR status = $2)`
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM promotions `+where, appID, status).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count promotions: %w", err)
+	}
+
+	query := `SELECT ` + promotionColumns + ` FROM promotions ` + where + `
+	          ORDER BY id DESC
+	          LIMIT $3 OFFSET $4`
+	rows, err := r.db.Query(query, appID, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list promotions: %w", err)
+	}
+	defer rows.Close()
+
+	var promotions []*Promotion
+	for rows.Next() {
+		promotion, err := scanPromotion(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		promotions = append(promotions, promotion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	for _, promotion := range promotions {
+		if promotion.Approvers, err = r.approvers(promotion.ID); err != nil {
+			return nil, 0, err
+		}
+	}
+	return promotions, total, nil
+}
+
+// AddApproval records the approval of a promotion request by a user
+// It returns false if the approver had already approved it, and the number of
+// approvals the request has, counted under a lock on the request so that
+// concurrent approvals each see the others.
+func (r *PromotionRepository) AddApproval(promotionID int, approver string) (bool, int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.QueryRow(`SELECT id FROM promotions WHERE id = $1 FOR UPDATE`, promotionID).Scan(&id); err != nil {
+		return false, 0, fmt.Errorf("failed to lock promotion: %w", err)
+	}
+
+	result, err := tx.Exec(
+		`INSERT INTO promotion_approvals (promotion_id, approver) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		promotionID, approver,
+	)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to record promotion approval: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, 0, err
+	}
+
+	var approvals int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM promotion_approvals WHERE promotion_id = $1`, promotionID).Scan(&approvals); err != nil {
+		return false, 0, fmt.Errorf("failed to count promotion approvals: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, 0, fmt.Errorf("failed to commit promotion approval: %w", err)
+	}
+	return affected > 0, approvals, nil
+}
+
+// Decide approves or rejects a pending promotion request
+// Returns false if the request was no longer pending, e.g. decided concurrently.
+func (r *PromotionRepository) Decide(id int, status, decidedBy, reason string) (bool, error) {
+	result, err := r.db.Exec(
+		`UPDATE promotions SET status = $1, decided_by = $2, reason = $3, decided_at = CURRENT_TIMESTAMP
+		 WHERE id = $4 AND status = 'pending'`,
+		status, decidedBy, sql.NullString{String: reason, Valid: reason != ""}, id,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to decide promotion: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// approvers lists the approvers of a promotion request, oldest first
+func (r *PromotionRepository) approvers(promotionID int) ([]string, error) {
+	rows, err := r.db.Query(
+		`SELECT approver FROM promotion_approvals WHERE promotion_id = $1 ORDER BY created_at, approver`,
+		promotionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list promotion approvals: %w", err)
+	}
+	defer rows.Close()
+
+	approvers := []string{}
+	for rows.Next() {
+		var approver string
+		if err := rows.Scan(&approver); err != nil {
+			return nil, err
+		}
+		approvers = append(approvers, approver)
+	}
+	return approvers, rows.Err()
+}
+
+// scanPromotionPolicy scans a promotion policy row selected with promotionPolicyColumns
+func scanPromotionPolicy(row interface{ Scan(...interface{}) error }) (*PromotionPolicy, error) {
+	var policy PromotionPolicy
+	err := row.Scan(&policy.ID, &policy.AppID, &policy.Channel, &policy.FromChannel, &policy.MinHours,
+		&policy.RequiredLabelKey, &policy.RequiredLabelValue, &policy.RequiredApprovals,
+		&policy.CreatedAt, &policy.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// scanPromotion scans a promotion row selected with promotionColumns
+func scanPromotion(row interface{ Scan(...interface{}) error }) (*Promotion, error) {
+	var promotion Promotion
+	err := row.Scan(&promotion.ID, &promotion.AppID, &promotion.Channel, &promotion.Version,
+		&promotion.FromChannel, &promotion.Status, &promotion.RequiredApprovals, &promotion.RequestedBy,
+		&promotion.DecidedBy, &promotion.Reason, &promotion.CreatedAt, &promotion.DecidedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
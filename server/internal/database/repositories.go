@@ -149,8 +149,8 @@ func (r *VersionRepository) Create(appID int, hash string) (*Version, error) {
 	var version Version
 	query := `INSERT INTO versions (app_id, hash) VALUES ($1, $2)
 	          ON CONFLICT (app_id, hash) DO NOTHING
-	          RETURNING id, app_id, hash, is_published, created_at`
-	err := r.db.QueryRow(query, appID, hash).Scan(&version.ID, &version.AppID, &version.Hash, &version.IsPublished, &version.CreatedAt)
+	          RETURNING id, app_id, hash, is_published, pinned, created_at`
+	err := r.db.QueryRow(query, appID, hash).Scan(&version.ID, &version.AppID, &version.Hash, &version.IsPublished, &version.Pinned, &version.CreatedAt)
 	if err != nil {
 		// If no rows returned (conflict occurred), fetch the existing version
 		if err.Error() == "sql: no rows in result set" {
@@ -161,12 +161,41 @@ func (r *VersionRepository) Create(appID int, hash string) (*Version, error) {
 	return &version, nil
 }
 
+// Replace recreates a version record in one transaction
+// An overwritten version starts over unpublished, unpinned and without labels,
+// with a new creation time, and readers never observe a moment where the
+// version is missing.
+func (r *VersionRepository) Replace(appID int, hash string) (*Version, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM versions WHERE app_id = $1 AND hash = $2`, appID, hash); err != nil {
+		return nil, fmt.Errorf("failed to delete version: %w", err)
+	}
+
+	var version Version
+	query := `INSERT INTO versions (app_id, hash) VALUES ($1, $2)
+	          RETURNING id, app_id, hash, is_published, pinned, created_at`
+	err = tx.QueryRow(query, appID, hash).Scan(&version.ID, &version.AppID, &version.Hash, &version.IsPublished, &version.Pinned, &version.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &version, nil
+}
+
 // GetByHash gets a version by app ID and hash
 func (r *VersionRepository) GetByHash(appID int, hash string) (*Version, error) {
 	var version Version
-	query := `SELECT id, app_id, hash, is_published, created_at FROM versions 
+	query := `SELECT id, app_id, hash, is_published, pinned, created_at FROM versions 
 	          WHERE app_id = $1 AND hash = $2`
-	err := r.db.QueryRow(query, appID, hash).Scan(&version.ID, &version.AppID, &version.Hash, &version.IsPublished, &version.CreatedAt)
+	err := r.db.QueryRow(query, appID, hash).Scan(&version.ID, &version.AppID, &version.Hash, &version.IsPublished, &version.Pinned, &version.CreatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get version: %w", err)
 	}
@@ -175,7 +204,7 @@ func (r *VersionRepository) GetByHash(appID int, hash string) (*Version, error)
 
 // ListByApp lists versions for an app
 func (r *VersionRepository) ListByApp(appID int, limit, offset int) ([]*Version, error) {
-	query := `SELECT id, app_id, hash, is_published, created_at FROM versions 
+	query := `SELECT id, app_id, hash, is_published, pinned, created_at FROM versions 
 	          WHERE app_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`
 	rows, err := r.db.Query(query, appID, limit, offset)
 	if err != nil {
@@ -186,7 +215,40 @@ func (r *VersionRepository) ListByApp(appID int, limit, offset int) ([]*Version,
 	var versions []*Version
 	for rows.Next() {
 		var v Version
-		if err := rows.Scan(&v.ID, &v.AppID, &v.Hash, &v.IsPublished, &v.CreatedAt); err != nil {
+		if err := rows.Scan(&v.ID, &v.AppID, &v.Hash, &v.IsPublished, &v.Pinned, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, &v)
+	}
+	return versions, rows.Err()
+}
+
+// ListByLabels lists the versions of an app that match every label selector
+func (r *VersionRepository) ListByLabels(appID int, selectors []LabelSelector, limit, offset int) ([]*Version, error) {
+	query := `SELECT id, app_id, hash, is_published, pinned, created_at FROM versions WHERE app_id = $1`
+	args := []interface{}{appID}
+	for _, selector := range selectors {
+		args = append(args, selector.Key)
+		condition := fmt.Sprintf("l.key = $%d", len(args))
+		if selector.Value != "" {
+			args = append(args, selector.Value)
+			condition += fmt.Sprintf(" AND l.value = $%d", len(args))
+		}
+		query += ` AND EXISTS (SELECT 1 FROM version_labels l WHERE l.version_id = versions.id AND ` + condition + `)`
+	}
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(` ORDER BY created_at DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*Version
+	for rows.Next() {
+		var v Version
+		if err := rows.Scan(&v.ID, &v.AppID, &v.Hash, &v.IsPublished, &v.Pinned, &v.CreatedAt); err != nil {
 			return nil, err
 		}
 		versions = append(versions, &v)
@@ -211,7 +273,7 @@ func (r *VersionRepository) CountByApp(appID int) (int, error) {
 
 // GetOldestVersions returns oldest versions beyond limit
 func (r *VersionRepository) GetOldestVersions(appID int, limit int) ([]*Version, error) {
-	query := `SELECT id, app_id, hash, is_published, created_at FROM versions 
+	query := `SELECT id, app_id, hash, is_published, pinned, created_at FROM versions 
 	          WHERE app_id = $1 
 	          ORDER BY created_at ASC 
 	          LIMIT $2`
@@ -224,7 +286,7 @@ func (r *VersionRepository) GetOldestVersions(appID int, limit int) ([]*Version,
 	var versions []*Version
 	for rows.Next() {
 		var v Version
-		if err := rows.Scan(&v.ID, &v.AppID, &v.Hash, &v.IsPublished, &v.CreatedAt); err != nil {
+		if err := rows.Scan(&v.ID, &v.AppID, &v.Hash, &v.IsPublished, &v.Pinned, &v.CreatedAt); err != nil {
 			return nil, err
 		}
 		versions = append(versions, &v)
@@ -242,6 +304,16 @@ func (r *VersionRepository) SetPublished(appID int, hash string, published bool)
 	return nil
 }
 
+// SetPinned pins or unpins a version; pinned versions are never pruned by retention
+func (r *VersionRepository) SetPinned(appID int, hash string, pinned bool) error {
+	query := `UPDATE versions SET pinned = $1 WHERE app_id = $2 AND hash = $3`
+	_, err := r.db.Exec(query, pinned, appID, hash)
+	if err != nil {
+		return fmt.Errorf("failed to set pinned status: %w", err)
+	}
+	return nil
+}
+
 // UnpublishAllVersions unpublishes all versions for an app
 func (r *VersionRepository) UnpublishAllVersions(appID int) error {
 	query := `UPDATE versions SET is_published = FALSE WHERE app_id = $1 AND is_published = TRUE`
@@ -255,13 +327,94 @@ func (r *VersionRepository) UnpublishAllVersions(appID int) error {
 // GetLatestPublished returns the latest published version for an app
 func (r *VersionRepository) GetLatestPublished(appID int) (*Version, error) {
 	var version Version
-	query := `SELECT id, app_id, hash, is_published, created_at FROM versions 
+	query := `SELECT id, app_id, hash, is_published, pinned, created_at FROM versions 
 	          WHERE app_id = $1 AND is_published = TRUE 
 	          ORDER BY created_at DESC 
 	          LIMIT 1`
-	err := r.db.QueryRow(query, appID).Scan(&version.ID, &version.AppID, &version.Hash, &version.IsPublished, &version.CreatedAt)
+	err := r.db.QueryRow(query, appID).Scan(&version.ID, &version.AppID, &version.Hash, &version.IsPublished, &version.Pinned, &version.CreatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get latest published version: %w", err)
 	}
 	return &version, nil
 }
+
+// ListLabelsByApp returns the labels of the versions of an app, keyed by version ID
+func (r *VersionRepository) ListLabelsByApp(appID int) (map[int]map[string]string, error) {
+	query := `SELECT l.version_id, l.key, l.value FROM version_labels l
+	          JOIN versions v ON v.id = l.version_id
+	          WHERE v.app_id = $1`
+	rows, err := r.db.Query(query, appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list version labels: %w", err)
+	}
+	defer rows.Close()
+
+	labels := make(map[int]map[string]string)
+	for rows.Next() {
+		var versionID int
+		var key, value string
+		if err := rows.Scan(&versionID, &key, &value); err != nil {
+			return nil, err
+		}
+		if labels[versionID] == nil {
+			labels[versionID] = make(map[string]string)
+		}
+		labels[versionID][key] = value
+	}
+	return labels, rows.Err()
+}
+
+// GetLabels returns the labels of a version
+func (r *VersionRepository) GetLabels(versionID int) (map[string]string, error) {
+	rows, err := r.db.Query(`SELECT key, value FROM version_labels WHERE version_id = $1`, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version labels: %w", err)
+	}
+	defer rows.Close()
+
+	labels := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		labels[key] = value
+	}
+	return labels, rows.Err()
+}
+
+// SetLabels sets labels of a version, replacing the values of existing keys
+// Labels not given are left as they are.
+func (r *VersionRepository) SetLabels(versionID int, labels map[string]string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for key, value := range labels {
+		if _, err := tx.Exec(
+			`INSERT INTO version_labels (version_id, key, value) VALUES ($1, $2, $3)
+			 ON CONFLICT (version_id, key) DO UPDATE SET value = EXCLUDED.value`,
+			versionID, key, value,
+		); err != nil {
+			return fmt.Errorf("failed to set version label %s: %w", key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit version labels: %w", err)
+	}
+	return nil
+}
+
+// DeleteLabel removes a label from a version
+// Returns false if the version had no such label.
+func (r *VersionRepository) DeleteLabel(versionID int, key string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM version_labels WHERE version_id = $1 AND key = $2`, versionID, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete version label: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
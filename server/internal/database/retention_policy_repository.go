@@ -0,0 +1,128 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const retentionPolicyColumns = `id, project_id, app_id, keep_last, keep_days, keep_per_branch, branch_label, created_at, updated_at`
+
+// RetentionPolicyRepository handles retention policy database operations
+type RetentionPolicyRepository struct {
+	db *DB
+}
+
+// NewRetentionPolicyRepository creates a new retention policy repository
+func NewRetentionPolicyRepository(db *DB) *RetentionPolicyRepository {
+	return &RetentionPolicyRepository{db: db}
+}
+
+// GetForProject returns the policy of a project, or nil if it has none
+func (r *RetentionPolicyRepository) GetForProject(projectID int) (*RetentionPolicy, error) {
+	query := `SELECT ` + retentionPolicyColumns + ` FROM retention_policies
+	          WHERE project_id = $1 AND app_id IS NULL`
+	policy, err := scanRetentionPolicy(r.db.QueryRow(query, projectID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retention policy: %w", err)
+	}
+	return policy, nil
+}
+
+// GetForApp returns the policy of an app, or nil if it has none
+func (r *RetentionPolicyRepository) GetForApp(appID int) (*RetentionPolicy, error) {
+	query := `SELECT ` + retentionPolicyColumns + ` FROM retention_policies
+	          WHERE app_id = $1`
+	policy, err := scanRetentionPolicy(r.db.QueryRow(query, appID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retention policy: %w", err)
+	}
+	return policy, nil
+}
+
+// List lists all project and app policies, project policies first
+func (r *RetentionPolicyRepository) List() ([]*RetentionPolicy, error) {
+	query := `SELECT ` + retentionPolicyColumns + ` FROM retention_policies
+	          ORDER BY project_id, app_id NULLS FIRST`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list retention policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []*RetentionPolicy
+	for rows.Next() {
+		policy, err := scanRetentionPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// Set creates or replaces the policy of a project, or of an app if AppID is set
+func (r *RetentionPolicyRepository) Set(policy *RetentionPolicy) (*RetentionPolicy, error) {
+	conflict := `ON CONFLICT (project_id) WHERE app_id IS NULL`
+	if policy.AppID.Valid {
+		conflict = `ON CONFLICT (app_id) WHERE app_id IS NOT NULL`
+	}
+
+	query := `INSERT INTO retention_policies (project_id, app_id, keep_last, keep_days, keep_per_branch, branch_label)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          ` + conflict + ` DO UPDATE SET
+	              keep_last = EXCLUDED.keep_last,
+	              keep_days = EXCLUDED.keep_days,
+	              keep_per_branch = EXCLUDED.keep_per_branch,
+	              branch_label = EXCLUDED.branch_label,
+	              updated_at = CURRENT_TIMESTAMP
+	          RETURNING ` + retentionPolicyColumns
+	saved, err := scanRetentionPolicy(r.db.QueryRow(query, policy.ProjectID, policy.AppID,
+		policy.KeepLast, policy.KeepDays, policy.KeepPerBranch, policy.BranchLabel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set retention policy: %w", err)
+	}
+	return saved, nil
+}
+
+// DeleteForProject removes the policy of a project; app policies are kept
+// Returns false if the project had no policy.
+func (r *RetentionPolicyRepository) DeleteForProject(projectID int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM retention_policies WHERE project_id = $1 AND app_id IS NULL`, projectID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete retention policy: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// DeleteForApp removes the policy of an app
+// Returns false if the app had no policy.
+func (r *RetentionPolicyRepository) DeleteForApp(appID int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM retention_policies WHERE app_id = $1`, appID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete retention policy: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func scanRetentionPolicy(row interface{ Scan(...interface{}) error }) (*RetentionPolicy, error) {
+	var policy RetentionPolicy
+	err := row.Scan(&policy.ID, &policy.ProjectID, &policy.AppID, &policy.KeepLast, &policy.KeepDays,
+		&policy.KeepPerBranch, &policy.BranchLabel, &policy.CreatedAt, &policy.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Task run statuses
+const (
+	TaskRunRunning   = "running"
+	TaskRunSucceeded = "succeeded"
+	TaskRunFailed    = "failed"
+)
+
+// taskRunColumns leaves out details, which can be large and is only read by Get
+const taskRunColumns = `id, task_name, triggered_by, runner, status, dry_run, scheduled_at, started_at, finished_at, error, counts`
+
+// TaskRunRepository handles task run database operations
+type TaskRunRepository struct {
+	db *DB
+}
+
+// NewTaskRunRepository creates a new task run repository
+func NewTaskRunRepository(db *DB) *TaskRunRepository {
+	return &TaskRunRepository{db: db}
+}
+
+// Start records the start of a task run on the given runner (replica)
+// scheduledAt is the time the run was scheduled for, or nil for manual runs.
+// Returns nil if a run for the same scheduled time was already recorded, so a
+// scheduled run happens once across replicas.
+func (r *TaskRunRepository) Start(taskName, triggeredBy, runner string, scheduledAt *time.Time, dryRun bool) (*TaskRun, error) {
+	var scheduled sql.NullTime
+	if scheduledAt != nil {
+		scheduled = sql.NullTime{Time: *scheduledAt, Valid: true}
+	}
+
+	query := `INSERT INTO task_runs (task_name, triggered_by, runner, status, dry_run, scheduled_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          ON CONFLICT (task_name, scheduled_at) DO NOTHING
+	          RETURNING ` + taskRunColumns
+	run, err := scanTaskRun(r.db.QueryRow(query, taskName, triggeredBy, runner, TaskRunRunning, dryRun, scheduled))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to start task run: %w", err)
+	}
+	return run, nil
+}
+
+// Finish records the end of a task run with its status, error, counts and details
+// details is stored as JSON; nil records none.
+func (r *TaskRunRepository) Finish(id int64, status, errMsg string, counts map[string]int, details interface{}) error {
+	var countsJSON sql.NullString
+	if len(counts) > 0 {
+		data, err := json.Marshal(counts)
+		if err != nil {
+			return fmt.Errorf("failed to marshal task run counts: %w", err)
+		}
+		countsJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	var detailsJSON sql.NullString
+	if details != nil {
+		data, err := json.Marshal(details)
+		if err != nil {
+			return fmt.Errorf("failed to marshal task run details: %w", err)
+		}
+		detailsJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	query := `UPDATE task_runs
+	          SET status = $1, error = $2, counts = $3, details = $4, finished_at = CURRENT_TIMESTAMP
+	          WHERE id = $5`
+	if _, err := r.db.Exec(query, status, toNullString(errMsg), countsJSON, detailsJSON, id); err != nil {
+		return fmt.Errorf("failed to finish task run: %w", err)
+	}
+	return nil
+}
+
+// Latest returns the most recent run of a task, or nil if it never ran
+func (r *TaskRunRepository) Latest(taskName string) (*TaskRun, error) {
+	query := `SELECT ` + taskRunColumns + ` FROM task_runs
+	          WHERE task_name = $1
+	          ORDER BY started_at DESC, id DESC
+	          LIMIT 1`
+	run, err := scanTaskRun(r.db.QueryRow(query, taskName))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest task run: %w", err)
+	}
+	return run, nil
+}
+
+// Get returns a run of a task with its details, or nil if there is no such run
+func (r *TaskRunRepository) Get(taskName string, id int64) (*TaskRun, error) {
+	query := `SELECT ` + taskRunColumns + `, details FROM task_runs
+	          WHERE task_name = $1 AND id = $2`
+	var details sql.NullString
+	run, err := scanTaskRun(r.db.QueryRow(query, taskName, id), &details)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task run: %w", err)
+	}
+	run.Details = details
+	return run, nil
+}
+
+// LastScheduled returns the time the latest scheduled run of a task was
+// scheduled for, or nil if it never ran on schedule
+func (r *TaskRunRepository) LastScheduled(taskName string) (*time.Time, error) {
+	var scheduledAt sql.NullTime
+	query := `SELECT MAX(scheduled_at) FROM task_runs WHERE task_name = $1`
+	if err := r.db.QueryRow(query, taskName).Scan(&scheduledAt); err != nil {
+		return nil, fmt.Errorf("failed to get last scheduled task run: %w", err)
+	}
+	if !scheduledAt.Valid {
+		return nil, nil
+	}
+	return &scheduledAt.Time, nil
+}
+
+// ListByTask lists the runs of a task, newest first, with the total count
+func (r *TaskRunRepository) ListByTask(taskName string, limit, offset int) ([]*TaskRun, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM task_runs WHERE task_name = $1`
+	if err := r.db.QueryRow(countQuery, taskName).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count task runs: %w", err)
+	}
+
+	query := `SELECT ` + taskRunColumns + ` FROM task_runs
+	          WHERE task_name = $1
+	          ORDER BY started_at DESC, id DESC
+	          LIMIT $2 OFFSET $3`
+	rows, err := r.db.Query(query, taskName, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list task runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*TaskRun
+	for rows.Next() {
+		run, err := scanTaskRun(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, total, rows.Err()
+}
+
+// FailUnfinished marks the runs of a task that are still running as failed
+// Only call it while holding the task's lock: a run still marked running then
+// was interrupted by a restart or crash.
+func (r *TaskRunRepository) FailUnfinished(taskName string) (int64, error) {
+	query := `UPDATE task_runs
+	          SET status = $1, error = 'interrupted', finished_at = CURRENT_TIMESTAMP
+	          WHERE task_name = $2 AND status = $3`
+	result, err := r.db.Exec(query, TaskRunFailed, taskName, TaskRunRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail unfinished task runs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// RecordLeader records the replica holding the scheduler leadership, or renews it
+func (r *TaskRunRepository) RecordLeader(holder string) error {
+	query := `INSERT INTO scheduler_leader (id, holder, acquired_at, renewed_at)
+	          VALUES (1, $1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	          ON CONFLICT (id) DO UPDATE SET
+	              holder = EXCLUDED.holder,
+	              acquired_at = CASE WHEN scheduler_leader.holder = EXCLUDED.holder
+	                                 THEN scheduler_leader.acquired_at ELSE EXCLUDED.acquired_at END,
+	              renewed_at = EXCLUDED.renewed_at`
+	if _, err := r.db.Exec(query, holder); err != nil {
+		return fmt.Errorf("failed to record scheduler leader: %w", err)
+	}
+	return nil
+}
+
+// ClearLeader removes the leader record if the given replica holds it
+func (r *TaskRunRepository) ClearLeader(holder string) error {
+	if _, err := r.db.Exec(`DELETE FROM scheduler_leader WHERE holder = $1`, holder); err != nil {
+		return fmt.Errorf("failed to clear scheduler leader: %w", err)
+	}
+	return nil
+}
+
+// GetLeader returns the recorded scheduler leader, or nil if there is none
+// The leader is stale if it was not renewed within staleAfter.
+func (r *TaskRunRepository) GetLeader(staleAfter time.Duration) (*SchedulerLeader, error) {
+	var leader SchedulerLeader
+	query := `SELECT holder, acquired_at, renewed_at,
+	                 renewed_at < CURRENT_TIMESTAMP - INTERVAL '1 second' * $1
+	          FROM scheduler_leader WHERE id = 1`
+	err := r.db.QueryRow(query, int(staleAfter.Seconds())).Scan(&leader.Holder, &leader.AcquiredAt, &leader.RenewedAt, &leader.Stale)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduler leader: %w", err)
+	}
+	return &leader, nil
+}
+
+// DeleteOld deletes finished runs older than the given number of days
+// The latest run and the latest scheduled run of each task are kept, so the
+// scheduler still knows when a task last ran.
+func (r *TaskRunRepository) DeleteOld(days int) (int64, error) {
+	query := `DELETE FROM task_runs
+	          WHERE status <> $1 AND started_at < NOW() - INTERVAL '1 day' * $2
+	            AND id NOT IN (
+	                SELECT MAX(id) FROM task_runs GROUP BY task_name
+	                UNION
+	                SELECT MAX(id) FROM task_runs WHERE scheduled_at IS NOT NULL GROUP BY task_name
+	            )`
+	result, err := r.db.Exec(query, TaskRunRunning, days)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old task runs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// scanTaskRun scans taskRunColumns followed by any extra columns into extra
+func scanTaskRun(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*TaskRun, error) {
+	var run TaskRun
+	dest := []interface{}{
+		&run.ID,
+		&run.TaskName,
+		&run.TriggeredBy,
+		&run.Runner,
+		&run.Status,
+		&run.DryRun,
+		&run.ScheduledAt,
+		&run.StartedAt,
+		&run.FinishedAt,
+		&run.Error,
+		&run.Counts,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/database/dbtest"
+)
+
+func TestTaskRunRepository_ScheduledAt(t *testing.T) {
+	db := dbtest.Open(t)
+	// The session time zone must not shift stored times; one connection keeps the setting
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`SET TIME ZONE 'America/New_York'`); err != nil {
+		t.Fatalf("Failed to set time zone: %v", err)
+	}
+	runRepo := database.NewTaskRunRepository(db)
+
+	scheduledAt := time.Date(2025, 3, 9, 3, 0, 0, 0, time.FixedZone("UTC+8", 8*60*60))
+	run, err := runRepo.Start("cleanup", "schedule", "replica-1", &scheduledAt, false)
+	if err != nil || run == nil {
+		t.Fatalf("Expected the run to start, got %v (err %v)", run, err)
+	}
+	if !run.ScheduledAt.Valid || !run.ScheduledAt.Time.Equal(scheduledAt) {
+		t.Errorf("Expected scheduled_at %s, got %v", scheduledAt, run.ScheduledAt)
+	}
+
+	last, err := runRepo.LastScheduled("cleanup")
+	if err != nil || last == nil {
+		t.Fatalf("Expected a last scheduled run, got %v (err %v)", last, err)
+	}
+	if !last.Equal(scheduledAt) {
+		t.Errorf("Expected last scheduled run at %s, got %s", scheduledAt, last)
+	}
+
+	// The same instant in another zone is the same scheduled run
+	utc := scheduledAt.UTC()
+	duplicate, err := runRepo.Start("cleanup", "schedule", "replica-2", &utc, false)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if duplicate != nil {
+		t.Error("Expected a scheduled run to be recorded once")
+	}
+
+	// Manual runs have no scheduled time and are never duplicates
+	for i := 0; i < 2; i++ {
+		if run, err := runRepo.Start("cleanup", "admin", "replica-1", nil, false); err != nil || run == nil {
+			t.Fatalf("Expected manual run %d to start, got %v (err %v)", i, run, err)
+		}
+	}
+}
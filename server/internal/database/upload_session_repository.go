@@ -0,0 +1,234 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Upload session statuses
+const (
+	UploadSessionActive     = "active"
+	UploadSessionCommitting = "committing" // claimed by a finish request
+	UploadSessionCompleted  = "completed"
+	UploadSessionAborted    = "aborted"
+	UploadSessionExpired    = "expired"
+)
+
+// committingSessionGrace is how long past its expiry a session may stay
+// committing before it is taken for a finish that died (e.g. a server crash)
+const committingSessionGrace = "1 hour"
+
+const uploadSessionColumns = `id, project, app, version, token_id, username, expected_files, status, created_at, expires_at, completed_at`
+
+// UploadSessionRepository handles upload session database operations
+type UploadSessionRepository struct {
+	db *DB
+}
+
+// NewUploadSessionRepository creates a new upload session repository
+func NewUploadSessionRepository(db *DB) *UploadSessionRepository {
+	return &UploadSessionRepository{db: db}
+}
+
+// Create creates a new active upload session
+// expectedFiles is stored as JSON and may be nil when the client sent no manifest
+func (r *UploadSessionRepository) Create(id, project, app, version string, tokenID *int, username string, expectedFiles interface{}, expiresAt time.Time) (*UploadSession, error) {
+	var expectedJSON sql.NullString
+	if expectedFiles != nil {
+		data, err := json.Marshal(expectedFiles)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal expected files: %w", err)
+		}
+		expectedJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	query := `INSERT INTO upload_sessions (id, project, app, version, token_id, username, expected_files, status, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	          RETURNING ` + uploadSessionColumns
+
+	session, err := scanUploadSession(r.db.QueryRow(
+		query,
+		id,
+		project,
+		app,
+		version,
+		toNullInt64(tokenID),
+		toNullString(username),
+		expectedJSON,
+		UploadSessionActive,
+		expiresAt,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload session: %w", err)
+	}
+	return session, nil
+}
+
+// GetByID retrieves an upload session by ID
+func (r *UploadSessionRepository) GetByID(id string) (*UploadSession, error) {
+	query := `SELECT ` + uploadSessionColumns + ` FROM upload_sessions WHERE id = $1`
+	session, err := scanUploadSession(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+	return session, nil
+}
+
+// GetActiveByVersion retrieves the newest active, unexpired session for a version
+// Used for clients that do not send the upload ID with each request
+func (r *UploadSessionRepository) GetActiveByVersion(project, app, version string) (*UploadSession, error) {
+	query := `SELECT ` + uploadSessionColumns + ` FROM upload_sessions
+	          WHERE project = $1 AND app = $2 AND version = $3 AND status = $4 AND expires_at > NOW()
+	          ORDER BY created_at DESC LIMIT 1`
+	session, err := scanUploadSession(r.db.QueryRow(query, project, app, version, UploadSessionActive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+	return session, nil
+}
+
+// SetStatus moves an active session to a final status
+// Returns false if the session was no longer active
+func (r *UploadSessionRepository) SetStatus(id, status string) (bool, error) {
+	return r.transition(id, UploadSessionActive, status)
+}
+
+// Claim moves an active session to committing, so only one finish request
+// works on it and abort and expiry leave it alone until it is done
+// Returns false if the session was no longer active
+func (r *UploadSessionRepository) Claim(id string) (bool, error) {
+	return r.transition(id, UploadSessionActive, UploadSessionCommitting)
+}
+
+// Unclaim returns a committing session to active after a finish request
+// failed, so the client can fix the upload and finish again
+func (r *UploadSessionRepository) Unclaim(id string) (bool, error) {
+	return r.transition(id, UploadSessionCommitting, UploadSessionActive)
+}
+
+// Complete moves a committing session to completed
+func (r *UploadSessionRepository) Complete(id string) (bool, error) {
+	return r.transition(id, UploadSessionCommitting, UploadSessionCompleted)
+}
+
+// Expire moves a session listed by ListExpired to expired
+// Returns false if its status changed since it was listed
+func (r *UploadSessionRepository) Expire(session *UploadSession) (bool, error) {
+	return r.transition(session.ID, session.Status, UploadSessionExpired)
+}
+
+// transition moves a session from one status to another
+// Returns false if the session was not in the from status
+func (r *UploadSessionRepository) transition(id, from, to string) (bool, error) {
+	query := `UPDATE upload_sessions SET status = $1, completed_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = $3`
+	if to == UploadSessionActive || to == UploadSessionCommitting {
+		query = `UPDATE upload_sessions SET status = $1 WHERE id = $2 AND status = $3`
+	}
+	result, err := r.db.Exec(query, to, id, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update upload session: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated > 0, nil
+}
+
+// AddFile records a blob staged by a session
+func (r *UploadSessionRepository) AddFile(id, sha256 string, size int64) error {
+	_, err := r.db.Exec(
+		`INSERT INTO upload_session_files (session_id, sha256, size) VALUES ($1, $2, $3)
+		 ON CONFLICT (session_id, sha256) DO UPDATE SET size = EXCLUDED.size`,
+		id, sha256, size,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record staged file: %w", err)
+	}
+	return nil
+}
+
+// ListFiles lists the blobs staged by a session (sha256 -> size)
+func (r *UploadSessionRepository) ListFiles(id string) (map[string]int64, error) {
+	rows, err := r.db.Query(`SELECT sha256, size FROM upload_session_files WHERE session_id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list staged files: %w", err)
+	}
+	defer rows.Close()
+
+	files := make(map[string]int64)
+	for rows.Next() {
+		var hash string
+		var size int64
+		if err := rows.Scan(&hash, &size); err != nil {
+			return nil, err
+		}
+		files[hash] = size
+	}
+	return files, rows.Err()
+}
+
+// ListExpired lists active sessions whose expiry time has passed, and
+// committing sessions left behind by a finish that never ended
+func (r *UploadSessionRepository) ListExpired(limit int) ([]*UploadSession, error) {
+	query := `SELECT ` + uploadSessionColumns + ` FROM upload_sessions
+	          WHERE (status = $1 AND expires_at <= NOW())
+	             OR (status = $2 AND expires_at <= NOW() - INTERVAL '` + committingSessionGrace + `')
+	          ORDER BY expires_at ASC LIMIT $3`
+	rows, err := r.db.Query(query, UploadSessionActive, UploadSessionCommitting, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired upload sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*UploadSession
+	for rows.Next() {
+		session, err := scanUploadSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteFinished deletes sessions that ended more than the given number of days ago
+func (r *UploadSessionRepository) DeleteFinished(olderThanDays int) (int64, error) {
+	result, err := r.db.Exec(
+		`DELETE FROM upload_sessions WHERE status NOT IN ($1, $2) AND completed_at < NOW() - INTERVAL '1 day' * $3`,
+		UploadSessionActive, UploadSessionCommitting, olderThanDays,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete finished upload sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// scanUploadSession scans an upload session from a row
+func scanUploadSession(row interface{ Scan(...interface{}) error }) (*UploadSession, error) {
+	var session UploadSession
+	err := row.Scan(
+		&session.ID,
+		&session.Project,
+		&session.App,
+		&session.Version,
+		&session.TokenID,
+		&session.Username,
+		&session.ExpectedFiles,
+		&session.Status,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&session.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package database_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/database/dbtest"
+)
+
+func TestVersionRepository_ListByLabels(t *testing.T) {
+	db := dbtest.Open(t)
+	project, err := database.NewProjectRepository(db).CreateOrGet("shop")
+	if err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	app, err := database.NewAppRepository(db).CreateOrGet(project.ID, "api")
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	versionRepo := database.NewVersionRepository(db)
+	labels := map[string]map[string]string{
+		"v1": {"env": "staging"},
+		"v2": {"env": "prod", "tier": "gold"},
+		"v3": {"env": "prod"},
+		"v4": nil,
+	}
+	for hash, versionLabels := range labels {
+		version, err := versionRepo.Create(app.ID, hash)
+		if err != nil {
+			t.Fatalf("Failed to create version %s: %v", hash, err)
+		}
+		if err := versionRepo.SetLabels(version.ID, versionLabels); err != nil {
+			t.Fatalf("Failed to label version %s: %v", hash, err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		selectors []database.LabelSelector
+		want      []string
+	}{
+		{"no selectors", nil, []string{"v1", "v2", "v3", "v4"}},
+		{"key and value", []database.LabelSelector{{Key: "env", Value: "prod"}}, []string{"v2", "v3"}},
+		{"key only", []database.LabelSelector{{Key: "env"}}, []string{"v1", "v2", "v3"}},
+		{"all selectors must match", []database.LabelSelector{{Key: "env", Value: "prod"}, {Key: "tier"}}, []string{"v2"}},
+		{"no match", []database.LabelSelector{{Key: "env", Value: "dev"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions, err := versionRepo.ListByLabels(app.ID, tt.selectors, 100, 0)
+			if err != nil {
+				t.Fatalf("ListByLabels() error = %v", err)
+			}
+			var got []string
+			for _, v := range versions {
+				got = append(got, v.Hash)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListByLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending    = "pending"
+	WebhookDeliveryDelivering = "delivering"
+	WebhookDeliverySucceeded  = "succeeded"
+	WebhookDeliveryFailed     = "failed"
+)
+
+const webhookDeliveryColumns = `id, webhook_id, event_type, payload, status, attempts, max_attempts, next_attempt_at, locked_until, last_status_code, last_error, redelivery_of, created_at, completed_at`
+
+const webhookDeliveryAttemptColumns = `id, delivery_id, attempt, request_url, request_headers, request_body, status_code, response_headers, response_body, duration_ms, error, created_at`
+
+// WebhookDeliveryRepository handles webhook delivery database operations
+type WebhookDeliveryRepository struct {
+	db *DB
+}
+
+// NewWebhookDeliveryRepository creates a new webhook delivery repository
+func NewWebhookDeliveryRepository(db *DB) *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{db: db}
+}
+
+// Create queues a delivery of an event payload to a webhook, due immediately
+func (r *WebhookDeliveryRepository) Create(webhookID int, eventType, payload string, maxAttempts int, redeliveryOf *int64) (*WebhookDelivery, error) {
+	var redelivery sql.NullInt64
+	if redeliveryOf != nil {
+		redelivery = sql.NullInt64{Int64: *redeliveryOf, Valid: true}
+	}
+
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, max_attempts, redelivery_of)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          RETURNING ` + webhookDeliveryColumns
+
+	delivery, err := scanWebhookDelivery(r.db.QueryRow(query, webhookID, eventType, payload, WebhookDeliveryPending, maxAttempts, redelivery))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// CreateForStreamEntry queues a delivery of an event read from the event stream
+// A stream entry is queued for a webhook only once, so an entry handled again
+// after a failure does not send the event twice. Returns nil if the delivery
+// was already queued.
+func (r *WebhookDeliveryRepository) CreateForStreamEntry(webhookID int, streamEntryID, eventType, payload string, maxAttempts int) (*WebhookDelivery, error) {
+	query := `INSERT INTO webhook_deliveries (webhook_id, stream_entry_id, event_type, payload, status, max_attempts)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          ON CONFLICT (stream_entry_id, webhook_id) WHERE stream_entry_id IS NOT NULL DO NOTHING
+	          RETURNING ` + webhookDeliveryColumns
+
+	delivery, err := scanWebhookDelivery(r.db.QueryRow(query, webhookID, streamEntryID, eventType, payload, WebhookDeliveryPending, maxAttempts))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// GetByID retrieves a delivery by ID, or nil if it does not exist
+func (r *WebhookDeliveryRepository) GetByID(id int64) (*WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+	delivery, err := scanWebhookDelivery(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// ListByWebhook lists the deliveries of a webhook, newest first, with the total count
+// status filters by delivery status when not empty
+func (r *WebhookDeliveryRepository) ListByWebhook(webhookID int, status string, limit, offset int) ([]*WebhookDelivery, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1 AND ($2 = '' OR status = $2)`
+	if err := r.db.QueryRow(countQuery, webhookID, status).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+	          WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+	          ORDER BY created_at DESC, id DESC
+	          LIMIT $3 OFFSET $4`
+	rows, err := r.db.Query(query, webhookID, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// ClaimNext takes the next due delivery and leases it to the caller for lease
+// The attempt counter is incremented. A delivery whose lease ran out (the
+// worker holding it died) is due again. Returns nil if nothing is due.
+func (r *WebhookDeliveryRepository) ClaimNext(lease time.Duration) (*WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries
+	          SET status = $1, attempts = attempts + 1, locked_until = CURRENT_TIMESTAMP + INTERVAL '1 millisecond' * $2
+	          WHERE id = (
+	              SELECT id FROM webhook_deliveries
+	              WHERE (status = $3 AND next_attempt_at <= CURRENT_TIMESTAMP)
+	                 OR (status = $1 AND locked_until < CURRENT_TIMESTAMP)
+	              ORDER BY next_attempt_at
+	              LIMIT 1
+	              FOR UPDATE SKIP LOCKED
+	          )
+	          RETURNING ` + webhookDeliveryColumns
+
+	delivery, err := scanWebhookDelivery(r.db.QueryRow(query, WebhookDeliveryDelivering, lease.Milliseconds(), WebhookDeliveryPending))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// Reschedule puts a delivery back in the queue after a failed attempt, due after delay
+func (r *WebhookDeliveryRepository) Reschedule(id int64, delay time.Duration, statusCode int, errMsg string) error {
+	query := `UPDATE webhook_deliveries
+	          SET status = $1, next_attempt_at = CURRENT_TIMESTAMP + INTERVAL '1 millisecond' * $2, locked_until = NULL, last_status_code = $3, last_error = $4
+	          WHERE id = $5`
+	_, err := r.db.Exec(query, WebhookDeliveryPending, delay.Milliseconds(), toNullStatusCode(statusCode), toNullString(errMsg), id)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// Complete marks a delivery as succeeded or finally failed
+func (r *WebhookDeliveryRepository) Complete(id int64, status string, statusCode int, errMsg string) error {
+	query := `UPDATE webhook_deliveries
+	          SET status = $1, locked_until = NULL, last_status_code = $2, last_error = $3, completed_at = CURRENT_TIMESTAMP
+	          WHERE id = $4`
+	_, err := r.db.Exec(query, status, toNullStatusCode(statusCode), toNullString(errMsg), id)
+	if err != nil {
+		return fmt.Errorf("failed to complete webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// RecordAttempt stores the request and response of one delivery attempt
+// Headers are stored as JSON; a zero status code means no response was received.
+func (r *WebhookDeliveryRepository) RecordAttempt(deliveryID int64, attempt int, requestURL string, requestHeaders map[string]string, requestBody string, statusCode int, responseHeaders map[string]string, responseBody string, duration time.Duration, errMsg string) error {
+	requestHeadersJSON, err := toNullJSON(requestHeaders)
+	if err != nil {
+		return err
+	}
+	responseHeadersJSON, err := toNullJSON(responseHeaders)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO webhook_delivery_attempts (delivery_id, attempt, request_url, request_headers, request_body, status_code, response_headers, response_body, duration_ms, error)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err = r.db.Exec(
+		query,
+		deliveryID,
+		attempt,
+		requestURL,
+		requestHeadersJSON,
+		toNullString(requestBody),
+		toNullStatusCode(statusCode),
+		responseHeadersJSON,
+		toNullString(responseBody),
+		duration.Milliseconds(),
+		toNullString(errMsg),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery attempt: %w", err)
+	}
+	return nil
+}
+
+// ListAttempts lists the attempts of a delivery in order
+func (r *WebhookDeliveryRepository) ListAttempts(deliveryID int64) ([]*WebhookDeliveryAttempt, error) {
+	query := `SELECT ` + webhookDeliveryAttemptColumns + ` FROM webhook_delivery_attempts
+	          WHERE delivery_id = $1 ORDER BY attempt, id`
+	rows, err := r.db.Query(query, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook delivery attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []*WebhookDeliveryAttempt
+	for rows.Next() {
+		var attempt WebhookDeliveryAttempt
+		if err := rows.Scan(
+			&attempt.ID,
+			&attempt.DeliveryID,
+			&attempt.Attempt,
+			&attempt.RequestURL,
+			&attempt.RequestHeaders,
+			&attempt.RequestBody,
+			&attempt.StatusCode,
+			&attempt.ResponseHeaders,
+			&attempt.ResponseBody,
+			&attempt.DurationMs,
+			&attempt.Error,
+			&attempt.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, &attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// DeleteFinished deletes succeeded and failed deliveries older than the given number of days
+func (r *WebhookDeliveryRepository) DeleteFinished(days int) (int64, error) {
+	query := `DELETE FROM webhook_deliveries
+	          WHERE status IN ($1, $2) AND created_at < NOW() - INTERVAL '1 day' * $3`
+	result, err := r.db.Exec(query, WebhookDeliverySucceeded, WebhookDeliveryFailed, days)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete finished webhook deliveries: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func scanWebhookDelivery(row interface{ Scan(...interface{}) error }) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.MaxAttempts,
+		&delivery.NextAttemptAt,
+		&delivery.LockedUntil,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.RedeliveryOf,
+		&delivery.CreatedAt,
+		&delivery.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func toNullStatusCode(statusCode int) sql.NullInt64 {
+	if statusCode == 0 {
+		return sql.NullInt64{Valid: false}
+	}
+	return sql.NullInt64{Int64: int64(statusCode), Valid: true}
+}
+
+func toNullJSON(value map[string]string) (sql.NullString, error) {
+	if value == nil {
+		return sql.NullString{Valid: false}, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal headers: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
@@ -24,7 +24,8 @@ func NewWebhookRepository(db *DB) *WebhookRepository {
 }
 
 // Create creates a new webhook
-func (r *WebhookRepository) Create(name string, eventTypes []string, url string, headers map[string]string, enabled bool, projectID, appID *int) (*Webhook, error) {
+// An empty secret leaves the webhook unsigned; an empty format means raw.
+func (r *WebhookRepository) Create(name string, eventTypes []string, url string, headers map[string]string, enabled bool, projectID, appID *int, secret, format, template string) (*Webhook, error) {
 	var webhook Webhook
 	var headersJSON sql.NullString
 	
@@ -36,9 +37,13 @@ func (r *WebhookRepository) Create(name string, eventTypes []string, url string,
 		headersJSON = sql.NullString{String: string(headersBytes), Valid: true}
 	}
 
-	query := `INSERT INTO webhooks (name, event_types, url, headers, enabled, project_id, app_id)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7)
-	          RETURNING id, name, event_types, url, headers, enabled, project_id, app_id, created_at`
+	if format == "" {
+		format = "raw"
+	}
+
+	query := `INSERT INTO webhooks (name, event_types, url, headers, enabled, project_id, app_id, secret, format, template)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	          RETURNING id, name, event_types, url, headers, enabled, project_id, app_id, secret, format, template, created_at`
 	
 	err := r.db.QueryRow(
 		query,
@@ -49,6 +54,9 @@ func (r *WebhookRepository) Create(name string, eventTypes []string, url string,
 		enabled,
 		toNullInt64(projectID),
 		toNullInt64(appID),
+		toNullString(secret),
+		format,
+		toNullString(template),
 	).Scan(
 		&webhook.ID,
 		&webhook.Name,
@@ -58,6 +66,9 @@ func (r *WebhookRepository) Create(name string, eventTypes []string, url string,
 		&webhook.Enabled,
 		&webhook.ProjectID,
 		&webhook.AppID,
+		&webhook.Secret,
+		&webhook.Format,
+		&webhook.Template,
 		&webhook.CreatedAt,
 	)
 	
@@ -69,7 +80,7 @@ func (r *WebhookRepository) Create(name string, eventTypes []string, url string,
 
 // List lists all webhooks
 func (r *WebhookRepository) List() ([]*Webhook, error) {
-	query := `SELECT id, name, event_types, url, headers, enabled, project_id, app_id, created_at
+	query := `SELECT id, name, event_types, url, headers, enabled, project_id, app_id, secret, format, template, created_at
 	          FROM webhooks WHERE enabled = true ORDER BY created_at DESC`
 	rows, err := r.db.Query(query)
 	if err != nil {
@@ -89,6 +100,9 @@ func (r *WebhookRepository) List() ([]*Webhook, error) {
 			&webhook.Enabled,
 			&webhook.ProjectID,
 			&webhook.AppID,
+			&webhook.Secret,
+			&webhook.Format,
+			&webhook.Template,
 			&webhook.CreatedAt,
 		); err != nil {
 			return nil, err
@@ -101,7 +115,7 @@ func (r *WebhookRepository) List() ([]*Webhook, error) {
 // GetByID gets a webhook by ID
 func (r *WebhookRepository) GetByID(id int) (*Webhook, error) {
 	var webhook Webhook
-	query := `SELECT id, name, event_types, url, headers, enabled, project_id, app_id, created_at
+	query := `SELECT id, name, event_types, url, headers, enabled, project_id, app_id, secret, format, template, created_at
 	          FROM webhooks WHERE id = $1`
 	
 	err := r.db.QueryRow(query, id).Scan(
@@ -113,6 +127,9 @@ func (r *WebhookRepository) GetByID(id int) (*Webhook, error) {
 		&webhook.Enabled,
 		&webhook.ProjectID,
 		&webhook.AppID,
+		&webhook.Secret,
+		&webhook.Format,
+		&webhook.Template,
 		&webhook.CreatedAt,
 	)
 	
@@ -126,7 +143,7 @@ func (r *WebhookRepository) GetByID(id int) (*Webhook, error) {
 }
 
 // Update updates a webhook
-func (r *WebhookRepository) Update(id int, name string, eventTypes []string, url string, headers map[string]string, enabled bool, projectID, appID *int) error {
+func (r *WebhookRepository) Update(id int, name string, eventTypes []string, url string, headers map[string]string, enabled bool, projectID, appID *int, secret, format, template string) error {
 	var headersJSON sql.NullString
 	if headers != nil {
 		headersBytes, err := json.Marshal(headers)
@@ -136,9 +153,13 @@ func (r *WebhookRepository) Update(id int, name string, eventTypes []string, url
 		headersJSON = sql.NullString{String: string(headersBytes), Valid: true}
 	}
 
-	query := `UPDATE webhooks SET name = $1, event_types = $2, url = $3, headers = $4, enabled = $5, project_id = $6, app_id = $7
-	          WHERE id = $8`
-	_, err := r.db.Exec(query, name, pq.Array(eventTypes), url, headersJSON, enabled, toNullInt64(projectID), toNullInt64(appID), id)
+	if format == "" {
+		format = "raw"
+	}
+
+	query := `UPDATE webhooks SET name = $1, event_types = $2, url = $3, headers = $4, enabled = $5, project_id = $6, app_id = $7, secret = $8, format = $9, template = $10
+	          WHERE id = $11`
+	_, err := r.db.Exec(query, name, pq.Array(eventTypes), url, headersJSON, enabled, toNullInt64(projectID), toNullInt64(appID), toNullString(secret), format, toNullString(template), id)
 	return err
 }
 
@@ -150,11 +171,12 @@ func (r *WebhookRepository) Delete(id int) error {
 }
 
 // FindByEventType finds webhooks that match the event type and optionally project/app
+// Webhooks subscribed to "*" match every event type.
 func (r *WebhookRepository) FindByEventType(eventType string, projectID, appID *int) ([]*Webhook, error) {
-	query := `SELECT id, name, event_types, url, headers, enabled, project_id, app_id, created_at
+	query := `SELECT id, name, event_types, url, headers, enabled, project_id, app_id, secret, format, template, created_at
 	          FROM webhooks 
 	          WHERE enabled = true 
-	          AND $1 = ANY(event_types)
+	          AND ($1 = ANY(event_types) OR '*' = ANY(event_types))
 	          AND (project_id IS NULL OR project_id = $2)
 	          AND (app_id IS NULL OR (project_id = $2 AND app_id = $3))
 	          ORDER BY created_at DESC`
@@ -189,6 +211,9 @@ func (r *WebhookRepository) FindByEventType(eventType string, projectID, appID *
 			&webhook.Enabled,
 			&webhook.ProjectID,
 			&webhook.AppID,
+			&webhook.Secret,
+			&webhook.Format,
+			&webhook.Template,
 			&webhook.CreatedAt,
 		); err != nil {
 			return nil, err
@@ -6,21 +6,99 @@
 package events
 
 import (
+	"fmt"
 	"log"
+	"sync"
 	"time"
 )
 
 // EventType represents the type of event
 type EventType string
 
+// Event types
+// Version events carry project, app and version; app.deleted and
+// project.deleted leave out what no longer applies, and token and config
+// events are global unless the token is scoped to a project or app. Events
+// also carry the IDs of their project and app, which are looked up when the
+// event is published unless the publisher sets them, e.g. before a delete.
 const (
-	EventTypePush     EventType = "push"
-	EventTypePull     EventType = "pull"
-	EventTypePromote  EventType = "promote"
-	EventTypeRollback EventType = "rollback"
-	EventTypeDelete   EventType = "delete"
+	EventTypeVersionPushed      EventType = "version.pushed"
+	EventTypeVersionPublished   EventType = "version.published"
+	EventTypeVersionUnpublished EventType = "version.unpublished"
+	EventTypeVersionDeleted     EventType = "version.deleted"
+	EventTypeVersionPinned      EventType = "version.pinned"
+	EventTypeVersionUnpinned    EventType = "version.unpinned"
+	EventTypeVersionLabeled     EventType = "version.labeled"
+	EventTypeChannelUpdated     EventType = "channel.updated"
+	EventTypeChannelDeleted     EventType = "channel.deleted"
+	EventTypeChannelRolledBack  EventType = "channel.rolled_back"
+	EventTypeVersionPromoted    EventType = "version.promoted"
+	EventTypePromotionRequested EventType = "promotion.requested"
+	EventTypePromotionApproved  EventType = "promotion.approved"
+	EventTypePromotionRejected  EventType = "promotion.rejected"
+	EventTypeAppDeleted         EventType = "app.deleted"
+	EventTypeProjectDeleted     EventType = "project.deleted"
+	EventTypeRetentionPruned    EventType = "retention.pruned"
+	EventTypeTokenCreated       EventType = "token.created"
+	EventTypeTokenRevoked       EventType = "token.revoked"
+	EventTypeConfigUpdated      EventType = "config.updated"
+	EventTypeWebhookFailed      EventType = "webhook.failed"
+
+	// EventTypeAll subscribes a webhook to every event type
+	EventTypeAll EventType = "*"
 )
 
+// EventTypes is the catalog of events the server publishes
+var EventTypes = []EventType{
+	EventTypeVersionPushed,
+	EventTypeVersionPublished,
+	EventTypeVersionUnpublished,
+	EventTypeVersionDeleted,
+	EventTypeVersionPinned,
+	EventTypeVersionUnpinned,
+	EventTypeVersionLabeled,
+	EventTypeChannelUpdated,
+	EventTypeChannelDeleted,
+	EventTypeChannelRolledBack,
+	EventTypeVersionPromoted,
+	EventTypePromotionRequested,
+	EventTypePromotionApproved,
+	EventTypePromotionRejected,
+	EventTypeAppDeleted,
+	EventTypeProjectDeleted,
+	EventTypeRetentionPruned,
+	EventTypeTokenCreated,
+	EventTypeTokenRevoked,
+	EventTypeConfigUpdated,
+	EventTypeWebhookFailed,
+}
+
+// legacyEventTypes maps the event names used before the catalog to their events
+var legacyEventTypes = map[string]EventType{
+	"push":      EventTypeVersionPushed,
+	"publish":   EventTypeVersionPublished,
+	"unpublish": EventTypeVersionUnpublished,
+	"delete":    EventTypeVersionDeleted,
+	"rollback":  EventTypeChannelRolledBack,
+	"promote":   EventTypeVersionPromoted,
+}
+
+// ParseEventType resolves an event type name from the catalog, "*" or a legacy name
+func ParseEventType(name string) (EventType, error) {
+	if eventType, ok := legacyEventTypes[name]; ok {
+		return eventType, nil
+	}
+	if EventType(name) == EventTypeAll {
+		return EventTypeAll, nil
+	}
+	for _, eventType := range EventTypes {
+		if EventType(name) == eventType {
+			return eventType, nil
+		}
+	}
+	return "", fmt.Errorf("unknown event type %q", name)
+}
+
 // Event represents an event in the system
 type Event struct {
 	ID        int                    `json:"id"`
@@ -31,34 +109,56 @@ type Event struct {
 	AgentID   string                 `json:"agent_id,omitempty"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 	Timestamp time.Time              `json:"timestamp"`
+	ProjectID *int                   `json:"project_id,omitempty"`
+	AppID     *int                   `json:"app_id,omitempty"`
+
+	// StreamEntryID is the ID of the Redis stream entry the event was read from
+	StreamEntryID string `json:"-"`
 }
 
 // EventBus handles event publishing and subscription
 type EventBus interface {
 	Publish(event *Event) error
 	Subscribe(eventType EventType, handler EventHandler) error
+	// SubscribeAll calls handler for every event until unsubscribe is called
+	SubscribeAll(handler EventHandler) (unsubscribe func())
 }
 
 // EventHandler handles events
 type EventHandler func(event *Event) error
 
 // MemoryEventBus is a simple in-memory event bus implementation
+// It is safe for concurrent use. Handlers run synchronously in Publish, so
+// they must not block.
 type MemoryEventBus struct {
-	handlers map[EventType][]EventHandler
+	mu       sync.RWMutex
+	handlers map[EventType][]subscription
+	nextID   int
+}
+
+// subscription is a handler registered on a MemoryEventBus
+type subscription struct {
+	id      int
+	handler EventHandler
 }
 
 // NewMemoryEventBus creates a new memory event bus
 func NewMemoryEventBus() *MemoryEventBus {
 	return &MemoryEventBus{
-		handlers: make(map[EventType][]EventHandler),
+		handlers: make(map[EventType][]subscription),
 	}
 }
 
 // Publish publishes an event
 func (b *MemoryEventBus) Publish(event *Event) error {
-	handlers := b.handlers[event.Type]
-	for _, handler := range handlers {
-		if err := handler(event); err != nil {
+	b.mu.RLock()
+	subscriptions := make([]subscription, 0, len(b.handlers[event.Type])+len(b.handlers[EventTypeAll]))
+	subscriptions = append(subscriptions, b.handlers[event.Type]...)
+	subscriptions = append(subscriptions, b.handlers[EventTypeAll]...)
+	b.mu.RUnlock()
+
+	for _, sub := range subscriptions {
+		if err := sub.handler(event); err != nil {
 			// Log error but continue with other handlers
 			// Use standard library log for now (can be replaced with structured logger if needed)
 			log.Printf("Event handler error for event type %s (project=%s, app=%s, version=%s): %v",
@@ -70,6 +170,35 @@ func (b *MemoryEventBus) Publish(event *Event) error {
 
 // Subscribe subscribes to an event type
 func (b *MemoryEventBus) Subscribe(eventType EventType, handler EventHandler) error {
-	b.handlers[eventType] = append(b.handlers[eventType], handler)
+	b.subscribe(eventType, handler)
 	return nil
 }
+
+// SubscribeAll subscribes to every event type
+func (b *MemoryEventBus) SubscribeAll(handler EventHandler) func() {
+	return b.subscribe(EventTypeAll, handler)
+}
+
+// subscribe registers a handler and returns the function that removes it
+func (b *MemoryEventBus) subscribe(eventType EventType, handler EventHandler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	id := b.nextID
+	b.handlers[eventType] = append(b.handlers[eventType], subscription{id: id, handler: handler})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subscriptions := b.handlers[eventType]
+		for i, sub := range subscriptions {
+			if sub.id == id {
+				// Copy so a Publish holding the old slice is not affected
+				remaining := make([]subscription, 0, len(subscriptions)-1)
+				remaining = append(remaining, subscriptions[:i]...)
+				b.handlers[eventType] = append(remaining, subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package events
+
+import "testing"
+
+func TestParseEventType(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    EventType
+		wantErr bool
+	}{
+		{name: "version.pushed", want: EventTypeVersionPushed},
+		{name: "*", want: EventTypeAll},
+		{name: "push", want: EventTypeVersionPushed},
+		{name: "rollback", want: EventTypeChannelRolledBack},
+		{name: "promote", want: EventTypeVersionPromoted},
+		{name: "pull", wantErr: true},
+		{name: "version.pulled", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEventType(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEventType(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package events
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kk/kkartifact-server/internal/database"
+)
+
+// Publisher publishes events to the event bus, the audit log and subscribed webhooks
+// It is shared by the API handlers, scheduled tasks and the webhook dispatcher,
+// so every event is handled the same way wherever it comes from.
+type Publisher struct {
+	db         *database.DB
+	bus        EventBus
+	dispatcher *WebhookDispatcher
+}
+
+// NewPublisher creates a new event publisher
+// The dispatcher may be nil, in which case no webhooks are sent. Deliveries the
+// dispatcher gives up on are published through this publisher as webhook.failed.
+// Webhooks are queued by a subscriber of the bus, so with a shared bus each
+// event is queued once by whichever replica handles it.
+func NewPublisher(db *database.DB, bus EventBus, dispatcher *WebhookDispatcher) *Publisher {
+	p := &Publisher{
+		db:         db,
+		bus:        bus,
+		dispatcher: dispatcher,
+	}
+	if dispatcher != nil {
+		dispatcher.publisher = p
+		if bus != nil {
+			_ = bus.Subscribe(EventTypeAll, p.enqueueWebhooks)
+		}
+	}
+	return p
+}
+
+// Bus returns the event bus
+func (p *Publisher) Bus() EventBus {
+	return p.bus
+}
+
+// Dispatcher returns the webhook dispatcher, or nil
+func (p *Publisher) Dispatcher() *WebhookDispatcher {
+	return p.dispatcher
+}
+
+// Publish publishes an event
+// The event is recorded in the audit log with its type as the operation and
+// sent to the event bus, whose subscriber queues it for every enabled webhook
+// that subscribes to it and whose project/app scope matches. Failures are
+// logged; publishing never fails the caller.
+//
+// The IDs of the event's project and app are looked up unless they are set,
+// so the event keeps its scope even if they are deleted before the webhooks
+// are queued. The audit log entry only refers to those that still exist.
+func (p *Publisher) Publish(event *Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]interface{})
+	}
+
+	projectID, appID := p.resolveScope(event.Project, event.App)
+	if event.ProjectID == nil {
+		event.ProjectID = projectID
+	}
+	if event.AppID == nil {
+		event.AppID = appID
+	}
+
+	// The audit log entry ID is the event ID, so stream clients can resume from it
+	auditRepo := database.NewAuditRepository(p.db)
+	id, err := auditRepo.Record(string(event.Type), projectID, appID, event.Version, event.AgentID, event.Metadata)
+	if err != nil {
+		log.Printf("Failed to record %s event in audit log: %v", event.Type, err)
+	}
+	event.ID = id
+
+	if p.bus == nil {
+		p.queueWebhooks(event)
+		return
+	}
+	if err := p.bus.Publish(event); err != nil {
+		log.Printf("Failed to publish event to event bus: %v", err)
+		// Queue the webhooks here so they are not lost with the event
+		p.queueWebhooks(event)
+	}
+}
+
+// enqueueWebhooks is the bus subscriber that queues an event for its webhooks
+// It fails only if the webhooks could not be looked up, so the bus can retry;
+// a webhook that cannot be queued is logged and skipped.
+func (p *Publisher) enqueueWebhooks(event *Event) error {
+	if p.dispatcher == nil {
+		return nil
+	}
+	projectID, appID := event.ProjectID, event.AppID
+	if projectID == nil {
+		projectID, appID = p.resolveScope(event.Project, event.App)
+	}
+	webhooks, err := database.NewWebhookRepository(p.db).FindByEventType(string(event.Type), projectID, appID)
+	if err != nil {
+		return fmt.Errorf("failed to find webhooks for %s event: %w", event.Type, err)
+	}
+	for _, webhook := range webhooks {
+		if _, err := p.dispatcher.Enqueue(webhook, event); err != nil {
+			log.Printf("Failed to queue webhook %d: %v", webhook.ID, err)
+		}
+	}
+	return nil
+}
+
+// queueWebhooks queues an event for its webhooks without the bus
+func (p *Publisher) queueWebhooks(event *Event) {
+	if err := p.enqueueWebhooks(event); err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+// resolveScope looks up the IDs of the project and app an event is about
+// Names that do not exist (any more) resolve to nil; they are never created.
+func (p *Publisher) resolveScope(project, app string) (*int, *int) {
+	if project == "" {
+		return nil, nil
+	}
+	projectObj, err := database.NewProjectRepository(p.db).GetByName(project)
+	if err != nil {
+		return nil, nil
+	}
+	projectID := &projectObj.ID
+	if app == "" {
+		return projectID, nil
+	}
+	appObj, err := database.NewAppRepository(p.db).GetByName(projectObj.ID, app)
+	if err != nil {
+		return projectID, nil
+	}
+	return projectID, &appObj.ID
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package events
+
+import (
+	"testing"
+
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/database/dbtest"
+)
+
+func TestPublisher_QueuesStreamEntryOnce(t *testing.T) {
+	db := dbtest.Open(t)
+	webhook, err := database.NewWebhookRepository(db).Create("ci", []string{string(EventTypeVersionPushed)}, "http://localhost/hook", nil, true, nil, nil, "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	p := NewPublisher(db, nil, NewWebhookDispatcher(db, 1))
+	deliveryRepo := database.NewWebhookDeliveryRepository(db)
+
+	// A stream entry handled again after a failure
+	event := &Event{Type: EventTypeVersionPushed, Project: "p", App: "a", Version: "v1", StreamEntryID: "1700000000000-0"}
+	for i := 0; i < 2; i++ {
+		if err := p.enqueueWebhooks(event); err != nil {
+			t.Fatalf("enqueueWebhooks() error = %v", err)
+		}
+	}
+	if _, total, err := deliveryRepo.ListByWebhook(webhook.ID, "", 10, 0); err != nil || total != 1 {
+		t.Fatalf("Expected 1 delivery of the stream entry, got %d (err %v)", total, err)
+	}
+
+	// The next entry is a different event
+	event.StreamEntryID = "1700000000000-1"
+	if err := p.enqueueWebhooks(event); err != nil {
+		t.Fatalf("enqueueWebhooks() error = %v", err)
+	}
+	if _, total, err := deliveryRepo.ListByWebhook(webhook.ID, "", 10, 0); err != nil || total != 2 {
+		t.Fatalf("Expected 2 deliveries, got %d (err %v)", total, err)
+	}
+
+	// Events published without the stream are not keyed
+	event.StreamEntryID = ""
+	for i := 0; i < 2; i++ {
+		if err := p.enqueueWebhooks(event); err != nil {
+			t.Fatalf("enqueueWebhooks() error = %v", err)
+		}
+	}
+	if _, total, err := deliveryRepo.ListByWebhook(webhook.ID, "", 10, 0); err != nil || total != 4 {
+		t.Errorf("Expected 4 deliveries, got %d (err %v)", total, err)
+	}
+}
@@ -0,0 +1,381 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisEventField is the stream entry field holding the event JSON
+	redisEventField = "event"
+
+	redisReadCount = 100
+	redisReadBlock = 5 * time.Second
+
+	// redisRetryDelay is how long a reader waits after a Redis error
+	redisRetryDelay = 5 * time.Second
+
+	// redisMaintainInterval is how often stale entries are claimed and the
+	// replica's fan-out heartbeat is refreshed
+	redisMaintainInterval = time.Minute
+
+	// redisClaimMinIdle is how long an entry stays unacknowledged before
+	// another consumer takes it over
+	redisClaimMinIdle = time.Minute
+
+	// redisConsumerExpiry is how long a replica may be silent before its fan-out
+	// group and its (empty) consumer of the shared group are removed
+	redisConsumerExpiry = 10 * time.Minute
+)
+
+// RedisEventBus is an EventBus backed by a Redis stream
+// Every replica publishes to and reads from the same stream, so events are seen
+// across the deployment and are not lost while a replica restarts.
+//
+// Subscribe handlers share one consumer group: each event is handled by one
+// replica and acknowledged once all handlers succeeded, otherwise another
+// consumer claims it again after redisClaimMinIdle. SubscribeAll handlers see
+// every event on every replica; each replica reads through its own fan-out
+// group. Handlers are only called while Start is running.
+type RedisEventBus struct {
+	client       *redis.Client
+	stream       string
+	group        string
+	consumer     string
+	maxLen       int64
+	claimMinIdle time.Duration // how long an entry stays pending before claimStale takes it
+
+	mu       sync.RWMutex
+	handlers map[EventType][]EventHandler
+	fanout   *MemoryEventBus
+}
+
+// NewRedisEventBus creates a new Redis stream event bus
+// The stream is trimmed to about maxLen entries; 0 keeps every entry.
+func NewRedisEventBus(client *redis.Client, stream, group string, maxLen int) *RedisEventBus {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "kkartifact"
+	}
+	return &RedisEventBus{
+		client:       client,
+		stream:       stream,
+		group:        group,
+		consumer:     fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		maxLen:       int64(maxLen),
+		claimMinIdle: redisClaimMinIdle,
+		handlers:     make(map[EventType][]EventHandler),
+		fanout:       NewMemoryEventBus(),
+	}
+}
+
+// Publish adds an event to the stream
+func (b *RedisEventBus) Publish(event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	args := &redis.XAddArgs{
+		Stream: b.stream,
+		Values: map[string]interface{}{redisEventField: string(data)},
+	}
+	if b.maxLen > 0 {
+		args.MaxLen = b.maxLen
+		args.Approx = true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := b.client.XAdd(ctx, args).Err(); err != nil {
+		return fmt.Errorf("failed to add event to stream: %w", err)
+	}
+	return nil
+}
+
+// Subscribe subscribes to an event type through the shared consumer group
+// Each event is handled by one replica only; a handler error leaves the event
+// unacknowledged so it is handled again, by every handler. Handlers must
+// therefore be idempotent, e.g. keyed on the event's StreamEntryID.
+func (b *RedisEventBus) Subscribe(eventType EventType, handler EventHandler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+	return nil
+}
+
+// SubscribeAll subscribes to every event published on any replica
+func (b *RedisEventBus) SubscribeAll(handler EventHandler) func() {
+	return b.fanout.SubscribeAll(handler)
+}
+
+// Start reads the stream until ctx is done
+func (b *RedisEventBus) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, run := range []func(context.Context){b.consume, b.fanOut, b.maintain} {
+		wg.Add(1)
+		go func(run func(context.Context)) {
+			defer wg.Done()
+			run(ctx)
+		}(run)
+	}
+	wg.Wait()
+}
+
+// Close removes the replica's fan-out group
+func (b *RedisEventBus) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b.client.Del(ctx, b.heartbeatKey(b.consumer))
+	if err := b.client.XGroupDestroy(ctx, b.stream, b.fanoutGroup(b.consumer)).Err(); err != nil {
+		return fmt.Errorf("failed to remove fan-out group: %w", err)
+	}
+	return nil
+}
+
+// consume hands new entries of the shared group to the Subscribe handlers
+func (b *RedisEventBus) consume(ctx context.Context) {
+	b.read(ctx, b.group, false, b.handle)
+}
+
+// fanOut hands every entry to the SubscribeAll handlers of this replica
+func (b *RedisEventBus) fanOut(ctx context.Context) {
+	group := b.fanoutGroup(b.consumer)
+	b.read(ctx, group, true, func(ctx context.Context, msg redis.XMessage) {
+		event, err := decodeStreamEvent(msg)
+		if err != nil {
+			log.Printf("Skipping event stream entry %s: %v", msg.ID, err)
+			return
+		}
+		_ = b.fanout.Publish(event)
+	})
+}
+
+// read reads new entries through a consumer group until ctx is done
+// The group is created from the end of the stream if it does not exist (yet or
+// any more). Entries read with noAck are not tracked as pending.
+func (b *RedisEventBus) read(ctx context.Context, group string, noAck bool, handle func(context.Context, redis.XMessage)) {
+	ready := false
+	for ctx.Err() == nil {
+		if !ready {
+			if err := b.createGroup(ctx, group); err != nil {
+				log.Printf("Failed to create event consumer group %s: %v", group, err)
+				sleepContext(ctx, redisRetryDelay)
+				continue
+			}
+			ready = true
+		}
+
+		streams, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: b.consumer,
+			Streams:  []string{b.stream, ">"},
+			Count:    redisReadCount,
+			Block:    redisReadBlock,
+			NoAck:    noAck,
+		}).Result()
+		if err == redis.Nil {
+			continue // Nothing new
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to read event stream as %s: %v", group, err)
+			ready = false
+			sleepContext(ctx, redisRetryDelay)
+			continue
+		}
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				handle(ctx, msg)
+			}
+		}
+	}
+}
+
+// handle calls the Subscribe handlers for an entry of the shared group and acknowledges it
+func (b *RedisEventBus) handle(ctx context.Context, msg redis.XMessage) {
+	event, err := decodeStreamEvent(msg)
+	if err == nil {
+		err = b.dispatch(event)
+		if err != nil {
+			log.Printf("Event handler error for event %s (%s), retrying later: %v", msg.ID, event.Type, err)
+			return
+		}
+	} else {
+		// An entry that cannot be decoded never will be
+		log.Printf("Skipping event stream entry %s: %v", msg.ID, err)
+	}
+	if err := b.client.XAck(ctx, b.stream, b.group, msg.ID).Err(); err != nil {
+		log.Printf("Failed to acknowledge event %s: %v", msg.ID, err)
+	}
+}
+
+// dispatch calls every Subscribe handler for an event
+func (b *RedisEventBus) dispatch(event *Event) error {
+	b.mu.RLock()
+	handlers := make([]EventHandler, 0, len(b.handlers[event.Type])+len(b.handlers[EventTypeAll]))
+	handlers = append(handlers, b.handlers[event.Type]...)
+	handlers = append(handlers, b.handlers[EventTypeAll]...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// maintain periodically claims stale entries and cleans up after gone replicas
+func (b *RedisEventBus) maintain(ctx context.Context) {
+	ticker := time.NewTicker(redisMaintainInterval)
+	defer ticker.Stop()
+
+	for {
+		// The heartbeat shows other replicas that this replica's fan-out group is in use
+		if err := b.client.Set(ctx, b.heartbeatKey(b.consumer), time.Now().Unix(), redisConsumerExpiry).Err(); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to refresh event bus heartbeat: %v", err)
+		}
+		b.claimStale(ctx)
+		b.removeGoneReplicas(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimStale takes over entries another consumer did not acknowledge in time
+func (b *RedisEventBus) claimStale(ctx context.Context) {
+	start := "0-0"
+	for ctx.Err() == nil {
+		messages, next, err := b.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   b.stream,
+			Group:    b.group,
+			MinIdle:  b.claimMinIdle,
+			Start:    start,
+			Count:    redisReadCount,
+			Consumer: b.consumer,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil && !isNoGroupError(err) {
+				log.Printf("Failed to claim stale events: %v", err)
+			}
+			return
+		}
+		for _, msg := range messages {
+			b.handle(ctx, msg)
+		}
+		if next == "0-0" {
+			return
+		}
+		start = next
+	}
+}
+
+// removeGoneReplicas removes the fan-out groups and consumers of replicas
+// whose heartbeat has expired
+func (b *RedisEventBus) removeGoneReplicas(ctx context.Context) {
+	groups, err := b.client.XInfoGroups(ctx, b.stream).Result()
+	if err != nil {
+		return
+	}
+	prefix := b.fanoutGroup("")
+	for _, group := range groups {
+		if !strings.HasPrefix(group.Name, prefix) {
+			continue
+		}
+		consumer := strings.TrimPrefix(group.Name, prefix)
+		if consumer == b.consumer || b.alive(ctx, consumer) {
+			continue
+		}
+		if err := b.client.XGroupDestroy(ctx, b.stream, group.Name).Err(); err == nil {
+			log.Printf("Removed event fan-out group of gone replica %s", consumer)
+		}
+	}
+
+	consumers, err := b.client.XInfoConsumers(ctx, b.stream, b.group).Result()
+	if err != nil {
+		return
+	}
+	for _, consumer := range consumers {
+		// Consumers with pending entries are kept until claimStale has taken them over
+		if consumer.Name == b.consumer || consumer.Pending > 0 || b.alive(ctx, consumer.Name) {
+			continue
+		}
+		b.client.XGroupDelConsumer(ctx, b.stream, b.group, consumer.Name)
+	}
+}
+
+// alive reports whether a replica's heartbeat is current
+// Errors count as alive so nothing is removed while Redis is unreachable.
+func (b *RedisEventBus) alive(ctx context.Context, consumer string) bool {
+	count, err := b.client.Exists(ctx, b.heartbeatKey(consumer)).Result()
+	return err != nil || count > 0
+}
+
+// createGroup creates a consumer group that starts at the end of the stream
+// An existing group is kept as it is.
+func (b *RedisEventBus) createGroup(ctx context.Context, group string) error {
+	err := b.client.XGroupCreateMkStream(ctx, b.stream, group, "$").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// fanoutGroup returns the name of a replica's fan-out group
+func (b *RedisEventBus) fanoutGroup(consumer string) string {
+	return b.group + ":fanout:" + consumer
+}
+
+// heartbeatKey returns the key of a replica's heartbeat
+func (b *RedisEventBus) heartbeatKey(consumer string) string {
+	return b.stream + ":consumer:" + consumer
+}
+
+// decodeStreamEvent decodes the event of a stream entry
+func decodeStreamEvent(msg redis.XMessage) (*Event, error) {
+	data, ok := msg.Values[redisEventField].(string)
+	if !ok {
+		return nil, fmt.Errorf("entry has no %s field", redisEventField)
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return nil, fmt.Errorf("invalid event: %w", err)
+	}
+	event.StreamEntryID = msg.ID
+	return &event, nil
+}
+
+// isNoGroupError reports whether err says the stream or group does not exist
+func isNoGroupError(err error) bool {
+	return strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
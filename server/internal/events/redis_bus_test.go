@@ -0,0 +1,215 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedisBus returns a bus on a fresh stream of the Redis at TEST_REDIS_URL
+// The test is skipped if TEST_REDIS_URL is not set.
+func newTestRedisBus(t *testing.T, consumer string) *RedisEventBus {
+	t.Helper()
+	url := os.Getenv("TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("TEST_REDIS_URL is not set")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		t.Fatalf("Invalid TEST_REDIS_URL: %v", err)
+	}
+	client := redis.NewClient(opts)
+	t.Cleanup(func() { client.Close() })
+
+	stream := fmt.Sprintf("test:events:%s", t.Name())
+	t.Cleanup(func() {
+		ctx := context.Background()
+		client.Del(ctx, stream)
+		if keys, err := client.Keys(ctx, stream+":consumer:*").Result(); err == nil && len(keys) > 0 {
+			client.Del(ctx, keys...)
+		}
+	})
+
+	bus := NewRedisEventBus(client, stream, "test", 0)
+	bus.consumer = consumer
+	return bus
+}
+
+// startBus runs a bus until the test ends and waits for its groups to exist
+func startBus(t *testing.T, bus *RedisEventBus) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bus.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	waitFor(t, "consumer groups", func() bool {
+		groups, err := bus.client.XInfoGroups(context.Background(), bus.stream).Result()
+		if err != nil {
+			return false
+		}
+		found := 0
+		for _, group := range groups {
+			if group.Name == bus.group || group.Name == bus.fanoutGroup(bus.consumer) {
+				found++
+			}
+		}
+		return found == 2
+	})
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// pendingCount returns the number of unacknowledged entries of the shared group
+func pendingCount(t *testing.T, bus *RedisEventBus) int64 {
+	t.Helper()
+	pending, err := bus.client.XPending(context.Background(), bus.stream, bus.group).Result()
+	if err != nil {
+		t.Fatalf("Failed to read pending entries: %v", err)
+	}
+	return pending.Count
+}
+
+// eventRecorder records the events a handler was called with
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (r *eventRecorder) handle(event *Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *eventRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+func TestRedisEventBus_AcknowledgesHandledEvents(t *testing.T) {
+	bus := newTestRedisBus(t, "replica-1")
+	recorder := &eventRecorder{}
+	_ = bus.Subscribe(EventTypeVersionPushed, recorder.handle)
+	startBus(t, bus)
+
+	if err := bus.Publish(&Event{Type: EventTypeVersionPushed, Project: "p", App: "a", Version: "v1"}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	waitFor(t, "the handler", func() bool { return recorder.count() == 1 })
+	waitFor(t, "the acknowledgement", func() bool { return pendingCount(t, bus) == 0 })
+
+	event := recorder.events[0]
+	if event.Version != "v1" {
+		t.Errorf("Expected version v1, got %s", event.Version)
+	}
+	if event.StreamEntryID == "" {
+		t.Error("Expected the event to carry its stream entry ID")
+	}
+}
+
+func TestRedisEventBus_ReclaimsFailedEvents(t *testing.T) {
+	bus := newTestRedisBus(t, "replica-1")
+
+	var mu sync.Mutex
+	var entryIDs []string
+	handler := func(event *Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		entryIDs = append(entryIDs, event.StreamEntryID)
+		if len(entryIDs) == 1 {
+			return errors.New("receiver down")
+		}
+		return nil
+	}
+	calls := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(entryIDs)
+	}
+	_ = bus.Subscribe(EventTypeAll, handler)
+	startBus(t, bus)
+
+	if err := bus.Publish(&Event{Type: EventTypeVersionDeleted, Project: "p", App: "a", Version: "v1"}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	waitFor(t, "the first attempt", func() bool { return calls() == 1 })
+	if count := pendingCount(t, bus); count != 1 {
+		t.Fatalf("Expected the failed event to stay pending, got %d pending", count)
+	}
+
+	// Another replica takes over entries left pending
+	other := newTestRedisBus(t, "replica-2")
+	_ = other.Subscribe(EventTypeAll, handler)
+	other.claimMinIdle = 0
+	other.claimStale(context.Background())
+
+	if calls() != 2 {
+		t.Fatalf("Expected the event to be handled again, got %d calls", calls())
+	}
+	if entryIDs[0] != entryIDs[1] {
+		t.Errorf("Expected the same stream entry both times, got %s and %s", entryIDs[0], entryIDs[1])
+	}
+	if count := pendingCount(t, bus); count != 0 {
+		t.Errorf("Expected the reclaimed event to be acknowledged, got %d pending", count)
+	}
+}
+
+func TestRedisEventBus_FanOut(t *testing.T) {
+	first := newTestRedisBus(t, "replica-1")
+	second := newTestRedisBus(t, "replica-2")
+
+	var shared, firstAll, secondAll eventRecorder
+	_ = first.Subscribe(EventTypeAll, shared.handle)
+	_ = second.Subscribe(EventTypeAll, shared.handle)
+	first.SubscribeAll(firstAll.handle)
+	second.SubscribeAll(secondAll.handle)
+	startBus(t, first)
+	startBus(t, second)
+
+	for i := 0; i < 10; i++ {
+		if err := first.Publish(&Event{Type: EventTypeVersionPushed, Project: "p", App: "a", Version: fmt.Sprintf("v%d", i)}); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+
+	// Every replica sees every event, but each event is handled once
+	waitFor(t, "fan-out to the first replica", func() bool { return firstAll.count() == 10 })
+	waitFor(t, "fan-out to the second replica", func() bool { return secondAll.count() == 10 })
+	waitFor(t, "the shared handlers", func() bool { return shared.count() == 10 })
+	time.Sleep(100 * time.Millisecond)
+	if shared.count() != 10 {
+		t.Errorf("Expected each event to be handled once, got %d calls", shared.count())
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/util"
+)
+
+const (
+	// DefaultWebhookWorkers is the number of deliveries sent concurrently
+	DefaultWebhookWorkers = 4
+
+	// DefaultWebhookMaxAttempts is used when webhook_max_attempts is not configured
+	DefaultWebhookMaxAttempts = 8
+
+	// webhookLease is how long a worker holds a delivery; a delivery still held
+	// after that (the server stopped mid-attempt) is picked up again
+	webhookLease = time.Minute
+
+	// webhookPollInterval is how often idle workers look for due retries
+	webhookPollInterval = 5 * time.Second
+
+	webhookBackoffBase = 10 * time.Second
+	webhookBackoffMax  = time.Hour
+)
+
+// WebhookDispatcher delivers queued webhook events with a pool of workers
+// Deliveries are stored in the database before they are sent, so events are
+// not lost when a receiver is down or the server restarts. Failed attempts are
+// retried with exponential backoff until the delivery runs out of attempts.
+type WebhookDispatcher struct {
+	db        *database.DB
+	sender    *WebhookSender
+	workers   int
+	wake      chan struct{}
+	publisher *Publisher // set by NewPublisher
+}
+
+// NewWebhookDispatcher creates a new webhook dispatcher
+func NewWebhookDispatcher(db *database.DB, workers int) *WebhookDispatcher {
+	if workers < 1 {
+		workers = DefaultWebhookWorkers
+	}
+	return &WebhookDispatcher{
+		db:      db,
+		sender:  NewWebhookSender(),
+		workers: workers,
+		wake:    make(chan struct{}, workers),
+	}
+}
+
+// Enqueue queues an event for delivery to a webhook
+// An event read from the Redis stream is queued once per webhook however often
+// it is handled; nil is returned if it was already queued.
+func (d *WebhookDispatcher) Enqueue(webhook *database.Webhook, event *Event) (*database.WebhookDelivery, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	deliveryRepo := database.NewWebhookDeliveryRepository(d.db)
+	var delivery *database.WebhookDelivery
+	if event.StreamEntryID != "" {
+		delivery, err = deliveryRepo.CreateForStreamEntry(webhook.ID, event.StreamEntryID, string(event.Type), string(payload), d.maxAttempts())
+	} else {
+		delivery, err = deliveryRepo.Create(webhook.ID, string(event.Type), string(payload), d.maxAttempts(), nil)
+	}
+	if err != nil || delivery == nil {
+		return nil, err
+	}
+	d.notify()
+	return delivery, nil
+}
+
+// Redeliver queues a new delivery of the event of an earlier delivery
+func (d *WebhookDispatcher) Redeliver(delivery *database.WebhookDelivery) (*database.WebhookDelivery, error) {
+	deliveryRepo := database.NewWebhookDeliveryRepository(d.db)
+	redelivery, err := deliveryRepo.Create(delivery.WebhookID, delivery.EventType, delivery.Payload, d.maxAttempts(), &delivery.ID)
+	if err != nil {
+		return nil, err
+	}
+	d.notify()
+	return redelivery, nil
+}
+
+// Start runs the workers until ctx is done
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// work sends due deliveries until none are left, then waits for new ones
+func (d *WebhookDispatcher) work(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			delivered, err := d.deliverNext(ctx)
+			if err != nil {
+				log.Printf("Webhook delivery error: %v", err)
+				break
+			}
+			if !delivered {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// notify wakes an idle worker
+func (d *WebhookDispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverNext claims one due delivery and makes an attempt
+// Returns false if nothing was due.
+func (d *WebhookDispatcher) deliverNext(ctx context.Context) (bool, error) {
+	deliveryRepo := database.NewWebhookDeliveryRepository(d.db)
+	delivery, err := deliveryRepo.ClaimNext(webhookLease)
+	if err != nil || delivery == nil {
+		return false, err
+	}
+
+	webhook, err := database.NewWebhookRepository(d.db).GetByID(delivery.WebhookID)
+	if err != nil {
+		// Leave the lease to run out so the delivery is tried again
+		return true, err
+	}
+	if webhook == nil || !webhook.Enabled {
+		return true, deliveryRepo.Complete(delivery.ID, database.WebhookDeliveryFailed, 0, "webhook is disabled")
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(delivery.Payload), &event); err != nil {
+		return true, deliveryRepo.Complete(delivery.ID, database.WebhookDeliveryFailed, 0, fmt.Sprintf("invalid payload: %v", err))
+	}
+
+	request, err := NewWebhookRequest(webhook, strconv.FormatInt(delivery.ID, 10), &event)
+	if err != nil {
+		return true, deliveryRepo.Complete(delivery.ID, database.WebhookDeliveryFailed, 0, err.Error())
+	}
+	result, sendErr := d.sender.Deliver(ctx, request)
+	if ctx.Err() != nil {
+		// Shutting down; the delivery is picked up again after the lease
+		return true, nil
+	}
+
+	var errMsg string
+	if sendErr != nil {
+		errMsg = sendErr.Error()
+	}
+	var statusCode int
+	if result != nil {
+		statusCode = result.StatusCode
+		if err := deliveryRepo.RecordAttempt(delivery.ID, delivery.Attempts, webhook.URL, result.RequestHeaders, result.RequestBody, result.StatusCode, result.ResponseHeaders, result.ResponseBody, result.Duration, errMsg); err != nil {
+			log.Printf("Failed to record attempt of webhook delivery %d: %v", delivery.ID, err)
+		}
+	}
+
+	if sendErr == nil {
+		if util.IsDebugMode() {
+			log.Printf("Webhook %d delivered %s (delivery %d, attempt %d)", webhook.ID, event.Type, delivery.ID, delivery.Attempts)
+		}
+		return true, deliveryRepo.Complete(delivery.ID, database.WebhookDeliverySucceeded, statusCode, "")
+	}
+
+	if delivery.Attempts < delivery.MaxAttempts {
+		delay := WebhookBackoff(delivery.Attempts)
+		log.Printf("Webhook %d delivery %d attempt %d/%d failed, retrying in %s: %v", webhook.ID, delivery.ID, delivery.Attempts, delivery.MaxAttempts, delay.Round(time.Second), sendErr)
+		return true, deliveryRepo.Reschedule(delivery.ID, delay, statusCode, errMsg)
+	}
+
+	log.Printf("Webhook %d delivery %d failed after %d attempts: %v", webhook.ID, delivery.ID, delivery.Attempts, sendErr)
+	if err := deliveryRepo.Complete(delivery.ID, database.WebhookDeliveryFailed, statusCode, errMsg); err != nil {
+		return true, err
+	}
+	d.recordFailure(webhook, delivery, &event, sendErr)
+	return true, nil
+}
+
+// recordFailure publishes a delivery that ran out of attempts as webhook.failed
+// A failed webhook.failed delivery is only written to the audit log, so a
+// broken receiver subscribed to webhook.failed cannot trigger itself forever.
+func (d *WebhookDispatcher) recordFailure(webhook *database.Webhook, delivery *database.WebhookDelivery, event *Event, sendErr error) {
+	failed := &Event{
+		Type:    EventTypeWebhookFailed,
+		Project: event.Project,
+		App:     event.App,
+		Version: event.Version,
+		AgentID: event.AgentID,
+		Metadata: map[string]interface{}{
+			"webhook_id":  webhook.ID,
+			"webhook_url": webhook.URL,
+			"delivery_id": delivery.ID,
+			"attempts":    delivery.Attempts,
+			"event_type":  string(event.Type),
+			"error":       sendErr.Error(),
+		},
+		Timestamp: time.Now(),
+	}
+	if d.publisher != nil && event.Type != EventTypeWebhookFailed {
+		d.publisher.Publish(failed)
+		return
+	}
+
+	var projectID, appID *int
+	if webhook.ProjectID.Valid {
+		id := int(webhook.ProjectID.Int64)
+		projectID = &id
+	}
+	if webhook.AppID.Valid {
+		id := int(webhook.AppID.Int64)
+		appID = &id
+	}
+	auditRepo := database.NewAuditRepository(d.db)
+	_ = auditRepo.Create(string(failed.Type), projectID, appID, failed.Version, failed.AgentID, failed.Metadata)
+}
+
+// maxAttempts returns the configured number of delivery attempts
+func (d *WebhookDispatcher) maxAttempts() int {
+	value, err := database.NewConfigRepository(d.db).Get("webhook_max_attempts")
+	if err != nil {
+		return DefaultWebhookMaxAttempts
+	}
+	attempts, err := strconv.Atoi(value)
+	if err != nil || attempts < 1 {
+		return DefaultWebhookMaxAttempts
+	}
+	return attempts
+}
+
+// WebhookBackoff returns the delay before the retry that follows the given attempt
+// The delay doubles with every attempt up to webhookBackoffMax, and a random
+// jitter of up to half the delay spreads out retries to the same receiver.
+func WebhookBackoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := webhookBackoffMax
+	if attempt < 20 {
+		if d := webhookBackoffBase << uint(attempt-1); d > 0 && d < webhookBackoffMax {
+			delay = d
+		}
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// WebhookHeaders returns the custom headers configured for a webhook
+func WebhookHeaders(webhook *database.Webhook) map[string]string {
+	headers := make(map[string]string)
+	if webhook.Headers.Valid && webhook.Headers.String != "" {
+		if err := json.Unmarshal([]byte(webhook.Headers.String), &headers); err != nil {
+			log.Printf("Failed to parse webhook headers: %v", err)
+			return make(map[string]string)
+		}
+	}
+	return headers
+}
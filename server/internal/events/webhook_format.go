@@ -0,0 +1,406 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Webhook payload formats
+const (
+	WebhookFormatRaw      = "raw"      // the Event as JSON
+	WebhookFormatSlack    = "slack"    // Slack incoming webhook
+	WebhookFormatDingTalk = "dingtalk" // DingTalk robot (markdown)
+	WebhookFormatFeishu   = "feishu"   // Feishu/Lark bot (interactive card)
+	WebhookFormatWeCom    = "wecom"    // WeCom group robot (markdown)
+	WebhookFormatMSTeams  = "msteams"  // Microsoft Teams connector (MessageCard)
+	WebhookFormatTemplate = "template" // user-supplied Go text/template
+)
+
+// WebhookFormats lists the supported webhook payload formats
+var WebhookFormats = []string{
+	WebhookFormatRaw,
+	WebhookFormatSlack,
+	WebhookFormatDingTalk,
+	WebhookFormatFeishu,
+	WebhookFormatWeCom,
+	WebhookFormatMSTeams,
+	WebhookFormatTemplate,
+}
+
+// webhookTemplateFuncs are available to user templates
+var webhookTemplateFuncs = template.FuncMap{
+	// json renders a value as JSON, e.g. "text": {{json .Title}}
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"size": formatSize,
+}
+
+// WebhookMessage is the human-readable summary of an event used by chat formats
+// It is also the data passed to user templates, so {{.Project}}, {{.Title}},
+// {{.GitCommit}}, {{.FileCount}}, {{.Size}} and {{.Metadata}} can be used.
+type WebhookMessage struct {
+	*Event
+	Title     string
+	GitCommit string
+	FileCount int64
+	TotalSize int64
+	Size      string // TotalSize in human-readable form
+	Fields    []WebhookField
+}
+
+// WebhookField is one labelled line of a webhook message
+type WebhookField struct {
+	Name  string
+	Value string
+}
+
+// ValidateWebhookFormat checks a format and, for the template format, its template
+func ValidateWebhookFormat(format, tmpl string) error {
+	switch format {
+	case "", WebhookFormatRaw, WebhookFormatSlack, WebhookFormatDingTalk, WebhookFormatFeishu, WebhookFormatWeCom, WebhookFormatMSTeams:
+		return nil
+	case WebhookFormatTemplate:
+		if strings.TrimSpace(tmpl) == "" {
+			return fmt.Errorf("template is required for the template format")
+		}
+		// Render a sample event so errors such as unknown fields show up now
+		sample := &Event{
+			Type:      EventTypeVersionPushed,
+			Project:   "project",
+			App:       "app",
+			Version:   "v1.0.0",
+			Metadata:  map[string]interface{}{"git_commit": "0123456789abcdef", "file_count": 1, "total_size": 1024},
+			Timestamp: time.Now(),
+		}
+		if _, _, err := RenderWebhookBody(format, tmpl, sample); err != nil {
+			return err
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported webhook format %q (supported: %s)", format, strings.Join(WebhookFormats, ", "))
+	}
+}
+
+// RenderWebhookBody renders the request body of an event in a webhook format
+// Returns the body and its content type.
+func RenderWebhookBody(format, tmpl string, event *Event) ([]byte, string, error) {
+	if format == "" || format == WebhookFormatRaw {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to marshal event: %w", err)
+		}
+		return body, "application/json", nil
+	}
+
+	msg := NewWebhookMessage(event)
+	var payload interface{}
+	switch format {
+	case WebhookFormatSlack:
+		payload = slackPayload(msg)
+	case WebhookFormatDingTalk:
+		payload = dingTalkPayload(msg)
+	case WebhookFormatFeishu:
+		payload = feishuPayload(msg)
+	case WebhookFormatWeCom:
+		payload = weComPayload(msg)
+	case WebhookFormatMSTeams:
+		payload = msTeamsPayload(msg)
+	case WebhookFormatTemplate:
+		return renderWebhookTemplate(tmpl, msg)
+	default:
+		return nil, "", fmt.Errorf("unsupported webhook format %q", format)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal %s payload: %w", format, err)
+	}
+	return body, "application/json", nil
+}
+
+// NewWebhookMessage summarizes an event for people
+func NewWebhookMessage(event *Event) *WebhookMessage {
+	msg := &WebhookMessage{Event: event}
+	msg.GitCommit, _ = event.Metadata["git_commit"].(string)
+	msg.FileCount, _ = metadataInt(event.Metadata, "file_count")
+	msg.TotalSize, _ = metadataInt(event.Metadata, "total_size")
+	msg.Size = formatSize(msg.TotalSize)
+
+	target := event.Project
+	if event.App != "" {
+		target += "/" + event.App
+	}
+	if event.Version != "" {
+		target += "@" + event.Version
+	}
+	msg.Title = "[kkArtifact] " + eventTitle(event.Type)
+	if target != "" {
+		msg.Title += ": " + target
+	}
+
+	if event.Project != "" {
+		msg.Fields = append(msg.Fields, WebhookField{"Project", event.Project})
+	}
+	if event.App != "" {
+		msg.Fields = append(msg.Fields, WebhookField{"App", event.App})
+	}
+	if event.Version != "" {
+		msg.Fields = append(msg.Fields, WebhookField{"Version", event.Version})
+	}
+	if msg.GitCommit != "" {
+		msg.Fields = append(msg.Fields, WebhookField{"Git Commit", msg.GitCommit})
+	}
+	if _, ok := event.Metadata["file_count"]; ok {
+		msg.Fields = append(msg.Fields, WebhookField{"Files", fmt.Sprintf("%d", msg.FileCount)})
+	}
+	if _, ok := event.Metadata["total_size"]; ok {
+		msg.Fields = append(msg.Fields, WebhookField{"Size", msg.Size})
+	}
+	msg.Fields = append(msg.Fields, eventFields(event)...)
+	if event.AgentID != "" {
+		msg.Fields = append(msg.Fields, WebhookField{"By", event.AgentID})
+	}
+	msg.Fields = append(msg.Fields, WebhookField{"Time", event.Timestamp.Format("2006-01-02 15:04:05 MST")})
+	return msg
+}
+
+// eventTitle describes an event type
+func eventTitle(eventType EventType) string {
+	switch eventType {
+	case EventTypeVersionPushed:
+		return "New version pushed"
+	case EventTypeVersionPublished:
+		return "Version published"
+	case EventTypeVersionUnpublished:
+		return "Version unpublished"
+	case EventTypeVersionDeleted:
+		return "Version deleted"
+	case EventTypeVersionPinned:
+		return "Version pinned"
+	case EventTypeVersionUnpinned:
+		return "Version unpinned"
+	case EventTypeVersionLabeled:
+		return "Version labels changed"
+	case EventTypeChannelUpdated:
+		return "Channel updated"
+	case EventTypeChannelDeleted:
+		return "Channel deleted"
+	case EventTypeChannelRolledBack:
+		return "Channel rolled back"
+	case EventTypeVersionPromoted:
+		return "Version promoted"
+	case EventTypePromotionRequested:
+		return "Promotion awaiting approval"
+	case EventTypePromotionApproved:
+		return "Promotion approved"
+	case EventTypePromotionRejected:
+		return "Promotion rejected"
+	case EventTypeAppDeleted:
+		return "App deleted"
+	case EventTypeProjectDeleted:
+		return "Project deleted"
+	case EventTypeRetentionPruned:
+		return "Old versions pruned"
+	case EventTypeTokenCreated:
+		return "Token created"
+	case EventTypeTokenRevoked:
+		return "Token revoked"
+	case EventTypeConfigUpdated:
+		return "Configuration updated"
+	case EventTypeWebhookFailed:
+		return "Webhook delivery failed"
+	default:
+		return string(eventType)
+	}
+}
+
+// eventFields lists the metadata shown for events that are not about a pushed version
+func eventFields(event *Event) []WebhookField {
+	var fields []WebhookField
+	add := func(name, key string) {
+		if value, ok := event.Metadata[key]; ok {
+			fields = append(fields, WebhookField{name, fmt.Sprint(value)})
+		}
+	}
+	switch event.Type {
+	case EventTypeVersionLabeled:
+		add("Labels", "labels")
+		add("Removed", "removed")
+	case EventTypeChannelUpdated, EventTypeChannelDeleted, EventTypeChannelRolledBack:
+		add("Channel", "channel")
+		add("Previous", "previous_version")
+		add("Steps", "steps")
+	case EventTypeVersionPromoted, EventTypePromotionRequested, EventTypePromotionApproved, EventTypePromotionRejected:
+		add("Channel", "channel")
+		add("From", "from_channel")
+		add("Previous", "previous_version")
+		add("Approvals", "approvals")
+		add("Unmet", "unmet")
+		add("Reason", "reason")
+	case EventTypeRetentionPruned:
+		add("Pruned", "count")
+		add("Versions", "versions")
+	case EventTypeTokenCreated, EventTypeTokenRevoked:
+		add("Token", "token_name")
+		add("Permissions", "permissions")
+	case EventTypeConfigUpdated:
+		if changes, ok := event.Metadata["changes"].(map[string]interface{}); ok {
+			keys := make([]string, 0, len(changes))
+			for key := range changes {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fields = append(fields, WebhookField{key, fmt.Sprint(changes[key])})
+			}
+		}
+	case EventTypeWebhookFailed:
+		add("Webhook", "webhook_url")
+		add("Event", "event_type")
+		add("Attempts", "attempts")
+		add("Error", "error")
+	}
+	return fields
+}
+
+// markdownLines renders the fields as "**Name**: value" lines
+func (m *WebhookMessage) markdownLines(prefix string) string {
+	lines := make([]string, len(m.Fields))
+	for i, field := range m.Fields {
+		lines[i] = fmt.Sprintf("%s**%s**: %s", prefix, field.Name, field.Value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func slackPayload(m *WebhookMessage) interface{} {
+	fields := make([]map[string]interface{}, len(m.Fields))
+	for i, field := range m.Fields {
+		fields[i] = map[string]interface{}{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", field.Name, field.Value)}
+	}
+	return map[string]interface{}{
+		"text": m.Title,
+		"blocks": []interface{}{
+			map[string]interface{}{
+				"type": "section",
+				"text": map[string]interface{}{"type": "mrkdwn", "text": "*" + m.Title + "*"},
+			},
+			map[string]interface{}{
+				"type":   "section",
+				"fields": fields,
+			},
+		},
+	}
+}
+
+func dingTalkPayload(m *WebhookMessage) interface{} {
+	return map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]interface{}{
+			"title": m.Title,
+			"text":  "### " + m.Title + "\n\n" + m.markdownLines("- "),
+		},
+	}
+}
+
+func feishuPayload(m *WebhookMessage) interface{} {
+	return map[string]interface{}{
+		"msg_type": "interactive",
+		"card": map[string]interface{}{
+			"header": map[string]interface{}{
+				"title":    map[string]interface{}{"tag": "plain_text", "content": m.Title},
+				"template": "blue",
+			},
+			"elements": []interface{}{
+				map[string]interface{}{
+					"tag":  "div",
+					"text": map[string]interface{}{"tag": "lark_md", "content": m.markdownLines("")},
+				},
+			},
+		},
+	}
+}
+
+func weComPayload(m *WebhookMessage) interface{} {
+	return map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]interface{}{
+			"content": "### " + m.Title + "\n" + m.markdownLines("> "),
+		},
+	}
+}
+
+func msTeamsPayload(m *WebhookMessage) interface{} {
+	facts := make([]map[string]interface{}, len(m.Fields))
+	for i, field := range m.Fields {
+		facts[i] = map[string]interface{}{"name": field.Name, "value": field.Value}
+	}
+	return map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    m.Title,
+		"themeColor": "0076D7",
+		"title":      m.Title,
+		"sections":   []interface{}{map[string]interface{}{"facts": facts}},
+	}
+}
+
+// renderWebhookTemplate executes a user template
+// The body is sent as JSON if it is valid JSON, otherwise as plain text.
+func renderWebhookTemplate(tmpl string, m *WebhookMessage) ([]byte, string, error) {
+	t, err := template.New("webhook").Funcs(webhookTemplateFuncs).Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid webhook template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, m); err != nil {
+		return nil, "", fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	if json.Valid(buf.Bytes()) {
+		return buf.Bytes(), "application/json", nil
+	}
+	return buf.Bytes(), "text/plain; charset=utf-8", nil
+}
+
+// metadataInt reads a number from event metadata
+// Metadata read back from JSON holds float64 rather than int values.
+func metadataInt(metadata map[string]interface{}, key string) (int64, bool) {
+	switch v := metadata[key].(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// formatSize formats a byte count for people
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
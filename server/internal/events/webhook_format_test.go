@@ -0,0 +1,257 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package events
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPushEvent() *Event {
+	return &Event{
+		Type:    EventTypeVersionPushed,
+		Project: "shop",
+		App:     "api",
+		Version: "v1.2.3",
+		AgentID: "ci-runner",
+		// Metadata read back from the event bus holds float64 numbers
+		Metadata:  map[string]interface{}{"git_commit": "abc123", "file_count": float64(3), "total_size": float64(2048)},
+		Timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// jsonPath walks decoded JSON by object keys and array indexes
+func jsonPath(t *testing.T, value interface{}, path ...interface{}) interface{} {
+	t.Helper()
+	for _, step := range path {
+		switch key := step.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				t.Fatalf("Expected an object at %q, got %T", key, value)
+			}
+			value = object[key]
+		case int:
+			array, ok := value.([]interface{})
+			if !ok || key >= len(array) {
+				t.Fatalf("Expected an array with index %d, got %v", key, value)
+			}
+			value = array[key]
+		}
+	}
+	return value
+}
+
+func TestRenderWebhookBody_Formats(t *testing.T) {
+	const title = "[kkArtifact] New version pushed: shop/api@v1.2.3"
+
+	tests := []struct {
+		format string
+		path   []interface{}
+		want   string // substring of the string at path
+	}{
+		{WebhookFormatRaw, []interface{}{"type"}, string(EventTypeVersionPushed)},
+		{"", []interface{}{"version"}, "v1.2.3"},
+		{WebhookFormatSlack, []interface{}{"text"}, title},
+		{WebhookFormatSlack, []interface{}{"blocks", 1, "fields", 0, "text"}, "*Project*\nshop"},
+		{WebhookFormatDingTalk, []interface{}{"msgtype"}, "markdown"},
+		{WebhookFormatDingTalk, []interface{}{"markdown", "title"}, title},
+		{WebhookFormatDingTalk, []interface{}{"markdown", "text"}, "- **Size**: 2.0 KiB"},
+		{WebhookFormatFeishu, []interface{}{"msg_type"}, "interactive"},
+		{WebhookFormatFeishu, []interface{}{"card", "header", "title", "content"}, title},
+		{WebhookFormatFeishu, []interface{}{"card", "elements", 0, "text", "content"}, "**Git Commit**: abc123"},
+		{WebhookFormatWeCom, []interface{}{"msgtype"}, "markdown"},
+		{WebhookFormatWeCom, []interface{}{"markdown", "content"}, "> **Files**: 3"},
+		{WebhookFormatMSTeams, []interface{}{"@type"}, "MessageCard"},
+		{WebhookFormatMSTeams, []interface{}{"title"}, title},
+		{WebhookFormatMSTeams, []interface{}{"sections", 0, "facts", 0, "value"}, "shop"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			body, contentType, err := RenderWebhookBody(tt.format, "", testPushEvent())
+			if err != nil {
+				t.Fatalf("RenderWebhookBody() error: %v", err)
+			}
+			if contentType != "application/json" {
+				t.Errorf("Expected content type application/json, got %s", contentType)
+			}
+			var decoded interface{}
+			if err := json.Unmarshal(body, &decoded); err != nil {
+				t.Fatalf("Body is not JSON: %v\n%s", err, body)
+			}
+			got, _ := jsonPath(t, decoded, tt.path...).(string)
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("Expected %v to contain %q, got %q", tt.path, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderWebhookBody_Template(t *testing.T) {
+	tests := []struct {
+		name        string
+		tmpl        string
+		body        string
+		contentType string
+		wantErr     string
+	}{
+		{
+			name:        "json body",
+			tmpl:        `{"text": {{json .Title}}, "size": {{json .Size}}, "commit": "{{.GitCommit}}"}`,
+			body:        `{"text": "[kkArtifact] New version pushed: shop/api@v1.2.3", "size": "2.0 KiB", "commit": "abc123"}`,
+			contentType: "application/json",
+		},
+		{
+			name:        "plain text body",
+			tmpl:        `{{.Project}}/{{.App}} {{.Version}} ({{.FileCount}} files)`,
+			body:        "shop/api v1.2.3 (3 files)",
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:        "missing metadata key",
+			tmpl:        `[{{index .Metadata "branch"}}]`,
+			body:        "[<no value>]",
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:    "parse error",
+			tmpl:    `{{.Title`,
+			wantErr: "invalid webhook template",
+		},
+		{
+			name:    "unknown field",
+			tmpl:    `{{.NoSuchField}}`,
+			wantErr: "failed to render webhook template",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType, err := RenderWebhookBody(WebhookFormatTemplate, tt.tmpl, testPushEvent())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderWebhookBody() error: %v", err)
+			}
+			if string(body) != tt.body {
+				t.Errorf("Expected body %q, got %q", tt.body, body)
+			}
+			if contentType != tt.contentType {
+				t.Errorf("Expected content type %s, got %s", tt.contentType, contentType)
+			}
+		})
+	}
+}
+
+func TestRenderWebhookBody_UnsupportedFormat(t *testing.T) {
+	if _, _, err := RenderWebhookBody("carrier-pigeon", "", testPushEvent()); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
+
+func TestValidateWebhookFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		tmpl    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{WebhookFormatSlack, "", false},
+		{WebhookFormatMSTeams, "", false},
+		{WebhookFormatTemplate, `{{.Title}}`, false},
+		{WebhookFormatTemplate, "  ", true},
+		{WebhookFormatTemplate, `{{.Title`, true},
+		{WebhookFormatTemplate, `{{.NoSuchField}}`, true},
+		{"carrier-pigeon", "", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateWebhookFormat(tt.format, tt.tmpl)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateWebhookFormat(%q, %q) error = %v, wantErr %v", tt.format, tt.tmpl, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewWebhookMessage_EventFields(t *testing.T) {
+	event := &Event{
+		Type:      EventTypeTokenCreated,
+		Metadata:  map[string]interface{}{"token_name": "ci", "permissions": []string{"pull", "push"}},
+		Timestamp: time.Now(),
+	}
+
+	msg := NewWebhookMessage(event)
+	if msg.Title != "[kkArtifact] Token created" {
+		t.Errorf("Expected title without a target, got %q", msg.Title)
+	}
+	fields := make(map[string]string)
+	for _, field := range msg.Fields {
+		fields[field.Name] = field.Value
+	}
+	if fields["Token"] != "ci" || fields["Permissions"] != "[pull push]" {
+		t.Errorf("Expected token fields, got %v", fields)
+	}
+	for _, name := range []string{"Project", "App", "Version", "Files"} {
+		if _, ok := fields[name]; ok {
+			t.Errorf("Expected no %s field, got %v", name, fields)
+		}
+	}
+}
+
+func TestNewWebhookMessage_ChannelFields(t *testing.T) {
+	event := &Event{
+		Type:      EventTypeChannelRolledBack,
+		Project:   "shop",
+		App:       "api",
+		Version:   "v1",
+		Metadata:  map[string]interface{}{"channel": "prod", "previous_version": "v2", "steps": 1},
+		Timestamp: time.Now(),
+	}
+
+	fields := make(map[string]string)
+	for _, field := range NewWebhookMessage(event).Fields {
+		fields[field.Name] = field.Value
+	}
+	if fields["Channel"] != "prod" || fields["Previous"] != "v2" || fields["Steps"] != "1" {
+		t.Errorf("Expected channel fields, got %v", fields)
+	}
+	if _, ok := fields["Files"]; ok {
+		t.Errorf("Expected no Files field without file_count, got %v", fields)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		delay   time.Duration // before jitter
+	}{
+		{0, webhookBackoffBase},
+		{1, webhookBackoffBase},
+		{2, 2 * webhookBackoffBase},
+		{3, 4 * webhookBackoffBase},
+		{9, 256 * webhookBackoffBase},
+		{10, webhookBackoffMax},
+		{19, webhookBackoffMax},
+		{100, webhookBackoffMax},
+	}
+
+	for _, tt := range tests {
+		// The jitter is random, so sample it
+		for i := 0; i < 50; i++ {
+			got := WebhookBackoff(tt.attempt)
+			if got < tt.delay/2 || got > tt.delay {
+				t.Fatalf("WebhookBackoff(%d) = %v, expected between %v and %v", tt.attempt, got, tt.delay/2, tt.delay)
+			}
+		}
+	}
+}
@@ -7,12 +7,21 @@ package events
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/pkg/webhook"
 )
 
+// maxRecordedResponseBody limits how much of a webhook response body is kept
+const maxRecordedResponseBody = 16 * 1024
+
 // WebhookSender sends webhooks
 type WebhookSender struct {
 	client *http.Client
@@ -27,33 +36,109 @@ func NewWebhookSender() *WebhookSender {
 	}
 }
 
+// WebhookResult describes a webhook request and the response it got
+type WebhookResult struct {
+	RequestHeaders  map[string]string
+	RequestBody     string
+	StatusCode      int // 0 if no response was received
+	ResponseHeaders map[string]string
+	ResponseBody    string // truncated to maxRecordedResponseBody
+	Duration        time.Duration
+}
+
+// WebhookRequest is a webhook ready to be sent
+type WebhookRequest struct {
+	URL     string
+	Headers map[string]string
+	Body    []byte
+}
+
+// NewWebhookRequest builds the request of a webhook for an event
+// The body is rendered in the webhook's format. Custom headers are added first
+// so they cannot replace the X-KKArtifact headers. With a secret, the rendered
+// body is signed together with the current time.
+func NewWebhookRequest(hook *database.Webhook, deliveryID string, event *Event) (*WebhookRequest, error) {
+	return buildWebhookRequest(hook.URL, WebhookHeaders(hook), hook.Secret.String, deliveryID, hook.Format, hook.Template.String, event)
+}
+
+func buildWebhookRequest(url string, headers map[string]string, secret, deliveryID, format, tmpl string, event *Event) (*WebhookRequest, error) {
+	body, contentType, err := RenderWebhookBody(format, tmpl, event)
+	if err != nil {
+		return nil, err
+	}
+
+	requestHeaders := make(map[string]string, len(headers)+5)
+	requestHeaders["Content-Type"] = contentType
+	for k, v := range headers {
+		requestHeaders[k] = v
+	}
+	requestHeaders[webhook.HeaderEvent] = string(event.Type)
+	if deliveryID != "" {
+		requestHeaders[webhook.HeaderDelivery] = deliveryID
+	}
+	if secret != "" {
+		timestamp := time.Now().Unix()
+		requestHeaders[webhook.HeaderTimestamp] = strconv.FormatInt(timestamp, 10)
+		requestHeaders[webhook.HeaderSignature] = webhook.Sign(secret, timestamp, body)
+	}
+
+	return &WebhookRequest{URL: url, Headers: requestHeaders, Body: body}, nil
+}
+
 // Send sends a webhook to the given URL
 func (ws *WebhookSender) Send(url string, headers map[string]string, event *Event) error {
-	body, err := json.Marshal(event)
+	request, err := buildWebhookRequest(url, headers, "", "", WebhookFormatRaw, "", event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return err
 	}
+	_, err = ws.Deliver(context.Background(), request)
+	return err
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+// Deliver sends a webhook request and reports the request and response
+// The error is set if the request failed or the status was 400 or higher; the
+// result is filled in as far as the request got.
+func (ws *WebhookSender) Deliver(ctx context.Context, request *WebhookRequest) (*WebhookResult, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", request.URL, bytes.NewReader(request.Body))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range headers {
+	for k, v := range request.Headers {
 		req.Header.Set(k, v)
 	}
 
+	result := &WebhookResult{
+		RequestHeaders: flattenHeader(req.Header),
+		RequestBody:    string(request.Body),
+	}
+
+	start := time.Now()
 	resp, err := ws.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send webhook: %w", err)
+		result.Duration = time.Since(start)
+		return result, fmt.Errorf("failed to send webhook: %w", err)
 	}
 	defer resp.Body.Close()
 
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxRecordedResponseBody))
+	result.Duration = time.Since(start)
+	result.StatusCode = resp.StatusCode
+	result.ResponseHeaders = flattenHeader(resp.Header)
+	// Stored as text, so drop bytes Postgres cannot keep
+	result.ResponseBody = strings.ToValidUTF8(strings.ReplaceAll(string(responseBody), "\x00", ""), "")
+
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		return result, fmt.Errorf("webhook returned status %d", resp.StatusCode)
 	}
 
-	return nil
+	return result, nil
 }
 
+// flattenHeader joins multi-valued headers into a plain map
+func flattenHeader(header http.Header) map[string]string {
+	flat := make(map[string]string, len(header))
+	for key, values := range header {
+		flat[key] = strings.Join(values, ", ")
+	}
+	return flat
+}
@@ -48,6 +48,11 @@ func (w *gzipWriter) Write(data []byte) (int, error) {
 }
 
 func shouldCompress(c *gin.Context) bool {
+	// Event streams must reach the client as soon as they are flushed
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		return false
+	}
+
 	contentType := c.Writer.Header().Get("Content-Type")
 	
 	// Don't compress already compressed content
@@ -33,30 +33,51 @@ func (t *AuditCleanupTask) Name() string {
 }
 
 // Run runs the audit log cleanup task
-func (t *AuditCleanupTask) Run(ctx context.Context) error {
+func (t *AuditCleanupTask) Run(ctx context.Context) (TaskCounts, error) {
 	// Get retention days from config
 	configRepo := database.NewConfigRepository(t.db)
 	retentionDaysStr, err := configRepo.Get("audit_log_retention_days")
 	if err != nil {
-		return fmt.Errorf("failed to get audit log retention days: %w", err)
+		return nil, fmt.Errorf("failed to get audit log retention days: %w", err)
 	}
 
 	retentionDays, err := strconv.Atoi(retentionDaysStr)
 	if err != nil {
-		return fmt.Errorf("invalid audit log retention days: %w", err)
+		return nil, fmt.Errorf("invalid audit log retention days: %w", err)
 	}
 
 	// Delete old audit logs
 	auditRepo := database.NewAuditRepository(t.db)
 	deletedCount, err := auditRepo.DeleteOldLogs(retentionDays)
 	if err != nil {
-		return fmt.Errorf("failed to delete old audit logs: %w", err)
+		return nil, fmt.Errorf("failed to delete old audit logs: %w", err)
 	}
 
 	if deletedCount > 0 && util.IsDebugMode() {
 		log.Printf("Deleted %d audit log entries older than %d days", deletedCount, retentionDays)
 	}
 
-	return nil
+	// The webhook delivery log is kept as long as the audit log
+	deliveryRepo := database.NewWebhookDeliveryRepository(t.db)
+	deletedDeliveries, err := deliveryRepo.DeleteFinished(retentionDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete old webhook deliveries: %w", err)
+	}
+
+	if deletedDeliveries > 0 && util.IsDebugMode() {
+		log.Printf("Deleted %d webhook deliveries older than %d days", deletedDeliveries, retentionDays)
+	}
+
+	// So is the task run history
+	deletedRuns, err := database.NewTaskRunRepository(t.db).DeleteOld(retentionDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete old task runs: %w", err)
+	}
+
+	return TaskCounts{
+		"audit_logs_deleted":         int(deletedCount),
+		"webhook_deliveries_deleted": int(deletedDeliveries),
+		"task_runs_deleted":          int(deletedRuns),
+	}, nil
 }
 
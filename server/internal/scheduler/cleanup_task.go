@@ -9,9 +9,9 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strconv"
 
 	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/events"
 	"github.com/kk/kkartifact-server/internal/storage"
 )
 
@@ -20,15 +20,18 @@ type CleanupTask struct {
 	db              *database.DB
 	artifactManager *storage.ArtifactManager
 	cleanupManager  *storage.CleanupManager
+	publisher       *events.Publisher
 }
 
 // NewCleanupTask creates a new cleanup task
-func NewCleanupTask(db *database.DB, artifactManager *storage.ArtifactManager) *CleanupTask {
+// Pruned versions are published as retention.pruned events when publisher is not nil.
+func NewCleanupTask(db *database.DB, artifactManager *storage.ArtifactManager, publisher *events.Publisher) *CleanupTask {
 	cleanupManager := storage.NewCleanupManager(artifactManager, db)
 	return &CleanupTask{
 		db:              db,
 		artifactManager: artifactManager,
 		cleanupManager:  cleanupManager,
+		publisher:       publisher,
 	}
 }
 
@@ -38,19 +41,69 @@ func (t *CleanupTask) Name() string {
 }
 
 // Run runs the cleanup task
-func (t *CleanupTask) Run(ctx context.Context) error {
-	// Get retention limit from config
-	configRepo := database.NewConfigRepository(t.db)
-	retentionLimitStr, err := configRepo.Get("version_retention_limit")
+func (t *CleanupTask) Run(ctx context.Context) (TaskCounts, error) {
+	counts := TaskCounts{"apps": 0, "versions_deleted": 0, "apps_failed": 0}
+
+	err := t.forEachApp(counts, func(project *database.Project, app *database.App, policy *storage.RetentionPolicy) error {
+		pruned, err := t.cleanupManager.CleanupOldVersions(ctx, project.Name, app.Name, policy)
+		counts["versions_deleted"] += len(pruned)
+		if len(pruned) > 0 && t.publisher != nil {
+			t.publisher.Publish(&events.Event{
+				Type:    events.EventTypeRetentionPruned,
+				Project: project.Name,
+				App:     app.Name,
+				AgentID: "scheduler",
+				Metadata: map[string]interface{}{
+					"versions":        pruned,
+					"count":           len(pruned),
+					"retention_limit": policy.KeepLast,
+					"policy":          policy,
+				},
+			})
+		}
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get retention limit: %w", err)
+		return nil, err
 	}
 
-	retentionLimit, err := strconv.Atoi(retentionLimitStr)
+	// Remove blobs no version references any more
+	if err := t.cleanupManager.CleanupUnreferencedBlobs(ctx); err != nil {
+		log.Printf("Failed to cleanup unreferenced blobs: %v", err)
+	}
+
+	return counts, nil
+}
+
+// DryRun reports the versions the cleanup would delete without deleting them
+// The details list, per app, each version with the rules that select it and
+// the bytes it would free.
+func (t *CleanupTask) DryRun(ctx context.Context) (TaskCounts, interface{}, error) {
+	counts := TaskCounts{"apps": 0, "versions_to_delete": 0, "bytes_to_free": 0, "apps_failed": 0}
+	previews := []*storage.RetentionPreview{}
+
+	err := t.forEachApp(counts, func(project *database.Project, app *database.App, policy *storage.RetentionPolicy) error {
+		preview, err := t.cleanupManager.PreviewRetention(project.Name, app.Name, app.ID, policy)
+		if err != nil {
+			return err
+		}
+		counts["versions_to_delete"] += len(preview.Versions)
+		counts["bytes_to_free"] += int(preview.Bytes)
+		if len(preview.Versions) > 0 {
+			previews = append(previews, preview)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("invalid retention limit: %w", err)
+		return nil, nil, err
 	}
 
+	return counts, map[string]interface{}{"apps": previews}, nil
+}
+
+// forEachApp calls fn with every app and its effective retention policy
+// Failures are logged and counted in counts["apps_failed"].
+func (t *CleanupTask) forEachApp(counts TaskCounts, fn func(project *database.Project, app *database.App, policy *storage.RetentionPolicy) error) error {
 	// Get all projects
 	projectRepo := database.NewProjectRepository(t.db)
 	projects, err := projectRepo.List(1000, 0) // Get up to 1000 projects
@@ -59,8 +112,6 @@ func (t *CleanupTask) Run(ctx context.Context) error {
 	}
 
 	appRepo := database.NewAppRepository(t.db)
-
-	// Iterate through all apps and cleanup
 	for _, project := range projects {
 		apps, err := appRepo.ListByProject(project.ID, 1000, 0)
 		if err != nil {
@@ -68,13 +119,21 @@ func (t *CleanupTask) Run(ctx context.Context) error {
 		}
 
 		for _, app := range apps {
-			if err := t.cleanupManager.CleanupOldVersions(ctx, project.Name, app.Name, retentionLimit); err != nil {
+			counts["apps"]++
+			// Resolve the global, project and app rules for this app
+			policy, err := storage.LoadRetentionPolicy(t.db, project.ID, &app.ID)
+			if err != nil {
+				log.Printf("Failed to load retention policy for %s/%s: %v", project.Name, app.Name, err)
+				counts["apps_failed"]++
+				continue
+			}
+
+			if err := fn(project, app, policy); err != nil {
 				// Log error but continue
 				log.Printf("Failed to cleanup versions for %s/%s: %v", project.Name, app.Name, err)
+				counts["apps_failed"]++
 			}
 		}
 	}
-
 	return nil
 }
-
@@ -0,0 +1,232 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// at returns a UTC time on the minute
+func at(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func mustParseSchedule(t *testing.T, expr string) *Schedule {
+	t.Helper()
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		t.Fatalf("ParseSchedule(%q) error: %v", expr, err)
+	}
+	return schedule
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"* * * foo *",
+		"* * * * mon-funday",
+		"@fortnightly",
+	}
+
+	for _, expr := range tests {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("ParseSchedule(%q) expected an error", expr)
+		}
+	}
+}
+
+func TestParseSchedule_String(t *testing.T) {
+	schedule := mustParseSchedule(t, "  @daily ")
+	if schedule.String() != "@daily" {
+		t.Errorf("Expected the trimmed expression, got %q", schedule.String())
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// 2025-01-01 is a Wednesday
+	start := at(2025, 1, 1, 10, 7)
+
+	tests := []struct {
+		name     string
+		expr     string
+		from     time.Time
+		expected []time.Time // consecutive runs
+	}{
+		{
+			name:     "every minute skips the current minute",
+			expr:     "* * * * *",
+			from:     start.Add(30 * time.Second),
+			expected: []time.Time{at(2025, 1, 1, 10, 8), at(2025, 1, 1, 10, 9)},
+		},
+		{
+			name:     "daily descriptor",
+			expr:     "@daily",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 2, 0, 0), at(2025, 1, 3, 0, 0)},
+		},
+		{
+			name:     "descriptors are case insensitive",
+			expr:     "@HOURLY",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 1, 11, 0), at(2025, 1, 1, 12, 0)},
+		},
+		{
+			name:     "weekly descriptor runs on Sunday",
+			expr:     "@weekly",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 5, 0, 0), at(2025, 1, 12, 0, 0)},
+		},
+		{
+			name:     "monthly descriptor",
+			expr:     "@monthly",
+			from:     start,
+			expected: []time.Time{at(2025, 2, 1, 0, 0), at(2025, 3, 1, 0, 0)},
+		},
+		{
+			name:     "yearly descriptor",
+			expr:     "@yearly",
+			from:     start,
+			expected: []time.Time{at(2026, 1, 1, 0, 0), at(2027, 1, 1, 0, 0)},
+		},
+		{
+			name:     "list",
+			expr:     "0,30 9 * * *",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 2, 9, 0), at(2025, 1, 2, 9, 30), at(2025, 1, 3, 9, 0)},
+		},
+		{
+			name:     "range",
+			expr:     "0 22-23 * * *",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 1, 22, 0), at(2025, 1, 1, 23, 0), at(2025, 1, 2, 22, 0)},
+		},
+		{
+			name:     "step",
+			expr:     "*/20 * * * *",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 1, 10, 20), at(2025, 1, 1, 10, 40), at(2025, 1, 1, 11, 0)},
+		},
+		{
+			name:     "range with step",
+			expr:     "0 1-7/3 * * *",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 2, 1, 0), at(2025, 1, 2, 4, 0), at(2025, 1, 2, 7, 0), at(2025, 1, 3, 1, 0)},
+		},
+		{
+			name:     "start with step runs to the end of the range",
+			expr:     "5/15 * * * *",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 1, 10, 20), at(2025, 1, 1, 10, 35), at(2025, 1, 1, 10, 50), at(2025, 1, 1, 11, 5)},
+		},
+		{
+			name:     "month and weekday names",
+			expr:     "0 12 * feb MON-tue",
+			from:     start,
+			expected: []time.Time{at(2025, 2, 3, 12, 0), at(2025, 2, 4, 12, 0), at(2025, 2, 10, 12, 0)},
+		},
+		{
+			name:     "day of week 7 is Sunday",
+			expr:     "0 0 * * 7",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 5, 0, 0), at(2025, 1, 12, 0, 0)},
+		},
+		{
+			name:     "both day fields restricted runs on either",
+			expr:     "0 0 10 * fri",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 3, 0, 0), at(2025, 1, 10, 0, 0), at(2025, 1, 17, 0, 0)},
+		},
+		{
+			name:     "starred day of month uses day of week only",
+			expr:     "0 0 * * fri",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 3, 0, 0), at(2025, 1, 10, 0, 0)},
+		},
+		{
+			name:     "starred day of week uses day of month only",
+			expr:     "0 0 31 * *",
+			from:     start,
+			expected: []time.Time{at(2025, 1, 31, 0, 0), at(2025, 3, 31, 0, 0), at(2025, 5, 31, 0, 0)},
+		},
+		{
+			name:     "leap day",
+			expr:     "0 0 29 2 *",
+			from:     start,
+			expected: []time.Time{at(2028, 2, 29, 0, 0)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := mustParseSchedule(t, tt.expr)
+			from := tt.from
+			for _, expected := range tt.expected {
+				got := schedule.Next(from)
+				if !got.Equal(expected) {
+					t.Fatalf("Next(%s) = %s, expected %s", from, got, expected)
+				}
+				from = got
+			}
+		})
+	}
+}
+
+func TestSchedule_NextImpossibleDate(t *testing.T) {
+	for _, expr := range []string{"0 0 30 2 *", "0 0 31 4 *"} {
+		if got := mustParseSchedule(t, expr).Next(at(2025, 1, 1, 0, 0)); !got.IsZero() {
+			t.Errorf("Next() of %q = %s, expected the zero time", expr, got)
+		}
+	}
+}
+
+func TestSchedule_NextDaylightSaving(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	local := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, berlin)
+	}
+
+	// Clocks go from 02:00 to 03:00 on 30 March, so 02:30 does not exist that day
+	daily := mustParseSchedule(t, "30 2 * * *")
+	if got := daily.Next(local(3, 29, 12, 0)); !got.Equal(local(3, 31, 2, 30)) {
+		t.Errorf("Expected the skipped run to move to the next day, got %s", got)
+	}
+	// Other times keep their wall clock time across the change
+	if got := mustParseSchedule(t, "0 9 * * *").Next(local(3, 29, 12, 0)); got.Hour() != 9 || got.Day() != 30 {
+		t.Errorf("Expected 09:00 on 30 March, got %s", got)
+	}
+
+	// Clocks go from 03:00 back to 02:00 on 26 October; a daily run happens once
+	first := daily.Next(local(10, 25, 12, 0))
+	if first.Day() != 26 || first.Hour() != 2 || first.Minute() != 30 {
+		t.Fatalf("Expected 02:30 on 26 October, got %s", first)
+	}
+	if got := daily.Next(first); got.Day() != 27 {
+		t.Errorf("Expected the next run on 27 October, got %s", got)
+	}
+
+	// An hourly schedule runs in both 02:00 hours, an hour apart
+	hourly := mustParseSchedule(t, "0 * * * *")
+	earlier := hourly.Next(local(10, 26, 1, 30))
+	later := hourly.Next(earlier)
+	if earlier.Hour() != 2 || later.Hour() != 2 || later.Sub(earlier) != time.Hour {
+		t.Errorf("Expected two runs at 02:00 an hour apart, got %s and %s", earlier, later)
+	}
+}
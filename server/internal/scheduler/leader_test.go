@@ -0,0 +1,294 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build integration
+
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/database/dbtest"
+)
+
+// testTask counts its runs; while block is set a run waits until it is closed
+type testTask struct {
+	name    string
+	runs    atomic.Int32
+	started chan struct{}
+	block   chan struct{}
+}
+
+func newTestTask(name string) *testTask {
+	return &testTask{name: name, started: make(chan struct{}, 10)}
+}
+
+func (t *testTask) Name() string { return t.name }
+
+func (t *testTask) Run(ctx context.Context) (TaskCounts, error) {
+	t.runs.Add(1)
+	t.started <- struct{}{}
+	if t.block != nil {
+		select {
+		case <-t.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return TaskCounts{"items": 1}, nil
+}
+
+// newTestScheduler returns a scheduler for a replica that wakes and retries quickly
+func newTestScheduler(db *database.DB, replica string, tasks ...Task) *Scheduler {
+	s := New(db)
+	s.replica = replica
+	s.wakeInterval = 100 * time.Millisecond
+	s.retryInterval = 100 * time.Millisecond
+	for _, task := range tasks {
+		s.AddTask(task, "0 * * * *")
+	}
+	return s
+}
+
+// startScheduler runs a scheduler until the returned function is called or the test ends
+func startScheduler(t *testing.T, s *Scheduler) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitFinished waits until the latest run of a task is no longer running and returns it
+func waitFinished(t *testing.T, db *database.DB, taskName string) *database.TaskRun {
+	t.Helper()
+	runRepo := database.NewTaskRunRepository(db)
+	var run *database.TaskRun
+	waitFor(t, "the run of "+taskName, func() bool {
+		var err error
+		run, err = runRepo.Latest(taskName)
+		return err == nil && run != nil && run.Status != database.TaskRunRunning
+	})
+	return run
+}
+
+// waitRecordedLeader waits until the given replica is recorded as the leader
+func waitRecordedLeader(t *testing.T, db *database.DB, replica string) {
+	t.Helper()
+	runRepo := database.NewTaskRunRepository(db)
+	waitFor(t, replica+" to be recorded as leader", func() bool {
+		leader, err := runRepo.GetLeader(LeaderStaleAfter)
+		return err == nil && leader != nil && leader.Holder == replica && !leader.Stale
+	})
+}
+
+func TestScheduler_OneLeader(t *testing.T) {
+	db := dbtest.Open(t)
+	a := newTestScheduler(db, "replica-a")
+	b := newTestScheduler(db, "replica-b")
+	startScheduler(t, a)
+	startScheduler(t, b)
+
+	waitFor(t, "a leader", func() bool { return a.IsLeader() || b.IsLeader() })
+	for i := 0; i < 10; i++ {
+		if a.IsLeader() == b.IsLeader() {
+			t.Fatalf("Expected exactly one leader, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	leader := a
+	if b.IsLeader() {
+		leader = b
+	}
+	waitRecordedLeader(t, db, leader.Replica())
+}
+
+func TestScheduler_TakeoverWhenLeaderStops(t *testing.T) {
+	db := dbtest.Open(t)
+	a := newTestScheduler(db, "replica-a")
+	b := newTestScheduler(db, "replica-b")
+	stopA := startScheduler(t, a)
+	waitFor(t, "replica-a to lead", a.IsLeader)
+	startScheduler(t, b)
+
+	time.Sleep(300 * time.Millisecond)
+	if b.IsLeader() {
+		t.Fatal("Expected replica-b to wait while replica-a leads")
+	}
+
+	stopA()
+	if a.IsLeader() {
+		t.Error("Expected replica-a to give up leadership when stopped")
+	}
+	waitFor(t, "replica-b to take over", b.IsLeader)
+	waitRecordedLeader(t, db, "replica-b")
+}
+
+func TestScheduler_LosesLeadership(t *testing.T) {
+	db := dbtest.Open(t)
+	a := newTestScheduler(db, "replica-a")
+	// Keep replica-a from taking leadership back so replica-b takes over
+	a.retryInterval = time.Hour
+	b := newTestScheduler(db, "replica-b")
+	startScheduler(t, a)
+	waitFor(t, "replica-a to lead", a.IsLeader)
+	startScheduler(t, b)
+
+	// The leader's connection is lost, and Postgres releases the lock with it
+	dbtest.TerminateLockHolder(t, db, leaderLockName)
+
+	waitFor(t, "replica-a to notice", func() bool { return !a.IsLeader() })
+	waitFor(t, "replica-b to take over", b.IsLeader)
+	waitRecordedLeader(t, db, "replica-b")
+}
+
+func TestScheduler_TaskLock(t *testing.T) {
+	db := dbtest.Open(t)
+	task := newTestTask("block")
+	task.block = make(chan struct{})
+	a := newTestScheduler(db, "replica-a", task)
+	b := newTestScheduler(db, "replica-b", task)
+
+	if _, err := a.RunNow("block", "admin", false); err != nil {
+		t.Fatalf("RunNow() error = %v", err)
+	}
+	<-task.started
+
+	// The task is running, so it cannot start again on any replica
+	if _, err := a.RunNow("block", "admin", false); err != ErrTaskRunning {
+		t.Errorf("Expected ErrTaskRunning on the same replica, got %v", err)
+	}
+	if _, err := b.RunNow("block", "admin", false); err != ErrTaskRunning {
+		t.Errorf("Expected ErrTaskRunning on another replica, got %v", err)
+	}
+	// Scheduled runs are skipped too
+	scheduledAt := time.Now().Truncate(time.Hour)
+	if _, err := b.run(b.find("block"), TriggerSchedule, &scheduledAt, false); err != ErrTaskRunning {
+		t.Errorf("Expected ErrTaskRunning for a scheduled run, got %v", err)
+	}
+
+	close(task.block)
+	run := waitFinished(t, db, "block")
+	if run.Status != database.TaskRunSucceeded || run.Runner.String != "replica-a" {
+		t.Errorf("Expected a succeeded run on replica-a, got %s on %s", run.Status, run.Runner.String)
+	}
+
+	// The lock is released with the run
+	if _, err := b.RunNow("block", "admin", false); err != nil {
+		t.Fatalf("RunNow() after the run error = %v", err)
+	}
+	<-task.started
+	waitFinished(t, db, "block")
+	if runs := task.runs.Load(); runs != 2 {
+		t.Errorf("Expected 2 runs, got %d", runs)
+	}
+
+	if _, err := b.RunNow("block", "admin", true); err != ErrDryRunUnsupported {
+		t.Errorf("Expected ErrDryRunUnsupported, got %v", err)
+	}
+	if _, err := b.RunNow("missing", "admin", false); err != ErrUnknownTask {
+		t.Errorf("Expected ErrUnknownTask, got %v", err)
+	}
+}
+
+func TestScheduler_CatchUp(t *testing.T) {
+	db := dbtest.Open(t)
+	hourly := newTestTask("hourly")
+	fresh := newTestTask("fresh")
+	a := newTestScheduler(db, "replica-a", hourly, fresh)
+	b := newTestScheduler(db, "replica-b", hourly, fresh)
+	runRepo := database.NewTaskRunRepository(db)
+
+	// The last scheduled run was about three hours ago; fresh never ran on schedule
+	schedule := mustParseSchedule(t, "0 * * * *")
+	now := time.Now()
+	lastScheduled := schedule.Next(now.Add(-4 * time.Hour))
+	if _, err := runRepo.Start("hourly", TriggerSchedule, "replica-gone", &lastScheduled, false); err != nil {
+		t.Fatalf("Failed to record scheduled run: %v", err)
+	}
+
+	a.catchUp(now)
+	run := waitFinished(t, db, "hourly")
+	if run.TriggeredBy != TriggerCatchUp {
+		t.Fatalf("Expected a catch-up run, got one triggered by %s", run.TriggeredBy)
+	}
+	// Only the latest missed run is made up for
+	missed := schedule.Next(now.Add(-time.Hour))
+	if !run.ScheduledAt.Valid || !run.ScheduledAt.Time.Equal(missed) {
+		t.Errorf("Expected the run scheduled at %s, got %v", missed, run.ScheduledAt)
+	}
+
+	// Another replica catching up at the same time does not run it again
+	b.catchUp(now)
+	if _, total, err := runRepo.ListByTask("hourly", 10, 0); err != nil || total != 2 {
+		t.Errorf("Expected 2 runs of hourly, got %d (err %v)", total, err)
+	}
+	if runs := hourly.runs.Load(); runs != 1 {
+		t.Errorf("Expected hourly to run once, got %d", runs)
+	}
+	if runs := fresh.runs.Load(); runs != 0 {
+		t.Errorf("Expected fresh not to be caught up, got %d runs", runs)
+	}
+}
+
+func TestScheduler_TakeoverCatchesUp(t *testing.T) {
+	db := dbtest.Open(t)
+	hourly := newTestTask("hourly")
+	runRepo := database.NewTaskRunRepository(db)
+
+	// The previous leader ran the task on schedule and died mid-run
+	lastScheduled := mustParseSchedule(t, "0 * * * *").Next(time.Now().Add(-3 * time.Hour))
+	interrupted, err := runRepo.Start("hourly", TriggerSchedule, "replica-gone", &lastScheduled, false)
+	if err != nil || interrupted == nil {
+		t.Fatalf("Failed to record scheduled run: %v", err)
+	}
+
+	b := newTestScheduler(db, "replica-b", hourly)
+	startScheduler(t, b)
+	waitFor(t, "replica-b to lead", b.IsLeader)
+	<-hourly.started
+
+	run := waitFinished(t, db, "hourly")
+	if run.TriggeredBy != TriggerCatchUp || run.Runner.String != "replica-b" {
+		t.Errorf("Expected a catch-up run on replica-b, got %s on %s", run.TriggeredBy, run.Runner.String)
+	}
+	previous, err := runRepo.Get("hourly", interrupted.ID)
+	if err != nil || previous == nil {
+		t.Fatalf("Failed to get interrupted run: %v", err)
+	}
+	if previous.Status != database.TaskRunFailed {
+		t.Errorf("Expected the interrupted run to be failed, got %s", previous.Status)
+	}
+}
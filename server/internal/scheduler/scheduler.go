@@ -7,54 +7,319 @@ package scheduler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/kk/kkartifact-server/internal/database"
+)
+
+// ScheduleOff disables the scheduled runs of a task; it can still be run by hand
+const ScheduleOff = "off"
+
+// Task run triggers recorded in task_runs.triggered_by (manual runs record the user)
+const (
+	TriggerSchedule = "schedule"
+	TriggerCatchUp  = "catch-up"
+)
+
+const (
+	// leaderLockName is the advisory lock held by the replica that schedules tasks
+	leaderLockName = "kkartifact:scheduler"
+
+	// leaderRetryInterval is how often other replicas try to take over leadership
+	leaderRetryInterval = 15 * time.Second
+
+	// LeaderStaleAfter is how long a leader record may go without renewal
+	// before it is shown as stale (it is renewed every minute)
+	LeaderStaleAfter = 3 * time.Minute
+)
+
+var (
+	// ErrUnknownTask is returned for a task name that is not registered
+	ErrUnknownTask = errors.New("unknown task")
+
+	// ErrTaskRunning is returned when a task is started while it is still running
+	// on this or another replica
+	ErrTaskRunning = errors.New("task is already running")
+
+	// ErrDryRunUnsupported is returned when a dry run is asked of a task that has none
+	ErrDryRunUnsupported = errors.New("task does not support dry runs")
+
+	// errAlreadyRun is returned when another replica already ran a scheduled run
+	errAlreadyRun = errors.New("scheduled run was already done")
 )
 
 // Task represents a scheduled task
+// Run returns counts of what it did (e.g. versions deleted), which are
+// recorded with the run.
 type Task interface {
-	Run(ctx context.Context) error
+	Run(ctx context.Context) (TaskCounts, error)
 	Name() string
 }
 
-// Scheduler manages scheduled tasks
+// TaskCounts are the counts a task run reports
+type TaskCounts map[string]int
+
+// DryRunTask is a task that can report what it would do without doing it
+// DryRun returns counts and details of what it decided (e.g. the versions it
+// would delete), which are recorded with the run.
+type DryRunTask interface {
+	Task
+	DryRun(ctx context.Context) (TaskCounts, interface{}, error)
+}
+
+// TaskInfo describes a registered task
+type TaskInfo struct {
+	Name            string
+	Schedule        string
+	DefaultSchedule string
+	NextRun         *time.Time
+	CanDryRun       bool // the task implements DryRunTask
+	DryRun          bool // scheduled runs are dry runs
+}
+
+// Scheduler runs tasks on cron schedules
+// Each task's schedule is read from the config table (schedule.<task name>)
+// every minute, so changes apply without a restart; the default given to
+// AddTask is used when none is configured. Every run is recorded in task_runs.
+// Runs that were due while no scheduler was running are caught up. Tasks
+// implementing DryRunTask can be run dry by hand, and their scheduled runs are
+// dry runs while dry_run.<task name> is true.
+//
+// With several replicas, only the leader schedules runs: leadership is a
+// Postgres advisory lock, which is released when the leader's connection is
+// lost, and the other replicas keep trying to take it over. Every run, also
+// a manual one, holds a lock of its task, so a task never runs twice at the
+// same time, on any replica. Different tasks run concurrently.
 type Scheduler struct {
-	tasks      []Task
-	stop       chan struct{}
-	lastRunDay int // Track last run day to prevent multiple runs per day
+	db      *database.DB
+	tasks   []*scheduledTask
+	stop    chan struct{}
+	replica string
+	leader  atomic.Bool
+
+	// wakeInterval is how often the leader checks for due runs (every minute)
+	// and retryInterval how often other replicas try to take over
+	wakeInterval  time.Duration
+	retryInterval time.Duration
+
+	mu  sync.Mutex
+	ctx context.Context // context of runs, set by Start
+}
+
+// scheduledTask is a registered task
+type scheduledTask struct {
+	task            Task
+	defaultSchedule string
+	invalidSchedule string // last invalid configured schedule, logged once (guarded by Scheduler.mu)
 }
 
 // New creates a new scheduler
-func New() *Scheduler {
+func New(db *database.DB) *Scheduler {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "kkartifact"
+	}
 	return &Scheduler{
-		tasks:      []Task{},
-		stop:       make(chan struct{}),
-		lastRunDay: -1, // Initialize to -1 so first run can happen
+		db:      db,
+		stop:    make(chan struct{}),
+		replica: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		ctx:     context.Background(),
+
+		wakeInterval:  time.Minute,
+		retryInterval: leaderRetryInterval,
 	}
 }
 
-// AddTask adds a task to the scheduler
-func (s *Scheduler) AddTask(task Task) {
-	s.tasks = append(s.tasks, task)
+// AddTask adds a task to the scheduler with the schedule it has by default
+func (s *Scheduler) AddTask(task Task, defaultSchedule string) {
+	if err := ValidateSchedule(defaultSchedule); err != nil {
+		panic(fmt.Sprintf("task %s: %v", task.Name(), err))
+	}
+	s.tasks = append(s.tasks, &scheduledTask{task: task, defaultSchedule: defaultSchedule})
+}
+
+// ScheduleKey returns the config key holding the schedule of a task
+func ScheduleKey(taskName string) string {
+	return "schedule." + taskName
+}
+
+// DryRunKey returns the config key that makes the scheduled runs of a task dry runs
+func DryRunKey(taskName string) string {
+	return "dry_run." + taskName
+}
+
+// CanDryRun reports whether a registered task supports dry runs
+func (s *Scheduler) CanDryRun(name string) bool {
+	st := s.find(name)
+	if st == nil {
+		return false
+	}
+	_, ok := st.task.(DryRunTask)
+	return ok
+}
+
+// ValidateSchedule checks a schedule is a valid cron expression or "off"
+func ValidateSchedule(expr string) error {
+	if strings.EqualFold(strings.TrimSpace(expr), ScheduleOff) {
+		return nil
+	}
+	_, err := ParseSchedule(expr)
+	return err
+}
+
+// Replica returns the name this replica runs tasks under
+func (s *Scheduler) Replica() string {
+	return s.replica
+}
+
+// IsLeader reports whether this replica is the one scheduling tasks
+func (s *Scheduler) IsLeader() bool {
+	return s.leader.Load()
+}
+
+// HasTask reports whether a task is registered
+func (s *Scheduler) HasTask(name string) bool {
+	return s.find(name) != nil
+}
+
+// Tasks describes the registered tasks
+func (s *Scheduler) Tasks() []TaskInfo {
+	now := time.Now()
+	infos := make([]TaskInfo, 0, len(s.tasks))
+	for _, st := range s.tasks {
+		expr, schedule := s.schedule(st)
+		info := TaskInfo{
+			Name:            st.task.Name(),
+			Schedule:        expr,
+			DefaultSchedule: st.defaultSchedule,
+			DryRun:          s.dryRun(st),
+		}
+		_, info.CanDryRun = st.task.(DryRunTask)
+		if schedule != nil {
+			if next := schedule.Next(now); !next.IsZero() {
+				info.NextRun = &next
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// RunNow starts a run of a task in the background and returns its record
+// triggeredBy names who started it. A dry run only records what the task
+// would do.
+func (s *Scheduler) RunNow(name, triggeredBy string, dryRun bool) (*database.TaskRun, error) {
+	st := s.find(name)
+	if st == nil {
+		return nil, ErrUnknownTask
+	}
+	if _, ok := st.task.(DryRunTask); dryRun && !ok {
+		return nil, ErrDryRunUnsupported
+	}
+	return s.run(st, triggeredBy, nil, dryRun)
 }
 
 // Start starts the scheduler
+// It runs until ctx is done or Stop is called, scheduling tasks whenever this
+// replica is the leader.
 func (s *Scheduler) Start(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Hour) // Check every hour
-	defer ticker.Stop()
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	for {
+		lock, err := s.db.TryAdvisoryLock(ctx, leaderLockName)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to take scheduler leadership: %v", err)
+		}
+		if lock != nil {
+			s.lead(ctx, lock)
+		}
 
-	// Run immediately on start
-	s.runTasks(ctx)
+		select {
+		case <-time.After(s.retryInterval):
+		case <-s.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// lead schedules tasks while this replica holds the leader lock
+func (s *Scheduler) lead(ctx context.Context, lock *database.AdvisoryLock) {
+	runRepo := database.NewTaskRunRepository(s.db)
+	s.leader.Store(true)
+	defer func() {
+		s.leader.Store(false)
+		if err := runRepo.ClearLeader(s.replica); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		lock.Release()
+	}()
+
+	log.Printf("Scheduler leadership taken by %s", s.replica)
+	if err := runRepo.RecordLeader(s.replica); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	s.failInterrupted()
+	last := time.Now()
+	s.catchUp(last)
 
 	for {
+		// Wake at the start of every minute
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(s.wakeInterval).Add(s.wakeInterval).Sub(now))
 		select {
-		case <-ticker.C:
-			s.runTasks(ctx)
+		case <-timer.C:
 		case <-s.stop:
+			timer.Stop()
 			return
 		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		if err := lock.Check(ctx); err != nil {
+			// Another replica takes over once Postgres drops the lock
+			log.Printf("Scheduler leadership lost: %v", err)
 			return
 		}
+		if err := runRepo.RecordLeader(s.replica); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+
+		now = time.Now()
+		s.runDue(last, now)
+		last = now
+	}
+}
+
+// failInterrupted marks runs left running by a replica that is gone as failed
+// A task whose lock is free is not running anywhere.
+func (s *Scheduler) failInterrupted() {
+	runRepo := database.NewTaskRunRepository(s.db)
+	for _, st := range s.tasks {
+		name := st.task.Name()
+		lock, err := s.db.TryAdvisoryLock(context.Background(), taskLockName(name))
+		if err != nil || lock == nil {
+			continue
+		}
+		if count, err := runRepo.FailUnfinished(name); err != nil {
+			log.Printf("Failed to mark interrupted runs of task %s: %v", name, err)
+		} else if count > 0 {
+			log.Printf("Marked %d interrupted runs of task %s as failed", count, name)
+		}
+		lock.Release()
 	}
 }
 
@@ -63,24 +328,181 @@ func (s *Scheduler) Stop() {
 	close(s.stop)
 }
 
-func (s *Scheduler) runTasks(ctx context.Context) {
-	now := time.Now()
-	currentDay := now.YearDay() // Day of year (1-365/366)
-	
-	// Run at 3 AM (03:00-03:09) and only once per day
-	if now.Hour() == 3 && now.Minute() < 10 && s.lastRunDay != currentDay {
-		s.lastRunDay = currentDay
-		
-		log.Printf("Running scheduled cleanup tasks at %s", now.Format("2006-01-02 15:04:05"))
-		for _, task := range s.tasks {
-			log.Printf("Running scheduled task: %s", task.Name())
-			if err := task.Run(ctx); err != nil {
-				// Always log task failures
-				log.Printf("Task %s failed: %v", task.Name(), err)
-			} else {
-				log.Printf("Task %s completed successfully", task.Name())
-			}
+// runDue starts the tasks scheduled after last and up to now
+func (s *Scheduler) runDue(last, now time.Time) {
+	for _, st := range s.tasks {
+		_, schedule := s.schedule(st)
+		if schedule == nil {
+			continue
+		}
+		next, ok := dueRun(schedule, last, now)
+		if !ok {
+			continue
+		}
+		if _, err := s.run(st, TriggerSchedule, &next, s.dryRun(st)); err != nil && err != errAlreadyRun {
+			log.Printf("Skipping scheduled run of task %s: %v", st.task.Name(), err)
+		}
+	}
+}
+
+// catchUp runs the tasks that missed a scheduled run since they last ran on schedule
+// A task that never ran on schedule has nothing to catch up. However many runs
+// were missed, the task runs once.
+func (s *Scheduler) catchUp(now time.Time) {
+	runRepo := database.NewTaskRunRepository(s.db)
+	for _, st := range s.tasks {
+		_, schedule := s.schedule(st)
+		if schedule == nil {
+			continue
+		}
+		lastScheduled, err := runRepo.LastScheduled(st.task.Name())
+		if err != nil {
+			log.Printf("Failed to get last run of task %s: %v", st.task.Name(), err)
+			continue
+		}
+		if lastScheduled == nil {
+			continue
+		}
+
+		missed := missedRun(schedule, *lastScheduled, now)
+		if missed.IsZero() {
+			continue
+		}
+		log.Printf("Catching up task %s, missed its run at %s", st.task.Name(), missed.Format("2006-01-02 15:04"))
+		if _, err := s.run(st, TriggerCatchUp, &missed, s.dryRun(st)); err != nil && err != errAlreadyRun {
+			log.Printf("Failed to catch up task %s: %v", st.task.Name(), err)
+		}
+	}
+}
+
+// dueRun returns the first run of a schedule after last if it is due by now
+func dueRun(schedule *Schedule, last, now time.Time) (time.Time, bool) {
+	next := schedule.Next(last)
+	if next.IsZero() || next.After(now) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// missedRun returns the latest run of a schedule after lastScheduled that was
+// due by now, or the zero time if none was
+// Catching up from the latest run lets the next start continue from there.
+func missedRun(schedule *Schedule, lastScheduled, now time.Time) time.Time {
+	var missed time.Time
+	for next := schedule.Next(lastScheduled.In(now.Location())); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+		missed = next
+	}
+	return missed
+}
+
+// run starts a run of a task in the background and records it
+// The task's lock is held until the run finishes. dryRun is only set for
+// tasks implementing DryRunTask.
+func (s *Scheduler) run(st *scheduledTask, triggeredBy string, scheduledAt *time.Time, dryRun bool) (*database.TaskRun, error) {
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	name := st.task.Name()
+	lock, err := s.db.TryAdvisoryLock(ctx, taskLockName(name))
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		return nil, ErrTaskRunning
+	}
+
+	runRepo := database.NewTaskRunRepository(s.db)
+	record, err := runRepo.Start(name, triggeredBy, s.replica, scheduledAt, dryRun)
+	if err != nil || record == nil {
+		lock.Release()
+		if err == nil {
+			err = errAlreadyRun
+		}
+		return nil, err
+	}
+
+	go func() {
+		defer lock.Release()
+
+		var counts TaskCounts
+		var details interface{}
+		var err error
+		if dryRun {
+			log.Printf("Dry-running task %s (%s)", name, triggeredBy)
+			counts, details, err = st.task.(DryRunTask).DryRun(ctx)
+		} else {
+			log.Printf("Running task %s (%s)", name, triggeredBy)
+			counts, err = st.task.Run(ctx)
+		}
+		status, errMsg := database.TaskRunSucceeded, ""
+		if err != nil {
+			// Always log task failures
+			log.Printf("Task %s failed: %v", name, err)
+			status, errMsg = database.TaskRunFailed, err.Error()
+		} else {
+			log.Printf("Task %s completed successfully", name)
+		}
+		if err := runRepo.Finish(record.ID, status, errMsg, counts, details); err != nil {
+			log.Printf("Failed to record run of task %s: %v", name, err)
+		}
+	}()
+	return record, nil
+}
+
+// schedule returns the expression and parsed schedule of a task
+// The schedule is nil if the task is off. An invalid configured schedule falls
+// back to the default.
+func (s *Scheduler) schedule(st *scheduledTask) (string, *Schedule) {
+	expr := st.defaultSchedule
+	if value, err := database.NewConfigRepository(s.db).Get(ScheduleKey(st.task.Name())); err == nil && strings.TrimSpace(value) != "" {
+		expr = strings.TrimSpace(value)
+	}
+	if strings.EqualFold(expr, ScheduleOff) {
+		return ScheduleOff, nil
+	}
+
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		s.mu.Lock()
+		if st.invalidSchedule != expr {
+			st.invalidSchedule = expr
+			log.Printf("Task %s has an invalid schedule, using %q: %v", st.task.Name(), st.defaultSchedule, err)
+		}
+		s.mu.Unlock()
+		expr = st.defaultSchedule
+		if strings.EqualFold(expr, ScheduleOff) {
+			return ScheduleOff, nil
+		}
+		schedule, _ = ParseSchedule(expr)
+	}
+	return expr, schedule
+}
+
+// dryRun reports whether the scheduled runs of a task are dry runs
+func (s *Scheduler) dryRun(st *scheduledTask) bool {
+	if _, ok := st.task.(DryRunTask); !ok {
+		return false
+	}
+	value, err := database.NewConfigRepository(s.db).Get(DryRunKey(st.task.Name()))
+	if err != nil {
+		return false
+	}
+	dryRun, _ := strconv.ParseBool(strings.TrimSpace(value))
+	return dryRun
+}
+
+// find returns a registered task by name
+func (s *Scheduler) find(name string) *scheduledTask {
+	for _, st := range s.tasks {
+		if st.task.Name() == name {
+			return st
 		}
 	}
+	return nil
 }
 
+// taskLockName returns the name of the advisory lock held while a task runs
+func taskLockName(taskName string) string {
+	return "kkartifact:task:" + taskName
+}
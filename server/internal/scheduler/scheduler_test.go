@@ -0,0 +1,114 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDueRun(t *testing.T) {
+	hourly := mustParseSchedule(t, "0 * * * *")
+
+	tests := []struct {
+		name     string
+		last     time.Time
+		now      time.Time
+		expected time.Time // zero if nothing is due
+	}{
+		{
+			name: "not due yet",
+			last: at(2025, 1, 1, 10, 0),
+			now:  at(2025, 1, 1, 10, 59),
+		},
+		{
+			name:     "due at now",
+			last:     at(2025, 1, 1, 10, 59),
+			now:      at(2025, 1, 1, 11, 0),
+			expected: at(2025, 1, 1, 11, 0),
+		},
+		{
+			name:     "due between ticks",
+			last:     at(2025, 1, 1, 10, 59).Add(30 * time.Second),
+			now:      at(2025, 1, 1, 11, 0).Add(30 * time.Second),
+			expected: at(2025, 1, 1, 11, 0),
+		},
+		{
+			name: "the run at last is not repeated",
+			last: at(2025, 1, 1, 11, 0),
+			now:  at(2025, 1, 1, 11, 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := dueRun(hourly, tt.last, tt.now)
+			if ok != !tt.expected.IsZero() || !got.Equal(tt.expected) {
+				t.Errorf("dueRun() = %s, %v, expected %s", got, ok, tt.expected)
+			}
+		})
+	}
+
+	if _, ok := dueRun(mustParseSchedule(t, "0 0 30 2 *"), at(2025, 1, 1, 0, 0), at(2030, 1, 1, 0, 0)); ok {
+		t.Error("Expected nothing due for a schedule that never runs")
+	}
+}
+
+func TestMissedRun(t *testing.T) {
+	daily := mustParseSchedule(t, "0 3 * * *")
+
+	tests := []struct {
+		name          string
+		lastScheduled time.Time
+		now           time.Time
+		expected      time.Time // zero if nothing was missed
+	}{
+		{
+			name:          "nothing missed",
+			lastScheduled: at(2025, 1, 1, 3, 0),
+			now:           at(2025, 1, 2, 2, 59),
+		},
+		{
+			name:          "one run missed",
+			lastScheduled: at(2025, 1, 1, 3, 0),
+			now:           at(2025, 1, 2, 8, 0),
+			expected:      at(2025, 1, 2, 3, 0),
+		},
+		{
+			name:          "several runs missed catch up once from the latest",
+			lastScheduled: at(2025, 1, 1, 3, 0),
+			now:           at(2025, 1, 5, 8, 0),
+			expected:      at(2025, 1, 5, 3, 0),
+		},
+		{
+			name:          "run due at now counts as missed",
+			lastScheduled: at(2025, 1, 1, 3, 0),
+			now:           at(2025, 1, 2, 3, 0),
+			expected:      at(2025, 1, 2, 3, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missedRun(daily, tt.lastScheduled, tt.now); !got.Equal(tt.expected) {
+				t.Errorf("missedRun() = %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMissedRun_UsesTheLocationOfNow(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	daily := mustParseSchedule(t, "0 3 * * *")
+
+	// Runs are recorded in UTC but scheduled in local time
+	lastScheduled := time.Date(2025, 1, 1, 3, 0, 0, 0, tokyo).UTC()
+	now := time.Date(2025, 1, 2, 8, 0, 0, 0, tokyo)
+	expected := time.Date(2025, 1, 2, 3, 0, 0, 0, tokyo)
+	if got := missedRun(daily, lastScheduled, now); !got.Equal(expected) {
+		t.Errorf("missedRun() = %s, expected %s", got, expected)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/kk/kkartifact-server/internal/database"
+	"github.com/kk/kkartifact-server/internal/storage"
+	"github.com/kk/kkartifact-server/internal/util"
+)
+
+// finishedSessionRetentionDays is how long completed/aborted/expired session records are kept
+const finishedSessionRetentionDays = 7
+
+// UploadSessionCleanupTask is a scheduled task that garbage-collects abandoned upload sessions
+type UploadSessionCleanupTask struct {
+	db              *database.DB
+	artifactManager *storage.ArtifactManager
+}
+
+// NewUploadSessionCleanupTask creates a new upload session cleanup task
+func NewUploadSessionCleanupTask(db *database.DB, artifactManager *storage.ArtifactManager) *UploadSessionCleanupTask {
+	return &UploadSessionCleanupTask{
+		db:              db,
+		artifactManager: artifactManager,
+	}
+}
+
+// Name returns the task name
+func (t *UploadSessionCleanupTask) Name() string {
+	return "upload-session-cleanup"
+}
+
+// Run expires sessions past their expiry time, discards their staged files
+// and deletes old session records
+func (t *UploadSessionCleanupTask) Run(ctx context.Context) (TaskCounts, error) {
+	sessionRepo := database.NewUploadSessionRepository(t.db)
+
+	sessions, err := sessionRepo.ListExpired(1000)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired upload sessions: %w", err)
+	}
+
+	expiredCount := 0
+	for _, session := range sessions {
+		expired, err := sessionRepo.Expire(session)
+		if err != nil || !expired {
+			// Finished concurrently or failed; leave it for the next run
+			continue
+		}
+
+		staged, err := sessionRepo.ListFiles(session.ID)
+		if err != nil {
+			log.Printf("Failed to list staged files of upload session %s: %v", session.ID, err)
+			continue
+		}
+		hashes := make([]string, 0, len(staged))
+		for hash := range staged {
+			hashes = append(hashes, hash)
+		}
+		if err := t.artifactManager.DiscardStaging(ctx, session.ID, hashes); err != nil {
+			log.Printf("Failed to discard staged files of upload session %s: %v", session.ID, err)
+			continue
+		}
+		if err := t.artifactManager.AbortChunkedUploads(ctx, database.NewChunkedUploadRepository(t.db), session.ID); err != nil {
+			log.Printf("Failed to abort chunked uploads of upload session %s: %v", session.ID, err)
+		}
+
+		expiredCount++
+		if util.IsDebugMode() {
+			log.Printf("Expired upload session %s for %s/%s/%s (%d staged files)", session.ID, session.Project, session.App, session.Version, len(hashes))
+		}
+	}
+
+	deletedCount, err := sessionRepo.DeleteFinished(finishedSessionRetentionDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete finished upload sessions: %w", err)
+	}
+	if deletedCount > 0 && util.IsDebugMode() {
+		log.Printf("Deleted %d upload session records older than %d days", deletedCount, finishedSessionRetentionDays)
+	}
+
+	return TaskCounts{
+		"sessions_expired": expiredCount,
+		"sessions_deleted": int(deletedCount),
+	}, nil
+}
@@ -8,6 +8,7 @@ package server
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/kk/kkartifact-server/internal/config"
 	"github.com/kk/kkartifact-server/internal/database"
 	"github.com/kk/kkartifact-server/internal/bootstrap"
+	"github.com/kk/kkartifact-server/internal/events"
 	"github.com/kk/kkartifact-server/internal/middleware"
 	"github.com/kk/kkartifact-server/internal/scheduler"
 	"github.com/kk/kkartifact-server/internal/storage"
@@ -35,6 +37,7 @@ type Server struct {
 	db      *database.DB
 	storage storage.Storage
 	cache   cache.Cache
+	closers []io.Closer
 }
 
 // New creates a new server instance
@@ -122,31 +125,62 @@ func New(cfg *config.Config) (*Server, error) {
 
 	// Initialize handler
 	authenticator := auth.NewTokenAuthenticator(db)
-	handler := api.NewHandler(db, storageBackend, authenticator)
+	webhookDispatcher := events.NewWebhookDispatcher(db, cfg.Webhook.Workers)
+	var eventBus events.EventBus
+	var closers []io.Closer
+	switch cfg.Events.Backend {
+	case "", "memory":
+		eventBus = events.NewMemoryEventBus()
+	case "redis":
+		// Shares the cache connection; events reach every replica and survive restarts
+		if cacheBackend == nil {
+			return nil, fmt.Errorf("redis event bus requires Redis, which is not available")
+		}
+		redisBus := events.NewRedisEventBus(cacheBackend.Client(), cfg.Events.Stream, cfg.Events.ConsumerGroup, cfg.Events.StreamMaxLen)
+		go redisBus.Start(context.Background())
+		closers = append(closers, redisBus)
+		eventBus = redisBus
+		log.Printf("Event bus: Redis stream %s (consumer group %s)", cfg.Events.Stream, cfg.Events.ConsumerGroup)
+	default:
+		return nil, fmt.Errorf("unknown event bus backend: %s", cfg.Events.Backend)
+	}
+	publisher := events.NewPublisher(db, eventBus, webhookDispatcher)
+
+	// Initialize scheduler and cleanup tasks (schedules are editable through /config)
+	sched := scheduler.New(db)
+	artifactManager := storage.NewArtifactManager(storageBackend, database.NewBlobRepository(db))
+	
+	// Add version cleanup task
+	cleanupTask := scheduler.NewCleanupTask(db, artifactManager, publisher)
+	sched.AddTask(cleanupTask, "0 3 * * *")
+	
+	// Add audit log cleanup task
+	auditCleanupTask := scheduler.NewAuditCleanupTask(db)
+	sched.AddTask(auditCleanupTask, "10 3 * * *")
+	
+	// Add upload session cleanup task
+	uploadSessionCleanupTask := scheduler.NewUploadSessionCleanupTask(db, artifactManager)
+	sched.AddTask(uploadSessionCleanupTask, "20 3 * * *")
+
+	handler := api.NewHandler(db, storageBackend, artifactManager, authenticator, publisher, sched)
 	handler.RegisterRoutes(router)
 
+	// Start webhook delivery workers (queued deliveries survive restarts)
+	go webhookDispatcher.Start(context.Background())
+
 	server := &Server{
 		config:  cfg,
 		router:  router,
 		db:      db,
 		storage: storageBackend,
 		cache:   cacheBackend,
+		closers: closers,
 	}
 
-	// Initialize scheduler and cleanup tasks
-	sched := scheduler.New()
-	artifactManager := storage.NewArtifactManager(storageBackend)
-	
-	// Add version cleanup task
-	cleanupTask := scheduler.NewCleanupTask(db, artifactManager)
-	sched.AddTask(cleanupTask)
-	
-	// Add audit log cleanup task
-	auditCleanupTask := scheduler.NewAuditCleanupTask(db)
-	sched.AddTask(auditCleanupTask)
-	
 	go sched.Start(context.Background())
-	log.Printf("Scheduler started: cleanup tasks will run daily at 3 AM (03:00)")
+	for _, task := range sched.Tasks() {
+		log.Printf("Scheduled task %s: %s", task.Name, task.Schedule)
+	}
 
 	return server, nil
 }
@@ -160,6 +194,11 @@ func (s *Server) Start() error {
 
 // Close closes the server and cleans up resources
 func (s *Server) Close() error {
+	for _, closer := range s.closers {
+		if err := closer.Close(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
@@ -0,0 +1,160 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Archive formats
+const (
+	ArchiveTarGz = "tar.gz"
+	ArchiveZip   = "zip"
+)
+
+// ArchiveFilter selects the files of a version that go into an archive
+// A file is included if it matches any include pattern (or there are none) and
+// no exclude pattern. Patterns use path.Match syntax and match the full path;
+// a pattern without a slash also matches the file name in any directory, and a
+// pattern ending in "/" matches everything below that directory.
+type ArchiveFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// Validate checks that all patterns are well-formed
+func (f ArchiveFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/"), ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a file path passes the filter
+func (f ArchiveFilter) Matches(filePath string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, filePath) {
+		return false
+	}
+	return !matchAny(f.Exclude, filePath)
+}
+
+// matchAny reports whether a path matches one of the patterns
+func matchAny(patterns []string, filePath string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/") {
+			if strings.HasPrefix(filePath, pattern) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, filePath); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(filePath)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteArchive streams the files of a committed version into w as a tar.gz or zip archive
+// Files are read from storage one at a time, so memory use does not depend on
+// the size of the version.
+func (am *ArtifactManager) WriteArchive(ctx context.Context, w io.Writer, project, app, version string, manifest *Manifest, format string, filter ArchiveFilter) error {
+	modTime := time.Now()
+	if buildTime, err := time.Parse(time.RFC3339, manifest.BuildTime); err == nil {
+		modTime = buildTime
+	}
+
+	switch format {
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		tw := tar.NewWriter(gz)
+		err := am.eachArchiveFile(ctx, project, app, version, manifest, filter, func(file ManifestFile, reader io.Reader) error {
+			header := &tar.Header{
+				Name:    file.Path,
+				Mode:    0644,
+				Size:    file.Size,
+				ModTime: modTime,
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			_, err := io.Copy(tw, reader)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return gz.Close()
+
+	case ArchiveZip:
+		zw := zip.NewWriter(w)
+		err := am.eachArchiveFile(ctx, project, app, version, manifest, filter, func(file ManifestFile, reader io.Reader) error {
+			header := &zip.FileHeader{
+				Name:     file.Path,
+				Method:   zip.Deflate,
+				Modified: modTime,
+			}
+			header.SetMode(0644)
+			entry, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(entry, reader)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return zw.Close()
+
+	default:
+		return fmt.Errorf("unsupported archive format: %s", format)
+	}
+}
+
+// eachArchiveFile opens every file of a version that passes the filter, in manifest order
+func (am *ArtifactManager) eachArchiveFile(ctx context.Context, project, app, version string, manifest *Manifest, filter ArchiveFilter, fn func(file ManifestFile, reader io.Reader) error) error {
+	for _, file := range manifest.Files {
+		if !filter.Matches(file.Path) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		storagePath, err := am.FilePath(ctx, project, app, version, file.Path)
+		if err != nil {
+			return err
+		}
+		reader, err := am.storage.Get(ctx, storagePath)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", file.Path, err)
+		}
+		err = fn(file, reader)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("failed to archive file %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestArchiveFilter_Matches(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ArchiveFilter
+		path   string
+		want   bool
+	}{
+		{"no patterns", ArchiveFilter{}, "bin/app", true},
+		{"include full path", ArchiveFilter{Include: []string{"bin/*"}}, "bin/app", true},
+		{"include misses", ArchiveFilter{Include: []string{"bin/*"}}, "conf/app.yml", false},
+		{"include base name", ArchiveFilter{Include: []string{"*.yml"}}, "conf/app.yml", true},
+		{"include directory", ArchiveFilter{Include: []string{"conf/"}}, "conf/prod/app.yml", true},
+		{"exclude wins", ArchiveFilter{Include: []string{"conf/"}, Exclude: []string{"*.bak"}}, "conf/app.yml.bak", false},
+		{"exclude only", ArchiveFilter{Exclude: []string{"docs/"}}, "docs/readme.md", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(tt.path); got != tt.want {
+				t.Errorf("Matches(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if err := (ArchiveFilter{Include: []string{"[bin"}}).Validate(); err == nil {
+		t.Error("Validate() with malformed pattern should fail")
+	}
+}
+
+func TestArtifactManager_WriteArchive(t *testing.T) {
+	localStorage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	am := NewArtifactManager(localStorage, nil)
+	ctx := context.Background()
+
+	files := map[string]string{
+		"bin/app":      "binary",
+		"conf/app.yml": "port: 80",
+	}
+	manifest := &Manifest{Project: "p1", App: "a1", Version: "v1"}
+	for path, content := range files {
+		hash := sha256Hex(content)
+		if _, err := am.PutBlob(ctx, hash, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("Failed to put blob: %v", err)
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{Path: path, SHA256: hash, Size: int64(len(content))})
+	}
+	if err := am.CommitManifest(ctx, "p1", "a1", "v1", manifest); err != nil {
+		t.Fatalf("Failed to commit manifest: %v", err)
+	}
+
+	var buf bytes.Buffer
+	filter := ArchiveFilter{Exclude: []string{"*.yml"}}
+	if err := am.WriteArchive(ctx, &buf, "p1", "a1", "v1", manifest, ArchiveTarGz, filter); err != nil {
+		t.Fatalf("WriteArchive(tar.gz) error = %v", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Failed to open gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	got := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read tar: %v", err)
+		}
+		content, _ := io.ReadAll(tr)
+		got[header.Name] = string(content)
+	}
+	if len(got) != 1 || got["bin/app"] != "binary" {
+		t.Errorf("tar.gz entries = %v, want only bin/app", got)
+	}
+
+	buf.Reset()
+	if err := am.WriteArchive(ctx, &buf, "p1", "a1", "v1", manifest, ArchiveZip, ArchiveFilter{}); err != nil {
+		t.Fatalf("WriteArchive(zip) error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open zip: %v", err)
+	}
+	if len(zr.File) != len(files) {
+		t.Fatalf("zip has %d entries, want %d", len(zr.File), len(files))
+	}
+	for _, entry := range zr.File {
+		rc, err := entry.Open()
+		if err != nil {
+			t.Fatalf("Failed to open zip entry: %v", err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(content) != files[entry.Name] {
+			t.Errorf("zip entry %s = %q, want %q", entry.Name, content, files[entry.Name])
+		}
+	}
+
+	if err := am.WriteArchive(ctx, io.Discard, "p1", "a1", "v1", manifest, "rar", ArchiveFilter{}); err == nil {
+		t.Error("WriteArchive() with unknown format should fail")
+	}
+}
@@ -7,103 +7,263 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// manifestCacheTTL bounds how long a parsed manifest is reused for file lookups
+const manifestCacheTTL = 30 * time.Second
+
 // ArtifactManager manages artifact versions
 type ArtifactManager struct {
-	storage Storage
+	storage   Storage
+	blobIndex BlobIndex
+	versionMu sync.Mutex
+
+	cacheMu       sync.Mutex
+	manifestCache map[string]*cachedManifest
+}
+
+// cachedManifest is a parsed manifest with a path index for file lookups
+type cachedManifest struct {
+	manifest  *Manifest
+	files     map[string]*ManifestFile
+	expiresAt time.Time
 }
 
 // NewArtifactManager creates a new artifact manager
-func NewArtifactManager(storage Storage) *ArtifactManager {
+// blobIndex tracks blob references; it may be nil when no database is available,
+// in which case blobs are never deleted
+func NewArtifactManager(storage Storage, blobIndex BlobIndex) *ArtifactManager {
 	return &ArtifactManager{
-		storage: storage,
+		storage:       storage,
+		blobIndex:     blobIndex,
+		manifestCache: make(map[string]*cachedManifest),
 	}
 }
 
-// StoreVersion stores an artifact version
-func (am *ArtifactManager) StoreVersion(ctx context.Context, project, app, version string, manifest *Manifest, files map[string]io.Reader) error {
-	versionPath := am.versionPath(project, app, version)
+// GetManifest retrieves manifest for a version
+func (am *ArtifactManager) GetManifest(ctx context.Context, project, app, version string) (*Manifest, error) {
+	manifestPath := filepath.Join(am.versionPath(project, app, version), "meta.yaml")
 	
-	// Check if version already exists (immutability)
-	exists, err := am.storage.Exists(ctx, filepath.Join(versionPath, "meta.yaml"))
+	reader, err := am.storage.Get(ctx, manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
 	if err != nil {
-		return fmt.Errorf("failed to check version existence: %w", err)
-	}
-	if exists {
-		return fmt.Errorf("version %s already exists and cannot be overwritten", version)
-	}
-
-	// Store files
-	for filePath, reader := range files {
-		fullPath := filepath.Join(versionPath, filePath)
-		
-		// Validate path
-		if err := ValidatePath(fullPath); err != nil {
-			return fmt.Errorf("invalid path %s: %w", filePath, err)
-		}
-		
-		// Get file size if possible
-		var size int64
-		if seeker, ok := reader.(io.Seeker); ok {
-			if currentPos, err := seeker.Seek(0, io.SeekCurrent); err == nil {
-				if endPos, err := seeker.Seek(0, io.SeekEnd); err == nil {
-					size = endPos - currentPos
-					seeker.Seek(currentPos, io.SeekStart)
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	return ParseManifest(data)
+}
+
+// CommitManifest writes meta.yaml for a version whose files are already in the blob store
+// The manifest is marked as CAS layout and its blobs are referenced by the version.
+// An existing version is replaced: its files are released only after the new
+// manifest is in place, so blobs shared by both versions are kept.
+// Concurrent commits of the same version run one after the other; the last one wins.
+func (am *ArtifactManager) CommitManifest(ctx context.Context, project, app, version string, manifest *Manifest) error {
+	manifest.Layout = LayoutCAS
+	manifestBytes, err := SerializeManifest(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+
+	unreferenced, err := am.setBlobRefs(ctx, project, app, version, manifest, func() error {
+		previous, _ := am.GetManifest(ctx, project, app, version)
+
+		// The blob references are in place (though not yet committed) before the
+		// version becomes visible, so cleanup never removes its blobs
+		if err := am.putManifest(ctx, project, app, version, manifestBytes); err != nil {
+			return err
+		}
+		am.invalidateManifest(project, app, version)
+
+		// Remove files of a replaced legacy version, which live in the version directory
+		if previous != nil && !previous.IsCAS() {
+			versionPath := am.versionPath(project, app, version)
+			for _, file := range previous.Files {
+				if err := am.storage.Delete(ctx, filepath.Join(versionPath, file.Path)); err != nil {
+					return fmt.Errorf("failed to remove replaced file %s: %w", file.Path, err)
 				}
 			}
 		}
-		
-		if err := am.storage.Put(ctx, fullPath, reader, size); err != nil {
-			return fmt.Errorf("failed to store file %s: %w", filePath, err)
-		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Store manifest
-	manifestBytes, err := SerializeManifest(manifest)
+	// Blobs only the replaced manifest used can go once the new references are committed
+	return am.deleteBlobs(ctx, unreferenced)
+}
+
+// FilePath resolves the storage path of a file within a version
+// CAS versions point at blobs; legacy versions keep files under the version directory
+func (am *ArtifactManager) FilePath(ctx context.Context, project, app, version, filePath string) (string, error) {
+	storagePath, _, err := am.ResolveFile(ctx, project, app, version, filePath)
+	return storagePath, err
+}
+
+// ResolveFile resolves the storage path of a file within a version together with
+// its manifest entry. The entry is nil for a legacy version whose manifest does
+// not list the file.
+func (am *ArtifactManager) ResolveFile(ctx context.Context, project, app, version, filePath string) (string, *ManifestFile, error) {
+	entry, err := am.cachedManifest(ctx, project, app, version)
 	if err != nil {
-		return fmt.Errorf("failed to serialize manifest: %w", err)
+		// No manifest means the version was never committed
+		return "", nil, fmt.Errorf("version %s not found", version)
 	}
-	
-	manifestPath := filepath.Join(versionPath, "meta.yaml")
-	manifestReader := io.NopCloser(io.Reader(io.Reader(strings.NewReader(string(manifestBytes)))))
-	if err := am.storage.Put(ctx, manifestPath, manifestReader, int64(len(manifestBytes))); err != nil {
-		return fmt.Errorf("failed to store manifest: %w", err)
+
+	file, ok := entry.files[filePath]
+	if !entry.manifest.IsCAS() {
+		return filepath.Join(am.versionPath(project, app, version), filePath), file, nil
 	}
 
-	return nil
+	if !ok {
+		return "", nil, fmt.Errorf("file %s not found in version %s", filePath, version)
+	}
+	return BlobPath(file.SHA256), file, nil
 }
 
-// GetManifest retrieves manifest for a version
-func (am *ArtifactManager) GetManifest(ctx context.Context, project, app, version string) (*Manifest, error) {
-	manifestPath := filepath.Join(am.versionPath(project, app, version), "meta.yaml")
-	
-	reader, err := am.storage.Get(ctx, manifestPath)
+// MigrateVersion converts a legacy version tree to blob storage
+// Each file is verified against the manifest, stored as a blob and removed from
+// the version directory. Returns false if the version was already migrated.
+func (am *ArtifactManager) MigrateVersion(ctx context.Context, project, app, version string) (bool, error) {
+	manifest, err := am.GetManifest(ctx, project, app, version)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get manifest: %w", err)
+		return false, err
+	}
+	if manifest.IsCAS() {
+		return false, nil
 	}
-	defer reader.Close()
 
-	data, err := io.ReadAll(reader)
+	versionPath := am.versionPath(project, app, version)
+	for i := range manifest.Files {
+		file := &manifest.Files[i]
+		legacyPath := filepath.Join(versionPath, file.Path)
+
+		hash, size, err := am.hashFile(ctx, legacyPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to hash file %s: %w", file.Path, err)
+		}
+		if file.SHA256 != "" && file.SHA256 != hash {
+			return false, fmt.Errorf("file %s does not match manifest: expected sha256 %s, got %s", file.Path, file.SHA256, hash)
+		}
+		file.SHA256 = hash
+		file.Size = size
+
+		reader, err := am.storage.Get(ctx, legacyPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to read file %s: %w", file.Path, err)
+		}
+		_, err = am.PutBlob(ctx, hash, reader, size)
+		reader.Close()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	manifest.Layout = LayoutCAS
+
+	// Marshal directly to keep the original build time
+	manifestBytes, err := yaml.Marshal(manifest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read manifest: %w", err)
+		return false, fmt.Errorf("failed to serialize manifest: %w", err)
 	}
 
-	return ParseManifest(data)
+	_, err = am.setBlobRefs(ctx, project, app, version, manifest, func() error {
+		if err := am.putManifest(ctx, project, app, version, manifestBytes); err != nil {
+			return err
+		}
+		am.invalidateManifest(project, app, version)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// The version is now served from blobs; remove the legacy copies
+	for _, file := range manifest.Files {
+		if err := am.storage.Delete(ctx, filepath.Join(versionPath, file.Path)); err != nil {
+			return true, fmt.Errorf("failed to remove legacy file %s: %w", file.Path, err)
+		}
+	}
+
+	return true, nil
 }
 
 // DeleteVersion deletes an artifact version
+// Blobs are only removed once no other version references them
 func (am *ArtifactManager) DeleteVersion(ctx context.Context, project, app, version string) error {
 	versionPath := am.versionPath(project, app, version)
-	return am.storage.Delete(ctx, versionPath)
+
+	return am.releaseVersion(ctx, project, app, version, func() error {
+		am.invalidateManifest(project, app, version)
+
+		// Remove meta.yaml first so the version disappears before its files do;
+		// object stores also do not delete by prefix
+		if err := am.storage.Delete(ctx, filepath.Join(versionPath, CommitMarker)); err != nil {
+			return err
+		}
+		return am.storage.Delete(ctx, versionPath)
+	})
 }
 
-// ListVersions lists all versions for an app
+// ListVersions lists the committed versions of an app
+// A version directory only counts once its meta.yaml has been written
 func (am *ArtifactManager) ListVersions(ctx context.Context, project, app string) ([]string, error) {
+	dirs, err := am.listVersionDirs(ctx, project, app)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, version := range dirs {
+		committed, err := am.storage.Exists(ctx, filepath.Join(am.versionPath(project, app, version), CommitMarker))
+		if err != nil {
+			return nil, fmt.Errorf("failed to check version %s: %w", version, err)
+		}
+		if committed {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+// ListUncommittedVersions lists version directories without meta.yaml
+// These are left behind by uploads from before commits went through staging.
+func (am *ArtifactManager) ListUncommittedVersions(ctx context.Context, project, app string) ([]string, error) {
+	dirs, err := am.listVersionDirs(ctx, project, app)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, version := range dirs {
+		committed, err := am.storage.Exists(ctx, filepath.Join(am.versionPath(project, app, version), CommitMarker))
+		if err != nil {
+			return nil, fmt.Errorf("failed to check version %s: %w", version, err)
+		}
+		if !committed {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+// listVersionDirs lists every version directory of an app, committed or not
+func (am *ArtifactManager) listVersionDirs(ctx context.Context, project, app string) ([]string, error) {
 	appPath := am.appPath(project, app)
 	
 	entries, err := am.storage.List(ctx, appPath)
@@ -112,15 +272,17 @@ func (am *ArtifactManager) ListVersions(ctx context.Context, project, app string
 	}
 
 	var versions []string
+	seen := make(map[string]bool)
 	for _, entry := range entries {
-		// Extract version from path (entries are like "version/meta.yaml" or "version/")
-		baseName := filepath.Base(entry)
-		ext := filepath.Ext(entry)
-		if baseName == "meta.yaml" || ext == "" {
-			version := filepath.Base(filepath.Dir(entry))
-			if version != "." && version != appPath {
-				versions = append(versions, version)
-			}
+		// Entries are like "project/app/version/meta.yaml" or "project/app/version"
+		relPath, err := filepath.Rel(appPath, strings.TrimSuffix(entry, "/"))
+		if err != nil || relPath == "." || strings.HasPrefix(relPath, "..") {
+			continue
+		}
+		version := strings.Split(filepath.ToSlash(relPath), "/")[0]
+		if !seen[version] {
+			seen[version] = true
+			versions = append(versions, version)
 		}
 	}
 
@@ -140,11 +302,107 @@ func (am *ArtifactManager) appPath(project, app string) string {
 // DeleteApp deletes an app and all its versions from storage
 func (am *ArtifactManager) DeleteApp(ctx context.Context, project, app string) error {
 	appPath := am.appPath(project, app)
-	return am.storage.Delete(ctx, appPath)
+	am.invalidatePrefix(appPath)
+	if err := am.storage.Delete(ctx, appPath); err != nil {
+		return err
+	}
+	return am.releaseBlobs(ctx, project, app)
 }
 
 // DeleteProject deletes a project and all its apps and versions from storage
 func (am *ArtifactManager) DeleteProject(ctx context.Context, project string) error {
-	return am.storage.Delete(ctx, project)
+	am.invalidatePrefix(project)
+	if err := am.storage.Delete(ctx, project); err != nil {
+		return err
+	}
+	return am.releaseBlobs(ctx, project, "")
+}
+
+// putManifest writes meta.yaml for a version
+// The file is written under the staging prefix and then promoted, so readers
+// see either the previous manifest or the complete new one.
+func (am *ArtifactManager) putManifest(ctx context.Context, project, app, version string, manifestBytes []byte) error {
+	stagingID, err := newStagingID()
+	if err != nil {
+		return err
+	}
+	stagingDir := filepath.Join(StagingRoot, stagingID)
+	stagedPath := filepath.Join(stagingDir, CommitMarker)
+	if err := am.storage.Put(ctx, stagedPath, strings.NewReader(string(manifestBytes)), int64(len(manifestBytes))); err != nil {
+		return fmt.Errorf("failed to store manifest: %w", err)
+	}
+	defer am.storage.Delete(ctx, stagingDir)
+
+	manifestPath := filepath.Join(am.versionPath(project, app, version), CommitMarker)
+	if err := am.storage.Promote(ctx, stagedPath, manifestPath); err != nil {
+		am.storage.Delete(ctx, stagedPath)
+		return fmt.Errorf("failed to commit manifest: %w", err)
+	}
+	return nil
+}
+
+// hashFile computes the SHA256 and size of a stored file
+func (am *ArtifactManager) hashFile(ctx context.Context, path string) (string, int64, error) {
+	reader, err := am.storage.Get(ctx, path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), size, nil
+}
+
+// cachedManifest returns a recently parsed manifest, reading it from storage if needed
+func (am *ArtifactManager) cachedManifest(ctx context.Context, project, app, version string) (*cachedManifest, error) {
+	key := am.versionPath(project, app, version)
+
+	am.cacheMu.Lock()
+	entry, ok := am.manifestCache[key]
+	am.cacheMu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry, nil
+	}
+
+	manifest, err := am.GetManifest(ctx, project, app, version)
+	if err != nil {
+		return nil, err
+	}
+
+	entry = &cachedManifest{
+		manifest:  manifest,
+		files:     make(map[string]*ManifestFile, len(manifest.Files)),
+		expiresAt: time.Now().Add(manifestCacheTTL),
+	}
+	for i := range manifest.Files {
+		entry.files[manifest.Files[i].Path] = &manifest.Files[i]
+	}
+
+	am.cacheMu.Lock()
+	am.manifestCache[key] = entry
+	am.cacheMu.Unlock()
+	return entry, nil
+}
+
+// invalidateManifest drops a version from the manifest cache
+func (am *ArtifactManager) invalidateManifest(project, app, version string) {
+	am.cacheMu.Lock()
+	delete(am.manifestCache, am.versionPath(project, app, version))
+	am.cacheMu.Unlock()
+}
+
+// invalidatePrefix drops every cached manifest under a project or app path
+func (am *ArtifactManager) invalidatePrefix(prefix string) {
+	am.cacheMu.Lock()
+	for key := range am.manifestCache {
+		if key == prefix || strings.HasPrefix(key, prefix+string(filepath.Separator)) {
+			delete(am.manifestCache, key)
+		}
+	}
+	am.cacheMu.Unlock()
 }
 
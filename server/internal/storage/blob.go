@@ -0,0 +1,246 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+)
+
+// BlobRoot is the storage prefix holding content-addressed blobs
+// It starts with a dot so storage scans never mistake it for a project
+const BlobRoot = ".blobs/sha256"
+
+// BlobIndex tracks which versions reference each blob so that a blob shared by
+// several versions is only removed once the last of them is gone
+// It is implemented by database.BlobRepository.
+type BlobIndex interface {
+	// SetRefs makes a version reference exactly the given blobs (sha256 -> size)
+	// while no other commit or delete of the version runs. commit runs before the
+	// references are committed, which are rolled back if it fails; it gets the
+	// blobs nothing referenced before, whose data may have been removed. Returns
+	// previously referenced blobs that became unreferenced.
+	SetRefs(project, app, version string, blobs map[string]int64, commit func(revived []string) error) ([]string, error)
+	// ReleaseRefs drops the references held by matching versions (empty app or
+	// version matches all) and returns the blobs that became unreferenced. For a
+	// single version, remove runs first while the version is locked.
+	ReleaseRefs(project, app, version string, remove func() error) ([]string, error)
+	// DeleteUnreferenced removes the record of a blob nobody references and
+	// runs deleteObject before any commit can reference the blob again
+	DeleteUnreferenced(hash string, deleteObject func() error) (bool, error)
+}
+
+// MissingBlobsError is returned by a commit whose manifest references blobs
+// that were removed from storage (e.g. garbage collected) after the upload
+// found them present. Uploading them again and retrying the commit succeeds.
+type MissingBlobsError struct {
+	Hashes []string
+}
+
+func (e *MissingBlobsError) Error() string {
+	return fmt.Sprintf("%d blobs referenced by the manifest are missing from storage", len(e.Hashes))
+}
+
+// Mismatches lists the manifest files whose blobs are missing
+func (e *MissingBlobsError) Mismatches(manifest *Manifest) []FileMismatch {
+	missing := make(map[string]bool, len(e.Hashes))
+	for _, hash := range e.Hashes {
+		missing[hash] = true
+	}
+
+	mismatches := make([]FileMismatch, 0, len(e.Hashes))
+	for _, file := range manifest.Files {
+		if missing[file.SHA256] {
+			mismatches = append(mismatches, FileMismatch{
+				Path:           file.Path,
+				Reason:         MismatchMissing,
+				ExpectedSHA256: file.SHA256,
+				ExpectedSize:   file.Size,
+			})
+		}
+	}
+	return mismatches
+}
+
+// BlobPath returns the storage path of a blob: .blobs/sha256/{ab}/{hash}
+func BlobPath(hash string) string {
+	return path.Join(BlobRoot, hash[:2], hash)
+}
+
+// IsBlobHash reports whether s is a lowercase hex SHA256 digest
+func IsBlobHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// HasBlob checks whether a blob is present in storage
+func (am *ArtifactManager) HasBlob(ctx context.Context, hash string) (bool, error) {
+	if !IsBlobHash(hash) {
+		return false, nil
+	}
+	return am.storage.Exists(ctx, BlobPath(hash))
+}
+
+// PutBlob stores a blob unless it already exists
+// Returns true if the blob was written, false if it was deduplicated
+func (am *ArtifactManager) PutBlob(ctx context.Context, hash string, reader io.Reader, size int64) (bool, error) {
+	if !IsBlobHash(hash) {
+		return false, fmt.Errorf("invalid blob hash: %s", hash)
+	}
+
+	exists, err := am.storage.Exists(ctx, BlobPath(hash))
+	if err != nil {
+		return false, fmt.Errorf("failed to check blob existence: %w", err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	// Write to staging first so a partially written blob is never visible
+	stagingID, err := newStagingID()
+	if err != nil {
+		return false, err
+	}
+	stagedPath := path.Join(StagingRoot, stagingID, hash)
+	if err := am.storage.Put(ctx, stagedPath, reader, size); err != nil {
+		return false, fmt.Errorf("failed to store blob %s: %w", hash, err)
+	}
+	if err := am.storage.Promote(ctx, stagedPath, BlobPath(hash)); err != nil {
+		return false, fmt.Errorf("failed to store blob %s: %w", hash, err)
+	}
+	am.storage.Delete(ctx, path.Dir(stagedPath))
+	return true, nil
+}
+
+// DeleteBlob removes a blob from storage if no version references it any more
+func (am *ArtifactManager) DeleteBlob(ctx context.Context, hash string) error {
+	deleteObject := func() error {
+		return am.storage.Delete(ctx, BlobPath(hash))
+	}
+	if am.blobIndex == nil {
+		return deleteObject()
+	}
+	_, err := am.blobIndex.DeleteUnreferenced(hash, deleteObject)
+	return err
+}
+
+// setBlobRefs makes a version reference the blobs of its manifest and runs
+// commit while no other commit or delete of the version runs
+// Blobs nothing referenced before are checked to still be in storage first, as
+// garbage collection may have removed them after the upload found them present;
+// a *MissingBlobsError is returned if any is gone. Returns the blobs the version
+// no longer references that became unused; the caller deletes them once the new
+// references are committed.
+func (am *ArtifactManager) setBlobRefs(ctx context.Context, project, app, version string, manifest *Manifest, commit func() error) ([]string, error) {
+	if am.blobIndex == nil {
+		// Without a blob index there is nothing shared to protect across
+		// instances, so only this process is serialized
+		am.versionMu.Lock()
+		defer am.versionMu.Unlock()
+		return nil, commit()
+	}
+
+	var commitErr error
+	unreferenced, err := am.blobIndex.SetRefs(project, app, version, manifestBlobs(manifest), func(revived []string) error {
+		commitErr = am.checkBlobs(ctx, revived)
+		if commitErr == nil {
+			commitErr = commit()
+		}
+		return commitErr
+	})
+	if commitErr != nil {
+		return nil, commitErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to reference blobs: %w", err)
+	}
+	return unreferenced, nil
+}
+
+// checkBlobs returns a *MissingBlobsError if any of the blobs is not in storage
+func (am *ArtifactManager) checkBlobs(ctx context.Context, hashes []string) error {
+	var missing []string
+	for _, hash := range hashes {
+		exists, err := am.HasBlob(ctx, hash)
+		if err != nil {
+			return fmt.Errorf("failed to check blob existence: %w", err)
+		}
+		if !exists {
+			missing = append(missing, hash)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingBlobsError{Hashes: missing}
+	}
+	return nil
+}
+
+// releaseVersion runs remove while no other commit or delete of the version
+// runs, then drops the version's blob references and deletes the blobs that
+// are no longer used
+func (am *ArtifactManager) releaseVersion(ctx context.Context, project, app, version string, remove func() error) error {
+	if am.blobIndex == nil {
+		am.versionMu.Lock()
+		defer am.versionMu.Unlock()
+		return remove()
+	}
+
+	var removeErr error
+	unreferenced, err := am.blobIndex.ReleaseRefs(project, app, version, func() error {
+		removeErr = remove()
+		return removeErr
+	})
+	if removeErr != nil {
+		return removeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release blob references: %w", err)
+	}
+	return am.deleteBlobs(ctx, unreferenced)
+}
+
+// releaseBlobs drops the blob references of every version of an app (or of a
+// project, for an empty app) and deletes the blobs that are no longer used
+func (am *ArtifactManager) releaseBlobs(ctx context.Context, project, app string) error {
+	if am.blobIndex == nil {
+		return nil
+	}
+
+	unreferenced, err := am.blobIndex.ReleaseRefs(project, app, "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to release blob references: %w", err)
+	}
+	return am.deleteBlobs(ctx, unreferenced)
+}
+
+// deleteBlobs deletes blobs that were reported unreferenced
+func (am *ArtifactManager) deleteBlobs(ctx context.Context, hashes []string) error {
+	var firstErr error
+	for _, hash := range hashes {
+		if err := am.DeleteBlob(ctx, hash); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to delete blob %s: %w", hash, err)
+		}
+	}
+	return firstErr
+}
+
+// manifestBlobs returns the sha256 -> size map of a manifest's files
+func manifestBlobs(manifest *Manifest) map[string]int64 {
+	blobs := make(map[string]int64, len(manifest.Files))
+	for _, file := range manifest.Files {
+		blobs[file.SHA256] = file.Size
+	}
+	return blobs
+}
@@ -0,0 +1,369 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha256Hex(content string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+}
+
+func TestArtifactManager_PutBlobDeduplicates(t *testing.T) {
+	localStorage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	am := NewArtifactManager(localStorage, nil)
+	ctx := context.Background()
+
+	content := "shared content"
+	hash := sha256Hex(content)
+
+	written, err := am.PutBlob(ctx, hash, strings.NewReader(content), int64(len(content)))
+	if err != nil || !written {
+		t.Fatalf("PutBlob() = %v, %v, want true, nil", written, err)
+	}
+	written, err = am.PutBlob(ctx, hash, strings.NewReader(content), int64(len(content)))
+	if err != nil || written {
+		t.Fatalf("PutBlob() second call = %v, %v, want false, nil", written, err)
+	}
+
+	if _, err := am.PutBlob(ctx, "not-a-hash", strings.NewReader(content), 0); err == nil {
+		t.Error("PutBlob() with invalid hash should fail")
+	}
+}
+
+func TestArtifactManager_CommitManifestResolvesBlobs(t *testing.T) {
+	localStorage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	am := NewArtifactManager(localStorage, nil)
+	ctx := context.Background()
+
+	content := "binary"
+	hash := sha256Hex(content)
+	if _, err := am.PutBlob(ctx, hash, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("Failed to put blob: %v", err)
+	}
+
+	manifest := &Manifest{
+		Project: "p1",
+		App:     "a1",
+		Version: "v1",
+		Files:   []ManifestFile{{Path: "bin/app", SHA256: hash, Size: int64(len(content))}},
+	}
+	if err := am.CommitManifest(ctx, "p1", "a1", "v1", manifest); err != nil {
+		t.Fatalf("Failed to commit manifest: %v", err)
+	}
+
+	stored, err := am.GetManifest(ctx, "p1", "a1", "v1")
+	if err != nil {
+		t.Fatalf("Failed to get manifest: %v", err)
+	}
+	if !stored.IsCAS() {
+		t.Errorf("Expected layout %q, got %q", LayoutCAS, stored.Layout)
+	}
+
+	path, err := am.FilePath(ctx, "p1", "a1", "v1", "bin/app")
+	if err != nil {
+		t.Fatalf("Failed to resolve file: %v", err)
+	}
+	if path != BlobPath(hash) {
+		t.Errorf("Expected %s, got %s", BlobPath(hash), path)
+	}
+
+	if _, err := am.FilePath(ctx, "p1", "a1", "v1", "missing"); err == nil {
+		t.Error("FilePath() for a file outside the manifest should fail")
+	}
+}
+
+func TestArtifactManager_MigrateVersion(t *testing.T) {
+	localStorage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	am := NewArtifactManager(localStorage, nil)
+	ctx := context.Background()
+
+	content := "legacy file"
+	legacyPath := filepath.Join("p1", "a1", "v1", "app.jar")
+	if err := localStorage.Put(ctx, legacyPath, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("Failed to put legacy file: %v", err)
+	}
+	manifest := &Manifest{
+		Project:   "p1",
+		App:       "a1",
+		Version:   "v1",
+		BuildTime: "2025-01-01T00:00:00Z",
+		Files:     []ManifestFile{{Path: "app.jar", SHA256: sha256Hex(content), Size: int64(len(content))}},
+	}
+	// CommitManifest only writes CAS manifests, so write the legacy one directly
+	manifestBytes, err := SerializeManifest(manifest)
+	if err != nil {
+		t.Fatalf("Failed to serialize manifest: %v", err)
+	}
+	if err := am.putManifest(ctx, "p1", "a1", "v1", manifestBytes); err != nil {
+		t.Fatalf("Failed to store legacy manifest: %v", err)
+	}
+
+	// Legacy versions resolve to the version directory
+	path, err := am.FilePath(ctx, "p1", "a1", "v1", "app.jar")
+	if err != nil || path != legacyPath {
+		t.Fatalf("FilePath() = %s, %v, want %s", path, err, legacyPath)
+	}
+
+	migrated, err := am.MigrateVersion(ctx, "p1", "a1", "v1")
+	if err != nil || !migrated {
+		t.Fatalf("MigrateVersion() = %v, %v, want true, nil", migrated, err)
+	}
+
+	if exists, _ := localStorage.Exists(ctx, legacyPath); exists {
+		t.Error("Legacy file should be removed after migration")
+	}
+	if exists, _ := localStorage.Exists(ctx, BlobPath(sha256Hex(content))); !exists {
+		t.Error("Blob should exist after migration")
+	}
+
+	path, err = am.FilePath(ctx, "p1", "a1", "v1", "app.jar")
+	if err != nil || path != BlobPath(sha256Hex(content)) {
+		t.Errorf("FilePath() after migration = %s, %v", path, err)
+	}
+
+	// Migrating twice is a no-op
+	migrated, err = am.MigrateVersion(ctx, "p1", "a1", "v1")
+	if err != nil || migrated {
+		t.Errorf("MigrateVersion() second call = %v, %v, want false, nil", migrated, err)
+	}
+}
+
+func TestArtifactManager_PromoteStagedBlob(t *testing.T) {
+	localStorage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	am := NewArtifactManager(localStorage, nil)
+	ctx := context.Background()
+
+	content := "staged content"
+	hash := sha256Hex(content)
+	if err := am.StageBlob(ctx, "session1", hash, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("Failed to stage blob: %v", err)
+	}
+	if exists, _ := am.HasBlob(ctx, hash); exists {
+		t.Fatal("Staged blob should not be visible in the blob store")
+	}
+
+	if err := am.PromoteStagedBlob(ctx, "session1", hash, int64(len(content))); err != nil {
+		t.Fatalf("Failed to promote blob: %v", err)
+	}
+	if exists, _ := am.HasBlob(ctx, hash); !exists {
+		t.Error("Promoted blob should exist in the blob store")
+	}
+	if exists, _ := localStorage.Exists(ctx, StagingPath("session1", hash)); exists {
+		t.Error("Staged copy should be removed after promotion")
+	}
+
+	if err := am.DiscardStaging(ctx, "session1", nil); err != nil {
+		t.Errorf("Failed to discard staging: %v", err)
+	}
+}
+
+func TestArtifactManager_VerifyUpload(t *testing.T) {
+	localStorage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	am := NewArtifactManager(localStorage, nil)
+	ctx := context.Background()
+
+	stored := "already stored"
+	if _, err := am.PutBlob(ctx, sha256Hex(stored), strings.NewReader(stored), int64(len(stored))); err != nil {
+		t.Fatalf("Failed to put blob: %v", err)
+	}
+
+	// Staged under the hash of the full content, but only part of it arrived
+	full := "full content"
+	truncated := "full"
+	if err := am.StageBlob(ctx, "session1", sha256Hex(full), strings.NewReader(truncated), int64(len(truncated))); err != nil {
+		t.Fatalf("Failed to stage blob: %v", err)
+	}
+	// Recorded as staged, but not in storage
+	staged := map[string]int64{sha256Hex(full): int64(len(truncated)), sha256Hex("lost"): 4}
+
+	manifest := &Manifest{Files: []ManifestFile{
+		{Path: "ok.txt", SHA256: sha256Hex(stored), Size: int64(len(stored))},
+		{Path: "wrong-size.txt", SHA256: sha256Hex(stored), Size: 1},
+		{Path: "truncated.txt", SHA256: sha256Hex(full), Size: int64(len(full))},
+		{Path: "missing.txt", SHA256: sha256Hex("missing"), Size: 7},
+		{Path: "ok.txt", SHA256: sha256Hex(stored), Size: int64(len(stored))},
+		{Path: "bad-hash.txt", SHA256: "abc", Size: 1},
+		{Path: "lost.txt", SHA256: sha256Hex("lost"), Size: 4},
+	}}
+
+	badFiles, err := am.VerifyUpload(ctx, "session1", manifest, staged)
+	if err != nil {
+		t.Fatalf("VerifyUpload() error = %v", err)
+	}
+
+	want := map[string]string{
+		"wrong-size.txt": MismatchSize,
+		"truncated.txt":  MismatchSize,
+		"missing.txt":    MismatchMissing,
+		"ok.txt":         MismatchDuplicate,
+		"bad-hash.txt":   MismatchInvalid,
+		"lost.txt":       MismatchMissing,
+	}
+	if len(badFiles) != len(want) {
+		t.Fatalf("Expected %d bad files, got %d: %+v", len(want), len(badFiles), badFiles)
+	}
+	for _, file := range badFiles {
+		if want[file.Path] != file.Reason {
+			t.Errorf("File %s: expected reason %s, got %s", file.Path, want[file.Path], file.Reason)
+		}
+	}
+}
+
+func TestArtifactManager_ListVersionsCommittedOnly(t *testing.T) {
+	localStorage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	am := NewArtifactManager(localStorage, nil)
+	ctx := context.Background()
+
+	content := "payload"
+	if _, err := am.PutBlob(ctx, sha256Hex(content), strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("PutBlob() error = %v", err)
+	}
+	manifest := &Manifest{
+		Project: "p1",
+		App:     "a1",
+		Version: "v1",
+		Files:   []ManifestFile{{Path: "app.jar", SHA256: sha256Hex(content), Size: int64(len(content))}},
+	}
+	if err := am.CommitManifest(ctx, "p1", "a1", "v1", manifest); err != nil {
+		t.Fatalf("CommitManifest() error = %v", err)
+	}
+
+	// A version directory without meta.yaml is an unfinished upload
+	partial := filepath.Join("p1", "a1", "v2", "app.jar")
+	if err := localStorage.Put(ctx, partial, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("Failed to put partial file: %v", err)
+	}
+
+	versions, err := am.ListVersions(ctx, "p1", "a1")
+	if err != nil || len(versions) != 1 || versions[0] != "v1" {
+		t.Errorf("ListVersions() = %v, %v, want [v1]", versions, err)
+	}
+	uncommitted, err := am.ListUncommittedVersions(ctx, "p1", "a1")
+	if err != nil || len(uncommitted) != 1 || uncommitted[0] != "v2" {
+		t.Errorf("ListUncommittedVersions() = %v, %v, want [v2]", uncommitted, err)
+	}
+	if _, err := am.FilePath(ctx, "p1", "a1", "v2", "app.jar"); err == nil {
+		t.Error("FilePath() should fail for an uncommitted version")
+	}
+
+	// Staging leaves nothing behind once the commit is done
+	if staged, _ := localStorage.List(ctx, StagingRoot); len(staged) > 1 {
+		t.Errorf("Staging should be empty after commit, got %v", staged)
+	}
+}
+
+// memoryBlobIndex is an in-memory BlobIndex counting references per blob
+type memoryBlobIndex struct {
+	refs map[string]int
+}
+
+func (m *memoryBlobIndex) SetRefs(project, app, version string, blobs map[string]int64, commit func(revived []string) error) ([]string, error) {
+	revived := make([]string, 0, len(blobs))
+	for hash := range blobs {
+		if m.refs[hash] == 0 {
+			revived = append(revived, hash)
+		}
+	}
+	if err := commit(revived); err != nil {
+		return nil, err
+	}
+	for hash := range blobs {
+		m.refs[hash]++
+	}
+	return nil, nil
+}
+
+func (m *memoryBlobIndex) ReleaseRefs(project, app, version string, remove func() error) ([]string, error) {
+	if remove != nil {
+		return nil, remove()
+	}
+	return nil, nil
+}
+
+func (m *memoryBlobIndex) DeleteUnreferenced(hash string, deleteObject func() error) (bool, error) {
+	if m.refs[hash] > 0 {
+		return false, nil
+	}
+	return true, deleteObject()
+}
+
+func TestArtifactManager_CommitManifestRejectsCollectedBlobs(t *testing.T) {
+	localStorage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	index := &memoryBlobIndex{refs: make(map[string]int)}
+	am := NewArtifactManager(localStorage, index)
+	ctx := context.Background()
+
+	content := "collected"
+	hash := sha256Hex(content)
+	if _, err := am.PutBlob(ctx, hash, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("PutBlob() error = %v", err)
+	}
+	// Garbage collection removes the unreferenced blob after the upload saw it
+	if err := am.DeleteBlob(ctx, hash); err != nil {
+		t.Fatalf("DeleteBlob() error = %v", err)
+	}
+
+	manifest := &Manifest{
+		Project: "p1",
+		App:     "a1",
+		Version: "v1",
+		Files:   []ManifestFile{{Path: "app.jar", SHA256: hash, Size: int64(len(content))}},
+	}
+	err = am.CommitManifest(ctx, "p1", "a1", "v1", manifest)
+	var missingErr *MissingBlobsError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("CommitManifest() error = %v, want *MissingBlobsError", err)
+	}
+	if mismatches := missingErr.Mismatches(manifest); len(mismatches) != 1 || mismatches[0].Path != "app.jar" || mismatches[0].Reason != MismatchMissing {
+		t.Errorf("Mismatches() = %+v, want app.jar missing", mismatches)
+	}
+	if _, err := am.GetManifest(ctx, "p1", "a1", "v1"); err == nil {
+		t.Error("A commit with missing blobs should not write the manifest")
+	}
+
+	// Uploaded again, the commit goes through and the referenced blob is kept
+	if _, err := am.PutBlob(ctx, hash, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("PutBlob() error = %v", err)
+	}
+	if err := am.CommitManifest(ctx, "p1", "a1", "v1", manifest); err != nil {
+		t.Fatalf("CommitManifest() error = %v", err)
+	}
+	if err := am.DeleteBlob(ctx, hash); err != nil {
+		t.Fatalf("DeleteBlob() error = %v", err)
+	}
+	if exists, _ := am.HasBlob(ctx, hash); !exists {
+		t.Error("DeleteBlob() removed a referenced blob")
+	}
+}
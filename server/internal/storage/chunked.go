@@ -0,0 +1,106 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/kk/kkartifact-server/internal/database"
+)
+
+// Chunk size limits for chunked uploads
+// The minimum is the smallest part S3 accepts; files are limited to MaxChunks chunks.
+const (
+	MinChunkSize     int64 = 5 << 20
+	DefaultChunkSize int64 = 8 << 20
+	MaxChunkSize     int64 = 512 << 20
+	MaxChunks        int64 = 10000
+)
+
+// ChunkCount returns the number of chunks a file of the given size is split into
+func ChunkCount(size, chunkSize int64) int64 {
+	if size == 0 {
+		return 1
+	}
+	return (size + chunkSize - 1) / chunkSize
+}
+
+// ChunkLength returns the expected length of a chunk (the last one may be shorter)
+func ChunkLength(index, size, chunkSize int64) int64 {
+	if remaining := size - index*chunkSize; remaining < chunkSize {
+		return remaining
+	}
+	return chunkSize
+}
+
+// StartChunkedBlob starts assembling a staged blob from chunks
+// Returns the storage backend's multipart upload ID
+func (am *ArtifactManager) StartChunkedBlob(ctx context.Context, sessionID, hash string) (string, error) {
+	if !IsBlobHash(hash) {
+		return "", fmt.Errorf("invalid blob hash: %s", hash)
+	}
+	return am.storage.NewMultipartUpload(ctx, StagingPath(sessionID, hash))
+}
+
+// PutChunk stores one chunk of a staged blob; chunk index 0 is storage part 1
+func (am *ArtifactManager) PutChunk(ctx context.Context, sessionID, hash, storageUploadID string, index int, reader io.Reader, size int64) (string, error) {
+	etag, err := am.storage.PutPart(ctx, StagingPath(sessionID, hash), storageUploadID, index+1, reader, size)
+	if err != nil {
+		return "", fmt.Errorf("failed to store chunk %d of %s: %w", index, hash, err)
+	}
+	return etag, nil
+}
+
+// CompleteChunkedBlob assembles the chunks and checks the result against the expected hash
+// A blob that does not match is removed from staging.
+func (am *ArtifactManager) CompleteChunkedBlob(ctx context.Context, sessionID, hash, storageUploadID string, parts []Part) error {
+	stagedPath := StagingPath(sessionID, hash)
+	if err := am.storage.CompleteMultipartUpload(ctx, stagedPath, storageUploadID, parts); err != nil {
+		return fmt.Errorf("failed to assemble %s: %w", hash, err)
+	}
+
+	actual, _, err := am.hashFile(ctx, stagedPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash assembled file %s: %w", hash, err)
+	}
+	if actual != hash {
+		am.storage.Delete(ctx, stagedPath)
+		return &ChunkMismatchError{Expected: hash, Actual: actual}
+	}
+	return nil
+}
+
+// ChunkMismatchError reports an assembled file whose content does not match its hash
+type ChunkMismatchError struct {
+	Expected string
+	Actual   string
+}
+
+// Error implements the error interface
+func (e *ChunkMismatchError) Error() string {
+	return fmt.Sprintf("assembled file does not match: expected sha256 %s, got %s", e.Expected, e.Actual)
+}
+
+// AbortChunkedUploads discards the unfinished chunked uploads of an upload session
+func (am *ArtifactManager) AbortChunkedUploads(ctx context.Context, uploadRepo *database.ChunkedUploadRepository, sessionID string) error {
+	uploads, err := uploadRepo.ListActiveBySession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, upload := range uploads {
+		if _, err := uploadRepo.SetStatus(upload.ID, database.UploadSessionAborted); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := am.storage.AbortMultipartUpload(ctx, StagingPath(sessionID, upload.SHA256), upload.StorageUploadID); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to abort chunked upload %s: %w", upload.ID, err)
+		}
+	}
+	return firstErr
+}
@@ -8,6 +8,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/kk/kkartifact-server/internal/database"
 	"github.com/kk/kkartifact-server/internal/util"
@@ -27,53 +28,53 @@ func NewCleanupManager(artifactManager *ArtifactManager, db *database.DB) *Clean
 	}
 }
 
-// CleanupOldVersions cleans up old versions beyond the retention limit
-// This function deletes versions from both storage and database
-func (cm *CleanupManager) CleanupOldVersions(ctx context.Context, project, app string, retentionLimit int) error {
+// CleanupOldVersions cleans up the versions the retention policy does not keep
+// This function deletes versions from both storage and database, and returns
+// the versions it deleted from the database
+func (cm *CleanupManager) CleanupOldVersions(ctx context.Context, project, app string, policy *RetentionPolicy) ([]string, error) {
 	// Get project and app from database
 	projectRepo := database.NewProjectRepository(cm.db)
 	projectModel, err := projectRepo.CreateOrGet(project)
 	if err != nil {
-		return fmt.Errorf("failed to get project: %w", err)
+		return nil, fmt.Errorf("failed to get project: %w", err)
 	}
 
 	appRepo := database.NewAppRepository(cm.db)
 	appModel, err := appRepo.CreateOrGet(projectModel.ID, app)
 	if err != nil {
-		return fmt.Errorf("failed to get app: %w", err)
+		return nil, fmt.Errorf("failed to get app: %w", err)
 	}
 
 	versionRepo := database.NewVersionRepository(cm.db)
 
 	// Step 1: Clean up incomplete uploads (versions in storage without meta.yaml or without DB record)
-	// This should be done first, before checking retention limits
+	// This should be done first, before applying the retention policy
 	if err := cm.cleanupIncompleteVersions(ctx, project, app, appModel.ID, versionRepo); err != nil {
 		// Log error but continue - this is not critical
 		fmt.Printf("Warning: failed to cleanup incomplete versions for %s/%s: %v\n", project, app, err)
 	}
 
-	// Step 2: Clean up old versions beyond retention limit
-	// Get all versions from database to count
-	allVersions, err := versionRepo.ListByApp(appModel.ID, 10000, 0) // Get all versions
-	if err != nil {
-		return fmt.Errorf("failed to list versions from database: %w", err)
+	// Step 2: Clean up versions the retention policy does not keep
+	if !policy.Active() {
+		return nil, nil
 	}
 
-	if len(allVersions) <= retentionLimit {
-		return nil
+	decisions, err := cm.retentionDecisions(appModel.ID, policy)
+	if err != nil {
+		return nil, err
 	}
 
-	// Calculate how many to delete
-	toDelete := len(allVersions) - retentionLimit
-
-	// Get oldest versions (from database, sorted by created_at ASC)
-	oldestVersions, err := versionRepo.GetOldestVersions(appModel.ID, toDelete)
-	if err != nil {
-		return fmt.Errorf("failed to get oldest versions: %w", err)
+	// Delete oldest first; decisions are newest first
+	var toDelete []*RetentionVersion
+	for i := len(decisions) - 1; i >= 0; i-- {
+		if !decisions[i].Keep {
+			toDelete = append(toDelete, decisions[i].Version)
+		}
 	}
 
 	// Delete from both storage and database
-	for _, version := range oldestVersions {
+	var deleted []string
+	for _, version := range toDelete {
 		// Delete from storage first
 		if err := cm.artifactManager.DeleteVersion(ctx, project, app, version.Hash); err != nil {
 			// Log error but continue - storage might not exist
@@ -88,6 +89,134 @@ func (cm *CleanupManager) CleanupOldVersions(ctx context.Context, project, app s
 				fmt.Printf("Failed to delete version %s from database: %v\n", version.Hash, err)
 			}
 			// Continue even if database delete fails
+			continue
+		}
+		deleted = append(deleted, version.Hash)
+	}
+
+	return deleted, nil
+}
+
+// RetentionPreview lists the versions of an app a retention policy prunes
+type RetentionPreview struct {
+	Project  string           `json:"project"`
+	App      string           `json:"app"`
+	Policy   *RetentionPolicy `json:"policy"`
+	Versions []PrunedVersion  `json:"versions"`
+	Bytes    int64            `json:"bytes"` // freed by pruning all of them
+}
+
+// PrunedVersion is a version a retention policy prunes
+type PrunedVersion struct {
+	Version   string    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Rule      string    `json:"rule"`   // the rules that select it
+	Reason    string    `json:"reason"` // e.g. "not among the 10 newest versions"
+	Bytes     int64     `json:"bytes"`  // freed by deleting it
+}
+
+// PreviewRetention returns the versions of an app a retention policy would prune, without deleting anything
+// Versions are deleted oldest first, so a blob shared by several pruned versions
+// is counted for the newest of them, whose deletion frees it. Blobs still used by
+// kept versions or other apps free nothing.
+func (cm *CleanupManager) PreviewRetention(project, app string, appID int, policy *RetentionPolicy) (*RetentionPreview, error) {
+	preview := &RetentionPreview{
+		Project:  project,
+		App:      app,
+		Policy:   policy,
+		Versions: []PrunedVersion{},
+	}
+	if !policy.Active() {
+		return preview, nil
+	}
+
+	decisions, err := cm.retentionDecisions(appID, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decisions are newest first
+	index := make(map[string]int)
+	var hashes []string
+	for _, decision := range decisions {
+		if decision.Keep {
+			continue
+		}
+		index[decision.Version.Hash] = len(preview.Versions)
+		hashes = append(hashes, decision.Version.Hash)
+		preview.Versions = append(preview.Versions, PrunedVersion{
+			Version:   decision.Version.Hash,
+			CreatedAt: decision.Version.CreatedAt,
+			Rule:      decision.Rule,
+			Reason:    decision.Reason,
+		})
+	}
+	if len(hashes) == 0 {
+		return preview, nil
+	}
+
+	refs, err := database.NewBlobRepository(cm.db).ListExclusiveRefs(project, app, hashes)
+	if err != nil {
+		return nil, err
+	}
+	freedBy := make(map[string]database.BlobRef)
+	for _, ref := range refs {
+		if current, ok := freedBy[ref.SHA256]; !ok || index[ref.Version] < index[current.Version] {
+			freedBy[ref.SHA256] = ref
+		}
+	}
+	for _, ref := range freedBy {
+		preview.Versions[index[ref.Version]].Bytes += ref.Size
+		preview.Bytes += ref.Size
+	}
+	return preview, nil
+}
+
+// retentionDecisions applies a retention policy to the versions of an app
+func (cm *CleanupManager) retentionDecisions(appID int, policy *RetentionPolicy) ([]RetentionDecision, error) {
+	versionRepo := database.NewVersionRepository(cm.db)
+	dbVersions, err := versionRepo.ListByApp(appID, 10000, 0) // Get all versions
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions from database: %w", err)
+	}
+	labels, err := versionRepo.ListLabelsByApp(appID)
+	if err != nil {
+		return nil, err
+	}
+	channels, err := database.NewChannelRepository(cm.db).ChannelsByVersion(appID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]*RetentionVersion, len(dbVersions))
+	for i, v := range dbVersions {
+		versions[i] = &RetentionVersion{
+			Hash:      v.Hash,
+			CreatedAt: v.CreatedAt,
+			Published: v.IsPublished,
+			Pinned:    v.Pinned,
+			Channels:  channels[v.Hash],
+			Labels:    labels[v.ID],
+		}
+	}
+	return policy.Evaluate(versions, time.Now()), nil
+}
+
+// CleanupUnreferencedBlobs removes blobs left behind with no references
+// Blobs are normally deleted together with their last version; this catches
+// deletions that were interrupted between releasing references and removing data
+func (cm *CleanupManager) CleanupUnreferencedBlobs(ctx context.Context) error {
+	blobRepo := database.NewBlobRepository(cm.db)
+	hashes, err := blobRepo.ListUnreferenced(1000)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if err := cm.artifactManager.DeleteBlob(ctx, hash); err != nil {
+			if util.IsDebugMode() {
+				fmt.Printf("Failed to delete unreferenced blob %s: %v\n", hash, err)
+			}
 		}
 	}
 
@@ -96,12 +225,26 @@ func (cm *CleanupManager) CleanupOldVersions(ctx context.Context, project, app s
 
 // cleanupIncompleteVersions cleans up versions in storage that don't have meta.yaml or don't exist in database
 func (cm *CleanupManager) cleanupIncompleteVersions(ctx context.Context, project, app string, appID int, versionRepo *database.VersionRepository) error {
-	// Get all versions from storage
-	storageVersions, err := cm.artifactManager.ListVersions(ctx, project, app)
+	// Version directories without meta.yaml are incomplete uploads - delete them
+	uncommitted, err := cm.artifactManager.ListUncommittedVersions(ctx, project, app)
 	if err != nil {
 		// If listing fails (e.g., app doesn't exist in storage), that's okay
 		return nil
 	}
+	for _, version := range uncommitted {
+		fmt.Printf("Cleaning up incomplete version (no meta.yaml): %s/%s/%s\n", project, app, version)
+		if err := cm.artifactManager.DeleteVersion(ctx, project, app, version); err != nil {
+			fmt.Printf("Failed to delete incomplete version %s/%s/%s: %v\n", project, app, version, err)
+		} else {
+			fmt.Printf("Successfully deleted incomplete version: %s/%s/%s\n", project, app, version)
+		}
+	}
+
+	// Get all committed versions from storage
+	storageVersions, err := cm.artifactManager.ListVersions(ctx, project, app)
+	if err != nil {
+		return nil
+	}
 
 	// Get all versions from database
 	dbVersions, err := versionRepo.ListByApp(appID, 10000, 0)
@@ -115,25 +258,8 @@ func (cm *CleanupManager) cleanupIncompleteVersions(ctx context.Context, project
 		dbVersionMap[v.Hash] = true
 	}
 
-	// Check each version in storage
+	// If meta.yaml exists but version is not in database, delete it (orphaned version)
 	for _, version := range storageVersions {
-		// Check if meta.yaml exists (indicates completed upload)
-		// Use GetManifest to check if meta.yaml exists and is valid
-		_, err := cm.artifactManager.GetManifest(ctx, project, app, version)
-		hasManifest := err == nil // If GetManifest succeeds, meta.yaml exists
-
-		// If meta.yaml doesn't exist (GetManifest failed), this is an incomplete upload - delete it
-		if !hasManifest {
-			fmt.Printf("Cleaning up incomplete version (no meta.yaml): %s/%s/%s\n", project, app, version)
-			if err := cm.artifactManager.DeleteVersion(ctx, project, app, version); err != nil {
-				fmt.Printf("Failed to delete incomplete version %s/%s/%s: %v\n", project, app, version, err)
-			} else {
-				fmt.Printf("Successfully deleted incomplete version: %s/%s/%s\n", project, app, version)
-			}
-			continue
-		}
-
-		// If meta.yaml exists but version is not in database, delete it (orphaned version)
 		if !dbVersionMap[version] {
 			fmt.Printf("Cleaning up orphaned version (not in database): %s/%s/%s\n", project, app, version)
 			if err := cm.artifactManager.DeleteVersion(ctx, project, app, version); err != nil {
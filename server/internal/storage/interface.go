@@ -18,6 +18,10 @@ type Storage interface {
 	// Get retrieves a file from the given path
 	Get(ctx context.Context, path string) (io.ReadCloser, error)
 
+	// GetRange retrieves length bytes of a file starting at offset
+	// A negative length reads to the end of the file.
+	GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error)
+
 	// Delete removes a file or directory at the given path
 	Delete(ctx context.Context, path string) error
 
@@ -29,8 +33,35 @@ type Storage interface {
 
 	// Stat returns metadata about a file or directory
 	Stat(ctx context.Context, path string) (*FileInfo, error)
+
+	// Promote moves a staged file or directory to its final path, replacing
+	// what is there. Readers see either the old or the new content: files inside
+	// a directory are moved before its CommitMarker, which is moved last.
+	Promote(ctx context.Context, stagingPath, finalPath string) error
+
+	// NewMultipartUpload starts assembling a file at path from separately uploaded parts
+	NewMultipartUpload(ctx context.Context, path string) (string, error)
+
+	// PutPart stores one numbered part (starting at 1) and returns its ETag
+	// Putting the same part number again replaces it.
+	PutPart(ctx context.Context, path, uploadID string, number int, reader io.Reader, size int64) (string, error)
+
+	// CompleteMultipartUpload joins the parts in order into the file at path
+	CompleteMultipartUpload(ctx context.Context, path, uploadID string, parts []Part) error
+
+	// AbortMultipartUpload discards the parts of an unfinished multipart upload
+	AbortMultipartUpload(ctx context.Context, path, uploadID string) error
+}
+
+// Part identifies a stored part of a multipart upload
+type Part struct {
+	Number int
+	ETag   string
 }
 
+// CommitMarker is the file whose presence marks a version directory as committed
+const CommitMarker = "meta.yaml"
+
 // FileInfo contains metadata about a stored file
 type FileInfo struct {
 	Path    string
@@ -7,12 +7,20 @@ package storage
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+// multipartRoot is the directory under the base path holding parts of unfinished multipart uploads
+const multipartRoot = ".multipart"
+
 // LocalStorage implements Storage interface using local filesystem
 type LocalStorage struct {
 	basePath string
@@ -60,6 +68,28 @@ func (l *LocalStorage) Get(ctx context.Context, path string) (io.ReadCloser, err
 	return os.Open(fullPath)
 }
 
+// GetRange retrieves part of a file, seeking to offset
+func (l *LocalStorage) GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(l.buildPath(path))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return file, nil
+	}
+	return &limitedReadCloser{Reader: io.LimitReader(file, length), Closer: file}, nil
+}
+
+// limitedReadCloser reads a limited section of a file and closes the file
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
 // Delete removes a file or directory at the given path
 func (l *LocalStorage) Delete(ctx context.Context, path string) error {
 	fullPath := l.buildPath(path)
@@ -101,6 +131,70 @@ func (l *LocalStorage) List(ctx context.Context, prefix string) ([]string, error
 	return paths, err
 }
 
+// Promote moves a staged file or directory to its final path
+// A rename is atomic on the same filesystem. If the final directory already
+// exists, files are renamed into it one by one with the commit marker last.
+func (l *LocalStorage) Promote(ctx context.Context, stagingPath, finalPath string) error {
+	src := l.buildPath(stagingPath)
+	dst := l.buildPath(finalPath)
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return os.Rename(src, dst)
+	}
+
+	// Fast path: nothing at the destination yet
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	} else if _, statErr := os.Stat(dst); statErr != nil {
+		return err
+	}
+
+	// Merge into the existing directory
+	var files []string
+	var marker string
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if relPath == CommitMarker {
+			marker = relPath
+			return nil
+		}
+		files = append(files, relPath)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if marker != "" {
+		files = append(files, marker)
+	}
+
+	for _, relPath := range files {
+		target := filepath.Join(dst, relPath)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(src, relPath), target); err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(src)
+}
+
 // Stat returns metadata about a file or directory
 func (l *LocalStorage) Stat(ctx context.Context, path string) (*FileInfo, error) {
 	fullPath := l.buildPath(path)
@@ -117,3 +211,101 @@ func (l *LocalStorage) Stat(ctx context.Context, path string) (*FileInfo, error)
 	}, nil
 }
 
+
+// NewMultipartUpload starts a multipart upload; parts are kept as files until completed
+func (l *LocalStorage) NewMultipartUpload(ctx context.Context, path string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(b)
+	if err := os.MkdirAll(l.partsDir(uploadID), 0755); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+// PutPart stores one part of a multipart upload
+// The part is written to a temporary file and renamed, so a retried part never
+// leaves a truncated file behind.
+func (l *LocalStorage) PutPart(ctx context.Context, path, uploadID string, number int, reader io.Reader, size int64) (string, error) {
+	dir := l.partsDir(uploadID)
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("multipart upload %s not found", uploadID)
+	}
+
+	file, err := os.CreateTemp(dir, "part-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(file, hash), reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if size >= 0 && written != size {
+		return "", fmt.Errorf("part %d is %d bytes, expected %d", number, written, size)
+	}
+
+	if err := os.Rename(file.Name(), filepath.Join(dir, strconv.Itoa(number))); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// CompleteMultipartUpload concatenates the parts into the file at path
+func (l *LocalStorage) CompleteMultipartUpload(ctx context.Context, path, uploadID string, parts []Part) error {
+	dir := l.partsDir(uploadID)
+	fullPath := l.buildPath(path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(fullPath), ".assemble-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	for _, part := range parts {
+		if err := appendFile(file, filepath.Join(dir, strconv.Itoa(part.Number))); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to append part %d: %w", part.Number, err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(file.Name(), fullPath); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// AbortMultipartUpload removes the parts of an unfinished multipart upload
+func (l *LocalStorage) AbortMultipartUpload(ctx context.Context, path, uploadID string) error {
+	return os.RemoveAll(l.partsDir(uploadID))
+}
+
+// partsDir returns the directory holding the parts of a multipart upload
+func (l *LocalStorage) partsDir(uploadID string) string {
+	return filepath.Join(l.basePath, multipartRoot, filepath.Base(uploadID))
+}
+
+// appendFile copies the content of the file at path to dst
+func appendFile(dst io.Writer, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -14,6 +14,10 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// LayoutCAS marks a version whose files are stored as content-addressed blobs
+// Versions without a layout use the legacy {project}/{app}/{version}/{path} tree
+const LayoutCAS = "cas"
+
 // Manifest represents the meta.yaml file structure
 type Manifest struct {
 	Project   string         `yaml:"project"`
@@ -22,6 +26,7 @@ type Manifest struct {
 	GitCommit string         `yaml:"git_commit,omitempty"`
 	BuildTime string         `yaml:"build_time"`
 	Builder   string         `yaml:"builder"`
+	Layout    string         `yaml:"layout,omitempty"`
 	Files     []ManifestFile `yaml:"files"`
 }
 
@@ -54,6 +59,11 @@ func SerializeManifest(manifest *Manifest) ([]byte, error) {
 	return yaml.Marshal(manifest)
 }
 
+// IsCAS reports whether the version's files are stored as blobs
+func (m *Manifest) IsCAS() bool {
+	return m.Layout == LayoutCAS
+}
+
 // ParseManifest parses manifest from YAML bytes
 func ParseManifest(data []byte) (*Manifest, error) {
 	var manifest Manifest
@@ -0,0 +1,86 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// RangeReader reads a stored file of known size as an io.ReadSeeker
+// Seeking is free; the next Read opens a ranged GET from the new offset, so
+// serving a byte range only transfers that range from the backend.
+type RangeReader struct {
+	ctx     context.Context
+	storage Storage
+	path    string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+// NewRangeReader creates a reader for the file at path, which is size bytes long
+func NewRangeReader(ctx context.Context, storage Storage, path string, size int64) *RangeReader {
+	return &RangeReader{ctx: ctx, storage: storage, path: path, size: size}
+}
+
+// Read reads from the current offset
+func (r *RangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.storage.GetRange(r.ctx, r.path, r.offset, r.size-r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read
+func (r *RangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if offset != r.offset {
+		r.closeBody()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+// Close releases the open ranged GET, if any
+func (r *RangeReader) Close() error {
+	return r.closeBody()
+}
+
+func (r *RangeReader) closeBody() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
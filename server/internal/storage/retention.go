@@ -0,0 +1,247 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kk/kkartifact-server/internal/database"
+)
+
+// Retention rules, as reported for each version a policy keeps or prunes
+const (
+	RetentionRulePublished     = "published"
+	RetentionRulePinned        = "pinned"
+	RetentionRuleChannel       = "channel"
+	RetentionRuleKeepLast      = "keep_last"
+	RetentionRuleKeepDays      = "keep_days"
+	RetentionRuleKeepPerBranch = "keep_per_branch"
+)
+
+// Where a rule of an effective policy comes from
+const (
+	RetentionSourceGlobal  = "global"
+	RetentionSourceProject = "project"
+	RetentionSourceApp     = "app"
+)
+
+// DefaultBranchLabel is the version label holding the git branch
+const DefaultBranchLabel = "branch"
+
+// RetentionPolicy is the effective retention policy of an app
+// Each rule keeps some versions and a version no rule keeps is pruned. A rule
+// set to 0 is off; when all are off nothing is pruned. Published and pinned
+// versions and versions on a release channel are always kept.
+type RetentionPolicy struct {
+	KeepLast      int    `json:"keep_last"`       // keep the N newest versions
+	KeepDays      int    `json:"keep_days"`       // keep versions newer than D days
+	KeepPerBranch int    `json:"keep_per_branch"` // keep the N newest versions of each branch
+	BranchLabel   string `json:"branch_label"`    // version label holding the branch
+
+	// Sources maps each rule to where it is set: global, project or app
+	Sources map[string]string `json:"sources,omitempty"`
+}
+
+// RetentionVersion is a version as seen by a retention policy
+type RetentionVersion struct {
+	Hash      string
+	CreatedAt time.Time
+	Published bool
+	Pinned    bool
+	Channels  []string // release channels pointing at the version
+	Labels    map[string]string
+}
+
+// RetentionDecision is what a policy decided for one version
+type RetentionDecision struct {
+	Version *RetentionVersion
+	Keep    bool
+	// Rule is the rule keeping the version, or for a pruned version the active
+	// rules that select it, joined by "+" (e.g. keep_last+keep_days)
+	Rule   string
+	Reason string // why the version is kept or pruned
+}
+
+// Active reports whether any rule is on, i.e. whether the policy prunes anything
+func (p *RetentionPolicy) Active() bool {
+	return p.KeepLast > 0 || p.KeepDays > 0 || p.KeepPerBranch > 0
+}
+
+// Evaluate decides which versions to keep, newest first
+func (p *RetentionPolicy) Evaluate(versions []*RetentionVersion, now time.Time) []RetentionDecision {
+	sorted := make([]*RetentionVersion, len(versions))
+	copy(sorted, versions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	branchLabel := p.BranchLabel
+	if branchLabel == "" {
+		branchLabel = DefaultBranchLabel
+	}
+	cutoff := now.AddDate(0, 0, -p.KeepDays)
+	perBranch := make(map[string]int)
+
+	decisions := make([]RetentionDecision, len(sorted))
+	for i, version := range sorted {
+		decision := RetentionDecision{Version: version}
+
+		// Versions without the label count as one more branch
+		branch := version.Labels[branchLabel]
+		perBranch[branch]++
+
+		switch {
+		case version.Published:
+			decision.Keep, decision.Rule, decision.Reason = true, RetentionRulePublished, "published"
+		case version.Pinned:
+			decision.Keep, decision.Rule, decision.Reason = true, RetentionRulePinned, "pinned"
+		case len(version.Channels) > 0:
+			decision.Keep, decision.Rule = true, RetentionRuleChannel
+			decision.Reason = "on channel " + strings.Join(version.Channels, ", ")
+		case !p.Active():
+			decision.Keep, decision.Reason = true, "no retention rule is set"
+		case p.KeepLast > 0 && i < p.KeepLast:
+			decision.Keep, decision.Rule = true, RetentionRuleKeepLast
+			decision.Reason = fmt.Sprintf("among the %d newest versions", p.KeepLast)
+		case p.KeepDays > 0 && version.CreatedAt.After(cutoff):
+			decision.Keep, decision.Rule = true, RetentionRuleKeepDays
+			decision.Reason = fmt.Sprintf("newer than %d days", p.KeepDays)
+		case p.KeepPerBranch > 0 && perBranch[branch] <= p.KeepPerBranch:
+			decision.Keep, decision.Rule = true, RetentionRuleKeepPerBranch
+			decision.Reason = fmt.Sprintf("among the %d newest versions %s", p.KeepPerBranch, branchDescription(branchLabel, branch))
+		default:
+			decision.Rule = strings.Join(p.activeRules(), "+")
+			decision.Reason = p.pruneReason(branchLabel, branch)
+		}
+		decisions[i] = decision
+	}
+	return decisions
+}
+
+// activeRules lists the rules that are on
+func (p *RetentionPolicy) activeRules() []string {
+	var rules []string
+	if p.KeepLast > 0 {
+		rules = append(rules, RetentionRuleKeepLast)
+	}
+	if p.KeepDays > 0 {
+		rules = append(rules, RetentionRuleKeepDays)
+	}
+	if p.KeepPerBranch > 0 {
+		rules = append(rules, RetentionRuleKeepPerBranch)
+	}
+	return rules
+}
+
+// pruneReason lists the active rules a pruned version falls outside of
+func (p *RetentionPolicy) pruneReason(branchLabel, branch string) string {
+	var reasons []string
+	if p.KeepLast > 0 {
+		reasons = append(reasons, fmt.Sprintf("not among the %d newest versions", p.KeepLast))
+	}
+	if p.KeepDays > 0 {
+		reasons = append(reasons, fmt.Sprintf("older than %d days", p.KeepDays))
+	}
+	if p.KeepPerBranch > 0 {
+		reasons = append(reasons, fmt.Sprintf("not among the %d newest versions %s", p.KeepPerBranch, branchDescription(branchLabel, branch)))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+func branchDescription(branchLabel, branch string) string {
+	if branch == "" {
+		return fmt.Sprintf("without a %s label", branchLabel)
+	}
+	return fmt.Sprintf("of %s %s", branchLabel, branch)
+}
+
+// LoadRetentionPolicy resolves the effective retention policy of a project or app
+// Rules come from the global config, overridden by the project policy and
+// then by the app policy. appID is nil for the policy of the project itself.
+func LoadRetentionPolicy(db *database.DB, projectID int, appID *int) (*RetentionPolicy, error) {
+	policy, err := LoadGlobalRetentionPolicy(db)
+	if err != nil {
+		return nil, err
+	}
+
+	policyRepo := database.NewRetentionPolicyRepository(db)
+	projectPolicy, err := policyRepo.GetForProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+	policy.override(projectPolicy, RetentionSourceProject)
+
+	if appID != nil {
+		appPolicy, err := policyRepo.GetForApp(*appID)
+		if err != nil {
+			return nil, err
+		}
+		policy.override(appPolicy, RetentionSourceApp)
+	}
+	return policy, nil
+}
+
+// LoadGlobalRetentionPolicy reads the global retention rules from the config
+func LoadGlobalRetentionPolicy(db *database.DB) (*RetentionPolicy, error) {
+	configRepo := database.NewConfigRepository(db)
+	value, err := configRepo.Get("version_retention_limit")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retention limit: %w", err)
+	}
+	keepLast, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retention limit: %w", err)
+	}
+
+	policy := &RetentionPolicy{
+		KeepLast:    keepLast,
+		BranchLabel: DefaultBranchLabel,
+		Sources: map[string]string{
+			RetentionRuleKeepLast:      RetentionSourceGlobal,
+			RetentionRuleKeepDays:      RetentionSourceGlobal,
+			RetentionRuleKeepPerBranch: RetentionSourceGlobal,
+			"branch_label":             RetentionSourceGlobal,
+		},
+	}
+	// The other global rules are off unless set
+	if value, err := configRepo.Get("retention_keep_days"); err == nil {
+		policy.KeepDays, _ = strconv.Atoi(value)
+	}
+	if value, err := configRepo.Get("retention_keep_per_branch"); err == nil {
+		policy.KeepPerBranch, _ = strconv.Atoi(value)
+	}
+	if value, err := configRepo.Get("retention_branch_label"); err == nil && value != "" {
+		policy.BranchLabel = value
+	}
+	return policy, nil
+}
+
+// override applies the rules a project or app policy sets
+func (p *RetentionPolicy) override(o *database.RetentionPolicy, source string) {
+	if o == nil {
+		return
+	}
+	if o.KeepLast.Valid {
+		p.KeepLast = int(o.KeepLast.Int64)
+		p.Sources[RetentionRuleKeepLast] = source
+	}
+	if o.KeepDays.Valid {
+		p.KeepDays = int(o.KeepDays.Int64)
+		p.Sources[RetentionRuleKeepDays] = source
+	}
+	if o.KeepPerBranch.Valid {
+		p.KeepPerBranch = int(o.KeepPerBranch.Int64)
+		p.Sources[RetentionRuleKeepPerBranch] = source
+	}
+	if o.BranchLabel.Valid {
+		p.BranchLabel = o.BranchLabel.String
+		p.Sources["branch_label"] = source
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// testVersions returns n versions one day apart, newest first
+func testVersions(now time.Time, n int) []*RetentionVersion {
+	versions := make([]*RetentionVersion, n)
+	for i := range versions {
+		versions[i] = &RetentionVersion{
+			Hash:      fmt.Sprintf("v%d", n-i),
+			CreatedAt: now.AddDate(0, 0, -i),
+		}
+	}
+	return versions
+}
+
+func keptHashes(decisions []RetentionDecision) map[string]string {
+	kept := make(map[string]string)
+	for _, decision := range decisions {
+		if decision.Keep {
+			kept[decision.Version.Hash] = decision.Rule
+		}
+	}
+	return kept
+}
+
+func TestRetentionPolicy_KeepLast(t *testing.T) {
+	now := time.Now()
+	policy := &RetentionPolicy{KeepLast: 2}
+
+	kept := keptHashes(policy.Evaluate(testVersions(now, 5), now))
+	if len(kept) != 2 || kept["v5"] != RetentionRuleKeepLast || kept["v4"] != RetentionRuleKeepLast {
+		t.Errorf("Expected v5 and v4 kept by keep_last, got %v", kept)
+	}
+}
+
+func TestRetentionPolicy_RulesAreCombined(t *testing.T) {
+	now := time.Now()
+	policy := &RetentionPolicy{KeepLast: 1, KeepDays: 3}
+
+	decisions := policy.Evaluate(testVersions(now, 6), now)
+	kept := keptHashes(decisions)
+	expected := map[string]string{
+		"v6": RetentionRuleKeepLast,
+		"v5": RetentionRuleKeepDays,
+		"v4": RetentionRuleKeepDays,
+	}
+	if len(kept) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, kept)
+	}
+	for hash, rule := range expected {
+		if kept[hash] != rule {
+			t.Errorf("Expected %s kept by %s, got %q", hash, rule, kept[hash])
+		}
+	}
+
+	pruned := decisions[len(decisions)-1]
+	if pruned.Keep || pruned.Rule != "keep_last+keep_days" {
+		t.Errorf("Expected v1 pruned by keep_last+keep_days, got keep=%v rule=%q", pruned.Keep, pruned.Rule)
+	}
+}
+
+func TestRetentionPolicy_KeepPerBranch(t *testing.T) {
+	now := time.Now()
+	versions := testVersions(now, 6)
+	for i, version := range versions {
+		if i%2 == 0 {
+			version.Labels = map[string]string{"branch": "main"}
+		} else if i < 4 {
+			version.Labels = map[string]string{"branch": "dev"}
+		}
+	}
+	policy := &RetentionPolicy{KeepPerBranch: 1, BranchLabel: "branch"}
+
+	kept := keptHashes(policy.Evaluate(versions, now))
+	// Newest of main (v6), of dev (v5) and of the unlabelled versions (v1)
+	if len(kept) != 3 || kept["v6"] == "" || kept["v5"] == "" || kept["v1"] == "" {
+		t.Errorf("Expected v6, v5 and v1 kept, got %v", kept)
+	}
+}
+
+func TestRetentionPolicy_PublishedPinnedAndChannelVersionsAreKept(t *testing.T) {
+	now := time.Now()
+	versions := testVersions(now, 5)
+	versions[2].Published = true
+	versions[3].Pinned = true
+	versions[4].Channels = []string{"staging"}
+	policy := &RetentionPolicy{KeepLast: 1}
+
+	kept := keptHashes(policy.Evaluate(versions, now))
+	if kept["v3"] != RetentionRulePublished {
+		t.Errorf("Expected published v3 kept, got %q", kept["v3"])
+	}
+	if kept["v2"] != RetentionRulePinned {
+		t.Errorf("Expected pinned v2 kept, got %q", kept["v2"])
+	}
+	if kept["v1"] != RetentionRuleChannel {
+		t.Errorf("Expected v1 on a channel kept, got %q", kept["v1"])
+	}
+	if _, ok := kept["v4"]; ok {
+		t.Error("Expected v4 pruned")
+	}
+}
+
+func TestRetentionPolicy_NoRulesKeepsEverything(t *testing.T) {
+	now := time.Now()
+	policy := &RetentionPolicy{}
+
+	if policy.Active() {
+		t.Error("Expected policy without rules to be inactive")
+	}
+	kept := keptHashes(policy.Evaluate(testVersions(now, 3), now))
+	if len(kept) != 3 {
+		t.Errorf("Expected all versions kept, got %v", kept)
+	}
+}
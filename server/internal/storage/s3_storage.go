@@ -66,6 +66,30 @@ func (s *S3Storage) Get(ctx context.Context, path string) (io.ReadCloser, error)
 	return obj, nil
 }
 
+// GetRange retrieves part of an object with a ranged GET
+func (s *S3Storage) GetRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	opts := minio.GetObjectOptions{}
+	if length > 0 {
+		if err := opts.SetRange(offset, offset+length-1); err != nil {
+			return nil, err
+		}
+	} else if offset > 0 {
+		// bytes=offset- reads to the end of the object
+		if err := opts.SetRange(offset, 0); err != nil {
+			return nil, err
+		}
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, s.buildPath(path), opts)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
 // Delete removes a file at the given path
 func (s *S3Storage) Delete(ctx context.Context, path string) error {
 	objectPath := s.buildPath(path)
@@ -105,6 +129,71 @@ func (s *S3Storage) List(ctx context.Context, prefix string) ([]string, error) {
 	return paths, nil
 }
 
+// Promote copies staged objects to their final path and then deletes them
+// S3 has no rename, so a directory is copied object by object with the commit
+// marker copied last; readers treat a version as present only once the marker exists.
+func (s *S3Storage) Promote(ctx context.Context, stagingPath, finalPath string) error {
+	src := s.buildPath(stagingPath)
+	dst := s.buildPath(finalPath)
+
+	// A single object
+	if _, err := s.client.StatObject(ctx, s.bucket, src, minio.StatObjectOptions{}); err == nil {
+		if err := s.copyObject(ctx, src, dst); err != nil {
+			return err
+		}
+		return s.client.RemoveObject(ctx, s.bucket, src, minio.RemoveObjectOptions{})
+	}
+
+	// A directory: every object under the prefix
+	var keys []string
+	var marker string
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    src + "/",
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if strings.TrimPrefix(obj.Key, src+"/") == CommitMarker {
+			marker = obj.Key
+			continue
+		}
+		keys = append(keys, obj.Key)
+	}
+	if marker != "" {
+		keys = append(keys, marker)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("nothing staged at %s", stagingPath)
+	}
+
+	for _, key := range keys {
+		if err := s.copyObject(ctx, key, dst+"/"+strings.TrimPrefix(key, src+"/")); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyObject copies an object within the bucket
+// A single CopyObject is limited to 5 GiB; ComposeObject copies larger
+// objects part by part on the server and falls back to CopyObject otherwise.
+func (s *S3Storage) copyObject(ctx context.Context, srcKey, dstKey string) error {
+	_, err := s.client.ComposeObject(ctx,
+		minio.CopyDestOptions{Bucket: s.bucket, Object: dstKey},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: srcKey},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", srcKey, dstKey, err)
+	}
+	return nil
+}
+
 // Stat returns metadata about a file
 func (s *S3Storage) Stat(ctx context.Context, path string) (*FileInfo, error) {
 	objectPath := s.buildPath(path)
@@ -120,3 +209,44 @@ func (s *S3Storage) Stat(ctx context.Context, path string) (*FileInfo, error) {
 		IsDir:   strings.HasSuffix(path, "/"),
 	}, nil
 }
+
+// NewMultipartUpload starts an S3 multipart upload for the object at path
+func (s *S3Storage) NewMultipartUpload(ctx context.Context, path string) (string, error) {
+	core := minio.Core{Client: s.client}
+	uploadID, err := core.NewMultipartUpload(ctx, s.bucket, s.buildPath(path), minio.PutObjectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	return uploadID, nil
+}
+
+// PutPart uploads one part of an S3 multipart upload
+// S3 requires every part except the last to be at least 5 MiB.
+func (s *S3Storage) PutPart(ctx context.Context, path, uploadID string, number int, reader io.Reader, size int64) (string, error) {
+	core := minio.Core{Client: s.client}
+	part, err := core.PutObjectPart(ctx, s.bucket, s.buildPath(path), uploadID, number, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", number, err)
+	}
+	return part.ETag, nil
+}
+
+// CompleteMultipartUpload asks S3 to assemble the object from its parts
+func (s *S3Storage) CompleteMultipartUpload(ctx context.Context, path, uploadID string, parts []Part) error {
+	completeParts := make([]minio.CompletePart, len(parts))
+	for i, part := range parts {
+		completeParts[i] = minio.CompletePart{PartNumber: part.Number, ETag: part.ETag}
+	}
+
+	core := minio.Core{Client: s.client}
+	if _, err := core.CompleteMultipartUpload(ctx, s.bucket, s.buildPath(path), uploadID, completeParts, minio.PutObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload aborts an S3 multipart upload and frees its parts
+func (s *S3Storage) AbortMultipartUpload(ctx context.Context, path, uploadID string) error {
+	core := minio.Core{Client: s.client}
+	return core.AbortMultipartUpload(ctx, s.bucket, s.buildPath(path), uploadID)
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+)
+
+// StagingRoot is the storage prefix holding files of unfinished upload sessions
+const StagingRoot = ".uploads"
+
+// StagingPath returns the storage path of a blob staged by an upload session
+func StagingPath(sessionID, hash string) string {
+	return path.Join(StagingRoot, sessionID, hash)
+}
+
+// newStagingID returns a random name for a one-off staging directory
+func newStagingID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate staging ID: %w", err)
+	}
+	return "commit-" + hex.EncodeToString(b), nil
+}
+
+// StageBlob stores a blob in an upload session's staging area
+// The caller must have checked that the content matches hash; staged blobs are
+// not hashed again when the upload is finished.
+func (am *ArtifactManager) StageBlob(ctx context.Context, sessionID, hash string, reader io.Reader, size int64) error {
+	if !IsBlobHash(hash) {
+		return fmt.Errorf("invalid blob hash: %s", hash)
+	}
+	if err := am.storage.Put(ctx, StagingPath(sessionID, hash), reader, size); err != nil {
+		return fmt.Errorf("failed to stage blob %s: %w", hash, err)
+	}
+	return nil
+}
+
+// PromoteStagedBlob moves a staged blob into the blob store
+// If another upload already stored the same content, the staged copy is dropped
+func (am *ArtifactManager) PromoteStagedBlob(ctx context.Context, sessionID, hash string, size int64) error {
+	stagedPath := StagingPath(sessionID, hash)
+
+	exists, err := am.HasBlob(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("failed to check blob existence: %w", err)
+	}
+	if exists {
+		return am.storage.Delete(ctx, stagedPath)
+	}
+
+	if err := am.storage.Promote(ctx, stagedPath, BlobPath(hash)); err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", hash, err)
+	}
+	return nil
+}
+
+// DiscardStaging removes an upload session's staged blobs
+func (am *ArtifactManager) DiscardStaging(ctx context.Context, sessionID string, hashes []string) error {
+	var firstErr error
+	// Delete objects one by one: object stores do not delete by prefix
+	for _, hash := range hashes {
+		if err := am.storage.Delete(ctx, StagingPath(sessionID, hash)); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to delete staged blob %s: %w", hash, err)
+		}
+	}
+	if err := am.storage.Delete(ctx, path.Join(StagingRoot, sessionID)); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
@@ -103,3 +103,144 @@ func TestValidatePath(t *testing.T) {
 	}
 }
 
+
+func TestLocalStorage_Promote(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	ctx := context.Background()
+
+	put := func(path, content string) {
+		if err := storage.Put(ctx, path, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("Failed to put %s: %v", path, err)
+		}
+	}
+	read := func(path string) string {
+		reader, err := storage.Get(ctx, path)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", path, err)
+		}
+		defer reader.Close()
+		data, _ := io.ReadAll(reader)
+		return string(data)
+	}
+
+	// A single file replaces the destination
+	put("final/meta.yaml", "old")
+	put(".uploads/s1/meta.yaml", "new")
+	if err := storage.Promote(ctx, ".uploads/s1/meta.yaml", "final/meta.yaml"); err != nil {
+		t.Fatalf("Promote() file error = %v", err)
+	}
+	if got := read("final/meta.yaml"); got != "new" {
+		t.Errorf("Promoted file = %q, want %q", got, "new")
+	}
+
+	// A directory is merged into an existing one
+	put(".uploads/s2/meta.yaml", "v2")
+	put(".uploads/s2/bin/app", "binary")
+	if err := storage.Promote(ctx, ".uploads/s2", "final"); err != nil {
+		t.Fatalf("Promote() dir error = %v", err)
+	}
+	if got := read("final/meta.yaml"); got != "v2" {
+		t.Errorf("Promoted marker = %q, want %q", got, "v2")
+	}
+	if got := read("final/bin/app"); got != "binary" {
+		t.Errorf("Promoted file = %q, want %q", got, "binary")
+	}
+	if exists, _ := storage.Exists(ctx, ".uploads/s2"); exists {
+		t.Error("Staging directory should be removed after promote")
+	}
+}
+
+func TestLocalStorage_MultipartUpload(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	ctx := context.Background()
+
+	uploadID, err := storage.NewMultipartUpload(ctx, "big/file.bin")
+	if err != nil {
+		t.Fatalf("NewMultipartUpload() error = %v", err)
+	}
+
+	// Parts may arrive out of order and be sent again
+	chunks := []string{"hello ", "chunked ", "world"}
+	parts := make([]Part, len(chunks))
+	for _, i := range []int{2, 0, 1, 0} {
+		etag, err := storage.PutPart(ctx, "big/file.bin", uploadID, i+1, strings.NewReader(chunks[i]), int64(len(chunks[i])))
+		if err != nil {
+			t.Fatalf("PutPart(%d) error = %v", i+1, err)
+		}
+		parts[i] = Part{Number: i + 1, ETag: etag}
+	}
+
+	// A part of the wrong size is rejected
+	if _, err := storage.PutPart(ctx, "big/file.bin", uploadID, 1, strings.NewReader("short"), 6); err == nil {
+		t.Error("PutPart() should reject a part of the wrong size")
+	}
+
+	if err := storage.CompleteMultipartUpload(ctx, "big/file.bin", uploadID, parts); err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+
+	reader, err := storage.Get(ctx, "big/file.bin")
+	if err != nil {
+		t.Fatalf("Failed to get assembled file: %v", err)
+	}
+	defer reader.Close()
+	content, _ := io.ReadAll(reader)
+	if string(content) != "hello chunked world" {
+		t.Errorf("Assembled file = %q, want %q", content, "hello chunked world")
+	}
+
+	if exists, _ := storage.Exists(ctx, multipartRoot+"/"+uploadID); exists {
+		t.Error("Parts should be removed after completion")
+	}
+}
+
+func TestRangeReader(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	ctx := context.Background()
+
+	content := "0123456789abcdef"
+	if err := storage.Put(ctx, "file.bin", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("Failed to put file: %v", err)
+	}
+
+	part, err := storage.GetRange(ctx, "file.bin", 4, 6)
+	if err != nil {
+		t.Fatalf("GetRange() error = %v", err)
+	}
+	data, _ := io.ReadAll(part)
+	part.Close()
+	if string(data) != "456789" {
+		t.Errorf("GetRange(4, 6) = %q, want %q", data, "456789")
+	}
+
+	reader := NewRangeReader(ctx, storage, "file.bin", int64(len(content)))
+	defer reader.Close()
+	if size, err := reader.Seek(0, io.SeekEnd); err != nil || size != int64(len(content)) {
+		t.Fatalf("Seek(0, SeekEnd) = %d, %v, want %d", size, err, len(content))
+	}
+	if _, err := reader.Seek(-6, io.SeekEnd); err != nil {
+		t.Fatalf("Seek(-6, SeekEnd) error = %v", err)
+	}
+	data, err = io.ReadAll(reader)
+	if err != nil || string(data) != "abcdef" {
+		t.Errorf("read after seek = %q, %v, want %q", data, err, "abcdef")
+	}
+
+	// Seeking back reopens the file from the new offset
+	if _, err := reader.Seek(2, io.SeekStart); err != nil {
+		t.Fatalf("Seek(2, SeekStart) error = %v", err)
+	}
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(reader, buf); err != nil || string(buf) != "234" {
+		t.Errorf("read after seek back = %q, %v, want %q", buf, err, "234")
+	}
+}
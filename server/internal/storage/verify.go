@@ -0,0 +1,120 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Reasons a manifest file fails verification
+const (
+	MismatchInvalid   = "invalid_entry"
+	MismatchDuplicate = "duplicate_path"
+	MismatchMissing   = "missing"
+	MismatchSize      = "size_mismatch"
+)
+
+// FileMismatch describes a manifest file that does not match the stored data
+type FileMismatch struct {
+	Path           string `json:"path"`
+	Reason         string `json:"reason"`
+	Message        string `json:"message,omitempty"`
+	ExpectedSHA256 string `json:"expected_sha256,omitempty"`
+	ExpectedSize   int64  `json:"expected_size"`
+	ActualSize     int64  `json:"actual_size,omitempty"`
+}
+
+// VerifyUpload checks every manifest file against the data an upload session provides
+// Files staged by the session (sha256 -> size) and files already in the blob
+// store are checked for presence and size; their content was hashed when it was
+// written. Returns the files that do not match; an error is only returned if
+// storage could not be read.
+func (am *ArtifactManager) VerifyUpload(ctx context.Context, sessionID string, manifest *Manifest, staged map[string]int64) ([]FileMismatch, error) {
+	mismatches := make([]FileMismatch, 0)
+	seenPaths := make(map[string]bool, len(manifest.Files))
+	checked := make(map[string]storedBlob)
+
+	for _, file := range manifest.Files {
+		mismatch := FileMismatch{
+			Path:           file.Path,
+			ExpectedSHA256: file.SHA256,
+			ExpectedSize:   file.Size,
+		}
+
+		if err := ValidatePath(file.Path); err != nil || file.Path == "" {
+			mismatch.Reason = MismatchInvalid
+			mismatch.Message = "invalid path"
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+		if !IsBlobHash(file.SHA256) {
+			mismatch.Reason = MismatchInvalid
+			mismatch.Message = "sha256 must be 64 lowercase hex characters"
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+		if seenPaths[file.Path] {
+			mismatch.Reason = MismatchDuplicate
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+		seenPaths[file.Path] = true
+
+		// Each blob is only checked once even if several paths share it
+		stored, ok := checked[file.SHA256]
+		if !ok {
+			var err error
+			stored, err = am.inspectBlob(ctx, sessionID, file.SHA256, staged)
+			if err != nil {
+				return nil, fmt.Errorf("failed to verify %s: %w", file.Path, err)
+			}
+			checked[file.SHA256] = stored
+		}
+
+		switch {
+		case !stored.exists:
+			mismatch.Reason = MismatchMissing
+		case stored.size != file.Size:
+			mismatch.Reason = MismatchSize
+			mismatch.ActualSize = stored.size
+		default:
+			continue
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+
+	return mismatches, nil
+}
+
+// storedBlob is what storage holds for a blob
+type storedBlob struct {
+	exists bool
+	size   int64
+}
+
+// inspectBlob reads the size of a staged or stored blob
+// Blobs are not read again: uploaded files are hashed before they are staged
+// and chunked files once their chunks are assembled, so a staged blob's name
+// is already its verified hash.
+func (am *ArtifactManager) inspectBlob(ctx context.Context, sessionID, hash string, staged map[string]int64) (storedBlob, error) {
+	objectPath := BlobPath(hash)
+	_, isStaged := staged[hash]
+	if isStaged {
+		objectPath = StagingPath(sessionID, hash)
+	}
+
+	exists, err := am.storage.Exists(ctx, objectPath)
+	if err != nil || !exists {
+		return storedBlob{}, err
+	}
+
+	info, err := am.storage.Stat(ctx, objectPath)
+	if err != nil {
+		return storedBlob{}, err
+	}
+	return storedBlob{exists: true, size: info.Size}, nil
+}
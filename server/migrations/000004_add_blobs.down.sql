@@ -0,0 +1,9 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+DROP INDEX IF EXISTS idx_blobs_unreferenced;
+DROP INDEX IF EXISTS idx_blob_refs_sha256;
+DROP TABLE IF EXISTS blob_refs;
+DROP TABLE IF EXISTS blobs;
@@ -0,0 +1,26 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Content-addressable blobs shared across versions (keyed by file SHA256)
+CREATE TABLE IF NOT EXISTS blobs (
+    sha256 VARCHAR(64) PRIMARY KEY,
+    size BIGINT NOT NULL DEFAULT 0,
+    ref_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Blob references held by each stored version
+-- Keyed by names rather than versions.id so storage cleanup can release
+-- references even after the version row has been deleted
+CREATE TABLE IF NOT EXISTS blob_refs (
+    project VARCHAR(255) NOT NULL,
+    app VARCHAR(255) NOT NULL,
+    version VARCHAR(255) NOT NULL,
+    sha256 VARCHAR(64) NOT NULL REFERENCES blobs(sha256) ON DELETE CASCADE,
+    PRIMARY KEY (project, app, version, sha256)
+);
+
+CREATE INDEX IF NOT EXISTS idx_blob_refs_sha256 ON blob_refs(sha256);
+CREATE INDEX IF NOT EXISTS idx_blobs_unreferenced ON blobs(sha256) WHERE ref_count = 0;
@@ -0,0 +1,9 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+DROP INDEX IF EXISTS idx_upload_sessions_status_expires;
+DROP INDEX IF EXISTS idx_upload_sessions_version;
+DROP TABLE IF EXISTS upload_session_files;
+DROP TABLE IF EXISTS upload_sessions;
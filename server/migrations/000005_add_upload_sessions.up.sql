@@ -0,0 +1,33 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Upload sessions: a push is staged under its session and only becomes a
+-- version when the session is finished
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    project VARCHAR(255) NOT NULL,
+    app VARCHAR(255) NOT NULL,
+    version VARCHAR(255) NOT NULL,
+    token_id INTEGER REFERENCES tokens(id) ON DELETE SET NULL,
+    username VARCHAR(255),
+    expected_files JSONB,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP
+);
+
+-- Blobs staged by a session, so they can be promoted on finish or
+-- discarded when the session is aborted or expires
+CREATE TABLE IF NOT EXISTS upload_session_files (
+    session_id VARCHAR(64) NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    sha256 VARCHAR(64) NOT NULL,
+    size BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, sha256)
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_version ON upload_sessions(project, app, version);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_status_expires ON upload_sessions(status, expires_at);
@@ -0,0 +1,7 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+DROP TABLE IF EXISTS chunked_upload_parts;
+DROP TABLE IF EXISTS chunked_uploads;
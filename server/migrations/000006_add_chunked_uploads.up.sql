@@ -0,0 +1,31 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Chunked uploads: a large file of an upload session is sent as numbered
+-- chunks and assembled into the session's staging area when complete
+CREATE TABLE IF NOT EXISTS chunked_uploads (
+    id VARCHAR(64) PRIMARY KEY,
+    session_id VARCHAR(64) NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    size BIGINT NOT NULL,
+    chunk_size BIGINT NOT NULL,
+    storage_upload_id TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    UNIQUE (session_id, sha256)
+);
+
+-- Chunks received so far, so an interrupted upload can resume where it stopped
+CREATE TABLE IF NOT EXISTS chunked_upload_parts (
+    upload_id VARCHAR(64) NOT NULL REFERENCES chunked_uploads(id) ON DELETE CASCADE,
+    part_number INTEGER NOT NULL,
+    byte_offset BIGINT NOT NULL,
+    size BIGINT NOT NULL,
+    etag VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (upload_id, part_number)
+);
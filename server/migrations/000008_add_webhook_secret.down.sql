@@ -0,0 +1,6 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+ALTER TABLE webhooks DROP COLUMN IF EXISTS secret;
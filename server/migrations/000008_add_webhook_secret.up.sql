@@ -0,0 +1,7 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Optional secret used to sign webhook requests (HMAC-SHA256)
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS secret TEXT;
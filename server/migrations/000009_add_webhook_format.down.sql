@@ -0,0 +1,7 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+ALTER TABLE webhooks DROP COLUMN IF EXISTS template;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS format;
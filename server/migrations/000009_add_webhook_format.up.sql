@@ -0,0 +1,9 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Payload format: raw, slack, dingtalk, feishu, wecom, msteams or template
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS format VARCHAR(20) NOT NULL DEFAULT 'raw';
+-- Go text/template rendered as the body when format is template
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS template TEXT;
@@ -0,0 +1,9 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+UPDATE webhooks SET event_types = array_replace(event_types, 'version.pushed', 'push');
+UPDATE webhooks SET event_types = array_replace(event_types, 'version.published', 'publish');
+UPDATE webhooks SET event_types = array_replace(event_types, 'version.unpublished', 'unpublish');
+UPDATE webhooks SET event_types = array_replace(event_types, 'version.deleted', 'delete');
@@ -0,0 +1,10 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Move webhook subscriptions to the event catalog names
+UPDATE webhooks SET event_types = array_replace(event_types, 'push', 'version.pushed');
+UPDATE webhooks SET event_types = array_replace(event_types, 'publish', 'version.published');
+UPDATE webhooks SET event_types = array_replace(event_types, 'unpublish', 'version.unpublished');
+UPDATE webhooks SET event_types = array_replace(event_types, 'delete', 'version.deleted');
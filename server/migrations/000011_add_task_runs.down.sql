@@ -0,0 +1,8 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+DELETE FROM config WHERE key LIKE 'schedule.%';
+
+DROP TABLE IF EXISTS task_runs;
@@ -0,0 +1,27 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Task runs: one row per run of a scheduled task, whether it ran on schedule,
+-- caught up after the server was down, or was started by hand
+CREATE TABLE IF NOT EXISTS task_runs (
+    id BIGSERIAL PRIMARY KEY,
+    task_name VARCHAR(100) NOT NULL,
+    triggered_by VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    scheduled_at TIMESTAMP,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,
+    error TEXT,
+    counts JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_runs_task_name ON task_runs(task_name, started_at DESC);
+
+-- Cron schedules of the built-in tasks (minute hour day-of-month month day-of-week)
+INSERT INTO config (key, value) VALUES
+    ('schedule.version-cleanup', '0 3 * * *'),
+    ('schedule.audit-log-cleanup', '10 3 * * *'),
+    ('schedule.upload-session-cleanup', '20 3 * * *')
+ON CONFLICT (key) DO NOTHING;
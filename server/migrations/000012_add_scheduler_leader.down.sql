@@ -0,0 +1,10 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+DROP TABLE IF EXISTS scheduler_leader;
+
+DROP INDEX IF EXISTS idx_task_runs_scheduled_at;
+
+ALTER TABLE task_runs DROP COLUMN IF EXISTS runner;
@@ -0,0 +1,21 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- The replica that ran a task
+ALTER TABLE task_runs ADD COLUMN IF NOT EXISTS runner VARCHAR(255);
+
+-- A scheduled run happens once even if several replicas see it due
+DELETE FROM task_runs a USING task_runs b
+WHERE a.task_name = b.task_name AND a.scheduled_at = b.scheduled_at AND a.id > b.id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_task_runs_scheduled_at ON task_runs(task_name, scheduled_at);
+
+-- The replica currently scheduling tasks. Leadership itself is a Postgres
+-- advisory lock; this row only makes it visible and is renewed every minute.
+CREATE TABLE IF NOT EXISTS scheduler_leader (
+    id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    holder VARCHAR(255) NOT NULL,
+    acquired_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    renewed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
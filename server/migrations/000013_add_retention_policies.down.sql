@@ -0,0 +1,12 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+DELETE FROM config WHERE key IN ('retention_keep_days', 'retention_keep_per_branch', 'retention_branch_label');
+
+DROP TABLE IF EXISTS version_labels;
+
+ALTER TABLE versions DROP COLUMN IF EXISTS pinned;
+
+DROP TABLE IF EXISTS retention_policies;
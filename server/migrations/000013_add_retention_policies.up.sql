@@ -0,0 +1,43 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Retention policy overrides: one row per project (app_id NULL) or app.
+-- A NULL rule is inherited from the project policy, then from the global
+-- config; 0 turns the rule off.
+CREATE TABLE IF NOT EXISTS retention_policies (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    app_id INTEGER REFERENCES apps(id) ON DELETE CASCADE,
+    keep_last INTEGER,
+    keep_days INTEGER,
+    keep_per_branch INTEGER,
+    branch_label VARCHAR(100),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_retention_policies_project ON retention_policies(project_id) WHERE app_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_retention_policies_app ON retention_policies(app_id) WHERE app_id IS NOT NULL;
+
+-- Pinned versions are never removed by retention
+ALTER TABLE versions ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Version labels (e.g. branch=main), used by the keep-per-branch rule
+CREATE TABLE IF NOT EXISTS version_labels (
+    version_id INTEGER NOT NULL REFERENCES versions(id) ON DELETE CASCADE,
+    key VARCHAR(100) NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (version_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_version_labels_key_value ON version_labels(key, value);
+
+-- Global retention rules; version_retention_limit is the global keep-last rule
+INSERT INTO config (key, value) VALUES
+    ('retention_keep_days', '0'),
+    ('retention_keep_per_branch', '0'),
+    ('retention_branch_label', 'branch')
+ON CONFLICT (key) DO NOTHING;
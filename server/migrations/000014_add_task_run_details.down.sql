@@ -0,0 +1,9 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+DELETE FROM config WHERE key LIKE 'dry_run.%';
+
+ALTER TABLE task_runs DROP COLUMN IF EXISTS details;
+ALTER TABLE task_runs DROP COLUMN IF EXISTS dry_run;
@@ -0,0 +1,9 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Dry runs report what a task would do without doing it; details holds what
+-- the run decided (e.g. the versions retention would prune)
+ALTER TABLE task_runs ADD COLUMN IF NOT EXISTS dry_run BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE task_runs ADD COLUMN IF NOT EXISTS details JSONB;
@@ -0,0 +1,20 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Named release channels (e.g. dev, staging, prod), each pointing an app at one
+-- version. The published flag stays the default channel. Channels hold the
+-- version hash, so overwriting a version keeps it on its channels.
+CREATE TABLE IF NOT EXISTS channels (
+    id SERIAL PRIMARY KEY,
+    app_id INTEGER NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    version VARCHAR(255) NOT NULL,
+    updated_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(app_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_channels_version ON channels(app_id, version);
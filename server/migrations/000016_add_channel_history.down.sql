@@ -0,0 +1,8 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+UPDATE webhooks SET event_types = array_replace(event_types, 'channel.rolled_back', 'rollback');
+
+DROP TABLE IF EXISTS channel_history;
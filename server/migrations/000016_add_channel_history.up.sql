@@ -0,0 +1,32 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Every change of a channel, including the published version (channel
+-- 'published'), so a channel can be rolled back. version is NULL when the
+-- channel was deleted or the version unpublished.
+CREATE TABLE IF NOT EXISTS channel_history (
+    id BIGSERIAL PRIMARY KEY,
+    app_id INTEGER NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    channel VARCHAR(100) NOT NULL,
+    version VARCHAR(255),
+    previous_version VARCHAR(255),
+    action VARCHAR(20) NOT NULL, -- set, delete or rollback
+    changed_by VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_history_channel ON channel_history(app_id, channel, id DESC);
+
+-- Start the history from where the channels point now
+INSERT INTO channel_history (app_id, channel, version, action, changed_by, created_at)
+SELECT app_id, name, version, 'set', updated_by, updated_at FROM channels;
+
+INSERT INTO channel_history (app_id, channel, version, action)
+SELECT DISTINCT ON (app_id) app_id, 'published', hash, 'set' FROM versions
+WHERE is_published = TRUE
+ORDER BY app_id, created_at DESC;
+
+-- The rollback event replaces the old "rollback" event name
+UPDATE webhooks SET event_types = array_replace(event_types, 'rollback', 'channel.rolled_back');
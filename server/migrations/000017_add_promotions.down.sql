@@ -0,0 +1,8 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+DROP TABLE IF EXISTS promotion_approvals;
+DROP TABLE IF EXISTS promotions;
+DROP TABLE IF EXISTS promotion_policies;
@@ -0,0 +1,46 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Rules a version must meet before it is promoted to a channel of an app
+CREATE TABLE IF NOT EXISTS promotion_policies (
+    id SERIAL PRIMARY KEY,
+    app_id INTEGER NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    channel VARCHAR(100) NOT NULL,           -- the channel promoted to, e.g. prod
+    from_channel VARCHAR(100),               -- the channel the version must be on, e.g. staging
+    min_hours INTEGER NOT NULL DEFAULT 0,    -- hours on from_channel, or since the push without one
+    required_label_key VARCHAR(100),         -- label the version must carry
+    required_label_value VARCHAR(255),       -- NULL for any value
+    required_approvals INTEGER NOT NULL DEFAULT 0, -- distinct approvers other than the requester
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(app_id, channel)
+);
+
+-- Promotion requests; pending until enough users approve or one rejects
+CREATE TABLE IF NOT EXISTS promotions (
+    id SERIAL PRIMARY KEY,
+    app_id INTEGER NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    channel VARCHAR(100) NOT NULL,
+    version VARCHAR(255) NOT NULL,
+    from_channel VARCHAR(100),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, approved or rejected
+    required_approvals INTEGER NOT NULL DEFAULT 0,
+    requested_by VARCHAR(255) NOT NULL,
+    decided_by VARCHAR(255),
+    reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    decided_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotions_app ON promotions(app_id, id DESC);
+-- One pending request per version and channel
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promotions_pending ON promotions(app_id, channel, version) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS promotion_approvals (
+    promotion_id INTEGER NOT NULL REFERENCES promotions(id) ON DELETE CASCADE,
+    approver VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (promotion_id, approver)
+);
@@ -0,0 +1,7 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+DROP INDEX IF EXISTS idx_webhook_deliveries_stream_entry;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS stream_entry_id;
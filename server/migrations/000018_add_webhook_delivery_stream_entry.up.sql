@@ -0,0 +1,11 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- The Redis stream entry an event was read from, so an entry handled again
+-- after a failure queues each webhook's delivery only once
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS stream_entry_id VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_stream_entry
+    ON webhook_deliveries(stream_entry_id, webhook_id) WHERE stream_entry_id IS NOT NULL;
@@ -0,0 +1,8 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Scheduled times were stored as UTC wall clock times; store the instant instead
+-- so they do not depend on the time zone of the server or the session
+ALTER TABLE task_runs ALTER COLUMN scheduled_at TYPE TIMESTAMPTZ USING scheduled_at AT TIME ZONE 'UTC';
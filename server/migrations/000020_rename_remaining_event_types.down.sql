@@ -0,0 +1,8 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Removed pull subscriptions are not restored
+UPDATE webhooks SET event_types = array_replace(event_types, 'channel.rolled_back', 'rollback');
+UPDATE webhooks SET event_types = array_replace(event_types, 'version.promoted', 'promote');
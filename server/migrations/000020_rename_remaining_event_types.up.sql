@@ -0,0 +1,10 @@
+-- Copyright (c) 2025 kk
+--
+-- This software is released under the MIT License.
+-- https://opensource.org/licenses/MIT
+
+-- Move the subscriptions 000010 left on legacy names to the event catalog names
+UPDATE webhooks SET event_types = array_replace(event_types, 'rollback', 'channel.rolled_back');
+UPDATE webhooks SET event_types = array_replace(event_types, 'promote', 'version.promoted');
+-- Pulls are not published as events, so the subscription never fired
+UPDATE webhooks SET event_types = array_remove(event_types, 'pull');
@@ -0,0 +1,101 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package webhook signs kkArtifact webhook requests and lets receivers verify them.
+//
+// A webhook with a secret is sent with these headers:
+//
+//	X-KKArtifact-Event:     event type, e.g. version.pushed
+//	X-KKArtifact-Delivery:  delivery ID, the same for every retry of a delivery
+//	X-KKArtifact-Timestamp: Unix time in seconds when the request was sent
+//	X-KKArtifact-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// A receiver checks the signature with VerifyRequest (or Verify) and rejects
+// requests whose timestamp is too old, so a captured request cannot be replayed.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request headers
+const (
+	HeaderEvent     = "X-KKArtifact-Event"
+	HeaderDelivery  = "X-KKArtifact-Delivery"
+	HeaderTimestamp = "X-KKArtifact-Timestamp"
+	HeaderSignature = "X-KKArtifact-Signature"
+)
+
+// DefaultTolerance is how old a signed request may be before it is rejected
+const DefaultTolerance = 5 * time.Minute
+
+const signaturePrefix = "sha256="
+
+// Verification errors
+var (
+	ErrMissingSignature = errors.New("webhook signature is missing")
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+	ErrExpiredTimestamp = errors.New("webhook timestamp is outside the tolerance")
+)
+
+// Sign returns the signature header value for a body sent at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature and timestamp header value against the body
+// A tolerance of 0 uses DefaultTolerance.
+func Verify(secret, signature, timestamp string, body []byte, tolerance time.Duration) error {
+	if signature == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp: %w", err)
+	}
+	age := time.Since(time.Unix(sentAt, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrExpiredTimestamp
+	}
+
+	expected := Sign(secret, sentAt, body)
+	if !strings.HasPrefix(signature, signaturePrefix) || !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyRequest checks the signature of an incoming webhook request and returns its body
+// The request body is read and replaced, so handlers can read it again.
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := Verify(secret, r.Header.Get(HeaderSignature), r.Header.Get(HeaderTimestamp), body, tolerance); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2025 kk
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package webhook
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"type":"push"}`)
+	now := time.Now().Unix()
+	timestamp := strconv.FormatInt(now, 10)
+	signature := Sign("secret", now, body)
+
+	if err := Verify("secret", signature, timestamp, body, 0); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := Verify("other", signature, timestamp, body, 0); err != ErrInvalidSignature {
+		t.Errorf("Verify() with wrong secret = %v, want %v", err, ErrInvalidSignature)
+	}
+	if err := Verify("secret", signature, timestamp, []byte(`{"type":"delete"}`), 0); err != ErrInvalidSignature {
+		t.Errorf("Verify() with changed body = %v, want %v", err, ErrInvalidSignature)
+	}
+	if err := Verify("secret", "", timestamp, body, 0); err != ErrMissingSignature {
+		t.Errorf("Verify() without signature = %v, want %v", err, ErrMissingSignature)
+	}
+
+	old := now - int64(time.Hour/time.Second)
+	if err := Verify("secret", Sign("secret", old, body), strconv.FormatInt(old, 10), body, 0); err != ErrExpiredTimestamp {
+		t.Errorf("Verify() with old timestamp = %v, want %v", err, ErrExpiredTimestamp)
+	}
+	// The signature covers the timestamp, so it cannot be moved to a fresh one
+	if err := Verify("secret", Sign("secret", old, body), timestamp, body, 0); err != ErrInvalidSignature {
+		t.Errorf("Verify() with replaced timestamp = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	body := []byte(`{"type":"push"}`)
+	now := time.Now().Unix()
+
+	req := httptest.NewRequest("POST", "/hook", bytes.NewReader(body))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now, 10))
+	req.Header.Set(HeaderSignature, Sign("secret", now, body))
+
+	got, err := VerifyRequest(req, "secret", time.Minute)
+	if err != nil {
+		t.Fatalf("VerifyRequest() error = %v", err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Errorf("VerifyRequest() body = %q, want %q", got, body)
+	}
+	again, _ := io.ReadAll(req.Body)
+	if !bytes.Equal(again, body) {
+		t.Errorf("request body after verify = %q, want %q", again, body)
+	}
+}
@@ -16,9 +16,14 @@ export interface Webhook {
   app_id?: number
   project_name?: string
   app_name?: string
+  has_secret?: boolean
+  format?: WebhookFormat
+  template?: string
   created_at: string
 }
 
+export type WebhookFormat = 'raw' | 'slack' | 'dingtalk' | 'feishu' | 'wecom' | 'msteams' | 'template'
+
 export interface CreateWebhookRequest {
   name: string
   event_types: string[]
@@ -27,6 +32,31 @@ export interface CreateWebhookRequest {
   enabled?: boolean
   project_id?: number
   app_id?: number
+  secret?: string
+  format?: WebhookFormat
+  template?: string
+}
+
+export interface TestWebhookRequest {
+  event_type?: string
+  project?: string
+  app?: string
+  version?: string
+}
+
+export interface TestWebhookResponse {
+  dry_run: boolean
+  request: {
+    url: string
+    headers: Record<string, string>
+    body: string
+  }
+  success: boolean
+  status_code?: number
+  response_headers?: Record<string, string>
+  response_body?: string
+  latency_ms: number
+  error?: string
 }
 
 export const webhooksApi = {
@@ -36,5 +66,7 @@ export const webhooksApi = {
   update: (id: number, data: Partial<CreateWebhookRequest>) =>
     client.put<Webhook>(`/webhooks/${id}`, data),
   delete: (id: number) => client.delete(`/webhooks/${id}`),
+  test: (id: number, data: TestWebhookRequest = {}, dryRun = false) =>
+    client.post<TestWebhookResponse>(`/webhooks/${id}/test`, data, { params: { dry_run: dryRun } }),
 }
 
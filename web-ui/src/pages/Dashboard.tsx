@@ -99,9 +99,38 @@ const Dashboard: React.FC = () => {
           unpublish: '取消发布',
           token_create: '创建令牌',
           token_delete: '删除令牌',
+          'version.pushed': '推送',
+          'version.published': '发布',
+          'version.unpublished': '取消发布',
+          'version.deleted': '删除版本',
+          'version.pinned': '固定版本',
+          'version.unpinned': '取消固定',
+          'version.labeled': '修改标签',
+          'channel.updated': '更新通道',
+          'channel.deleted': '删除通道',
+          'channel.rolled_back': '回滚通道',
+          'version.promoted': '推广版本',
+          'promotion.requested': '申请推广',
+          'promotion.approved': '批准推广',
+          'promotion.rejected': '拒绝推广',
+          'app.deleted': '删除应用',
+          'project.deleted': '删除项目',
+          'retention.pruned': '版本清理',
+          'token.created': '创建令牌',
+          'token.revoked': '删除令牌',
+          'config.updated': '修改配置',
+          'webhook.failed': 'Webhook 失败',
+        }
+        // Events share the badge colours of the operations they replaced
+        const badgeClasses: Record<string, string> = {
+          'version.pushed': 'push',
+          'version.published': 'publish',
+          'version.unpublished': 'unpublish',
+          'token.created': 'token_create',
+          'token.revoked': 'token_delete',
         }
         return (
-          <span className={`${styles.operationBadge} ${styles[text] || ''}`}>
+          <span className={`${styles.operationBadge} ${styles[badgeClasses[text] || text] || ''}`}>
             {labels[text] || text}
           </span>
         )
@@ -203,6 +203,7 @@ const TokensPage: React.FC = () => {
               pull: '拉取',
               push: '推送',
               publish: '发布',
+              promote: '推广',
             }
             return <Tag key={perm}>{labels[perm] || perm}</Tag>
           })}
@@ -371,6 +372,7 @@ const TokensPage: React.FC = () => {
                 <Option value="pull">拉取</Option>
                 <Option value="push">推送</Option>
                 <Option value="publish">发布</Option>
+                <Option value="promote">推广</Option>
                 <Option value="admin">管理员</Option>
               </Select>
             </Form.Item>
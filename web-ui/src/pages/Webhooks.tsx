@@ -320,7 +320,7 @@ const WebhooksPage: React.FC = () => {
             </Select>
           </Form.Item>
           <Form.Item name="event_types" label="事件类型（逗号分隔）" rules={[{ required: true }]}>
-            <Input placeholder="push,pull,publish" />
+            <Input placeholder="version.pushed,version.published（* 表示全部事件）" />
           </Form.Item>
           <Form.Item name="enabled" label="启用" valuePropName="checked" initialValue={true}>
             <Switch />